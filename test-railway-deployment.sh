@@ -35,6 +35,17 @@ else
     exit 1
 fi
 
+# Test 3b: Readiness
+echo "🚦 Testing readiness endpoint..."
+if curl -f http://localhost:8080/ready > /dev/null 2>&1; then
+    echo "✅ Readiness check passed"
+else
+    echo "❌ Readiness check failed"
+    docker logs $CONTAINER_ID
+    docker stop $CONTAINER_ID
+    exit 1
+fi
+
 # Test 4: Main page
 echo "🏠 Testing main page..."
 if curl -f http://localhost:8080/ > /dev/null 2>&1; then
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/models"
+	"web-ui/internal/services"
+)
+
+// Challenge is a classic or package challenge resolved from a command-line reference
+type Challenge struct {
+	ID               int    // Classic challenges
+	PackageName      string // Package challenges, with PackageChallenge
+	PackageChallenge string
+	Title            string
+	Readme           string
+	Template         string
+	TestFile         string
+	Hints            string
+}
+
+// IsPackage reports whether this is a package challenge
+func (c *Challenge) IsPackage() bool {
+	return c.PackageName != ""
+}
+
+// Name returns the reference accepted on the command line, e.g. "5" or "gin/challenge-1-basic-routing"
+func (c *Challenge) Name() string {
+	if c.IsPackage() {
+		return c.PackageName + "/" + c.PackageChallenge
+	}
+	return strconv.Itoa(c.ID)
+}
+
+// Dir returns the challenge directory relative to the repository root
+func (c *Challenge) Dir() string {
+	if c.IsPackage() {
+		return filepath.Join("packages", c.PackageName, c.PackageChallenge)
+	}
+	return fmt.Sprintf("challenge-%d", c.ID)
+}
+
+// SubmissionFile returns the user's solution file relative to the repository root
+func (c *Challenge) SubmissionFile(username string) string {
+	if c.IsPackage() {
+		return filepath.Join(c.Dir(), "submissions", username, "solution.go")
+	}
+	return filepath.Join(c.Dir(), "submissions", username, "solution-template.go")
+}
+
+// Branch returns the per-challenge branch used by `submit -via git`
+func (c *Challenge) Branch(username string) string {
+	if c.IsPackage() {
+		return services.SubmissionBranch(username, c.PackageName, c.PackageChallenge)
+	}
+	return services.SubmissionBranch(username, fmt.Sprintf("challenge-%d", c.ID))
+}
+
+// CommitScope returns the conventional commit scope for the challenge
+func (c *Challenge) CommitScope() string {
+	if c.IsPackage() {
+		return c.PackageName + "/" + c.PackageChallenge
+	}
+	return fmt.Sprintf("challenge-%d", c.ID)
+}
+
+// executionChallenge adapts the challenge for the ExecutionService
+func (c *Challenge) executionChallenge() *models.Challenge {
+	return &models.Challenge{
+		ID:       c.ID, // Package challenges don't use numeric IDs
+		Title:    c.Title,
+		TestFile: c.TestFile,
+	}
+}
+
+// resolveChallenge accepts "5", "challenge-5", "gin/challenge-1-basic-routing" or "gin/1"
+func (app *App) resolveChallenge(ref string) (*Challenge, error) {
+	if pkg, name, ok := strings.Cut(ref, "/"); ok {
+		return app.resolvePackageChallenge(pkg, name)
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(ref, "challenge-"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge %q: use a number like 5 or a package challenge like gin/1", ref)
+	}
+	challenge, ok := app.challengeService.GetChallenge(id)
+	if !ok {
+		return nil, fmt.Errorf("challenge %d not found", id)
+	}
+	return &Challenge{
+		ID:       challenge.ID,
+		Title:    challenge.Title,
+		Readme:   challenge.Description,
+		Template: challenge.Template,
+		TestFile: challenge.TestFile,
+		Hints:    challenge.Hints,
+	}, nil
+}
+
+func (app *App) resolvePackageChallenge(pkg, name string) (*Challenge, error) {
+	// Allow the numeric shorthand "gin/1" for "gin/challenge-1-basic-routing"
+	if _, err := strconv.Atoi(name); err == nil {
+		entries, err := os.ReadDir(filepath.Join(app.root, "packages", pkg))
+		if err != nil {
+			return nil, fmt.Errorf("package %s not found", pkg)
+		}
+		prefix := "challenge-" + name + "-"
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+				name = entry.Name()
+				break
+			}
+		}
+	}
+
+	challenge, err := app.packageService.GetPackageChallenge(pkg, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		PackageName:      pkg,
+		PackageChallenge: challenge.ID,
+		Title:            challenge.Title,
+		Readme:           challenge.Description,
+		Template:         challenge.Template,
+		TestFile:         challenge.TestFile,
+		Hints:            challenge.Hints,
+	}, nil
+}
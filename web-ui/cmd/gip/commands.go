@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"web-ui/internal/services"
+)
+
+// newFlagSet creates a flag set for a subcommand with a usage line
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gip "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gip %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runList implements `gip list [-packages] [package]`
+func (app *App) runList(args []string) error {
+	fs := newFlagSet("list")
+	packages := fs.Bool("packages", false, "list packages instead of classic challenges")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	username, _ := app.username()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	switch {
+	case *packages:
+		fmt.Fprintln(w, "PACKAGE\tCHALLENGES\tDIFFICULTY\tDESCRIPTION")
+		for _, name := range app.packageNames() {
+			challenges, _ := app.packageService.GetPackageChallenges(name)
+			meta := app.packageMetadata(name)
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", name, len(challenges), meta.Difficulty, meta.Description)
+		}
+
+	case fs.NArg() == 1:
+		pkg := fs.Arg(0)
+		challenges, err := app.packageService.GetPackageChallenges(pkg)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(challenges))
+		for id := range challenges {
+			ids = append(ids, id)
+		}
+		sortChallengeDirs(ids)
+
+		fmt.Fprintln(w, "CHALLENGE\tDIFFICULTY\tSTATUS\tTITLE")
+		for _, id := range ids {
+			challenge := challenges[id]
+			status := ""
+			if username != "" && fileExists(app.path(filepath.Join("packages", pkg, id, "submissions", username, "solution.go"))) {
+				status = "started"
+			}
+			fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\n", pkg, id, challenge.Difficulty, status, challenge.Title)
+		}
+
+	default:
+		challenges := app.challengeService.GetChallenges()
+		attempts := map[int]bool{}
+		scores := map[int]int{}
+		if username != "" {
+			userAttempts := app.userService.LoadUserAttempts(username, challenges)
+			attempts, scores = userAttempts.AttemptedIDs, userAttempts.Scores
+		}
+
+		ids := make([]int, 0, len(challenges))
+		for id := range challenges {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		fmt.Fprintln(w, "ID\tDIFFICULTY\tSTATUS\tTITLE")
+		for _, id := range ids {
+			status := ""
+			if attempts[id] {
+				status = fmt.Sprintf("%d%%", scores[id])
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", id, challenges[id].Difficulty, status, challenges[id].Title)
+		}
+	}
+	return nil
+}
+
+// runShow implements `gip show <challenge>`
+func (app *App) runShow(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: gip %s", commands["show"].usage)
+	}
+	challenge, err := app.resolveChallenge(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(strings.TrimSpace(challenge.Readme))
+	fmt.Println()
+	fmt.Printf("📁 %s\n", app.path(challenge.Dir()))
+	if fileExists(app.path(filepath.Join(challenge.Dir(), "learning.md"))) {
+		fmt.Printf("📚 Learning materials: %s\n", app.path(filepath.Join(challenge.Dir(), "learning.md")))
+	}
+	if hints := splitHints(challenge.Hints); len(hints) > 0 {
+		fmt.Printf("💡 %d hints available: gip hint %s\n", len(hints), challenge.Name())
+	}
+	fmt.Printf("🚀 Get started: gip start %s\n", challenge.Name())
+	return nil
+}
+
+// runStart implements `gip start [-force] <challenge>`
+func (app *App) runStart(args []string) error {
+	fs := newFlagSet("start")
+	force := fs.Bool("force", false, "overwrite an existing submission with the template")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a challenge is required")
+	}
+
+	username, err := app.username()
+	if err != nil {
+		return err
+	}
+	challenge, err := app.resolveChallenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	file := app.path(challenge.SubmissionFile(username))
+	if fileExists(file) && !*force {
+		fmt.Printf("Submission already exists at %s (use -force to reset it to the template)\n", file)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, []byte(challenge.Template), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Created %s\n", file)
+	if fileExists(app.path(filepath.Join(challenge.Dir(), "learning.md"))) {
+		fmt.Printf("📚 Learning materials: %s\n", app.path(filepath.Join(challenge.Dir(), "learning.md")))
+	}
+	fmt.Printf("Edit your solution, then run: gip test %s\n", challenge.Name())
+	return nil
+}
+
+// runHint implements `gip hint <challenge> [n]`
+func (app *App) runHint(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: gip %s", commands["hint"].usage)
+	}
+	challenge, err := app.resolveChallenge(args[0])
+	if err != nil {
+		return err
+	}
+
+	hints := splitHints(challenge.Hints)
+	if len(hints) == 0 {
+		return fmt.Errorf("no hints available for %s", challenge.Name())
+	}
+
+	n := 1
+	if len(args) == 2 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(hints) {
+			return fmt.Errorf("hint number must be between 1 and %d", len(hints))
+		}
+	}
+
+	fmt.Println(hints[n-1])
+	fmt.Println()
+	if n < len(hints) {
+		fmt.Printf("💡 Hint %d of %d. Next: gip hint %s %d\n", n, len(hints), challenge.Name(), n+1)
+	} else {
+		fmt.Printf("💡 Hint %d of %d. That was the last one!\n", n, len(hints))
+	}
+	return nil
+}
+
+// runProgress implements `gip progress [-packages]`
+func (app *App) runProgress(args []string) error {
+	fs := newFlagSet("progress")
+	packages := fs.Bool("packages", false, "show package challenge progress")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	username, err := app.username()
+	if err != nil {
+		return err
+	}
+
+	if *packages {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		defer w.Flush()
+		fmt.Fprintf(w, "Package progress for %s\n\n", username)
+		fmt.Fprintln(w, "PACKAGE\tSTARTED\tTOTAL")
+		for _, name := range app.packageNames() {
+			challenges, _ := app.packageService.GetPackageChallenges(name)
+			started := 0
+			for id := range challenges {
+				if fileExists(app.path(filepath.Join("packages", name, id, "submissions", username, "solution.go"))) {
+					started++
+				}
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\n", name, started, len(challenges))
+		}
+		return nil
+	}
+
+	challenges := app.challengeService.GetChallenges()
+	attempts := app.userService.LoadUserAttempts(username, challenges)
+
+	completed := 0
+	var inProgress []int
+	for id := range attempts.AttemptedIDs {
+		if attempts.Scores[id] == 100 {
+			completed++
+		} else {
+			inProgress = append(inProgress, id)
+		}
+	}
+	sort.Ints(inProgress)
+
+	fmt.Printf("Progress for %s\n\n", username)
+	fmt.Printf("  ✅ Completed:   %d / %d\n", completed, len(challenges))
+	fmt.Printf("  🚧 In progress: %d\n", len(inProgress))
+	for _, id := range inProgress {
+		fmt.Printf("     - %d %s (%d%%)\n", id, challenges[id].Title, attempts.Scores[id])
+	}
+	if len(challenges) > 0 {
+		fmt.Printf("\n  %s %d%%\n", progressBar(completed, len(challenges), 30), completed*100/len(challenges))
+	}
+	return nil
+}
+
+// splitHints splits hints.md into its "## " sections
+func splitHints(content string) []string {
+	var hints []string
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			if len(current) > 0 {
+				hints = append(hints, strings.TrimSpace(strings.Join(current, "\n")))
+			}
+			current = []string{line}
+			continue
+		}
+		if current != nil {
+			current = append(current, line)
+		}
+	}
+	if len(current) > 0 {
+		hints = append(hints, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return hints
+}
+
+// packageNames returns the package directories in alphabetical order
+func (app *App) packageNames() []string {
+	entries, err := os.ReadDir(app.path("packages"))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// packageMetadata reads package.json without the GitHub star lookup done by PackageService
+func (app *App) packageMetadata(name string) services.PackageMetadata {
+	var meta services.PackageMetadata
+	if data, err := os.ReadFile(app.path(filepath.Join("packages", name, "package.json"))); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// sortChallengeDirs orders "challenge-N-..." directories by N
+func sortChallengeDirs(ids []string) {
+	number := func(id string) int {
+		parts := strings.SplitN(id, "-", 3)
+		if len(parts) < 2 {
+			return 0
+		}
+		n, _ := strconv.Atoi(parts[1])
+		return n
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return number(ids[i]) < number(ids[j])
+	})
+}
+
+func progressBar(done, total, width int) string {
+	filled := done * width / total
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
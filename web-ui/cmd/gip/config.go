@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Config is the gip configuration file
+type Config struct {
+	Username string `json:"username"`
+	Server   string `json:"server,omitempty"` // Web UI base URL used by `submit -via api`
+	Token    string `json:"token,omitempty"`  // Personal API token with the submit scope
+}
+
+// configKeys maps the keys accepted by `gip config` to their fields
+var configKeys = map[string]func(*Config) *string{
+	"username": func(c *Config) *string { return &c.Username },
+	"server":   func(c *Config) *string { return &c.Server },
+	"token":    func(c *Config) *string { return &c.Token },
+}
+
+// configPath returns GIP_CONFIG or the per-user config location, e.g. ~/.config/gip/config.json
+func configPath() (string, error) {
+	if path := os.Getenv("GIP_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gip", "config.json"), nil
+}
+
+// loadConfig reads the config file; a missing file yields an empty config
+func loadConfig() (*Config, error) {
+	config := &Config{}
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// save writes the config file, readable only by the user since it may hold a token
+func (c *Config) save() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// runConfig implements `gip config [key [value]]`
+func (app *App) runConfig(args []string) error {
+	switch len(args) {
+	case 0:
+		path, _ := configPath()
+		fmt.Printf("Config file: %s\n", path)
+		keys := make([]string, 0, len(configKeys))
+		for key := range configKeys {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := *configKeys[key](app.config)
+			if key == "token" && value != "" {
+				value = "(set)"
+			}
+			fmt.Printf("  %-9s %s\n", key, value)
+		}
+		return nil
+	case 1, 2:
+		field, ok := configKeys[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown config key %q (use username, server or token)", args[0])
+		}
+		if len(args) == 1 {
+			fmt.Println(*field(app.config))
+			return nil
+		}
+		*field(app.config) = args[1]
+		path, err := app.config.save()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Saved %s to %s\n", args[0], path)
+		return nil
+	default:
+		return fmt.Errorf("usage: gip config [key [value]]")
+	}
+}
@@ -0,0 +1,185 @@
+// Command gip is the command-line client for Go Interview Practice.
+//
+// It replaces create_submission.sh and the per-challenge run_tests.sh scripts:
+//
+//	gip config username alice     remember your GitHub username
+//	gip list [package]            list classic challenges, or a package's challenges
+//	gip show <challenge>          print the challenge README
+//	gip start <challenge>         scaffold your submission from the template
+//	gip test <challenge>          run the tests against your submission
+//	gip submit <challenge>        submit via the web UI API or commit on a branch
+//	gip hint <challenge> [n]      show the n-th hint
+//	gip progress                  show completed challenges
+//
+// Challenges are referenced as "5" for classic challenges and "gin/1" or
+// "gin/challenge-1-basic-routing" for package challenges.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"web-ui/internal/config"
+	"web-ui/internal/services"
+	"web-ui/internal/utils"
+)
+
+// App holds the services shared by all subcommands
+type App struct {
+	root             string // Repository root
+	config           *Config
+	challengeService *services.ChallengeService
+	packageService   *services.PackageService
+	userService      *services.UserService
+	executionService *services.ExecutionService
+	gitService       *services.GitService
+}
+
+type command struct {
+	usage string
+	run   func(app *App, args []string) error
+}
+
+// commands is populated in init because the subcommands refer back to it for their usage
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"config":   {"config [key [value]]", (*App).runConfig},
+		"list":     {"list [-packages] [package]", (*App).runList},
+		"show":     {"show <challenge>", (*App).runShow},
+		"start":    {"start [-force] <challenge>", (*App).runStart},
+		"test":     {"test [-json] [-v] [-file path] <challenge>", (*App).runTest},
+		"submit":   {"submit [-via api|git] [-dry-run] <challenge>", (*App).runSubmit},
+		"hint":     {"hint <challenge> [n]", (*App).runHint},
+		"progress": {"progress [-packages]", (*App).runProgress},
+	}
+}
+
+var commandOrder = []string{"config", "list", "show", "start", "test", "submit", "hint", "progress"}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage(os.Stdout)
+		return
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gip: unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	app, err := newApp()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gip: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(app, os.Args[2:]); err != nil {
+		if err != errTestsFailed {
+			fmt.Fprintf(os.Stderr, "gip: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gip <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  gip %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Challenges are "5" for classic challenges and "gin/1" or "gin/challenge-1-basic-routing" for package challenges.`)
+}
+
+// newApp locates the repository and loads challenges
+func newApp() (*App, error) {
+	root, err := findRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep service loading logs out of the command output
+	log.SetOutput(io.Discard)
+	challengeService := services.NewChallengeService(root)
+	err = challengeService.LoadChallenges()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &App{
+		root:             root,
+		config:           config,
+		challengeService: challengeService,
+		packageService:   services.NewPackageService(root),
+		userService:      services.NewUserService(root),
+		executionService: services.NewExecutionService(root, services.ExecutionLimits{}),
+		gitService:       services.NewGitService(false),
+	}, nil
+}
+
+// findRepoRoot returns GIP_REPO or the nearest parent directory containing the challenges
+func findRepoRoot() (string, error) {
+	if root := os.Getenv("GIP_REPO"); root != "" {
+		return filepath.Abs(root)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if root, err := config.FindRepoRoot(dir); err == nil {
+		return root, nil
+	}
+
+	// Fall back to the enclosing git repository, e.g. when run from outside a challenge
+	output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err == nil && config.IsRepoRoot(strings.TrimSpace(string(output))) {
+		return strings.TrimSpace(string(output)), nil
+	}
+	return "", fmt.Errorf("not inside a go-interview-practice checkout (set GIP_REPO to its path)")
+}
+
+// username returns the configured username, falling back to GIP_USERNAME and the git remote.
+// It names the submission directory, so anything that is not a GitHub username is refused.
+func (app *App) username() (string, error) {
+	username := app.config.Username
+	if username == "" {
+		username = os.Getenv("GIP_USERNAME")
+	}
+	if username == "" {
+		// Only trust the fork's remote; user.name is often a display name
+		if info := utils.GetGitUsername(app.root); info.Source == "remote-origin" {
+			username = info.Username
+		}
+	}
+	if username == "" {
+		return "", fmt.Errorf("no username configured; run: gip config username <your-github-username>")
+	}
+	if !services.ValidUsername(username) {
+		return "", fmt.Errorf("invalid username %q; run: gip config username <your-github-username>", username)
+	}
+	return username, nil
+}
+
+// path converts a repository-relative path to an absolute one
+func (app *App) path(rel string) string {
+	return filepath.Join(app.root, rel)
+}
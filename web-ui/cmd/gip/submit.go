@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"web-ui/internal/services"
+)
+
+// runSubmit implements `gip submit [-via api|git] [-dry-run] <challenge>`
+func (app *App) runSubmit(args []string) error {
+	fs := newFlagSet("submit")
+	via := fs.String("via", "", `"api" to submit to the web UI (needs server and token), "git" to commit on a branch (default: api when a server is configured)`)
+	dryRun := fs.Bool("dry-run", false, "with -via git, print the git commands without running them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a challenge is required")
+	}
+
+	username, err := app.username()
+	if err != nil {
+		return err
+	}
+	challenge, err := app.resolveChallenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *via == "" {
+		*via = "git"
+		if app.config.Server != "" {
+			*via = "api"
+		}
+	}
+
+	switch *via {
+	case "api":
+		return app.submitViaAPI(challenge, username)
+	case "git":
+		return app.submitViaGit(challenge, username, *dryRun)
+	default:
+		return fmt.Errorf(`-via must be "api" or "git"`)
+	}
+}
+
+// submitViaAPI posts the submission to the web UI, which runs the tests and records the result
+func (app *App) submitViaAPI(challenge *Challenge, username string) error {
+	if app.config.Server == "" || app.config.Token == "" {
+		return fmt.Errorf("submitting via the API needs a server and a token with the submit scope:\n  gip config server http://localhost:8080\n  gip config token gip_...")
+	}
+
+	code, err := os.ReadFile(app.path(challenge.SubmissionFile(username)))
+	if err != nil {
+		return fmt.Errorf("could not read your submission: %v (run: gip start %s)", err, challenge.Name())
+	}
+
+	var endpoint string
+	var body interface{}
+	if challenge.IsPackage() {
+		endpoint = fmt.Sprintf("/api/packages/%s/%s/submit", url.PathEscape(challenge.PackageName), url.PathEscape(challenge.PackageChallenge))
+		body = map[string]string{"code": string(code)}
+	} else {
+		endpoint = "/api/submissions"
+		body = map[string]interface{}{"challengeId": challenge.ID, "code": string(code)}
+	}
+
+	fmt.Printf("Submitting %s to %s...\n", challenge.Name(), app.config.Server)
+
+	var result struct {
+		Passed      bool   `json:"passed"`     // Classic challenges
+		Success     bool   `json:"success"`    // Package challenges
+		TestOutput  string `json:"testOutput"` // Classic challenges
+		Output      string `json:"output"`     // Package challenges
+		ExecutionMs int64  `json:"executionMs"`
+	}
+	if err := app.postJSON(endpoint, body, &result); err != nil {
+		return err
+	}
+
+	output := result.TestOutput + result.Output
+	passed, total := services.CountTestResults(output)
+	if result.Passed || result.Success {
+		fmt.Printf("✅ Submission accepted: %d/%d tests passed\n", passed, total)
+		fmt.Printf("To appear on the public scoreboard, open a pull request: gip submit -via git %s\n", challenge.Name())
+		return nil
+	}
+
+	fmt.Println(strings.TrimRight(output, "\n"))
+	fmt.Printf("\n❌ Submission recorded with failing tests: %d/%d tests passed\n", passed, total)
+	return errTestsFailed
+}
+
+// submitViaGit tests the submission locally and commits it on its own branch
+func (app *App) submitViaGit(challenge *Challenge, username string, dryRun bool) error {
+	file := app.path(challenge.SubmissionFile(username))
+
+	if !dryRun {
+		report, err := app.testChallenge(challenge, "")
+		if err != nil {
+			return err
+		}
+		if !report.Passed {
+			printTestReport(report, false)
+			fmt.Println("\nFix the failing tests before submitting.")
+			return errTestsFailed
+		}
+		fmt.Printf("✅ %d/%d tests passed\n", report.TestsPassed, report.TestsTotal)
+	}
+
+	// The user asked for the commit explicitly, so GIT_INTEGRATION does not apply
+	app.gitService.SetEnabled(true)
+	result := app.gitService.CommitSubmission(services.GitCommitRequest{
+		FilePath: file,
+		Branch:   challenge.Branch(username),
+		Message:  services.SubmissionCommitMessage(challenge.CommitScope(), username),
+		DryRun:   dryRun,
+	})
+
+	for _, step := range result.Steps {
+		fmt.Printf("$ %s\n", step.Command)
+		if step.Output != "" {
+			fmt.Println(step.Output)
+		}
+	}
+	if !result.Success {
+		return fmt.Errorf("%s", result.Message)
+	}
+
+	fmt.Printf("✅ %s\n", result.Message)
+	if !dryRun {
+		fmt.Println("\nPublish the branch and open a pull request:")
+		for _, command := range app.gitService.PushCommands(result) {
+			fmt.Printf("  %s\n", command)
+		}
+	}
+	return nil
+}
+
+// postJSON sends an authenticated JSON request to the configured web UI
+func (app *App) postJSON(endpoint string, body, result interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(app.config.Server, "/")+endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+app.config.Token)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"web-ui/internal/services"
+)
+
+// errTestsFailed signals a failing test run whose results were already printed
+var errTestsFailed = errors.New("tests failed")
+
+// TestReport is the structured result of `gip test`
+type TestReport struct {
+	Challenge   string              `json:"challenge"`
+	Username    string              `json:"username"`
+	File        string              `json:"file"`
+	Passed      bool                `json:"passed"`
+	TestsPassed int                 `json:"testsPassed"`
+	TestsTotal  int                 `json:"testsTotal"`
+	ExecutionMs int64               `json:"executionMs"`
+	Tests       []services.TestCase `json:"tests"`
+	Output      string              `json:"output"`
+}
+
+// runTest implements `gip test [-json] [-v] [-file path] <challenge>`
+func (app *App) runTest(args []string) error {
+	fs := newFlagSet("test")
+	jsonOutput := fs.Bool("json", false, "print the results as JSON")
+	verbose := fs.Bool("v", false, "also print the raw go test output")
+	file := fs.String("file", "", "test this file instead of your submission")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a challenge is required")
+	}
+
+	challenge, err := app.resolveChallenge(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	report, err := app.testChallenge(challenge, *file)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printTestReport(report, *verbose)
+	}
+
+	if !report.Passed {
+		return errTestsFailed
+	}
+	return nil
+}
+
+// testChallenge runs the tests for a challenge against the user's submission or the given file
+func (app *App) testChallenge(challenge *Challenge, file string) (*TestReport, error) {
+	username := ""
+	if file == "" {
+		var err error
+		username, err = app.username()
+		if err != nil {
+			return nil, err
+		}
+		file = app.path(challenge.SubmissionFile(username))
+	}
+
+	code, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s not found; run: gip start %s", file, challenge.Name())
+		}
+		return nil, err
+	}
+
+	result := app.executionService.RunCode(context.Background(), string(code), challenge.executionChallenge())
+	passed, total := services.CountTestResults(result.Output)
+
+	return &TestReport{
+		Challenge:   challenge.CommitScope(),
+		Username:    username,
+		File:        file,
+		Passed:      result.Passed,
+		TestsPassed: passed,
+		TestsTotal:  total,
+		ExecutionMs: result.ExecutionMs,
+		Tests:       services.ParseTestCases(result.Output),
+		Output:      result.Output,
+	}, nil
+}
+
+func printTestReport(report *TestReport, verbose bool) {
+	fmt.Printf("Running tests for %s: %s\n\n", report.Challenge, report.File)
+
+	for _, test := range report.Tests {
+		indent := strings.Repeat("  ", test.Depth+1)
+		icon := "✅"
+		switch test.Status {
+		case "FAIL":
+			icon = "❌"
+		case "SKIP":
+			icon = "⏭️"
+		}
+		fmt.Printf("%s%s %s (%s)\n", indent, icon, test.Name, test.Duration)
+		if test.Output != "" {
+			for _, line := range strings.Split(strings.TrimRight(test.Output, "\n"), "\n") {
+				fmt.Printf("%s    %s\n", indent, line)
+			}
+		}
+	}
+
+	// Compilation errors and panics produce no test lines, so show the raw output
+	if verbose || len(report.Tests) == 0 {
+		fmt.Println()
+		fmt.Println(strings.TrimRight(report.Output, "\n"))
+	}
+
+	fmt.Println()
+	if report.Passed {
+		fmt.Printf("✅ %d/%d tests passed in %dms\n", report.TestsPassed, report.TestsTotal, report.ExecutionMs)
+	} else {
+		fmt.Printf("❌ %d/%d tests passed in %dms\n", report.TestsPassed, report.TestsTotal, report.ExecutionMs)
+	}
+}
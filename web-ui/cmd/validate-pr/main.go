@@ -0,0 +1,72 @@
+// Command validate-pr checks a submission pull request in a local clone.
+//
+// It verifies that the diff between base and head only adds or updates the
+// author's own submission files, runs each submission against the tests from
+// the base revision, optionally updates the affected SCOREBOARD.md rows, and
+// prints a Markdown report suitable for a pull request comment.
+//
+//	go run ./cmd/validate-pr -base origin/main -head HEAD -author alice -update-scoreboards
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"web-ui/internal/config"
+	"web-ui/internal/services"
+)
+
+func main() {
+	repo := flag.String("repo", "..", "path inside the repository to validate")
+	base := flag.String("base", "origin/main", "base revision of the pull request")
+	head := flag.String("head", "HEAD", "head revision of the pull request")
+	author := flag.String("author", "", "GitHub username of the pull request author (required)")
+	updateScoreboards := flag.Bool("update-scoreboards", false, "write results into the affected SCOREBOARD.md files")
+	output := flag.String("output", "", "write the Markdown report to this file instead of stdout")
+	flag.Parse()
+
+	if *author == "" {
+		fmt.Fprintln(os.Stderr, "validate-pr: -author is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// The judge logs dependency installs on stdout; keep stdout for the report
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+
+	root, err := config.FindRepoRoot(*repo)
+	if err != nil {
+		log.Fatalf("validate-pr: %v", err)
+	}
+	validator, err := services.NewPRValidator(root, services.NewExecutionService(root, services.ExecutionLimits{}))
+	if err != nil {
+		log.Fatalf("validate-pr: %v", err)
+	}
+
+	report, err := validator.Validate(*base, *head, *author)
+	if err != nil {
+		log.Fatalf("validate-pr: %v", err)
+	}
+
+	if *updateScoreboards {
+		if err := validator.UpdateScoreboards(report); err != nil {
+			log.Fatalf("validate-pr: %v", err)
+		}
+	}
+
+	markdown := report.Markdown()
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(markdown), 0644); err != nil {
+			log.Fatalf("validate-pr: %v", err)
+		}
+	} else {
+		fmt.Fprint(stdout, markdown)
+	}
+
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,433 @@
+// Package config resolves the web UI's settings from defaults, a config file,
+// environment variables and command-line flags, in increasing order of precedence.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the resolved server configuration
+type Config struct {
+	Addr      string // Listen address, e.g. ":8080"
+	RepoRoot  string // Absolute path of the checkout holding challenge-*/ and packages/
+	DataDir   string // Absolute path for server state: tokens, interviews, AI usage, hints
+	Server    ServerConfig
+	Execution ExecutionConfig
+	AI        AIConfig
+	Features  FeatureConfig
+	Log       LogConfig
+	Security  SecurityConfig
+	Sponsors  SponsorConfig
+	Packages  PackagesConfig
+	File      string // The config file that was read, empty if none
+}
+
+// ServerConfig tunes the HTTP server
+type ServerConfig struct {
+	ReadTimeout     time.Duration // Reading a whole request
+	WriteTimeout    time.Duration // Writing a response to pages, static files and reads
+	APIWriteTimeout time.Duration // Writing a response to API calls that change state; must outlast test runs and AI streams
+	IdleTimeout     time.Duration // Keep-alive connections between requests
+	ShutdownTimeout time.Duration // Waiting for in-flight requests before test runs are canceled
+	MaxBodyBytes    int64         // Largest request body accepted
+	TLSCertFile     string        // Serve HTTPS when set, together with TLSKeyFile
+	TLSKeyFile      string
+}
+
+// ExecutionConfig limits how submitted code is run
+type ExecutionConfig struct {
+	TestTimeout       time.Duration // Upper bound for one `go test` run
+	MaxConcurrentRuns int           // `go test` runs at once; further runs wait
+}
+
+// AIConfig selects the AI provider. API keys are secrets and stay in the environment.
+type AIConfig struct {
+	Provider          string
+	Model             string
+	BaseURL           string
+	PromptsDir        string // Prompt template overrides
+	DailyUserTokens   int    // 0 means unlimited
+	DailyGlobalTokens int    // 0 means unlimited
+}
+
+// FeatureConfig turns optional features on and off
+type FeatureConfig struct {
+	AI             bool
+	GitIntegration bool   // Commit saved submissions on per-challenge branches
+	HintPolicy     string // allowed, disabled or penalized:<points>
+}
+
+// SecurityConfig limits abusive clients
+type SecurityConfig struct {
+	UserRateLimit int  // Requests that change state per minute per signed-in user; 0 disables
+	IPRateLimit   int  // Requests that change state per minute per client IP; 0 disables
+	TrustProxy    bool // Client IP and scheme from X-Forwarded-For and X-Forwarded-Proto
+	ProxyHops     int  // Trusted proxies in front of the server, each appending to X-Forwarded-For
+}
+
+// SponsorConfig says where the sponsor badges on leaderboards come from. The list is
+// read from the file if set, otherwise from the GitHub GraphQL API if a token is set,
+// otherwise scraped from the account's sponsors page.
+type SponsorConfig struct {
+	Account         string        // GitHub account whose sponsors are shown
+	File            string        // One GitHub login per line
+	RefreshInterval time.Duration // Time between background refreshes
+}
+
+// PackagesConfig tunes the GitHub metadata shown on package cards
+type PackagesConfig struct {
+	MetadataRefreshInterval time.Duration // Time between refreshes of stars, releases and commits
+}
+
+// LogConfig shapes the structured logs written to stderr
+type LogConfig struct {
+	Format string // text or json
+	Level  string // debug, info, warn or error
+}
+
+// setting is one configuration value and the places it can be set
+type setting struct {
+	key   string // In the config file, e.g. "execution.test_timeout"; empty if not settable there
+	env   string
+	flag  string // Empty if not settable by flag
+	usage string
+	bool  bool
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{key: "", env: "PORT", usage: "port to listen on, as set by hosting platforms",
+		set: func(c *Config, v string) error {
+			if _, err := strconv.Atoi(v); err != nil {
+				return fmt.Errorf("invalid port %q", v)
+			}
+			c.Addr = ":" + v
+			return nil
+		}},
+	{key: "addr", env: "LISTEN_ADDR", flag: "addr", usage: "listen address",
+		set: func(c *Config, v string) error { c.Addr = v; return nil }},
+	{key: "repo_root", env: "REPO_ROOT", flag: "root", usage: "repository root (found from the working directory or the binary if unset)",
+		set: func(c *Config, v string) error { c.RepoRoot = v; return nil }},
+	{key: "data_dir", env: "DATA_DIR", flag: "data-dir", usage: "directory for server state (default <root>/web-ui/data)",
+		set: func(c *Config, v string) error { c.DataDir = v; return nil }},
+	{key: "server.read_timeout", env: "SERVER_READ_TIMEOUT", usage: "time limit for reading a request",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.ReadTimeout, v) }},
+	{key: "server.write_timeout", env: "SERVER_WRITE_TIMEOUT", usage: "time limit for writing a response",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.WriteTimeout, v) }},
+	{key: "server.api_write_timeout", env: "SERVER_API_WRITE_TIMEOUT", usage: "time limit for answering API calls that change state, such as test runs and AI streams",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.APIWriteTimeout, v) }},
+	{key: "server.idle_timeout", env: "SERVER_IDLE_TIMEOUT", usage: "how long idle keep-alive connections stay open",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.IdleTimeout, v) }},
+	{key: "server.shutdown_timeout", env: "SERVER_SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "how long to wait for in-flight requests on shutdown",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.ShutdownTimeout, v) }},
+	{key: "server.max_body_bytes", env: "SERVER_MAX_BODY_BYTES", flag: "max-body-bytes", usage: "largest request body accepted, in bytes",
+		set: func(c *Config, v string) error { return setPositiveInt64(&c.Server.MaxBodyBytes, v) }},
+	{key: "server.tls_cert_file", env: "TLS_CERT_FILE", flag: "tls-cert", usage: "TLS certificate file; serves HTTPS together with -tls-key",
+		set: func(c *Config, v string) error { c.Server.TLSCertFile = v; return nil }},
+	{key: "server.tls_key_file", env: "TLS_KEY_FILE", flag: "tls-key", usage: "TLS private key file",
+		set: func(c *Config, v string) error { c.Server.TLSKeyFile = v; return nil }},
+	{key: "execution.test_timeout", env: "EXEC_TEST_TIMEOUT", flag: "test-timeout", usage: "time limit for one test run",
+		set: func(c *Config, v string) error { return setDuration(&c.Execution.TestTimeout, v) }},
+	{key: "execution.max_concurrent_runs", env: "EXEC_MAX_CONCURRENT_RUNS", flag: "max-concurrent-runs", usage: "test runs at once",
+		set: func(c *Config, v string) error { return setPositiveInt(&c.Execution.MaxConcurrentRuns, v) }},
+	{key: "ai.provider", env: "AI_PROVIDER", flag: "ai-provider", usage: "AI provider: gemini, openai, claude, openai-compatible, mock or none",
+		set: func(c *Config, v string) error { c.AI.Provider = strings.ToLower(v); return nil }},
+	{key: "ai.model", env: "AI_MODEL", flag: "ai-model", usage: "AI model (provider default if unset)",
+		set: func(c *Config, v string) error { c.AI.Model = v; return nil }},
+	{key: "ai.base_url", env: "AI_BASE_URL", usage: "AI provider API base URL",
+		set: func(c *Config, v string) error { c.AI.BaseURL = v; return nil }},
+	{key: "ai.prompts_dir", env: "AI_PROMPTS_DIR", usage: "directory with prompt template overrides",
+		set: func(c *Config, v string) error { c.AI.PromptsDir = v; return nil }},
+	{key: "ai.daily_user_tokens", env: "AI_DAILY_USER_TOKENS", usage: "daily AI tokens per user, 0 for unlimited",
+		set: func(c *Config, v string) error { return setNonNegativeInt(&c.AI.DailyUserTokens, v) }},
+	{key: "ai.daily_global_tokens", env: "AI_DAILY_GLOBAL_TOKENS", usage: "daily AI tokens for the site, 0 for unlimited",
+		set: func(c *Config, v string) error { return setNonNegativeInt(&c.AI.DailyGlobalTokens, v) }},
+	{key: "features.ai", env: "AI_ENABLED", flag: "ai", usage: "enable AI features", bool: true,
+		set: func(c *Config, v string) error { return setBool(&c.Features.AI, v) }},
+	{key: "features.git_integration", env: "GIT_INTEGRATION", flag: "git-integration", usage: "commit saved submissions on per-challenge branches", bool: true,
+		set: func(c *Config, v string) error { return setBool(&c.Features.GitIntegration, v) }},
+	{key: "features.hint_policy", env: "HINT_POLICY", flag: "hint-policy", usage: "hint policy: allowed, disabled or penalized:<points>",
+		set: func(c *Config, v string) error { c.Features.HintPolicy = v; return nil }},
+	{key: "security.user_rate_limit", env: "RATE_LIMIT_PER_USER", flag: "rate-limit-user", usage: "requests that change state per minute per user, 0 for unlimited",
+		set: func(c *Config, v string) error { return setNonNegativeInt(&c.Security.UserRateLimit, v) }},
+	{key: "security.ip_rate_limit", env: "RATE_LIMIT_PER_IP", flag: "rate-limit-ip", usage: "requests that change state per minute per client IP, 0 for unlimited",
+		set: func(c *Config, v string) error { return setNonNegativeInt(&c.Security.IPRateLimit, v) }},
+	{key: "security.trust_proxy", env: "TRUST_PROXY", flag: "trust-proxy", usage: "take the client IP and scheme from X-Forwarded-For and X-Forwarded-Proto", bool: true,
+		set: func(c *Config, v string) error { return setBool(&c.Security.TrustProxy, v) }},
+	{key: "security.proxy_hops", env: "TRUST_PROXY_HOPS", flag: "trust-proxy-hops", usage: "number of trusted proxies in front of the server, e.g. 2 for a CDN in front of a load balancer",
+		set: func(c *Config, v string) error { return setPositiveInt(&c.Security.ProxyHops, v) }},
+	{key: "sponsors.account", env: "SPONSORS_ACCOUNT", flag: "sponsors-account", usage: "GitHub account whose sponsors get a badge",
+		set: func(c *Config, v string) error { c.Sponsors.Account = v; return nil }},
+	{key: "sponsors.file", env: "SPONSORS_FILE", flag: "sponsors-file", usage: "file listing sponsors, one GitHub login per line",
+		set: func(c *Config, v string) error { c.Sponsors.File = v; return nil }},
+	{key: "sponsors.refresh_interval", env: "SPONSORS_REFRESH_INTERVAL", usage: "time between sponsor list refreshes",
+		set: func(c *Config, v string) error { return setDuration(&c.Sponsors.RefreshInterval, v) }},
+	{key: "packages.metadata_refresh_interval", env: "PACKAGES_METADATA_REFRESH_INTERVAL", usage: "time between refreshes of package stars, releases and commits from GitHub",
+		set: func(c *Config, v string) error { return setDuration(&c.Packages.MetadataRefreshInterval, v) }},
+	{key: "log.format", env: "LOG_FORMAT", flag: "log-format", usage: "log format: text or json",
+		set: func(c *Config, v string) error { return setChoice(&c.Log.Format, v, "text", "json") }},
+	{key: "log.level", env: "LOG_LEVEL", flag: "log-level", usage: "log level: debug, info, warn or error",
+		set: func(c *Config, v string) error { return setChoice(&c.Log.Level, v, "debug", "info", "warn", "error") }},
+}
+
+// defaults returns the configuration used when nothing is set
+func defaults() *Config {
+	return &Config{
+		Addr: ":8080",
+		Server: ServerConfig{
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			APIWriteTimeout: 5 * time.Minute,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+			MaxBodyBytes:    1 << 20,
+		},
+		Execution: ExecutionConfig{
+			TestTimeout:       2 * time.Minute,
+			MaxConcurrentRuns: runtime.NumCPU(),
+		},
+		AI: AIConfig{
+			Provider:          "gemini",
+			DailyUserTokens:   100000,
+			DailyGlobalTokens: 2000000,
+		},
+		Features: FeatureConfig{
+			AI:         true,
+			HintPolicy: "allowed",
+		},
+		Security: SecurityConfig{
+			UserRateLimit: 30,
+			IPRateLimit:   60,
+			ProxyHops:     1,
+		},
+		Sponsors: SponsorConfig{
+			Account:         "RezaSi",
+			RefreshInterval: time.Hour,
+		},
+		Packages: PackagesConfig{
+			MetadataRefreshInterval: 6 * time.Hour,
+		},
+		Log: LogConfig{
+			Format: "text",
+			Level:  "info",
+		},
+	}
+}
+
+// flagValue records a flag's value so it can be applied after the file and environment
+type flagValue struct {
+	value   string
+	set     bool
+	boolean bool
+}
+
+func (f *flagValue) String() string   { return f.value }
+func (f *flagValue) IsBoolFlag() bool { return f.boolean }
+
+func (f *flagValue) Set(value string) error {
+	f.value, f.set = value, true
+	return nil
+}
+
+// Load resolves the configuration from the command-line arguments (without the program
+// name), the environment and the config file. The config file is -config or CONFIG_FILE,
+// otherwise config.yaml, config.yml or config.toml in the working directory or in the
+// web-ui directory of the repository. A .env file is loaded into the environment first,
+// without overriding variables that are already set.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("web-ui", flag.ContinueOnError)
+	configFile := fs.String("config", "", "config file (YAML or TOML)")
+	flags := make(map[string]*flagValue)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		value := &flagValue{boolean: s.bool}
+		flags[s.flag] = value
+		fs.Var(value, s.flag, s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	// Where to look for .env and the config file before the configuration is known
+	rootHint := os.Getenv("REPO_ROOT")
+	if flags["root"].set {
+		rootHint = flags["root"].value
+	}
+	if rootHint == "" {
+		rootHint, _ = discoverRepoRoot()
+	}
+	if err := loadDotEnv(".env", filepath.Join(rootHint, "web-ui", ".env"), filepath.Join(rootHint, ".env")); err != nil {
+		return nil, err
+	}
+
+	config := defaults()
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path = findConfigFile(".", filepath.Join(rootHint, "web-ui"))
+	}
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyFile(config, path, values); err != nil {
+			return nil, err
+		}
+		config.File = path
+	}
+
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.set(config, value); err != nil {
+				return nil, fmt.Errorf("%s: %v", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value := flags[s.flag]; s.flag != "" && value.set {
+			if err := s.set(config, value.value); err != nil {
+				return nil, fmt.Errorf("-%s: %v", s.flag, err)
+			}
+		}
+	}
+
+	if err := config.resolve(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyFile sets the values read from a config file, rejecting unknown keys
+func applyFile(config *Config, path string, values map[string]string) error {
+	for key, value := range values {
+		found := false
+		for _, s := range settings {
+			if s.key == "" || s.key != key {
+				continue
+			}
+			found = true
+			if err := s.set(config, value); err != nil {
+				return fmt.Errorf("%s: %s: %v", path, key, err)
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+	return nil
+}
+
+// resolve finds the repository root and makes the paths absolute
+func (c *Config) resolve() error {
+	if c.RepoRoot == "" {
+		root, err := discoverRepoRoot()
+		if err != nil {
+			return err
+		}
+		c.RepoRoot = root
+	}
+	root, err := filepath.Abs(c.RepoRoot)
+	if err != nil {
+		return err
+	}
+	if !IsRepoRoot(root) {
+		return fmt.Errorf("%s is not a go-interview-practice checkout (no packages/ and challenge-* directories)", root)
+	}
+	c.RepoRoot = root
+
+	if c.DataDir == "" {
+		c.DataDir = filepath.Join(root, "web-ui", "data")
+	}
+	if c.DataDir, err = filepath.Abs(c.DataDir); err != nil {
+		return err
+	}
+	if c.Sponsors.File != "" {
+		if c.Sponsors.File, err = filepath.Abs(c.Sponsors.File); err != nil {
+			return err
+		}
+	}
+	if c.AI.PromptsDir != "" {
+		if c.AI.PromptsDir, err = filepath.Abs(c.AI.PromptsDir); err != nil {
+			return err
+		}
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	if !c.Features.AI {
+		c.AI.Provider = "none"
+	}
+	return nil
+}
+
+func setDuration(target *time.Duration, value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid duration %q (e.g. 90s or 2m)", value)
+	}
+	*target = d
+	return nil
+}
+
+func setPositiveInt(target *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("expected a positive number, got %q", value)
+	}
+	*target = n
+	return nil
+}
+
+func setPositiveInt64(target *int64, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 1 {
+		return fmt.Errorf("expected a positive number, got %q", value)
+	}
+	*target = n
+	return nil
+}
+
+func setNonNegativeInt(target *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a number of 0 or more, got %q", value)
+	}
+	*target = n
+	return nil
+}
+
+func setChoice(target *string, value string, choices ...string) error {
+	value = strings.ToLower(value)
+	for _, choice := range choices {
+		if value == choice {
+			*target = value
+			return nil
+		}
+	}
+	return fmt.Errorf("expected one of %s, got %q", strings.Join(choices, ", "), value)
+}
+
+func setBool(target *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("expected true or false, got %q", value)
+	}
+	*target = b
+	return nil
+}
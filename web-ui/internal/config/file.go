@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFileNames are the config files looked for when none is given
+var configFileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// findConfigFile returns the first config file found in dirs, or ""
+func findConfigFile(dirs ...string) string {
+	for _, dir := range dirs {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads a YAML or TOML config file into dotted keys, e.g. "ai.provider".
+// Only the subset of each format that the settings need is supported, and anything
+// outside it is an error rather than a guess; see parseYAML and parseTOML.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = parseYAML(string(data))
+	case ".toml":
+		values, err = parseTOML(string(data))
+	default:
+		return nil, fmt.Errorf("%s: config files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+// parseYAML reads "key: value" lines at the top level and under "section:" lines, whose
+// keys are all indented by the same spaces. Values are plain, 'single-quoted' or
+// "double-quoted" scalars. Lists, flow collections, block scalars, anchors, aliases,
+// tags, deeper nesting and multiple documents are errors.
+func parseYAML(text string) (map[string]string, error) {
+	values := make(map[string]string)
+	section, indent := "", ""
+	for i, raw := range strings.Split(text, "\n") {
+		line := strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if line == "---" || line == "..." {
+			if len(values) > 0 || section != "" || line == "..." {
+				return nil, fmt.Errorf("line %d: multiple documents are not supported", i+1)
+			}
+			continue
+		}
+
+		prefix := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		switch {
+		case strings.Contains(prefix, "\t"):
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", i+1)
+		case prefix == "":
+			section, indent = "", ""
+		case section == "":
+			return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+		case indent == "":
+			indent = prefix
+		case prefix != indent:
+			return nil, fmt.Errorf("line %d: inconsistent indentation; only one level of sections is supported", i+1)
+		}
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			return nil, fmt.Errorf("line %d: lists are not supported", i+1)
+		}
+
+		colon := strings.Index(trimmed, ": ")
+		if colon < 0 && strings.HasSuffix(trimmed, ":") {
+			colon = len(trimmed) - 1
+		}
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", i+1)
+		}
+		key := strings.TrimSpace(trimmed[:colon])
+		if !validKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", i+1, key)
+		}
+		value, quoted, err := readValue(trimmed[colon+1:], true)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		if !quoted {
+			if value == "" {
+				if prefix != "" {
+					return nil, fmt.Errorf("line %d: only one level of sections is supported", i+1)
+				}
+				section = key
+				continue
+			}
+			if strings.ContainsAny(value[:1], "[]{}&*!|>%@`") || value == "-" || strings.HasPrefix(value, "- ") {
+				return nil, fmt.Errorf("line %d: %q is not a plain value; quote it", i+1, value)
+			}
+			if strings.Contains(value, ": ") {
+				return nil, fmt.Errorf("line %d: quote values that contain \": \"", i+1)
+			}
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", i+1, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseTOML reads "key = value" lines, with bare or dotted keys, under optional
+// "[section]" headers. Values are "basic" or 'literal' strings, integers or booleans.
+// Arrays, inline tables, multi-line strings, floats, dates, nested tables and arrays of
+// tables are errors.
+func parseTOML(text string) (map[string]string, error) {
+	values := make(map[string]string)
+	sections := make(map[string]bool)
+	section := ""
+	for i, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", i+1)
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", i+1)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, fmt.Errorf("line %d: unexpected %q after section header", i+1, rest)
+			}
+			section = strings.TrimSpace(line[1:end])
+			if !validKey(section) {
+				return nil, fmt.Errorf("line %d: invalid section %q; only one level of bare section names is supported", i+1, section)
+			}
+			if sections[section] {
+				return nil, fmt.Errorf("line %d: section [%s] is defined twice", i+1, section)
+			}
+			sections[section] = true
+			continue
+		}
+
+		rawKey, rawValue, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key = value\"", i+1)
+		}
+		parts := strings.Split(rawKey, ".")
+		for j, part := range parts {
+			if parts[j] = strings.TrimSpace(part); !validKey(parts[j]) {
+				return nil, fmt.Errorf("line %d: invalid key %q; only bare keys are supported", i+1, strings.TrimSpace(rawKey))
+			}
+		}
+		key := strings.Join(parts, ".")
+		if section != "" {
+			key = section + "." + key
+		}
+
+		rawValue = strings.TrimSpace(rawValue)
+		switch {
+		case strings.HasPrefix(rawValue, `"""`) || strings.HasPrefix(rawValue, "'''"):
+			return nil, fmt.Errorf("line %d: multi-line strings are not supported", i+1)
+		case strings.HasPrefix(rawValue, "[") || strings.HasPrefix(rawValue, "{"):
+			return nil, fmt.Errorf("line %d: arrays and inline tables are not supported", i+1)
+		}
+		value, quoted, err := readValue(rawValue, false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if !quoted {
+			if value, err = tomlBareValue(value); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", i+1, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// tomlBareValue returns an unquoted TOML value, which must be a boolean or an integer,
+// as the setting parsers expect it
+func tomlBareValue(value string) (string, error) {
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	digits := strings.TrimLeft(value, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return "", fmt.Errorf("integer %s has a leading zero", value)
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 0, 64)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a string, integer or boolean; quote strings", value)
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// readValue reads a quoted or plain value followed by an optional # comment. Double
+// quotes take Go escapes; in single quotes backslashes are literal and, with
+// doubledQuote as in YAML, a doubled single quote is a quote.
+func readValue(text string, doubledQuote bool) (value string, quoted bool, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" || (text[0] != '"' && text[0] != '\'') {
+		if i := commentStart(text); i >= 0 {
+			text = text[:i]
+		}
+		return strings.TrimSpace(text), false, nil
+	}
+
+	var rest string
+	if text[0] == '"' {
+		end := 1
+		for end < len(text) && text[end] != '"' {
+			if text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(text) {
+			return "", false, fmt.Errorf("unterminated string %s", text)
+		}
+		if value, err = strconv.Unquote(text[:end+1]); err != nil {
+			return "", false, fmt.Errorf("invalid quoted string %s", text[:end+1])
+		}
+		rest = text[end+1:]
+	} else {
+		var b strings.Builder
+		i := 1
+		for {
+			end := strings.IndexByte(text[i:], '\'')
+			if end < 0 {
+				return "", false, fmt.Errorf("unterminated string %s", text)
+			}
+			b.WriteString(text[i : i+end])
+			i += end + 1
+			if !doubledQuote || i >= len(text) || text[i] != '\'' {
+				break
+			}
+			b.WriteByte('\'')
+			i++
+		}
+		value, rest = b.String(), text[i:]
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return "", false, fmt.Errorf("unexpected %q after quoted string", rest)
+	}
+	return value, true, nil
+}
+
+// commentStart returns the index of a # that starts a comment, at the start of text or
+// after whitespace, or -1
+func commentStart(text string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// validKey reports whether key is a bare key: letters, digits, _ and -
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
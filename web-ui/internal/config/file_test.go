@@ -0,0 +1,107 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[string]string
+		err  string // Part of the error, empty when the text is valid
+	}{
+		{"sections", "---\naddr: \":8080\"\nserver:\n  read_timeout: 30s # comment\n  write_timeout: 5m\nai:\n    provider: mock\n",
+			map[string]string{"addr": ":8080", "server.read_timeout": "30s", "server.write_timeout": "5m", "ai.provider": "mock"}, ""},
+		{"quoted", "a: 'it''s # not a comment'\nb: \"tab\\there\"\nc: http://example.com/#anchor\n",
+			map[string]string{"a": "it's # not a comment", "b": "tab\there", "c": "http://example.com/#anchor"}, ""},
+		{"empty section", "ai:\n# nothing yet\naddr: x\n", map[string]string{"addr": "x"}, ""},
+		{"list", "features:\n  - ai\n", nil, "lists are not supported"},
+		{"flow sequence", "addr: [a, b]\n", nil, "not a plain value"},
+		{"flow mapping", "server: {read_timeout: 30s}\n", nil, "not a plain value"},
+		{"block scalar", "addr: |\n  x\n", nil, "not a plain value"},
+		{"anchor", "addr: &a x\n", nil, "not a plain value"},
+		{"alias", "addr: *a\n", nil, "not a plain value"},
+		{"tag", "addr: !!str x\n", nil, "not a plain value"},
+		{"nested section", "server:\n  tls:\n    cert: x\n", nil, "one level of sections"},
+		{"inconsistent indentation", "server:\n  a: 1\n    b: 2\n", nil, "inconsistent indentation"},
+		{"tab indentation", "server:\n\ta: 1\n", nil, "not tabs"},
+		{"indented without section", "  addr: x\n", nil, "unexpected indentation"},
+		{"missing space", "addr:x\n", nil, "expected \"key: value\""},
+		{"colon in plain value", "addr: a: b\n", nil, "quote values"},
+		{"quoted key", "\"addr\": x\n", nil, "invalid key"},
+		{"text after quote", "addr: 'x' y\n", nil, "after quoted string"},
+		{"unterminated quote", "addr: \"x\n", nil, "unterminated string"},
+		{"duplicate", "addr: a\naddr: b\n", nil, "duplicate key addr"},
+		{"second document", "addr: a\n---\naddr: b\n", nil, "multiple documents"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML(tt.text)
+			checkParse(t, got, err, tt.want, tt.err)
+		})
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[string]string
+		err  string
+	}{
+		{"sections", "addr = \":8080\" # comment\n[server]\nread_timeout = \"30s\"\nmax_body_bytes = 1_048_576\n[features]\nai = false\n",
+			map[string]string{"addr": ":8080", "server.read_timeout": "30s", "server.max_body_bytes": "1048576", "features.ai": "false"}, ""},
+		{"dotted keys", "ai.provider = 'C:\\mock'\n", map[string]string{"ai.provider": `C:\mock`}, ""},
+		{"hex integer", "[execution]\nmax_concurrent_runs = 0x10\n", map[string]string{"execution.max_concurrent_runs": "16"}, ""},
+		{"bare string", "[ai]\nprovider = mock\n", nil, "quote strings"},
+		{"float", "[ai]\ndaily_user_tokens = 1.5\n", nil, "quote strings"},
+		{"date", "addr = 1979-05-27\n", nil, "quote strings"},
+		{"leading zero", "[execution]\nmax_concurrent_runs = 010\n", nil, "leading zero"},
+		{"array", "addr = [\"a\"]\n", nil, "arrays and inline tables"},
+		{"inline table", "server = { read_timeout = \"30s\" }\n", nil, "arrays and inline tables"},
+		{"multi-line string", "addr = \"\"\"\nx\"\"\"\n", nil, "multi-line strings"},
+		{"nested table", "[server.tls]\ncert = \"x\"\n", nil, "invalid section"},
+		{"array of tables", "[[server]]\n", nil, "arrays of tables"},
+		{"table twice", "[ai]\nmodel = \"a\"\n[ai]\nprovider = \"b\"\n", nil, "defined twice"},
+		{"quoted key", "\"addr\" = \"x\"\n", nil, "invalid key"},
+		{"duplicate", "[ai]\nprovider = \"a\"\nprovider = \"b\"\n", nil, "duplicate key ai.provider"},
+		{"duplicate through dotted key", "ai.provider = \"a\"\n[ai]\nprovider = \"b\"\n", nil, "duplicate key ai.provider"},
+		{"text after header", "[ai] x\n", nil, "after section header"},
+		{"missing equals", "addr\n", nil, "expected \"key = value\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(tt.text)
+			checkParse(t, got, err, tt.want, tt.err)
+		})
+	}
+}
+
+func checkParse(t *testing.T, got map[string]string, err error, want map[string]string, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("got %v, error %v; want an error containing %q", got, err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExampleConfigParses(t *testing.T) {
+	values, err := readConfigFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyFile(defaults(), "config.example.yaml", values); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsRepoRoot reports whether dir is a go-interview-practice checkout: it has the
+// packages directory and at least one classic challenge
+func IsRepoRoot(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, "packages")); err != nil || !info.IsDir() {
+		return false
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "challenge-*"))
+	return len(matches) > 0
+}
+
+// FindRepoRoot returns the nearest directory at or above start that is a checkout
+func FindRepoRoot(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if IsRepoRoot(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go-interview-practice checkout at or above %s", start)
+		}
+		dir = parent
+	}
+}
+
+// discoverRepoRoot looks for the checkout above the working directory, then above the binary
+func discoverRepoRoot() (string, error) {
+	if wd, err := os.Getwd(); err == nil {
+		if root, err := FindRepoRoot(wd); err == nil {
+			return root, nil
+		}
+	}
+	if executable, err := os.Executable(); err == nil {
+		if root, err := FindRepoRoot(filepath.Dir(executable)); err == nil {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("could not find the repository root; set REPO_ROOT or -root")
+}
+
+// loadDotEnv sets variables from the first of paths that exists, without overriding
+// variables that already have a value. Lines are KEY=value, optionally after "export";
+// values are plain up to a # comment, 'single-quoted' literals or "double-quoted" with
+// Go escapes. Variable expansion and multi-line values are not supported: any line the
+// parser does not understand is an error and nothing from the file is set.
+func loadDotEnv(paths ...string) error {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		values, err := parseDotEnv(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		for _, v := range values {
+			if os.Getenv(v[0]) == "" {
+				os.Setenv(v[0], v[1])
+			}
+		}
+		slog.Info("Loaded environment variables", "path", path)
+		return nil
+	}
+	return nil
+}
+
+// parseDotEnv returns the key and value pairs of a .env file in order
+func parseDotEnv(text string) ([][2]string, error) {
+	var values [][2]string
+	for i, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		key, rawValue, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", i+1)
+		}
+		key = strings.TrimSpace(key)
+		if !validEnvName(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", i+1, key)
+		}
+		rawValue = strings.TrimSpace(rawValue)
+		value, _, err := readValue(rawValue, false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if !strings.HasPrefix(rawValue, "'") && strings.Contains(value, "$") {
+			return nil, fmt.Errorf("line %d: variable expansion is not supported; put values containing $ in single quotes", i+1)
+		}
+		values = append(values, [2]string{key, value})
+	}
+	return values, nil
+}
+
+// validEnvName reports whether name is a portable environment variable name
+func validEnvName(name string) bool {
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
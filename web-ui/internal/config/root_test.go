@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want [][2]string
+		err  string // Part of the error, empty when the text is valid
+	}{
+		{"values", "# comment\nexport AI_PROVIDER=mock\nPORT = 8080 # trailing comment\nEMPTY=\nURL=http://x/#y\n",
+			[][2]string{{"AI_PROVIDER", "mock"}, {"PORT", "8080"}, {"EMPTY", ""}, {"URL", "http://x/#y"}}, ""},
+		{"quoted", "A='$literal # kept'\nB=\"line\\nbreak\" # comment\n",
+			[][2]string{{"A", "$literal # kept"}, {"B", "line\nbreak"}}, ""},
+		{"no equals", "AI_PROVIDER\n", nil, "expected KEY=value"},
+		{"invalid name", "AI-PROVIDER=mock\n", nil, "invalid variable name"},
+		{"name starting with a digit", "1PORT=8080\n", nil, "invalid variable name"},
+		{"expansion", "DATA_DIR=$HOME/data\n", nil, "variable expansion"},
+		{"expansion in double quotes", "DATA_DIR=\"${HOME}/data\"\n", nil, "variable expansion"},
+		{"multi-line value", "KEY=\"first\nsecond\"\n", nil, "unterminated string"},
+		{"text after quote", "KEY='a' b\n", nil, "after quoted string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotEnv(tt.text)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, error %v; want an error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	t.Setenv("GIP_TEST_SET", "from environment")
+	t.Setenv("GIP_TEST_NEW", "")
+
+	// A file with an error sets nothing
+	if err := os.WriteFile(path, []byte("GIP_TEST_NEW=x\nbroken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDotEnv(filepath.Join(dir, "missing"), path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("got error %v, want one for line 2", err)
+	}
+	if got := os.Getenv("GIP_TEST_NEW"); got != "" {
+		t.Errorf("GIP_TEST_NEW = %q after a failed load", got)
+	}
+
+	if err := os.WriteFile(path, []byte("GIP_TEST_NEW=x\nGIP_TEST_SET=y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadDotEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("GIP_TEST_NEW"); got != "x" {
+		t.Errorf("GIP_TEST_NEW = %q, want x", got)
+	}
+	if got := os.Getenv("GIP_TEST_SET"); got != "from environment" {
+		t.Errorf("GIP_TEST_SET = %q, the environment should win", got)
+	}
+}
+
+func TestExampleEnvParses(t *testing.T) {
+	data, err := os.ReadFile("../../../env.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseDotEnv(string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
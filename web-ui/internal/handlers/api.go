@@ -1,13 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,134 +17,8 @@ import (
 
 	"web-ui/internal/models"
 	"web-ui/internal/services"
-	"web-ui/internal/utils"
 )
 
-// GitHubSponsorsResponse represents the GitHub GraphQL response for sponsors
-type GitHubSponsorsResponse struct {
-	Data struct {
-		Viewer struct {
-			SponsorshipsAsMaintainer struct {
-				Nodes []struct {
-					SponsorEntity struct {
-						Login string `json:"login"`
-					} `json:"sponsorEntity"`
-				} `json:"nodes"`
-			} `json:"sponsorshipsAsMaintainer"`
-		} `json:"viewer"`
-	} `json:"data"`
-	Errors []struct {
-		Message string `json:"message"`
-	} `json:"errors"`
-}
-
-// SponsorCache caches sponsor data to avoid hitting API limits
-type SponsorCache struct {
-	sponsors    map[string]bool
-	lastUpdated time.Time
-	mutex       sync.RWMutex
-}
-
-var sponsorCache = &SponsorCache{
-	sponsors: make(map[string]bool),
-}
-
-// LoadSponsors loads sponsor list by scraping the public GitHub sponsors page
-func (h *APIHandler) LoadSponsors() map[string]bool {
-	sponsorCache.mutex.RLock()
-	// Return cached data if it's less than 1 hour old
-	if time.Since(sponsorCache.lastUpdated) < time.Hour && len(sponsorCache.sponsors) > 0 {
-		defer sponsorCache.mutex.RUnlock()
-		return sponsorCache.sponsors
-	}
-	sponsorCache.mutex.RUnlock()
-
-	// Scrape sponsors from the public GitHub sponsors page
-	sponsors := h.scrapeSponsorsFromGitHub()
-
-	// Update cache
-	sponsorCache.mutex.Lock()
-	sponsorCache.sponsors = sponsors
-	sponsorCache.lastUpdated = time.Now()
-	sponsorCache.mutex.Unlock()
-	return sponsors
-}
-
-// scrapeSponsorsFromGitHub scrapes the public GitHub sponsors page
-func (h *APIHandler) scrapeSponsorsFromGitHub() map[string]bool {
-	sponsorMap := make(map[string]bool)
-
-	// Create HTTP client with timeout
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	// Fetch the public sponsors page
-	url := "https://github.com/sponsors/RezaSi"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		return sponsorMap
-	}
-
-	// Set user agent to avoid being blocked
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GoSponsorScraper/1.0)")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error fetching sponsors page: %v\n", err)
-		return sponsorMap
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		fmt.Printf("GitHub sponsors page returned status %d\n", resp.StatusCode)
-		return sponsorMap
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Error reading response body: %v\n", err)
-		return sponsorMap
-	}
-
-	html := string(body)
-
-	// Extract usernames from the HTML using regex - look for avatar images with alt="@username"
-	avatarRegex := regexp.MustCompile(`alt="@([a-zA-Z0-9][a-zA-Z0-9\-]*)"`)
-	matches := avatarRegex.FindAllStringSubmatch(html, -1)
-
-	for _, match := range matches {
-		if len(match) >= 2 {
-			username := match[1]
-			// Filter out the repository owner from sponsors list
-			if username != "RezaSi" {
-				sponsorMap[username] = true
-			}
-		}
-	}
-
-	// Fallback: if no sponsors found with avatar method, try href patterns
-	if len(sponsorMap) == 0 {
-		// Look for href="/username" patterns that aren't common GitHub paths
-		linkRegex := regexp.MustCompile(`href="/([a-zA-Z0-9][a-zA-Z0-9\-]+)"`)
-		linkMatches := linkRegex.FindAllStringSubmatch(html, -1)
-
-		for _, match := range linkMatches {
-			if len(match) >= 2 {
-				username := match[1]
-				// Filter out common GitHub paths that aren't usernames
-				if username != "sponsors" && username != "github" && username != "RezaSi" &&
-					!strings.HasPrefix(username, "orgs/") &&
-					!strings.Contains(username, "/") &&
-					len(username) > 2 { // reasonable username length
-					sponsorMap[username] = true
-				}
-			}
-		}
-	}
-
-	return sponsorMap
-}
-
 // APIHandler handles all API endpoints
 type APIHandler struct {
 	challengeService  *services.ChallengeService
@@ -152,7 +27,11 @@ type APIHandler struct {
 	executionService  *services.ExecutionService
 	packageService    *services.PackageService
 	aiService         *services.AIService
+	gitService        *services.GitService
+	hintService       *services.HintService
+	sponsorService    *services.SponsorService
 	submissions       []models.Submission
+	submissionsMutex  sync.Mutex // Guards submissions, which concurrent requests append to
 }
 
 // NewAPIHandler creates a new API handler
@@ -163,6 +42,9 @@ func NewAPIHandler(
 	executionService *services.ExecutionService,
 	packageService *services.PackageService,
 	aiService *services.AIService,
+	gitService *services.GitService,
+	hintService *services.HintService,
+	sponsorService *services.SponsorService,
 ) *APIHandler {
 	return &APIHandler{
 		challengeService:  challengeService,
@@ -171,6 +53,9 @@ func NewAPIHandler(
 		executionService:  executionService,
 		packageService:    packageService,
 		aiService:         aiService,
+		gitService:        gitService,
+		hintService:       hintService,
+		sponsorService:    sponsorService,
 		submissions:       make([]models.Submission, 0),
 	}
 }
@@ -233,6 +118,11 @@ func (h *APIHandler) HandleSubmissions(w http.ResponseWriter, r *http.Request) {
 
 // createSubmission creates a new submission
 func (h *APIHandler) createSubmission(w http.ResponseWriter, r *http.Request) {
+	username, ok := requireScope(w, r, services.ScopeSubmit)
+	if !ok {
+		return
+	}
+
 	var submission models.Submission
 	err := json.NewDecoder(r.Body).Decode(&submission)
 	if err != nil {
@@ -240,9 +130,6 @@ func (h *APIHandler) createSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set submission timestamp
-	submission.SubmittedAt = time.Now()
-
 	// Validate challenge exists
 	challenge, exists := h.challengeService.GetChallenge(submission.ChallengeID)
 	if !exists {
@@ -250,28 +137,59 @@ func (h *APIHandler) createSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	submission = h.submit(r.Context(), username, challenge, submission.Code)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submission)
+}
+
+// submit runs the code against the challenge's tests for the user, recording the
+// submission and adding it to the scoreboard if it passed
+func (h *APIHandler) submit(ctx context.Context, username string, challenge *models.Challenge, code string) models.Submission {
+	submission := models.Submission{
+		Username:    username,
+		ChallengeID: challenge.ID,
+		Code:        code,
+		SubmittedAt: time.Now(),
+	}
+
 	// Run the code
-	result := h.executionService.RunCode(submission.Code, challenge)
+	result := h.executionService.RunCode(ctx, submission.Code, challenge)
 	submission.Passed = result.Passed
 	submission.TestOutput = result.Output
 	submission.ExecutionMs = result.ExecutionMs
 
 	// Store submission
+	h.submissionsMutex.Lock()
 	h.submissions = append(h.submissions, submission)
+	h.submissionsMutex.Unlock()
 
 	// Add to scoreboard if passed
 	if submission.Passed {
 		h.scoreboardService.AddSubmission(submission)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(submission)
+	return submission
 }
 
 // getSubmissions returns all submissions
 func (h *APIHandler) getSubmissions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.submissions)
+	json.NewEncoder(w).Encode(h.userSubmissions(""))
+}
+
+// userSubmissions returns a copy of the user's submissions, or of all of them if
+// username is ""
+func (h *APIHandler) userSubmissions(username string) []models.Submission {
+	h.submissionsMutex.Lock()
+	defer h.submissionsMutex.Unlock()
+
+	submissions := make([]models.Submission, 0)
+	for _, submission := range h.submissions {
+		if username == "" || submission.Username == username {
+			submissions = append(submissions, submission)
+		}
+	}
+	return submissions
 }
 
 // GetScoreboard returns the scoreboard for a challenge
@@ -305,6 +223,10 @@ func (h *APIHandler) RunCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
 	var request struct {
 		ChallengeID int    `json:"challengeId"`
 		Code        string `json:"code"`
@@ -322,7 +244,7 @@ func (h *APIHandler) RunCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := h.executionService.RunCode(request.Code, challenge)
+	result := h.executionService.RunCode(r.Context(), request.Code, challenge)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
@@ -335,6 +257,11 @@ func (h *APIHandler) SaveSubmissionToFilesystem(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	username, ok := requireScope(w, r, services.ScopeSubmit)
+	if !ok {
+		return
+	}
+
 	var request services.SaveSubmissionRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
@@ -342,13 +269,8 @@ func (h *APIHandler) SaveSubmissionToFilesystem(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if request.Username == "" {
-		http.Error(w, "Username is required", http.StatusBadRequest)
-		return
-	}
-
-	// Set username cookie
-	h.setUsernameCookie(w, request.Username)
+	// Always save under the authenticated identity
+	request.Username = username
 
 	// Validate challenge exists
 	_, exists := h.challengeService.GetChallenge(request.ChallengeID)
@@ -357,13 +279,30 @@ func (h *APIHandler) SaveSubmissionToFilesystem(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	response := h.saveSubmission(request)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// saveSubmission saves a classic challenge solution, optionally commits it, and
+// refreshes the user's attempts
+func (h *APIHandler) saveSubmission(request services.SaveSubmissionRequest) services.SaveSubmissionResponse {
 	response := h.executionService.SaveSubmissionToFilesystem(request)
 
+	// Optionally commit the saved file on its own branch
+	if response.Success && request.Commit {
+		h.commitSavedSubmission(&response, services.GitCommitRequest{
+			FilePath: response.FilePath,
+			Branch:   services.SubmissionBranch(request.Username, fmt.Sprintf("challenge-%d", request.ChallengeID)),
+			Message:  services.SubmissionCommitMessage(fmt.Sprintf("challenge-%d", request.ChallengeID), request.Username),
+			DryRun:   request.DryRun,
+		})
+	}
+
 	// Clear user attempts cache
 	h.userService.RefreshUserAttempts(request.Username, h.challengeService.GetChallenges())
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return response
 }
 
 // RefreshUserAttempts refreshes user's attempt cache
@@ -373,22 +312,12 @@ func (h *APIHandler) RefreshUserAttempts(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var request struct {
-		Username string `json:"username"`
-	}
-
-	err := json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
-		http.Error(w, "Invalid request data", http.StatusBadRequest)
+	username, ok := requireScope(w, r, services.ScopeRead)
+	if !ok {
 		return
 	}
 
-	if request.Username == "" {
-		http.Error(w, "Username is required", http.StatusBadRequest)
-		return
-	}
-
-	attempts := h.userService.RefreshUserAttempts(request.Username, h.challengeService.GetChallenges())
+	attempts := h.userService.RefreshUserAttempts(username, h.challengeService.GetChallenges())
 
 	response := struct {
 		Username     string       `json:"username"`
@@ -396,7 +325,7 @@ func (h *APIHandler) RefreshUserAttempts(w http.ResponseWriter, r *http.Request)
 		Scores       map[int]int  `json:"scores"`
 		Success      bool         `json:"success"`
 	}{
-		Username:     request.Username,
+		Username:     username,
 		AttemptedIDs: attempts.AttemptedIDs,
 		Scores:       attempts.Scores,
 		Success:      true,
@@ -406,45 +335,6 @@ func (h *APIHandler) RefreshUserAttempts(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetGitUsername returns the username extracted from git configuration
-func (h *APIHandler) GetGitUsername(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	gitInfo := utils.GetGitUsername()
-
-	response := struct {
-		Username string `json:"username"`
-		Email    string `json:"email"`
-		Source   string `json:"source"`
-		Success  bool   `json:"success"`
-	}{
-		Username: gitInfo.Username,
-		Email:    gitInfo.Email,
-		Source:   gitInfo.Source,
-		Success:  gitInfo.Username != "",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-// setUsernameCookie sets the username cookie
-func (h *APIHandler) setUsernameCookie(w http.ResponseWriter, username string) {
-	// Cookie expires in 30 days
-	expiration := time.Now().Add(30 * 24 * time.Hour)
-	cookie := http.Cookie{
-		Name:     "username",
-		Value:    username,
-		Expires:  expiration,
-		Path:     "/",
-		HttpOnly: false, // Allow JavaScript to access it
-	}
-	http.SetCookie(w, &cookie)
-}
-
 // GetMainScoreboardRank returns the user's rank in the main scoreboard
 func (h *APIHandler) GetMainScoreboardRank(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
@@ -484,15 +374,10 @@ func (h *APIHandler) calculateMainScoreboardRank(username string) int {
 	// Process all challenge scoreboards to count actual completions
 	for challengeID := range challenges {
 		// Read scoreboard file directly to check test results
-		scoreboardPath := fmt.Sprintf("../challenge-%d/SCOREBOARD.md", challengeID)
+		scoreboardPath := filepath.Join(h.challengeService.ChallengeDir(challengeID), "SCOREBOARD.md")
 		content, err := ioutil.ReadFile(scoreboardPath)
 		if err != nil {
-			// Try alternative path
-			scoreboardPath = fmt.Sprintf("challenge-%d/SCOREBOARD.md", challengeID)
-			content, err = ioutil.ReadFile(scoreboardPath)
-			if err != nil {
-				continue
-			}
+			continue
 		}
 
 		// Parse scoreboard to find users who passed ALL tests
@@ -591,18 +476,7 @@ func (h *APIHandler) GetPackageLeaderboard(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "Package not found", http.StatusNotFound)
 		return
 	}
-
-	// Build package challenge list in learning path order
-	challengesMap, err := h.packageService.GetPackageChallenges(packageName)
-	if err != nil {
-		challengesMap = make(map[string]*models.PackageChallenge)
-	}
-	var challenges []*models.PackageChallenge
-	for _, id := range pkg.LearningPath {
-		if ch, ok := challengesMap[id]; ok {
-			challenges = append(challenges, ch)
-		}
-	}
+	challenges := h.learningPathChallenges(pkg)
 
 	// Reuse existing creator to gather leaderboard
 	leaderboard := h.createPackageLeaderboard(packageName, challenges)
@@ -619,6 +493,21 @@ func (h *APIHandler) GetPackageLeaderboard(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
+// learningPathChallenges returns the package's challenges in learning path order
+func (h *APIHandler) learningPathChallenges(pkg *models.Package) []*models.PackageChallenge {
+	challengesMap, err := h.packageService.GetPackageChallenges(pkg.Name)
+	if err != nil {
+		challengesMap = make(map[string]*models.PackageChallenge)
+	}
+	var challenges []*models.PackageChallenge
+	for _, id := range pkg.LearningPath {
+		if ch, ok := challengesMap[id]; ok {
+			challenges = append(challenges, ch)
+		}
+	}
+	return challenges
+}
+
 // createPackageLeaderboard builds the package leaderboard based on filesystem submissions
 func (h *APIHandler) createPackageLeaderboard(packageName string, challenges []*models.PackageChallenge) []models.PackageScoreboardEntry {
 	var leaderboard []models.PackageScoreboardEntry
@@ -631,10 +520,10 @@ func (h *APIHandler) createPackageLeaderboard(packageName string, challenges []*
 	userStats := make(map[string]*userPackageStats)
 
 	// Load sponsors for package leaderboard
-	sponsors := h.LoadSponsors()
+	sponsors := h.sponsorService.Sponsors()
 
 	for _, challenge := range challenges {
-		submissionsDir := filepath.Join("..", "packages", packageName, challenge.ID, "submissions")
+		submissionsDir := filepath.Join(h.packageService.ChallengeDir(packageName, challenge.ID), "submissions")
 		if _, err := os.Stat(submissionsDir); os.IsNotExist(err) {
 			continue
 		}
@@ -719,20 +608,15 @@ func (h *APIHandler) calculateMainLeaderboard() []LeaderboardUser {
 	userCompletions := make(map[string]map[int]bool)
 
 	// Load sponsor information
-	sponsors := h.LoadSponsors()
+	sponsors := h.sponsorService.Sponsors()
 
 	// Process all challenge scoreboards to find completions
 	for challengeID := range challenges {
 		// Read scoreboard file directly to check test results
-		scoreboardPath := fmt.Sprintf("../challenge-%d/SCOREBOARD.md", challengeID)
+		scoreboardPath := filepath.Join(h.challengeService.ChallengeDir(challengeID), "SCOREBOARD.md")
 		content, err := ioutil.ReadFile(scoreboardPath)
 		if err != nil {
-			// Try alternative path
-			scoreboardPath = fmt.Sprintf("challenge-%d/SCOREBOARD.md", challengeID)
-			content, err = ioutil.ReadFile(scoreboardPath)
-			if err != nil {
-				continue
-			}
+			continue
 		}
 
 		// Parse scoreboard to find users who passed ALL tests
@@ -843,10 +727,18 @@ func (h *APIHandler) HandlePackageChallenge(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Submissions must come from an authenticated user
+	if action == "submit" {
+		if _, ok := requireScope(w, r, services.ScopeSubmit); !ok {
+			return
+		}
+	} else if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
 	// Parse request body
 	var request struct {
-		Code     string `json:"code"`
-		Username string `json:"username"`
+		Code string `json:"code"`
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
@@ -875,42 +767,59 @@ func (h *APIHandler) HandlePackageChallenge(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Convert PackageChallenge to Challenge format for ExecutionService
-	challengeForExecution := &models.Challenge{
-		ID:       0, // Package challenges don't use numeric IDs
-		Title:    challenge.Title,
-		TestFile: challenge.TestFile,
-	}
-
-	// Run the actual tests using ExecutionService
-	result := h.executionService.RunCode(request.Code, challengeForExecution)
+	result := h.runPackageChallenge(r.Context(), challenge, request.Code)
 
 	// Format response
 	response := map[string]interface{}{
 		"success":      result.Passed,
 		"execution_ms": result.ExecutionMs,
 		"output":       result.Output,
+		"tests_passed": result.TestsPassed,
+		"tests_total":  result.TestsTotal,
 	}
 
-	// Count passed tests from output for display
-	testsPassed, testsTotal := h.parseTestResults(result.Output)
-	response["tests_passed"] = testsPassed
-	response["tests_total"] = testsTotal
-
 	if action == "submit" && result.Passed {
 		response["message"] = "Solution submitted successfully!"
 		response["show_pr_instructions"] = true
-
-		// Set username cookie if provided
-		if request.Username != "" {
-			h.setUsernameCookie(w, request.Username)
-		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// packageRunResult is the outcome of running a package challenge's tests
+type packageRunResult struct {
+	Passed      bool   `json:"passed"`
+	Output      string `json:"output"`
+	ExecutionMs int64  `json:"executionMs"`
+	TestsPassed int    `json:"testsPassed"`
+	TestsTotal  int    `json:"testsTotal"`
+}
+
+// runPackageChallenge runs the code against a package challenge's tests
+func (h *APIHandler) runPackageChallenge(ctx context.Context, challenge *models.PackageChallenge, code string) packageRunResult {
+	// Convert PackageChallenge to Challenge format for ExecutionService
+	challengeForExecution := &models.Challenge{
+		ID:       0, // Package challenges don't use numeric IDs
+		Title:    challenge.Title,
+		TestFile: challenge.TestFile,
+		Dir:      challenge.Dir,
+	}
+
+	// Run the actual tests using ExecutionService
+	result := h.executionService.RunCode(ctx, code, challengeForExecution)
+
+	// Count passed tests from output for display
+	testsPassed, testsTotal := h.parseTestResults(result.Output)
+	return packageRunResult{
+		Passed:      result.Passed,
+		Output:      result.Output,
+		ExecutionMs: result.ExecutionMs,
+		TestsPassed: testsPassed,
+		TestsTotal:  testsTotal,
+	}
+}
+
 // parseTestResults parses Go test output to count passed and total tests
 func (h *APIHandler) parseTestResults(output string) (passed int, total int) {
 	lines := strings.Split(output, "\n")
@@ -948,26 +857,21 @@ func (h *APIHandler) SavePackageChallengeToFilesystem(w http.ResponseWriter, r *
 		return
 	}
 
-	var request struct {
-		Username    string `json:"username"`
-		PackageName string `json:"packageName"`
-		ChallengeID string `json:"challengeId"`
-		Code        string `json:"code"`
+	username, ok := requireScope(w, r, services.ScopeSubmit)
+	if !ok {
+		return
 	}
 
+	var request packageSaveRequest
+
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request data", http.StatusBadRequest)
 		return
 	}
 
-	if request.Username == "" {
-		http.Error(w, "Username is required", http.StatusBadRequest)
-		return
-	}
-
-	// Set username cookie
-	h.setUsernameCookie(w, request.Username)
+	// Always save under the authenticated identity
+	request.Username = username
 
 	// Validate challenge exists
 	_, err = h.packageService.GetPackageChallenge(request.PackageName, request.ChallengeID)
@@ -976,74 +880,214 @@ func (h *APIHandler) SavePackageChallengeToFilesystem(w http.ResponseWriter, r *
 		return
 	}
 
-	// Save to filesystem
-	response := h.savePackageChallengeToFilesystem(request)
+	response := h.savePackageSubmission(request)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// savePackageChallengeToFilesystem handles the actual file saving for package challenges
-func (h *APIHandler) savePackageChallengeToFilesystem(request struct {
+// packageSaveRequest is the body of a package challenge filesystem save
+type packageSaveRequest struct {
 	Username    string `json:"username"`
 	PackageName string `json:"packageName"`
 	ChallengeID string `json:"challengeId"`
 	Code        string `json:"code"`
-}) services.SaveSubmissionResponse {
-	// Get working directory for correct relative paths
-	workDir, _ := os.Getwd()
-
-	// Try different path approaches to handle potential path issues
-	var submissionDir string
-	var fileSaved bool
-
-	// Try multiple path options for package challenges
-	pathOptions := []string{
-		// Option 1: From web-ui directory (standard case)
-		filepath.Join("..", "packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-		// Option 2: From root workspace
-		filepath.Join("packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-		// Option 3: Absolute path from detected workspace root
-		filepath.Join(workDir, "..", "packages", request.PackageName, request.ChallengeID, "submissions", request.Username),
-	}
-
-	for _, dirPath := range pathOptions {
-		err := os.MkdirAll(dirPath, 0755)
-		if err != nil {
-			continue
-		}
+	Commit      bool   `json:"commit"`
+	DryRun      bool   `json:"dryRun"`
+}
 
-		solutionFile := filepath.Join(dirPath, "solution.go")
-		err = ioutil.WriteFile(solutionFile, []byte(request.Code), 0644)
-		if err != nil {
-			continue
-		}
+// commitSavedSubmission runs the git integration and attaches its result to the response
+func (h *APIHandler) commitSavedSubmission(response *services.SaveSubmissionResponse, request services.GitCommitRequest) {
+	result := h.gitService.CommitSubmission(request)
+	response.Git = &result
+	if result.Success && !result.DryRun {
+		// Only publishing the branch is left for the user
+		response.GitCommands = h.gitService.PushCommands(result)
+		response.Message = result.Message
+	}
+}
+
+// savePackageSubmission saves a package challenge solution and optionally commits it
+func (h *APIHandler) savePackageSubmission(request packageSaveRequest) services.SaveSubmissionResponse {
+	// Save to filesystem
+	response := h.savePackageChallengeToFilesystem(request)
 
-		submissionDir = dirPath
-		fileSaved = true
-		break
+	// Optionally commit the saved file on its own branch
+	if response.Success && request.Commit {
+		scope := request.PackageName + "/" + request.ChallengeID
+		h.commitSavedSubmission(&response, services.GitCommitRequest{
+			FilePath: response.FilePath,
+			Branch:   services.SubmissionBranch(request.Username, request.PackageName, request.ChallengeID),
+			Message:  services.SubmissionCommitMessage(scope, request.Username),
+			DryRun:   request.DryRun,
+		})
 	}
+	return response
+}
 
-	if !fileSaved {
+// savePackageChallengeToFilesystem handles the actual file saving for package challenges
+func (h *APIHandler) savePackageChallengeToFilesystem(request packageSaveRequest) services.SaveSubmissionResponse {
+	if !services.ValidUsername(request.Username) || !services.ValidPackageName(request.PackageName) || !services.ValidPackageChallengeID(request.ChallengeID) {
 		return services.SaveSubmissionResponse{
 			Success: false,
-			Message: "Failed to save solution to any available path",
+			Message: "Invalid username, package or challenge",
+		}
+	}
+	submissionDir := filepath.Join(h.packageService.ChallengeDir(request.PackageName, request.ChallengeID), "submissions", request.Username)
+	if err := os.MkdirAll(submissionDir, 0755); err != nil {
+		return services.SaveSubmissionResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create submission directory: %v", err),
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(submissionDir, "solution.go"), []byte(request.Code), 0644); err != nil {
+		return services.SaveSubmissionResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to save solution: %v", err),
 		}
 	}
 
-	// Return success response with git commands
+	// Return success response with git commands for a per-challenge branch
 	relativePath := filepath.Join("packages", request.PackageName, request.ChallengeID, "submissions", request.Username, "solution.go")
 	return services.SaveSubmissionResponse{
 		Success:  true,
 		Message:  "Solution saved to filesystem",
 		FilePath: filepath.Join(submissionDir, "solution.go"),
-		GitCommands: []string{
-			"cd " + filepath.Join(workDir, ".."),
-			fmt.Sprintf("git add %s", relativePath),
-			fmt.Sprintf("git commit -m \"Add solution for %s %s by %s\"", request.PackageName, request.ChallengeID, request.Username),
-			"git push origin main",
-		},
+		GitCommands: services.SubmissionGitCommands(
+			h.challengeService.RepoRoot(),
+			services.SubmissionBranch(request.Username, request.PackageName, request.ChallengeID),
+			relativePath,
+			services.SubmissionCommitMessage(request.PackageName+"/"+request.ChallengeID, request.Username),
+		),
+	}
+}
+
+// aiChallengeRef identifies the challenge an AI request is about: a classic challenge
+// by challengeId, or a package challenge by packageName and packageChallengeId
+type aiChallengeRef struct {
+	ChallengeID        int    `json:"challengeId,omitempty"`
+	PackageName        string `json:"packageName,omitempty"`
+	PackageChallengeID string `json:"packageChallengeId,omitempty"`
+}
+
+// resolveAIChallenge loads the referenced challenge for the AI prompts, writing a 400 or 404 on failure
+func (h *APIHandler) resolveAIChallenge(w http.ResponseWriter, ref aiChallengeRef) (*services.ChallengeContext, bool) {
+	return resolveChallengeContext(w, ref, h.challengeService, h.packageService)
+}
+
+// resolveChallengeContext loads the referenced classic or package challenge, writing a 400 or 404 on failure
+func resolveChallengeContext(w http.ResponseWriter, ref aiChallengeRef, challengeService *services.ChallengeService, packageService *services.PackageService) (*services.ChallengeContext, bool) {
+	challenge, err := lookupChallengeContext(ref, challengeService, packageService)
+	if err != nil {
+		err.writeText(w)
+		return nil, false
+	}
+	return challenge, true
+}
+
+// lookupChallengeContext loads the referenced classic or package challenge, or returns a 400 or 404 error
+func lookupChallengeContext(ref aiChallengeRef, challengeService *services.ChallengeService, packageService *services.PackageService) (*services.ChallengeContext, *apiError) {
+	if ref.PackageName == "" && ref.PackageChallengeID == "" {
+		challenge, exists := challengeService.GetChallenge(ref.ChallengeID)
+		if !exists {
+			return nil, notFound("Challenge not found")
+		}
+		return services.ClassicChallengeContext(challenge), nil
+	}
+
+	if !isSafePathComponent(ref.PackageName) || !isSafePathComponent(ref.PackageChallengeID) {
+		return nil, badRequest("Invalid package challenge reference")
+	}
+	challenge, err := packageService.GetPackageChallenge(ref.PackageName, ref.PackageChallengeID)
+	if err != nil {
+		return nil, notFound("Challenge not found")
 	}
+	return services.PackageChallengeContext(ref.PackageName, challenge), nil
+}
+
+// isSafePathComponent reports whether name can be used as a single directory name
+func isSafePathComponent(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// aiErrorStatus maps each kind of AI failure to the HTTP status reported to the client
+var aiErrorStatus = map[string]int{
+	services.AIErrorNotConfigured:   http.StatusServiceUnavailable,
+	services.AIErrorAuth:            http.StatusServiceUnavailable,
+	services.AIErrorQuotaExceeded:   http.StatusServiceUnavailable,
+	services.AIErrorRateLimited:     http.StatusTooManyRequests,
+	services.AIErrorUsageLimit:      http.StatusTooManyRequests,
+	services.AIErrorTimeout:         http.StatusGatewayTimeout,
+	services.AIErrorCanceled:        http.StatusServiceUnavailable,
+	services.AIErrorInvalidRequest:  http.StatusBadGateway,
+	services.AIErrorUnavailable:     http.StatusBadGateway,
+	services.AIErrorNetwork:         http.StatusBadGateway,
+	services.AIErrorInvalidResponse: http.StatusBadGateway,
+}
+
+// writeAIError reports an AI failure as {"success": false, "error": {kind, message, ...}}
+// so the UI can explain what went wrong
+func writeAIError(w http.ResponseWriter, err error) {
+	aiErr := asAIError(err)
+	status := aiStatus(aiErr)
+	if aiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(aiErr.RetryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   aiErr,
+	})
+}
+
+// aiAPIError reports an AI failure in the /api/v1 envelope, with the code "ai_" + kind
+func aiAPIError(err error) *apiError {
+	aiErr := asAIError(err)
+	apiErr := &apiError{
+		Status:     aiStatus(aiErr),
+		Code:       "ai_" + aiErr.Kind,
+		Message:    aiErr.Message,
+		Retryable:  aiErr.Retryable,
+		RetryAfter: int(aiErr.RetryAfter.Seconds()),
+	}
+	if len(aiErr.Details) > 0 {
+		apiErr.Details = aiErr.Details
+	}
+	return apiErr
+}
+
+// asAIError classifies errors that did not come from the AI service as unavailable
+func asAIError(err error) *services.AIError {
+	if aiErr, ok := err.(*services.AIError); ok {
+		return aiErr
+	}
+	return &services.AIError{Kind: services.AIErrorUnavailable, Message: err.Error()}
+}
+
+// aiStatus returns the HTTP status for an AI failure
+func aiStatus(aiErr *services.AIError) int {
+	if status, ok := aiErrorStatus[aiErr.Kind]; ok {
+		return status
+	}
+	return http.StatusBadGateway
+}
+
+// aiContext charges the request's AI usage to the signed-in user, or to the client
+// address for anonymous visitors, resolved the same way as for rate limiting
+func aiContext(r *http.Request) context.Context {
+	user := currentUsername(r)
+	if user == "" {
+		host := services.ClientIPFromContext(r.Context())
+		if host == "" {
+			var err error
+			if host, _, err = net.SplitHostPort(r.RemoteAddr); err != nil {
+				host = r.RemoteAddr
+			}
+		}
+		user = "anonymous:" + host
+	}
+	return services.WithAIUser(r.Context(), user)
 }
 
 // AICodeReview performs AI-powered code review
@@ -1053,10 +1097,14 @@ func (h *APIHandler) AICodeReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
 	var request struct {
-		ChallengeID int    `json:"challengeId"`
-		Code        string `json:"code"`
-		Context     string `json:"context"`
+		aiChallengeRef
+		Code    string `json:"code"`
+		Context string `json:"context"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -1065,15 +1113,14 @@ func (h *APIHandler) AICodeReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
-	if !exists {
-		http.Error(w, "Challenge not found", http.StatusNotFound)
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
 		return
 	}
 
-	review, err := h.aiService.ReviewCode(request.Code, challenge, request.Context)
+	review, err := h.aiService.ReviewCode(aiContext(r), request.Code, challenge, request.Context)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("AI review failed: %v", err), http.StatusInternalServerError)
+		writeAIError(w, err)
 		return
 	}
 
@@ -1081,6 +1128,57 @@ func (h *APIHandler) AICodeReview(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(review)
 }
 
+// AICodeReviewStream streams the interviewer feedback over SSE as it is generated.
+// Events: "feedback" ({"text"}), "progress" ({"received"}), then "review" with the
+// validated review, or "error" ({"message", "kind", "retryable"}).
+func (h *APIHandler) AICodeReviewStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request struct {
+		aiChallengeRef
+		Code    string `json:"code"`
+		Context string `json:"context"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
+		return
+	}
+
+	stream, ok := newSSEStream(w)
+	if !ok {
+		return
+	}
+	stream.Send("status", map[string]string{"text": "Running tests, go vet and coverage..."})
+
+	review, err := h.aiService.StreamCodeReview(aiContext(r), request.Code, challenge, request.Context,
+		func(text string) error {
+			return stream.Send("feedback", map[string]string{"text": text})
+		},
+		func(received int) error {
+			return stream.Send("progress", map[string]int{"received": received})
+		},
+	)
+	if err != nil {
+		stream.SendAIError(err)
+		return
+	}
+	stream.Send("review", review)
+}
+
 // AIInterviewerQuestions generates AI interviewer questions
 func (h *APIHandler) AIInterviewerQuestions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -1088,8 +1186,12 @@ func (h *APIHandler) AIInterviewerQuestions(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
 	var request struct {
-		ChallengeID  int    `json:"challengeId"`
+		aiChallengeRef
 		Code         string `json:"code"`
 		UserProgress string `json:"userProgress"`
 	}
@@ -1100,24 +1202,25 @@ func (h *APIHandler) AIInterviewerQuestions(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
-	if !exists {
-		http.Error(w, "Challenge not found", http.StatusNotFound)
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
 		return
 	}
 
-	questions, err := h.aiService.GetInterviewerQuestions(request.Code, challenge, request.UserProgress)
+	questions, promptVersion, err := h.aiService.GetInterviewerQuestions(aiContext(r), request.Code, challenge, request.UserProgress)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("AI questions failed: %v", err), http.StatusInternalServerError)
+		writeAIError(w, err)
 		return
 	}
 
 	response := struct {
-		Questions []string `json:"questions"`
-		Success   bool     `json:"success"`
+		Questions     []string `json:"questions"`
+		PromptVersion string   `json:"promptVersion"`
+		Success       bool     `json:"success"`
 	}{
-		Questions: questions,
-		Success:   true,
+		Questions:     questions,
+		PromptVersion: promptVersion,
+		Success:       true,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1131,10 +1234,18 @@ func (h *APIHandler) AICodeHint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	if !h.freeHintsAllowed(w) {
+		return
+	}
+
 	var request struct {
-		ChallengeID int    `json:"challengeId"`
-		Code        string `json:"code"`
-		HintLevel   int    `json:"hintLevel"`
+		aiChallengeRef
+		Code      string `json:"code"`
+		HintLevel int    `json:"hintLevel"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -1143,9 +1254,8 @@ func (h *APIHandler) AICodeHint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
-	if !exists {
-		http.Error(w, "Challenge not found", http.StatusNotFound)
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
 		return
 	}
 
@@ -1154,37 +1264,66 @@ func (h *APIHandler) AICodeHint(w http.ResponseWriter, r *http.Request) {
 		request.HintLevel = 1
 	}
 
-	hint, err := h.aiService.GetCodeHint(request.Code, challenge, request.HintLevel)
+	hint, promptVersion, err := h.aiService.GetCodeHint(aiContext(r), request.Code, challenge, request.HintLevel)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("AI hint failed: %v", err), http.StatusInternalServerError)
+		writeAIError(w, err)
 		return
 	}
 
 	response := struct {
-		Hint      string `json:"hint"`
-		HintLevel int    `json:"hintLevel"`
-		Success   bool   `json:"success"`
+		Hint          string `json:"hint"`
+		HintLevel     int    `json:"hintLevel"`
+		PromptVersion string `json:"promptVersion"`
+		Success       bool   `json:"success"`
 	}{
-		Hint:      hint,
-		HintLevel: request.HintLevel,
-		Success:   true,
+		Hint:          hint,
+		HintLevel:     request.HintLevel,
+		PromptVersion: promptVersion,
+		Success:       true,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// AIDebugResponse provides raw AI response for debugging
-func (h *APIHandler) AIDebugResponse(w http.ResponseWriter, r *http.Request) {
+// freeHintsAllowed refuses hints at a level of the user's choosing when the site
+// restricts hints, since only the hint ladder (/api/hints/next) records and penalizes them
+func (h *APIHandler) freeHintsAllowed(w http.ResponseWriter) bool {
+	if err := h.freeHintsError(); err != nil {
+		err.writeText(w)
+		return false
+	}
+	return true
+}
+
+// freeHintsError returns a 403 error when the site restricts hints
+func (h *APIHandler) freeHintsError() *apiError {
+	if h.hintService.DefaultPolicy().Mode == services.HintsAllowed {
+		return nil
+	}
+	return newAPIError(http.StatusForbidden, CodeHintsRestricted, "Hints are restricted on this site; use the hint ladder")
+}
+
+// AICodeHintStream streams a hint over SSE.
+// Events: "hint" ({"text"}) for each piece, then "done" ({"hint", "hintLevel", "promptVersion"}) or "error".
+func (h *APIHandler) AICodeHintStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	if !h.freeHintsAllowed(w) {
+		return
+	}
+
 	var request struct {
-		ChallengeID int    `json:"challengeId"`
-		Code        string `json:"code"`
-		Context     string `json:"context"`
+		aiChallengeRef
+		Code      string `json:"code"`
+		HintLevel int    `json:"hintLevel"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&request)
@@ -1193,70 +1332,208 @@ func (h *APIHandler) AIDebugResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	challenge, exists := h.challengeService.GetChallenge(request.ChallengeID)
-	if !exists {
-		http.Error(w, "Challenge not found", http.StatusNotFound)
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
 		return
 	}
 
-	// Get raw AI response for debugging
-	prompt := h.aiService.BuildCodeReviewPrompt(request.Code, challenge, request.Context)
-	rawResponse, err := h.aiService.CallLLMRaw(prompt)
+	// Validate hint level
+	if request.HintLevel < 1 || request.HintLevel > 4 {
+		request.HintLevel = 1
+	}
 
-	response := struct {
-		RawResponse string `json:"raw_response"`
-		Prompt      string `json:"prompt"`
-		Success     bool   `json:"success"`
-		Error       string `json:"error,omitempty"`
-	}{
-		RawResponse: rawResponse,
-		Prompt:      prompt,
-		Success:     err == nil,
+	stream, ok := newSSEStream(w)
+	if !ok {
+		return
 	}
 
+	hint, promptVersion, err := h.aiService.StreamCodeHint(aiContext(r), request.Code, challenge, request.HintLevel, func(text string) error {
+		return stream.Send("hint", map[string]string{"text": text})
+	})
 	if err != nil {
-		response.Error = err.Error()
+		stream.SendAIError(err)
+		return
+	}
+	stream.Send("done", map[string]interface{}{
+		"hint":          hint,
+		"hintLevel":     request.HintLevel,
+		"promptVersion": promptVersion,
+	})
+}
+
+// AIBreakSolution asks the AI for inputs likely to break the submitted code and reports
+// the ones on which it disagrees with a known-passing solution
+func (h *APIHandler) AIBreakSolution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request struct {
+		aiChallengeRef
+		Code string `json:"code"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(request.Code) == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
+		return
+	}
+
+	report, err := h.aiService.BreakSolution(aiContext(r), request.Code, challenge, currentUsername(r))
+	if err == services.ErrNoReferenceSolution {
+		http.Error(w, "There is no known-passing solution for this challenge to compare against yet", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeAIError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(report)
+}
+
+// AIStatus reports whether AI features are available and how much of today's quota
+// the caller has left. It never reveals anything about the API key.
+func (h *APIHandler) AIStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.aiStatus(r))
+}
+
+// aiStatusResponse describes whether AI features are available to the caller
+type aiStatusResponse struct {
+	Provider   string                          `json:"provider"`
+	Configured bool                            `json:"configured"`
+	Status     string                          `json:"status"` // "ready" or "unavailable"
+	Model      string                          `json:"model,omitempty"`
+	Message    string                          `json:"message,omitempty"`
+	Prompts    map[string]string               `json:"prompts"`
+	Quota      map[string]services.QuotaStatus `json:"quota,omitempty"` // "user" and "global"
 }
 
-// GitHubWebhookHandler handles GitHub sponsor webhooks
-func (h *APIHandler) GitHubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+// aiStatus reports the AI provider and the caller's remaining quota
+func (h *APIHandler) aiStatus(r *http.Request) aiStatusResponse {
+	response := aiStatusResponse{
+		Provider:   h.aiService.ProviderName(),
+		Configured: h.aiService.Provider() != nil,
+		Status:     "ready",
+		Prompts:    h.aiService.PromptVersions(),
+	}
+	if provider := h.aiService.Provider(); provider != nil {
+		response.Model = provider.Model()
+	} else {
+		response.Status = "unavailable"
+		response.Message = h.aiService.UnavailableMessage()
+	}
+	if usageService := h.aiService.UsageService(); usageService != nil {
+		user, global := usageService.UserQuota(services.AIUserFromContext(aiContext(r)))
+		response.Quota = map[string]services.QuotaStatus{"user": user, "global": global}
+	}
+	return response
+}
+
+// AIUsageReport returns per-day AI usage by user, task, model and prompt version for administrators
+func (h *APIHandler) AIUsageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	usageService := h.aiService.UsageService()
+	if usageService == nil {
+		http.Error(w, "AI usage is not being recorded", http.StatusNotFound)
+		return
+	}
+
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 30 {
+			http.Error(w, "days must be between 1 and 30", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	report := usageService.Report(days)
+	report.Cache = h.aiService.CacheStats()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// AIDebugResponse provides raw AI response for debugging
+func (h *APIHandler) AIDebugResponse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request struct {
+		aiChallengeRef
+		Code    string `json:"code"`
+		Context string `json:"context"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
 		return
 	}
 
-	// Parse the webhook payload
-	var payload map[string]interface{}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+	challenge, ok := h.resolveAIChallenge(w, request.aiChallengeRef)
+	if !ok {
 		return
 	}
 
-	// Check if this is a sponsorship event
-	eventType := r.Header.Get("X-GitHub-Event")
-	if eventType == "sponsorship" {
-		// Clear the sponsor cache to force a refresh on next request
-		sponsorCache.mutex.Lock()
-		sponsorCache.sponsors = make(map[string]bool)
-		sponsorCache.lastUpdated = time.Time{} // Reset to zero time to force refresh
-		sponsorCache.mutex.Unlock()
+	// Get raw AI response for debugging
+	prompt := h.aiService.BuildCodeReviewPrompt(r.Context(), request.Code, challenge, request.Context)
+	rawResponse, err := h.aiService.CallLLMRaw(aiContext(r), prompt)
+
+	response := struct {
+		RawResponse string `json:"raw_response"`
+		Prompt      string `json:"prompt"`
+		Success     bool   `json:"success"`
+		Error       string `json:"error,omitempty"`
+	}{
+		RawResponse: rawResponse,
+		Prompt:      prompt,
+		Success:     err == nil,
+	}
 
-		fmt.Printf("Sponsor cache cleared due to webhook event: %s\n", eventType)
+	if err != nil {
+		response.Error = err.Error()
 	}
 
-	// Respond with 200 OK to acknowledge receipt
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
 // GetSponsorsDebug returns current sponsors for debugging
@@ -1266,16 +1543,21 @@ func (h *APIHandler) GetSponsorsDebug(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sponsors := h.LoadSponsors()
+	sponsors := h.sponsorService.Sponsors()
+	list := h.sponsorService.List()
 
 	response := struct {
-		Sponsors map[string]bool `json:"sponsors"`
-		Count    int             `json:"count"`
-		Success  bool            `json:"success"`
+		Sponsors  map[string]bool `json:"sponsors"`
+		Count     int             `json:"count"`
+		Source    string          `json:"source"`
+		UpdatedAt time.Time       `json:"updatedAt"`
+		Success   bool            `json:"success"`
 	}{
-		Sponsors: sponsors,
-		Count:    len(sponsors),
-		Success:  true,
+		Sponsors:  sponsors,
+		Count:     len(sponsors),
+		Source:    list.Source,
+		UpdatedAt: list.UpdatedAt,
+		Success:   true,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
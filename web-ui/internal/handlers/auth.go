@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"web-ui/internal/router"
+	"web-ui/internal/services"
+)
+
+// AuthHandler handles login, logout and session endpoints
+type AuthHandler struct {
+	authService      *services.AuthService
+	challengeService *services.ChallengeService
+	userService      *services.UserService
+	trustProxy       bool // Take the callback scheme from X-Forwarded-Proto
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(
+	authService *services.AuthService,
+	challengeService *services.ChallengeService,
+	userService *services.UserService,
+	trustProxy bool,
+) *AuthHandler {
+	return &AuthHandler{
+		authService:      authService,
+		challengeService: challengeService,
+		userService:      userService,
+		trustProxy:       trustProxy,
+	}
+}
+
+// Login starts the login flow by redirecting to the configured provider
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider := h.authService.Provider()
+	if provider == nil {
+		http.Error(w, "Sign-in is not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	state, err := h.authService.NewState()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, h.authService.StateCookie(state))
+	http.Redirect(w, r, provider.AuthCodeURL(state, h.callbackURL(r)), http.StatusFound)
+}
+
+// Callback completes the login flow and issues a session cookie
+func (h *AuthHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider := h.authService.Provider()
+	if provider == nil {
+		http.Error(w, "Sign-in is not configured on this server", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	if !h.authService.VerifyState(r, query.Get("state")) {
+		http.Error(w, "Invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "Missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := provider.Exchange(r.Context(), code, h.callbackURL(r))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Login failed", "error", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := h.authService.SessionCookie(identity)
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, h.authService.ClearStateCookie())
+	http.SetCookie(w, cookie)
+
+	// Warm the attempts cache for the freshly signed-in user
+	h.userService.RefreshUserAttempts(identity.Username, h.challengeService.GetChallenges())
+
+	slog.InfoContext(r.Context(), "User signed in", "user", identity.Username, "provider", identity.Provider)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// Logout clears the session cookie. It only accepts POST, so the CSRF check applies and
+// another site cannot sign users out with a link or image.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	http.SetCookie(w, h.authService.ClearSessionCookie())
+	http.SetCookie(w, h.authService.ClearCSRFCookie())
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Me returns the identity bound to the current request
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.identity(r))
+}
+
+// meResponse describes who the request is authenticated as
+type meResponse struct {
+	Authenticated bool               `json:"authenticated"`
+	Provider      string             `json:"provider"` // "none" when sign-in is disabled
+	User          *services.Identity `json:"user,omitempty"`
+}
+
+// identity returns the identity bound to the request and the deployment's auth provider
+func (h *AuthHandler) identity(r *http.Request) meResponse {
+	identity := services.IdentityFromContext(r.Context())
+	provider := "none"
+	if p := h.authService.Provider(); p != nil {
+		provider = p.Name()
+	}
+	return meResponse{
+		Authenticated: identity != nil,
+		Provider:      provider,
+		User:          identity,
+	}
+}
+
+// callbackURL returns the absolute OAuth callback URL for this deployment.
+// X-Forwarded-Proto is only honored behind a trusted proxy, as for the client IP.
+func (h *AuthHandler) callbackURL(r *http.Request) string {
+	if redirect := os.Getenv("AUTH_REDIRECT_URL"); redirect != "" {
+		return redirect
+	}
+	scheme := "http"
+	if r.TLS != nil || (h.trustProxy && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/auth/callback"
+}
+
+// requireUsername returns the authenticated username or writes a 401 response
+func requireUsername(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, err := signedInUser(r)
+	if err != nil {
+		err.writeText(w)
+		return "", false
+	}
+	return username, true
+}
+
+// requireScope returns the authenticated username if the request carries the scope,
+// otherwise writes a 401 or 403 response
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) (string, bool) {
+	username, err := scopedUser(r, scope)
+	if err != nil {
+		err.writeText(w)
+		return "", false
+	}
+	return username, true
+}
+
+// requireAdmin returns the authenticated username if it is listed in ADMIN_USERS
+// (comma-separated), otherwise writes a 401 or 403 response
+func requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, err := adminUser(r)
+	if err != nil {
+		err.writeText(w)
+		return "", false
+	}
+	return username, true
+}
+
+// checkScope rejects token-authenticated requests lacking the scope.
+// Anonymous and browser-session requests pass through unchanged.
+func checkScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if err := scopeError(r, scope); err != nil {
+		err.writeText(w)
+		return false
+	}
+	return true
+}
+
+// signedInUser returns the authenticated username, or a 401 error
+func signedInUser(r *http.Request) (string, *apiError) {
+	identity := services.IdentityFromContext(r.Context())
+	if identity == nil {
+		return "", newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+	}
+	return identity.Username, nil
+}
+
+// scopedUser returns the authenticated username if the request carries the scope,
+// otherwise a 401 or 403 error
+func scopedUser(r *http.Request, scope string) (string, *apiError) {
+	username, err := signedInUser(r)
+	if err != nil {
+		return "", err
+	}
+	if err := scopeError(r, scope); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// adminUser returns the authenticated username if it is an administrator, otherwise a 401 or 403 error
+func adminUser(r *http.Request) (string, *apiError) {
+	username, err := signedInUser(r)
+	if err != nil {
+		return "", err
+	}
+	for _, admin := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if strings.TrimSpace(admin) == username {
+			return username, nil
+		}
+	}
+	return "", forbidden("Administrator access required")
+}
+
+// scopeError returns a 403 error for token-authenticated requests lacking the scope
+func scopeError(r *http.Request, scope string) *apiError {
+	identity := services.IdentityFromContext(r.Context())
+	if identity != nil && !identity.HasScope(scope) {
+		return forbidden("API token is missing the '" + scope + "' scope")
+	}
+	return nil
+}
+
+// routeAuthError checks a request against the route's Auth and Scope, returning a 401
+// or 403 error if it may not use the route
+func routeAuthError(r *http.Request, doc router.Doc) *apiError {
+	if doc.Auth {
+		if _, err := signedInUser(r); err != nil {
+			return err
+		}
+	}
+	if doc.Scope != "" {
+		return scopeError(r, doc.Scope)
+	}
+	return nil
+}
+
+// currentUsername returns the authenticated username, or "" for anonymous requests
+func currentUsername(r *http.Request) string {
+	if identity := services.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Username
+	}
+	return ""
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"web-ui/internal/services"
+)
+
+func TestScopedUser(t *testing.T) {
+	readOnly := &services.APIToken{Username: "alice", Scopes: []string{services.ScopeRead}}
+	runner := &services.APIToken{Username: "alice", Scopes: []string{services.ScopeRead, services.ScopeRun}}
+
+	tests := []struct {
+		name     string
+		identity *services.Identity
+		scope    string
+		status   int // 0 when allowed
+	}{
+		{"anonymous", nil, services.ScopeRead, http.StatusUnauthorized},
+		{"session", &services.Identity{Username: "alice", Provider: "github"}, services.ScopeSubmit, 0},
+		{"token with scope", &services.Identity{Username: "alice", Token: runner}, services.ScopeRun, 0},
+		{"token without run", &services.Identity{Username: "alice", Token: readOnly}, services.ScopeRun, http.StatusForbidden},
+		{"token without submit", &services.Identity{Username: "alice", Token: runner}, services.ScopeSubmit, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/v1/challenges/1/runs", nil)
+			if tt.identity != nil {
+				r = r.WithContext(services.WithIdentity(r.Context(), tt.identity))
+			}
+
+			username, err := scopedUser(r, tt.scope)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("got error %d %s, want alice", err.Status, err.Message)
+				}
+				if username != "alice" {
+					t.Errorf("got username %q, want alice", username)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("allowed %q, want status %d", username, tt.status)
+			}
+			if err.Status != tt.status {
+				t.Errorf("got status %d, want %d", err.Status, tt.status)
+			}
+
+			// The legacy handlers refuse the same requests
+			w := httptest.NewRecorder()
+			if _, ok := requireScope(w, r, tt.scope); ok || w.Code != tt.status {
+				t.Errorf("requireScope: got ok=%v status %d, want status %d", ok, w.Code, tt.status)
+			}
+		})
+	}
+}
+
+// githubStandIn serves the OAuth token and user endpoints, granting a token for the code "good"
+func githubStandIn(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "shh" {
+			http.Error(w, "bad client", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("code") != "good" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_test"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"login": "octocat", "name": "Mona", "avatar_url": "https://example.com/a.png"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestAuthHandler(t *testing.T, trustProxy bool) *AuthHandler {
+	github := githubStandIn(t)
+	t.Setenv("AUTH_PROVIDER", "github")
+	t.Setenv("AUTH_REDIRECT_URL", "")
+	t.Setenv("GITHUB_CLIENT_ID", "client")
+	t.Setenv("GITHUB_CLIENT_SECRET", "shh")
+	t.Setenv("GITHUB_OAUTH_URL", github.URL)
+	t.Setenv("GITHUB_API_URL", github.URL)
+	t.Setenv("SESSION_SECRET", "test-secret")
+	root := t.TempDir()
+	authService, err := services.NewAuthService(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthHandler(authService, services.NewChallengeService(root), services.NewUserService(root), trustProxy)
+}
+
+// responseCookie returns the cookie the response sets under name, or nil
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOAuthFlow(t *testing.T) {
+	h := newTestAuthHandler(t, false)
+	github := os.Getenv("GITHUB_OAUTH_URL")
+
+	// Login redirects to the provider with a state bound to the state cookie
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest("GET", "http://practice.test/auth/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: got status %d, want %d", w.Code, http.StatusFound)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), github+"/login/oauth/authorize?") {
+		t.Fatalf("login redirected to %q, want the stand-in's authorize URL", w.Header().Get("Location"))
+	}
+	query := location.Query()
+	if query.Get("client_id") != "client" || query.Get("redirect_uri") != "http://practice.test/auth/callback" {
+		t.Errorf("unexpected authorize parameters %v", query)
+	}
+	state := query.Get("state")
+	stateCookie := responseCookie(w, services.StateCookieName)
+	if state == "" || stateCookie == nil {
+		t.Fatalf("login set no state: %q, cookie %v", state, stateCookie)
+	}
+
+	// The callback exchanges the code and issues a session cookie
+	callback := func(code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://practice.test/auth/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.Callback(w, r)
+		return w
+	}
+	tampered := *stateCookie
+	tampered.Value = stateCookie.Value[:len(stateCookie.Value)-2] + "xx"
+	tests := []struct {
+		name   string
+		code   string
+		state  string
+		cookie *http.Cookie
+		status int
+	}{
+		{"without the state cookie", "good", state, nil, http.StatusBadRequest},
+		{"with another state", "good", "0123456789abcdef", stateCookie, http.StatusBadRequest},
+		{"with a tampered state cookie", "good", state, &tampered, http.StatusBadRequest},
+		{"without a code", "", state, stateCookie, http.StatusBadRequest},
+		{"with a code the provider rejects", "bad", state, stateCookie, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := callback(tt.code, tt.state, tt.cookie); w.Code != tt.status {
+			t.Errorf("callback %s: got status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+
+	w = callback("good", state, stateCookie)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/" {
+		t.Fatalf("callback: got status %d to %q, want a redirect home", w.Code, w.Header().Get("Location"))
+	}
+	session := responseCookie(w, services.SessionCookieName)
+	if session == nil || session.Value == "" || !session.HttpOnly {
+		t.Fatalf("callback set session cookie %v", session)
+	}
+	if cleared := responseCookie(w, services.StateCookieName); cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("callback did not clear the state cookie: %v", cleared)
+	}
+
+	// The session identifies the user to /auth/me, as the identity middleware does
+	me := func(cookie *http.Cookie) meResponse {
+		r := httptest.NewRequest("GET", "/auth/me", nil)
+		r.AddCookie(cookie)
+		if identity := h.authService.IdentityFromRequest(r); identity != nil {
+			r = r.WithContext(services.WithIdentity(r.Context(), identity))
+		}
+		w := httptest.NewRecorder()
+		h.Me(w, r)
+		var response meResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	response := me(session)
+	if !response.Authenticated || response.Provider != "github" || response.User == nil ||
+		response.User.Username != "octocat" || response.User.Name != "Mona" {
+		t.Errorf("me: got %+v", response)
+	}
+	forged := *session
+	forged.Value = strings.Replace(session.Value, ".", ".A", 1)
+	if response := me(&forged); response.Authenticated {
+		t.Errorf("me accepted a tampered session cookie")
+	}
+
+	// Logout only accepts POST and clears the session and CSRF cookies
+	w = httptest.NewRecorder()
+	h.Logout(w, httptest.NewRequest("GET", "/auth/logout", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("logout with GET: got status %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	w = httptest.NewRecorder()
+	h.Logout(w, httptest.NewRequest("POST", "/auth/logout", nil))
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("logout: got status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	for _, name := range []string{services.SessionCookieName, services.CSRFCookieName} {
+		if cleared := responseCookie(w, name); cleared == nil || cleared.Value != "" || cleared.MaxAge >= 0 {
+			t.Errorf("logout did not clear %s: %v", name, cleared)
+		}
+	}
+}
+
+func TestCallbackURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		tls        bool
+		proto      string
+		want       string
+	}{
+		{"plain HTTP", false, false, "", "http://practice.test/auth/callback"},
+		{"TLS", false, true, "", "https://practice.test/auth/callback"},
+		{"untrusted X-Forwarded-Proto", false, false, "https", "http://practice.test/auth/callback"},
+		{"trusted X-Forwarded-Proto", true, false, "https", "https://practice.test/auth/callback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &AuthHandler{trustProxy: tt.trustProxy}
+			r := httptest.NewRequest("GET", "http://practice.test/auth/login", nil)
+			if tt.tls {
+				r = httptest.NewRequest("GET", "https://practice.test/auth/login", nil)
+			}
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if got := h.callbackURL(r); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("AUTH_REDIRECT_URL", "https://practice.example/auth/callback")
+	if got := (&AuthHandler{}).callbackURL(httptest.NewRequest("GET", "/", nil)); got != "https://practice.example/auth/callback" {
+		t.Errorf("AUTH_REDIRECT_URL ignored: got %s", got)
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Error codes of the /api/v1 error envelope. AI failures use "ai_" followed by the
+// AIError kind, e.g. "ai_rate_limited".
+const (
+	CodeBadRequest       = "bad_request"
+	CodeInvalidJSON      = "invalid_json"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeInvalidCSRF      = "invalid_csrf_token"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal"
+	CodeUnavailable      = "unavailable"
+
+	CodeInterviewClosed = "interview_closed"
+	CodeInterviewBusy   = "interview_busy"
+	CodeHintsDisabled   = "hints_disabled"
+	CodeHintsRestricted = "hints_restricted"
+	CodeNoMoreHints     = "no_more_hints"
+	CodeHintBusy        = "hint_in_progress"
+	CodeNoReference     = "no_reference_solution"
+)
+
+// apiError is a failed request: written as {"error": {...}} by the /api/v1 routes and
+// as plain text by the older routes
+type apiError struct {
+	Status     int         `json:"status"`
+	Code       string      `json:"code"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	Retryable  bool        `json:"retryable,omitempty"`
+	RetryAfter int         `json:"retryAfter,omitempty"` // Seconds, also sent as the Retry-After header
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+func badRequest(message string) *apiError {
+	return newAPIError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func notFound(message string) *apiError {
+	return newAPIError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func forbidden(message string) *apiError {
+	return newAPIError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func conflict(code, message string) *apiError {
+	return newAPIError(http.StatusConflict, code, message)
+}
+
+// writeText reports the error the way the older routes do, as plain text
+func (e *apiError) writeText(w http.ResponseWriter) {
+	http.Error(w, e.Message, e.Status)
+}
+
+// errorEnvelope is the body of every failed /api/v1 response
+type errorEnvelope struct {
+	Error *apiError `json:"error"`
+}
+
+// writeData writes a successful /api/v1 response: {"data": ...}
+func writeData(w http.ResponseWriter, status int, data interface{}) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]interface{}{"data": v1Value(reflect.ValueOf(data))}); err != nil {
+		writeError(w, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// writeError writes a failed /api/v1 response: {"error": {"status", "code", "message", ...}}
+func writeError(w http.ResponseWriter, err *apiError) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(errorEnvelope{Error: err})
+}
+
+// WriteError reports a failure from middleware in the format of the route: the error
+// envelope under /api/v1, plain text elsewhere
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	err := newAPIError(status, code, message)
+	if !IsV1Path(r.URL.Path) {
+		err.writeText(w)
+		return
+	}
+	writeError(w, err)
+}
+
+// IsV1Path reports whether path is served by the versioned API
+func IsV1Path(path string) bool {
+	return path == "/api/v1" || strings.HasPrefix(path, "/api/v1/")
+}
+
+// decodeJSON reads a JSON request body into v
+func decodeJSON(r *http.Request, v interface{}) *apiError {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return nil
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newAPIError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+			"Request body is larger than "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+	}
+	return newAPIError(http.StatusBadRequest, CodeInvalidJSON, "Invalid request body: "+err.Error())
+}
+
+// v1FieldName is the name /api/v1 clients see for a json tag name: camelCase, whatever
+// the casing of the model, so "execution_ms" and "executionMs" both become "executionMs"
+func v1FieldName(name string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '-':
+			upper = b.Len() > 0
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		case i == 0 || b.Len() == 0:
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	// Go field names without a tag, such as "ID" or "URL", are all lower case
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(b.String())
+	}
+	return b.String()
+}
+
+// orderedObject is a JSON object that keeps its fields in struct order
+type orderedObject []objectField
+
+type objectField struct {
+	name  string
+	value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// v1Value converts v to what encoding/json would write, with struct fields renamed by
+// v1FieldName. Map keys are data, not field names, and keep their spelling.
+func v1Value(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == timeType || v.Type().Implements(jsonMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return v1Value(v.Elem())
+	case reflect.Struct:
+		object := orderedObject{}
+		v1Fields(v, &object)
+		return object
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		object := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			if key.Kind() == reflect.String {
+				object[key.String()] = v1Value(iter.Value())
+			} else {
+				name, _ := json.Marshal(key.Interface())
+				object[strings.Trim(string(name), `"`)] = v1Value(iter.Value())
+			}
+		}
+		return object
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		fallthrough
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = v1Value(v.Index(i))
+		}
+		return items
+	}
+	if !v.CanInterface() {
+		// Promoted from an unexported embedded struct, such as aiChallengeRef
+		switch v.Kind() {
+		case reflect.Bool:
+			return v.Bool()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return v.Uint()
+		case reflect.Float32, reflect.Float64:
+			return v.Float()
+		case reflect.String:
+			return v.String()
+		}
+		return nil
+	}
+	return v.Interface()
+}
+
+// v1Fields appends a struct's JSON fields, flattening embedded structs and honoring
+// the "-" and omitempty tag options
+func v1Fields(v reflect.Value, object *orderedObject) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		value := v.Field(i)
+
+		if field.Anonymous && name == "" {
+			embedded := value
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				v1Fields(embedded, object)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(options, "omitempty") && isEmptyValue(value) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		*object = append(*object, objectField{name: v1FieldName(name), value: v1Value(value)})
+	}
+}
+
+// isEmptyValue matches encoding/json's definition for omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
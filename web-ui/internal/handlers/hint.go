@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"web-ui/internal/services"
+)
+
+// HintHandler serves the hint ladder: authored hints first, then AI hints
+type HintHandler struct {
+	hintService      *services.HintService
+	interviewService *services.InterviewService
+	challengeService *services.ChallengeService
+	packageService   *services.PackageService
+}
+
+// NewHintHandler creates a new hint handler
+func NewHintHandler(
+	hintService *services.HintService,
+	interviewService *services.InterviewService,
+	challengeService *services.ChallengeService,
+	packageService *services.PackageService,
+) *HintHandler {
+	return &HintHandler{
+		hintService:      hintService,
+		interviewService: interviewService,
+		challengeService: challengeService,
+		packageService:   packageService,
+	}
+}
+
+// GetHints returns the hints the user has revealed for a challenge and what comes next:
+// GET /api/hints?challengeId=N or ?packageName=P&packageChallengeId=C, with an optional interviewId
+func (h *HintHandler) GetHints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkScope(w, r, services.ScopeRead) {
+		return
+	}
+
+	query := r.URL.Query()
+	ref := aiChallengeRef{
+		PackageName:        query.Get("packageName"),
+		PackageChallengeID: query.Get("packageChallengeId"),
+	}
+	if ref.PackageName == "" && ref.PackageChallengeID == "" {
+		id, err := strconv.Atoi(query.Get("challengeId"))
+		if err != nil {
+			http.Error(w, "Invalid challenge ID", http.StatusBadRequest)
+			return
+		}
+		ref.ChallengeID = id
+	}
+
+	challenge, ok := resolveChallengeContext(w, ref, h.challengeService, h.packageService)
+	if !ok {
+		return
+	}
+	policy, _, ok := h.hintPolicy(w, r, challenge, query.Get("interviewId"))
+	if !ok {
+		return
+	}
+
+	progress := h.hintService.Progress(services.AIUserFromContext(aiContext(r)), challenge, policy)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// NextHint reveals the next hint on the ladder: POST /api/hints/next
+func (h *HintHandler) NextHint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request struct {
+		aiChallengeRef
+		Code        string `json:"code"`
+		InterviewID string `json:"interviewId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	challenge, ok := resolveChallengeContext(w, request.aiChallengeRef, h.challengeService, h.packageService)
+	if !ok {
+		return
+	}
+	policy, interviewID, ok := h.hintPolicy(w, r, challenge, request.InterviewID)
+	if !ok {
+		return
+	}
+
+	ctx := aiContext(r)
+	hint, err := h.hintService.Next(ctx, challenge, request.Code, policy, interviewID)
+	switch err {
+	case nil:
+	case services.ErrHintsDisabled:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case services.ErrNoMoreHints, services.ErrHintBusy:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		writeAIError(w, err)
+		return
+	}
+
+	if interviewID != "" {
+		if _, err := h.interviewService.RecordHint(currentUsername(r), interviewID, hint); err != nil {
+			slog.WarnContext(r.Context(), "Could not record hint in interview", "interview", interviewID, "error", err)
+		}
+	}
+
+	response := struct {
+		Hint     *services.RevealedHint `json:"hint"`
+		Progress *services.HintProgress `json:"progress"`
+		Success  bool                   `json:"success"`
+	}{
+		Hint:     hint,
+		Progress: h.hintService.Progress(services.AIUserFromContext(ctx), challenge, policy),
+		Success:  true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// hintPolicy returns the policy for a hint request and the interview it counts toward,
+// as policyFor does. It writes an error if the interview is not an active session about
+// this challenge.
+func (h *HintHandler) hintPolicy(w http.ResponseWriter, r *http.Request, challenge *services.ChallengeContext, interviewID string) (services.HintPolicy, string, bool) {
+	policy, interviewID, err := h.policyFor(r, challenge, interviewID)
+	if err != nil {
+		err.writeText(w)
+		return services.HintPolicy{}, "", false
+	}
+	return policy, interviewID, true
+}
+
+// policyFor returns the policy for a hint request and the interview the hint counts
+// toward. Without an interview ID the user's active interview on the challenge is used,
+// so leaving the ID out cannot get around its policy; with neither, the site's policy
+// applies. It returns an error if a given interview is not an active session about this
+// challenge.
+func (h *HintHandler) policyFor(r *http.Request, challenge *services.ChallengeContext, interviewID string) (services.HintPolicy, string, *apiError) {
+	if interviewID == "" {
+		if challenge.Package != "" {
+			return h.hintService.DefaultPolicy(), "", nil
+		}
+		session, err := h.interviewService.ActiveSession(currentUsername(r), challenge.ChallengeID)
+		if err != nil {
+			return services.HintPolicy{}, "", newAPIError(http.StatusInternalServerError, CodeInternal, err.Error())
+		}
+		if session == nil {
+			return h.hintService.DefaultPolicy(), "", nil
+		}
+		return session.HintPolicy, session.ID, nil
+	}
+
+	session, err := h.interviewService.Get(currentUsername(r), interviewID)
+	if err != nil {
+		return services.HintPolicy{}, "", interviewAPIError(err)
+	}
+	if challenge.Package != "" || session.ChallengeID != challenge.ChallengeID {
+		return services.HintPolicy{}, "", badRequest("The interview is about a different challenge")
+	}
+	if session.Status != services.InterviewActive {
+		return services.HintPolicy{}, "", interviewAPIError(services.ErrInterviewClosed)
+	}
+	return session.HintPolicy, interviewID, nil
+}
+
+// hintAPIError classifies a failure to reveal the next hint
+func hintAPIError(err error) *apiError {
+	switch err {
+	case services.ErrHintsDisabled:
+		return newAPIError(http.StatusForbidden, CodeHintsDisabled, err.Error())
+	case services.ErrNoMoreHints:
+		return conflict(CodeNoMoreHints, err.Error())
+	case services.ErrHintBusy:
+		return conflict(CodeHintBusy, err.Error())
+	}
+	return aiAPIError(err)
+}
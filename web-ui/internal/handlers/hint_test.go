@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"web-ui/internal/services"
+)
+
+const testInterviewID = "0123456789abcdef0123456789abcdef"
+
+// newTestHintHandler serves challenge 1, which has two authored hints, and saves an
+// active interview by alice on it with the given hint policy
+func newTestHintHandler(t *testing.T, policy services.HintPolicy) (*HintHandler, *services.InterviewService) {
+	t.Helper()
+	root, dataDir := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"challenge-1/README.md":                 "# Sum\n\nAdd two numbers.\n",
+		"challenge-1/solution-template.go":      "package main\n",
+		"challenge-1/hints.md":                  "## Hint 1\n\nUse +.\n\n## Hint 2\n\nReturn it.\n",
+		"challenge-1/solution-template_test.go": "package main\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	challenges := services.NewChallengeService(root)
+	if err := challenges.LoadChallenges(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := json.Marshal(services.InterviewSession{
+		ID:          testInterviewID,
+		Username:    "alice",
+		ChallengeID: 1,
+		Status:      services.InterviewActive,
+		HintPolicy:  policy,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, "interviews"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "interviews", testInterviewID+".json"), session, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ai := services.NewAIServiceWithConfig(services.LLMConfig{Provider: services.ProviderNone}, nil, nil, nil)
+	interviews := services.NewInterviewService(ai, challenges, dataDir)
+	hints := services.NewHintService(ai, dataDir, services.HintPolicy{Mode: services.HintsAllowed})
+	return NewHintHandler(hints, interviews, challenges, nil), interviews
+}
+
+func TestNextHintAppliesActiveInterviewPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        string
+		policy      services.HintPolicy
+		interviewID string
+		status      int
+		penalty     int
+		hintsUsed   int
+	}{
+		{"disabled without interview ID", "alice", services.HintPolicy{Mode: services.HintsDisabled}, "", http.StatusForbidden, 0, 0},
+		{"disabled with interview ID", "alice", services.HintPolicy{Mode: services.HintsDisabled}, testInterviewID, http.StatusForbidden, 0, 0},
+		{"penalized without interview ID", "alice", services.HintPolicy{Mode: services.HintsPenalized, Penalty: 5}, "", http.StatusOK, 5, 1},
+		{"penalized with interview ID", "alice", services.HintPolicy{Mode: services.HintsPenalized, Penalty: 5}, testInterviewID, http.StatusOK, 5, 1},
+		{"another user", "bob", services.HintPolicy{Mode: services.HintsDisabled}, "", http.StatusOK, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, interviews := newTestHintHandler(t, tt.policy)
+			body := `{"challengeId": 1, "interviewId": "` + tt.interviewID + `"}`
+			r := httptest.NewRequest("POST", "/api/hints/next", strings.NewReader(body))
+			r = r.WithContext(services.WithIdentity(r.Context(), &services.Identity{Username: tt.user, Provider: "github"}))
+			w := httptest.NewRecorder()
+			handler.NextHint(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d %s, want %d", w.Code, strings.TrimSpace(w.Body.String()), tt.status)
+			}
+			if w.Code == http.StatusOK {
+				var response struct {
+					Hint services.RevealedHint `json:"hint"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if response.Hint.Penalty != tt.penalty {
+					t.Errorf("got penalty %d, want %d", response.Hint.Penalty, tt.penalty)
+				}
+			}
+
+			session, err := interviews.Get("alice", testInterviewID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.HintsUsed != tt.hintsUsed || session.HintPenalty != tt.hintsUsed*tt.penalty {
+				t.Errorf("interview recorded %d hints costing %d, want %d costing %d",
+					session.HintsUsed, session.HintPenalty, tt.hintsUsed, tt.hintsUsed*tt.penalty)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"web-ui/internal/services"
+)
+
+// InterviewHandler serves conversational AI mock interviews
+type InterviewHandler struct {
+	interviewService *services.InterviewService
+	hintService      *services.HintService
+}
+
+// NewInterviewHandler creates a new interview handler
+func NewInterviewHandler(interviewService *services.InterviewService, hintService *services.HintService) *InterviewHandler {
+	return &InterviewHandler{
+		interviewService: interviewService,
+		hintService:      hintService,
+	}
+}
+
+// interviewRequest is the body accepted by the interview endpoints
+type interviewRequest struct {
+	ChallengeID int                          `json:"challengeId"`
+	Code        string                       `json:"code"`
+	Answer      string                       `json:"answer"`
+	Run         *services.InterviewRunResult `json:"run"`
+	HintPolicy  *services.HintPolicy         `json:"hintPolicy"` // When starting; the site's policy if omitted
+}
+
+// StartInterview opens a session and returns the first question: POST /api/interviews
+func (h *InterviewHandler) StartInterview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request interviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	hintPolicy := h.hintService.DefaultPolicy()
+	if request.HintPolicy != nil {
+		hintPolicy = *request.HintPolicy
+	}
+
+	session, err := h.interviewService.Start(aiContext(r), currentUsername(r), request.ChallengeID, request.Code, hintPolicy)
+	if err != nil {
+		writeInterviewError(w, err)
+		return
+	}
+	writeInterviewSession(w, http.StatusCreated, session)
+}
+
+// HandleInterview serves a session:
+// GET /api/interviews/{id}, POST /api/interviews/{id}/answer, /runs and /finish
+func (h *InterviewHandler) HandleInterview(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/interviews/"), "/"), "/")
+	id := parts[0]
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	if id == "" || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if action == "" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !checkScope(w, r, services.ScopeRead) {
+			return
+		}
+		session, err := h.interviewService.Get(currentUsername(r), id)
+		if err != nil {
+			writeInterviewError(w, err)
+			return
+		}
+		writeInterviewSession(w, http.StatusOK, session)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkScope(w, r, services.ScopeRun) {
+		return
+	}
+
+	var request interviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	username := currentUsername(r)
+	var session *services.InterviewSession
+	var err error
+	switch action {
+	case "answer":
+		session, err = h.interviewService.Answer(aiContext(r), username, id, strings.TrimSpace(request.Answer), request.Code)
+	case "runs":
+		if request.Run == nil {
+			http.Error(w, "Run result is required", http.StatusBadRequest)
+			return
+		}
+		session, err = h.interviewService.RecordRun(username, id, request.Code, *request.Run)
+	case "finish":
+		session, err = h.interviewService.Finish(aiContext(r), username, id, request.Code)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInterviewError(w, err)
+		return
+	}
+	writeInterviewSession(w, http.StatusOK, session)
+}
+
+func writeInterviewSession(w http.ResponseWriter, status int, session *services.InterviewSession) {
+	response := struct {
+		Session *services.InterviewSession `json:"session"`
+		Rubric  []services.RubricCriterion `json:"rubric"`
+		Success bool                       `json:"success"`
+	}{
+		Session: session,
+		Rubric:  services.InterviewRubric,
+		Success: true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeInterviewError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*services.AIError); ok {
+		writeAIError(w, err)
+		return
+	}
+	interviewAPIError(err).writeText(w)
+}
+
+// interviewAPIError classifies an interview failure
+func interviewAPIError(err error) *apiError {
+	if _, ok := err.(*services.InterviewInputError); ok {
+		return badRequest(err.Error())
+	}
+	if _, ok := err.(*services.AIError); ok {
+		return aiAPIError(err)
+	}
+	switch err {
+	case services.ErrInterviewNotFound:
+		return notFound(err.Error())
+	case services.ErrInterviewClosed:
+		return conflict(CodeInterviewClosed, err.Error())
+	case services.ErrInterviewBusy:
+		return conflict(CodeInterviewBusy, err.Error())
+	default:
+		return newAPIError(http.StatusBadGateway, CodeUnavailable, err.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/router"
+	"web-ui/internal/services"
+)
+
+// searchParams documents the query parameters of the search endpoints
+var searchParams = []router.Parameter{
+	{Name: "q", Description: "Words to find; may be empty when a filter is given"},
+	{Name: "difficulty", Description: "Only results of this difficulty, e.g. Beginner"},
+	{Name: "track", Description: "classic, or a package name for a package and its challenges"},
+	{Name: "tags", Description: "Comma-separated tags results must all have; may be repeated"},
+	{Name: "limit", Type: "integer", Description: "1 to 100, default 20"},
+}
+
+// SearchHandler serves full-text search over challenges, packages and learning material
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search returns ranked results with snippets: GET /api/search?q=...&difficulty=&track=&tags=&limit=
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := searchQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.searchService.Search(query))
+}
+
+// searchQuery reads a search query and its filters from the URL query parameters
+func searchQuery(values url.Values) (services.SearchQuery, error) {
+	query := services.SearchQuery{
+		Text:       strings.TrimSpace(values.Get("q")),
+		Difficulty: strings.TrimSpace(values.Get("difficulty")),
+		Track:      strings.TrimSpace(values.Get("track")),
+	}
+	for _, value := range values["tags"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxSearchLimit {
+			return query, errors.New("limit must be between 1 and 100")
+		}
+		query.Limit = n
+	}
+	if query.Text == "" && query.Difficulty == "" && query.Track == "" && len(query.Tags) == 0 {
+		return query, errors.New("q or a filter is required")
+	}
+	if len(query.Text) > 200 {
+		return query, errors.New("q is too long")
+	}
+	return query, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"web-ui/internal/services"
+)
+
+// sseStream writes server-sent events to a client
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEStream starts an event stream, or reports an error if the connection cannot stream
+func newSSEStream(w http.ResponseWriter) (*sseStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop reverse proxies buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseStream{w: w, flusher: flusher}, true
+}
+
+// Send writes one event with a JSON-encoded payload
+func (s *sseStream) Send(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// SendError reports a failure to the client; the stream ends afterwards
+func (s *sseStream) SendError(message string) {
+	s.Send("error", map[string]string{"message": message})
+}
+
+// SendAIError reports an AI failure with its classification, like writeAIError
+func (s *sseStream) SendAIError(err error) {
+	if aiErr, ok := err.(*services.AIError); ok {
+		s.Send("error", aiErr)
+		return
+	}
+	s.SendError(err.Error())
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"web-ui/internal/services"
+)
+
+// TokenHandler manages personal API tokens for the signed-in user
+type TokenHandler struct {
+	tokenService *services.TokenService
+}
+
+// NewTokenHandler creates a new token handler
+func NewTokenHandler(tokenService *services.TokenService) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+// HandleTokens lists (GET) or creates (POST) tokens
+func (h *TokenHandler) HandleTokens(w http.ResponseWriter, r *http.Request) {
+	username, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		response := struct {
+			Tokens  []*services.APITokenInfo `json:"tokens"`
+			Success bool                     `json:"success"`
+		}{
+			Tokens:  h.tokenService.List(username),
+			Success: true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case "POST":
+		var request services.CreateTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid request data", http.StatusBadRequest)
+			return
+		}
+
+		secret, info, err := h.tokenService.Create(username, request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The plaintext token is only ever returned here
+		response := struct {
+			Token   string                 `json:"token"`
+			Info    *services.APITokenInfo `json:"info"`
+			Success bool                   `json:"success"`
+		}{
+			Token:   secret,
+			Info:    info,
+			Success: true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RevokeToken revokes a token: DELETE /api/tokens/{id}
+func (h *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	username, ok := h.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokenService.Revoke(username, id); err != nil {
+		if err == services.ErrTokenNotFound {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// requireSession only allows browser sessions; API tokens cannot manage tokens
+func (h *TokenHandler) requireSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, err := sessionUser(r)
+	if err != nil {
+		err.writeText(w)
+		return "", false
+	}
+	return username, true
+}
+
+// sessionUser returns the username of a browser session, or a 401 or 403 error
+func sessionUser(r *http.Request) (string, *apiError) {
+	identity := services.IdentityFromContext(r.Context())
+	if identity == nil {
+		return "", newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+	}
+	if identity.Token != nil {
+		return "", forbidden("API tokens cannot be used to manage tokens")
+	}
+	return identity.Username, nil
+}
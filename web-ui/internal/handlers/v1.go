@@ -0,0 +1,941 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/metrics"
+	"web-ui/internal/models"
+	"web-ui/internal/router"
+	"web-ui/internal/services"
+)
+
+// V1Handler serves the versioned REST API under /api/v1. Every response is a JSON
+// envelope, {"data": ...} or {"error": {"status", "code", "message", ...}}, with
+// camelCase field names, and the routes are described by /api/v1/openapi.json.
+type V1Handler struct {
+	api        *APIHandler
+	auth       *AuthHandler
+	tokens     *TokenHandler
+	interviews *InterviewHandler
+	hints      *HintHandler
+	search     *SearchHandler
+	router     *router.Router
+	openAPI    []byte
+}
+
+// NewV1Handler creates the /api/v1 routes on top of the existing handlers and their services
+func NewV1Handler(
+	api *APIHandler,
+	auth *AuthHandler,
+	tokens *TokenHandler,
+	interviews *InterviewHandler,
+	hints *HintHandler,
+	search *SearchHandler,
+) *V1Handler {
+	h := &V1Handler{
+		api:        api,
+		auth:       auth,
+		tokens:     tokens,
+		interviews: interviews,
+		hints:      hints,
+		search:     search,
+		router:     router.New(),
+	}
+	h.router.NotFound = func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, notFound("No API route for "+r.URL.Path))
+	}
+	h.router.MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			r.Method+" is not allowed; use "+w.Header().Get("Allow")))
+	}
+	h.router.Matched = func(r *http.Request, route *router.Route) {
+		metrics.SetRoute(r, route.Pattern)
+	}
+	h.router.Authorize = func(w http.ResponseWriter, r *http.Request, route *router.Route) bool {
+		if err := routeAuthError(r, route.Doc); err != nil {
+			writeError(w, err)
+			return false
+		}
+		return true
+	}
+	h.registerRoutes()
+
+	spec, err := json.Marshal(h.router.OpenAPI(router.Spec{
+		Title:       "Go Interview Practice API",
+		Version:     "1.0.0",
+		Description: "Challenges, test runs, submissions, leaderboards, hints, search and AI interview practice.",
+		FieldName:   v1FieldName,
+		Envelope: func(data router.Schema) router.Schema {
+			return router.Schema{
+				"type":       "object",
+				"properties": router.Schema{"data": data},
+				"required":   []string{"data"},
+			}
+		},
+		Error: errorEnvelope{},
+	}))
+	if err != nil {
+		slog.Error("Failed to generate the OpenAPI document", "error", err)
+	}
+	h.openAPI = spec
+	return h
+}
+
+// ServeHTTP dispatches /api/v1 requests
+func (h *V1Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// v1Func handles a request, returning the response data or an error
+type v1Func func(r *http.Request) (interface{}, *apiError)
+
+// route registers a v1Func, writing its result in the envelope with doc.Status on success
+func (h *V1Handler) route(method, pattern string, fn v1Func, doc router.Doc) {
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	h.router.Handle(method, "/api/v1"+pattern, func(w http.ResponseWriter, r *http.Request) {
+		data, err := fn(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeData(w, status, data)
+	}, doc)
+}
+
+// Request bodies of the /api/v1 routes
+type (
+	v1CodeRequest struct {
+		Code string `json:"code"`
+	}
+	v1SaveRequest struct {
+		Code   string `json:"code"`
+		Commit bool   `json:"commit"` // Commit the saved file on a per-challenge branch
+		DryRun bool   `json:"dryRun"` // Report the git plan without running it
+	}
+	v1NextHintRequest struct {
+		Code        string `json:"code"`
+		InterviewID string `json:"interviewId,omitempty"` // Use the interview's hint policy
+	}
+	v1CreateTokenRequest struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		RateLimit int      `json:"rateLimit,omitempty"` // Requests per minute
+	}
+	v1ReviewRequest struct {
+		aiChallengeRef
+		Code    string `json:"code"`
+		Context string `json:"context,omitempty"`
+	}
+	v1AIHintRequest struct {
+		aiChallengeRef
+		Code      string `json:"code"`
+		HintLevel int    `json:"hintLevel"` // 1 (gentle) to 4 (near solution)
+	}
+	v1QuestionsRequest struct {
+		aiChallengeRef
+		Code         string `json:"code"`
+		UserProgress string `json:"userProgress,omitempty"`
+	}
+	v1StartInterviewRequest struct {
+		ChallengeID int                  `json:"challengeId"`
+		Code        string               `json:"code,omitempty"`
+		HintPolicy  *services.HintPolicy `json:"hintPolicy,omitempty"` // The site's policy if omitted
+	}
+	v1AnswerRequest struct {
+		Answer string `json:"answer"`
+		Code   string `json:"code,omitempty"`
+	}
+	v1InterviewRunRequest struct {
+		Code string                      `json:"code,omitempty"`
+		Run  services.InterviewRunResult `json:"run"`
+	}
+)
+
+// Response data of the /api/v1 routes
+type (
+	v1CreatedToken struct {
+		Token string                 `json:"token"` // Shown only once
+		Info  *services.APITokenInfo `json:"info"`
+	}
+	v1RevokedToken struct {
+		ID string `json:"id"`
+	}
+	v1Leaderboard struct {
+		Leaderboard     []LeaderboardUser `json:"leaderboard"`
+		TotalChallenges int               `json:"totalChallenges"`
+	}
+	v1PackageLeaderboard struct {
+		Package         string                          `json:"package"`
+		DisplayName     string                          `json:"displayName"`
+		TotalChallenges int                             `json:"totalChallenges"`
+		Leaderboard     []models.PackageScoreboardEntry `json:"leaderboard"`
+	}
+	v1Rank struct {
+		Username string `json:"username"`
+		Rank     int    `json:"rank"`
+	}
+	v1NextHint struct {
+		Hint     *services.RevealedHint `json:"hint"`
+		Progress *services.HintProgress `json:"progress"`
+	}
+	v1AIHint struct {
+		Hint          string `json:"hint"`
+		HintLevel     int    `json:"hintLevel"`
+		PromptVersion string `json:"promptVersion"`
+	}
+	v1Questions struct {
+		Questions     []string `json:"questions"`
+		PromptVersion string   `json:"promptVersion"`
+	}
+	v1Interview struct {
+		Session *services.InterviewSession `json:"session"`
+		Rubric  []services.RubricCriterion `json:"rubric"`
+	}
+)
+
+var (
+	challengeIDParam = []router.Parameter{{Name: "id", Type: "integer", Description: "Classic challenge number"}}
+	packageParams    = []router.Parameter{
+		{Name: "package", Description: "Package name, e.g. gin"},
+		{Name: "challenge", Description: "Package challenge ID, e.g. challenge-1-basic-routing"},
+	}
+	interviewIDParam = []router.Parameter{{Name: "id", Description: "Interview session ID"}}
+)
+
+// registerRoutes declares every /api/v1 route with its documentation
+func (h *V1Handler) registerRoutes() {
+	h.router.Handle("GET", "/api/v1/openapi.json", h.openAPIDocument, router.Doc{
+		Summary: "This OpenAPI document", Tag: "meta", Raw: true,
+	})
+	h.route("GET", "/me", h.me, router.Doc{
+		Summary: "The signed-in identity", Tag: "auth", Response: meResponse{},
+	})
+
+	// Personal API tokens, managed from a browser session
+	h.route("GET", "/tokens", h.listTokens, router.Doc{
+		Summary: "List your API tokens", Tag: "tokens", Auth: true, Response: []*services.APITokenInfo{},
+	})
+	h.route("POST", "/tokens", h.createToken, router.Doc{
+		Summary: "Create an API token", Tag: "tokens", Auth: true, Status: http.StatusCreated,
+		Description: "Scopes are read, run and submit. Tokens cannot manage tokens.",
+		Request:     v1CreateTokenRequest{}, Response: v1CreatedToken{},
+	})
+	h.route("DELETE", "/tokens/{id}", h.revokeToken, router.Doc{
+		Summary: "Revoke an API token", Tag: "tokens", Auth: true, Response: v1RevokedToken{},
+	})
+
+	// Classic challenges
+	h.route("GET", "/challenges", h.listChallenges, router.Doc{
+		Summary: "List classic challenges", Tag: "challenges", Response: []*models.Challenge{},
+	})
+	h.route("GET", "/challenges/{id}", h.getChallenge, router.Doc{
+		Summary: "Get a classic challenge", Tag: "challenges", Path: challengeIDParam, Response: models.Challenge{},
+	})
+	h.route("GET", "/challenges/{id}/scoreboard", h.challengeScoreboard, router.Doc{
+		Summary: "A challenge's scoreboard", Tag: "challenges", Path: challengeIDParam, Response: []models.ScoreboardEntry{},
+	})
+	h.route("POST", "/challenges/{id}/runs", h.runChallenge, router.Doc{
+		Summary: "Run code against a challenge's tests", Tag: "challenges", Scope: services.ScopeRun,
+		Path: challengeIDParam, Request: v1CodeRequest{}, Response: services.ExecutionResult{},
+	})
+	h.route("POST", "/challenges/{id}/submissions", h.submitChallenge, router.Doc{
+		Summary: "Submit a solution", Tag: "challenges", Auth: true, Scope: services.ScopeSubmit, Status: http.StatusCreated,
+		Path: challengeIDParam, Request: v1CodeRequest{}, Response: models.Submission{},
+	})
+	h.route("POST", "/challenges/{id}/save", h.saveChallenge, router.Doc{
+		Summary: "Save a solution to the repository", Tag: "challenges", Auth: true, Scope: services.ScopeSubmit,
+		Path: challengeIDParam, Request: v1SaveRequest{}, Response: services.SaveSubmissionResponse{},
+	})
+	h.route("GET", "/challenges/{id}/hints", h.challengeHints, router.Doc{
+		Summary: "Revealed hints and what comes next", Tag: "hints", Scope: services.ScopeRead, Path: challengeIDParam,
+		Query:    []router.Parameter{{Name: "interviewId", Description: "Use the interview's hint policy"}},
+		Response: services.HintProgress{},
+	})
+	h.route("POST", "/challenges/{id}/hints/next", h.nextChallengeHint, router.Doc{
+		Summary: "Reveal the next hint", Tag: "hints", Scope: services.ScopeRun, Path: challengeIDParam,
+		Request: v1NextHintRequest{}, Response: v1NextHint{},
+	})
+	h.route("GET", "/submissions", h.listSubmissions, router.Doc{
+		Summary: "Your submissions since the server started", Tag: "challenges", Auth: true, Scope: services.ScopeRead,
+		Response: []models.Submission{},
+	})
+
+	h.route("GET", "/search", h.searchContent, router.Doc{
+		Summary: "Search challenges, packages and learning material", Tag: "search",
+		Description: "Results match every word of q, best first, with an HTML snippet around the first match.",
+		Query:       searchParams, Response: services.SearchResults{},
+	})
+
+	// Leaderboards and progress
+	h.route("GET", "/leaderboard", h.leaderboard, router.Doc{
+		Summary: "The main leaderboard", Tag: "leaderboards", Response: v1Leaderboard{},
+	})
+	h.route("GET", "/users/{username}/rank", h.userRank, router.Doc{
+		Summary: "A user's rank on the main leaderboard", Tag: "leaderboards", Response: v1Rank{},
+	})
+	h.route("GET", "/me/attempts", h.myAttempts, router.Doc{
+		Summary: "The challenges you attempted and your scores", Tag: "leaderboards", Auth: true, Scope: services.ScopeRead,
+		Response: models.UserAttemptedChallenges{},
+	})
+
+	// Package learning paths
+	h.route("GET", "/packages", h.listPackages, router.Doc{
+		Summary: "List packages", Tag: "packages", Response: []*models.Package{},
+	})
+	h.route("GET", "/packages/{package}", h.getPackage, router.Doc{
+		Summary: "Get a package", Tag: "packages", Path: packageParams, Response: models.Package{},
+	})
+	h.route("GET", "/packages/{package}/leaderboard", h.packageLeaderboard, router.Doc{
+		Summary: "A package's leaderboard", Tag: "leaderboards", Path: packageParams, Response: v1PackageLeaderboard{},
+	})
+	h.route("GET", "/packages/{package}/challenges", h.listPackageChallenges, router.Doc{
+		Summary: "A package's challenges in learning path order", Tag: "packages", Path: packageParams,
+		Response: []*models.PackageChallenge{},
+	})
+	h.route("GET", "/packages/{package}/challenges/{challenge}", h.getPackageChallenge, router.Doc{
+		Summary: "Get a package challenge", Tag: "packages", Path: packageParams, Response: models.PackageChallenge{},
+	})
+	h.route("POST", "/packages/{package}/challenges/{challenge}/runs", h.testPackageChallenge, router.Doc{
+		Summary: "Run code against a package challenge's tests", Tag: "packages", Scope: services.ScopeRun,
+		Path: packageParams, Request: v1CodeRequest{}, Response: packageRunResult{},
+	})
+	h.route("POST", "/packages/{package}/challenges/{challenge}/submissions", h.testPackageChallenge, router.Doc{
+		Summary: "Submit a package challenge solution", Tag: "packages", Auth: true, Scope: services.ScopeSubmit,
+		Path: packageParams, Request: v1CodeRequest{}, Response: packageRunResult{},
+	})
+	h.route("POST", "/packages/{package}/challenges/{challenge}/save", h.savePackageChallenge, router.Doc{
+		Summary: "Save a package challenge solution to the repository", Tag: "packages", Auth: true, Scope: services.ScopeSubmit,
+		Path: packageParams, Request: v1SaveRequest{}, Response: services.SaveSubmissionResponse{},
+	})
+	h.route("GET", "/packages/{package}/challenges/{challenge}/hints", h.packageChallengeHints, router.Doc{
+		Summary: "Revealed hints and what comes next", Tag: "hints", Scope: services.ScopeRead, Path: packageParams,
+		Response: services.HintProgress{},
+	})
+	h.route("POST", "/packages/{package}/challenges/{challenge}/hints/next", h.nextPackageChallengeHint, router.Doc{
+		Summary: "Reveal the next hint", Tag: "hints", Scope: services.ScopeRun, Path: packageParams,
+		Request: v1NextHintRequest{}, Response: v1NextHint{},
+	})
+
+	// AI features. Requests name a classic challenge by challengeId, or a package
+	// challenge by packageName and packageChallengeId.
+	h.route("GET", "/ai/status", h.aiStatus, router.Doc{
+		Summary: "Whether AI features are available and your remaining quota", Tag: "ai", Response: aiStatusResponse{},
+	})
+	h.route("POST", "/ai/reviews", h.aiReview, router.Doc{
+		Summary: "Review code as an interviewer would", Tag: "ai", Scope: services.ScopeRun,
+		Request: v1ReviewRequest{}, Response: services.AICodeReview{},
+	})
+	h.router.Handle("POST", "/api/v1/ai/reviews/stream", h.aiReviewStream, router.Doc{
+		Summary: "Stream a code review", Tag: "ai", Scope: services.ScopeRun, Request: v1ReviewRequest{}, Stream: true,
+		Description: "Server-sent events: status, feedback ({text}), progress ({received}), then review or error.",
+	})
+	h.route("POST", "/ai/hints", h.aiHint, router.Doc{
+		Summary: "A hint at the level of your choosing", Tag: "ai", Scope: services.ScopeRun,
+		Description: "Refused when the site restricts hints; use the hint ladder instead.",
+		Request:     v1AIHintRequest{}, Response: v1AIHint{},
+	})
+	h.router.Handle("POST", "/api/v1/ai/hints/stream", h.aiHintStream, router.Doc{
+		Summary: "Stream a hint", Tag: "ai", Scope: services.ScopeRun, Request: v1AIHintRequest{}, Stream: true,
+		Description: "Server-sent events: hint ({text}) for each piece, then done ({hint, hintLevel, promptVersion}) or error.",
+	})
+	h.route("POST", "/ai/questions", h.aiQuestions, router.Doc{
+		Summary: "Follow-up interview questions about your code", Tag: "ai", Scope: services.ScopeRun,
+		Request: v1QuestionsRequest{}, Response: v1Questions{},
+	})
+	h.route("POST", "/ai/break-solution", h.aiBreakSolution, router.Doc{
+		Summary: "Look for inputs that break your solution", Tag: "ai", Scope: services.ScopeRun,
+		Request: v1ReviewRequest{}, Response: services.AdversarialReport{},
+	})
+	h.route("GET", "/admin/ai/usage", h.aiUsage, router.Doc{
+		Summary: "AI usage by day, user, task and model", Tag: "admin", Auth: true,
+		Query:    []router.Parameter{{Name: "days", Type: "integer", Description: "1 to 30, default 7"}},
+		Response: services.AIUsageReport{},
+	})
+
+	// Mock interviews
+	h.route("POST", "/interviews", h.startInterview, router.Doc{
+		Summary: "Start a mock interview", Tag: "interviews", Scope: services.ScopeRun, Status: http.StatusCreated,
+		Request: v1StartInterviewRequest{}, Response: v1Interview{},
+	})
+	h.route("GET", "/interviews/{id}", h.getInterview, router.Doc{
+		Summary: "Get a mock interview", Tag: "interviews", Scope: services.ScopeRead, Path: interviewIDParam,
+		Response: v1Interview{},
+	})
+	h.route("POST", "/interviews/{id}/answers", h.answerInterview, router.Doc{
+		Summary: "Answer the current question", Tag: "interviews", Scope: services.ScopeRun, Path: interviewIDParam,
+		Request: v1AnswerRequest{}, Response: v1Interview{},
+	})
+	h.route("POST", "/interviews/{id}/runs", h.recordInterviewRun, router.Doc{
+		Summary: "Record a test run during the interview", Tag: "interviews", Scope: services.ScopeRun, Path: interviewIDParam,
+		Request: v1InterviewRunRequest{}, Response: v1Interview{},
+	})
+	h.route("POST", "/interviews/{id}/finish", h.finishInterview, router.Doc{
+		Summary: "Finish and evaluate the interview", Tag: "interviews", Scope: services.ScopeRun, Path: interviewIDParam,
+		Request: v1CodeRequest{}, Response: v1Interview{},
+	})
+}
+
+// openAPIDocument serves the generated OpenAPI document
+func (h *V1Handler) openAPIDocument(w http.ResponseWriter, r *http.Request) {
+	if h.openAPI == nil {
+		writeError(w, newAPIError(http.StatusInternalServerError, CodeInternal, "The OpenAPI document could not be generated"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.openAPI)
+}
+
+func (h *V1Handler) me(r *http.Request) (interface{}, *apiError) {
+	return h.auth.identity(r), nil
+}
+
+func (h *V1Handler) listTokens(r *http.Request) (interface{}, *apiError) {
+	username, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.tokens.tokenService.List(username), nil
+}
+
+func (h *V1Handler) createToken(r *http.Request) (interface{}, *apiError) {
+	username, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1CreateTokenRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	secret, info, createErr := h.tokens.tokenService.Create(username, services.CreateTokenRequest{
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		RateLimit: request.RateLimit,
+	})
+	if createErr != nil {
+		return nil, badRequest(createErr.Error())
+	}
+	return v1CreatedToken{Token: secret, Info: info}, nil
+}
+
+func (h *V1Handler) revokeToken(r *http.Request) (interface{}, *apiError) {
+	username, err := sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+	id := router.PathParam(r, "id")
+	if revokeErr := h.tokens.tokenService.Revoke(username, id); revokeErr != nil {
+		if revokeErr == services.ErrTokenNotFound {
+			return nil, notFound("Token not found")
+		}
+		return nil, newAPIError(http.StatusInternalServerError, CodeInternal, "Failed to revoke token")
+	}
+	return v1RevokedToken{ID: id}, nil
+}
+
+// challengeParam loads the classic challenge named by the {id} path parameter
+func (h *V1Handler) challengeParam(r *http.Request) (*models.Challenge, *apiError) {
+	id, err := strconv.Atoi(router.PathParam(r, "id"))
+	if err != nil {
+		return nil, badRequest("Invalid challenge ID")
+	}
+	challenge, exists := h.api.challengeService.GetChallenge(id)
+	if !exists {
+		return nil, notFound("Challenge not found")
+	}
+	return challenge, nil
+}
+
+func (h *V1Handler) listChallenges(r *http.Request) (interface{}, *apiError) {
+	challenges := make([]*models.Challenge, 0)
+	for _, challenge := range h.api.challengeService.GetChallenges() {
+		challenges = append(challenges, challenge)
+	}
+	sort.Slice(challenges, func(i, j int) bool { return challenges[i].ID < challenges[j].ID })
+	return challenges, nil
+}
+
+func (h *V1Handler) getChallenge(r *http.Request) (interface{}, *apiError) {
+	return h.challengeParam(r)
+}
+
+func (h *V1Handler) challengeScoreboard(r *http.Request) (interface{}, *apiError) {
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	scoreboard, exists := h.api.scoreboardService.GetScoreboard(challenge.ID)
+	if !exists {
+		scoreboard = []models.ScoreboardEntry{}
+	}
+	return scoreboard, nil
+}
+
+func (h *V1Handler) runChallenge(r *http.Request) (interface{}, *apiError) {
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1CodeRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return h.api.executionService.RunCode(r.Context(), request.Code, challenge), nil
+}
+
+func (h *V1Handler) submitChallenge(r *http.Request) (interface{}, *apiError) {
+	username := currentUsername(r)
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1CodeRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return h.api.submit(r.Context(), username, challenge, request.Code), nil
+}
+
+func (h *V1Handler) saveChallenge(r *http.Request) (interface{}, *apiError) {
+	username := currentUsername(r)
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1SaveRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return h.api.saveSubmission(services.SaveSubmissionRequest{
+		Username:    username,
+		ChallengeID: challenge.ID,
+		Code:        request.Code,
+		Commit:      request.Commit,
+		DryRun:      request.DryRun,
+	}), nil
+}
+
+func (h *V1Handler) listSubmissions(r *http.Request) (interface{}, *apiError) {
+	return h.api.userSubmissions(currentUsername(r)), nil
+}
+
+func (h *V1Handler) challengeHints(r *http.Request) (interface{}, *apiError) {
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.hintProgress(r, services.ClassicChallengeContext(challenge))
+}
+
+func (h *V1Handler) nextChallengeHint(r *http.Request) (interface{}, *apiError) {
+	challenge, err := h.challengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.nextHint(r, services.ClassicChallengeContext(challenge))
+}
+
+// hintProgress returns the hints revealed so far for the challenge
+func (h *V1Handler) hintProgress(r *http.Request, challenge *services.ChallengeContext) (interface{}, *apiError) {
+	policy, _, err := h.hints.policyFor(r, challenge, r.URL.Query().Get("interviewId"))
+	if err != nil {
+		return nil, err
+	}
+	return h.hints.hintService.Progress(services.AIUserFromContext(aiContext(r)), challenge, policy), nil
+}
+
+// nextHint reveals the next hint on the challenge's ladder
+func (h *V1Handler) nextHint(r *http.Request, challenge *services.ChallengeContext) (interface{}, *apiError) {
+	var request v1NextHintRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	policy, interviewID, err := h.hints.policyFor(r, challenge, request.InterviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := aiContext(r)
+	hint, nextErr := h.hints.hintService.Next(ctx, challenge, request.Code, policy, interviewID)
+	if nextErr != nil {
+		return nil, hintAPIError(nextErr)
+	}
+	if interviewID != "" {
+		if _, err := h.interviews.interviewService.RecordHint(currentUsername(r), interviewID, hint); err != nil {
+			slog.WarnContext(r.Context(), "Could not record hint in interview", "interview", interviewID, "error", err)
+		}
+	}
+	return v1NextHint{
+		Hint:     hint,
+		Progress: h.hints.hintService.Progress(services.AIUserFromContext(ctx), challenge, policy),
+	}, nil
+}
+
+func (h *V1Handler) searchContent(r *http.Request) (interface{}, *apiError) {
+	query, err := searchQuery(r.URL.Query())
+	if err != nil {
+		return nil, badRequest(err.Error())
+	}
+	return h.search.searchService.Search(query), nil
+}
+
+func (h *V1Handler) leaderboard(r *http.Request) (interface{}, *apiError) {
+	return v1Leaderboard{
+		Leaderboard:     h.api.calculateMainLeaderboard(),
+		TotalChallenges: len(h.api.challengeService.GetChallenges()),
+	}, nil
+}
+
+func (h *V1Handler) userRank(r *http.Request) (interface{}, *apiError) {
+	username := router.PathParam(r, "username")
+	return v1Rank{Username: username, Rank: h.api.calculateMainScoreboardRank(username)}, nil
+}
+
+func (h *V1Handler) myAttempts(r *http.Request) (interface{}, *apiError) {
+	username := currentUsername(r)
+	return h.api.userService.RefreshUserAttempts(username, h.api.challengeService.GetChallenges()), nil
+}
+
+// packageParam loads the package named by the {package} path parameter
+func (h *V1Handler) packageParam(r *http.Request) (*models.Package, *apiError) {
+	name := router.PathParam(r, "package")
+	if !isSafePathComponent(name) {
+		return nil, badRequest("Invalid package name")
+	}
+	pkg, err := h.api.packageService.GetPackage(name)
+	if err != nil {
+		return nil, notFound("Package not found")
+	}
+	return pkg, nil
+}
+
+// packageChallengeParam loads the challenge named by the {package} and {challenge} path parameters
+func (h *V1Handler) packageChallengeParam(r *http.Request) (*services.ChallengeContext, *models.PackageChallenge, *apiError) {
+	packageName, challengeID := router.PathParam(r, "package"), router.PathParam(r, "challenge")
+	if !isSafePathComponent(packageName) || !isSafePathComponent(challengeID) {
+		return nil, nil, badRequest("Invalid package challenge reference")
+	}
+	challenge, err := h.api.packageService.GetPackageChallenge(packageName, challengeID)
+	if err != nil {
+		return nil, nil, notFound("Challenge not found")
+	}
+	return services.PackageChallengeContext(packageName, challenge), challenge, nil
+}
+
+func (h *V1Handler) listPackages(r *http.Request) (interface{}, *apiError) {
+	packages := make([]*models.Package, 0)
+	for _, pkg := range h.api.packageService.GetPackages() {
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages, nil
+}
+
+func (h *V1Handler) getPackage(r *http.Request) (interface{}, *apiError) {
+	return h.packageParam(r)
+}
+
+func (h *V1Handler) listPackageChallenges(r *http.Request) (interface{}, *apiError) {
+	pkg, err := h.packageParam(r)
+	if err != nil {
+		return nil, err
+	}
+	challenges := h.api.learningPathChallenges(pkg)
+	if challenges == nil {
+		challenges = []*models.PackageChallenge{}
+	}
+	return challenges, nil
+}
+
+func (h *V1Handler) getPackageChallenge(r *http.Request) (interface{}, *apiError) {
+	_, challenge, err := h.packageChallengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (h *V1Handler) packageLeaderboard(r *http.Request) (interface{}, *apiError) {
+	pkg, err := h.packageParam(r)
+	if err != nil {
+		return nil, err
+	}
+	challenges := h.api.learningPathChallenges(pkg)
+	leaderboard := h.api.createPackageLeaderboard(pkg.Name, challenges)
+	if leaderboard == nil {
+		leaderboard = []models.PackageScoreboardEntry{}
+	}
+	return v1PackageLeaderboard{
+		Package:         pkg.Name,
+		DisplayName:     pkg.DisplayName,
+		TotalChallenges: len(challenges),
+		Leaderboard:     leaderboard,
+	}, nil
+}
+
+// testPackageChallenge runs the request's code against the package challenge's tests
+func (h *V1Handler) testPackageChallenge(r *http.Request) (interface{}, *apiError) {
+	_, challenge, err := h.packageChallengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1CodeRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	if request.Code == "" {
+		return nil, badRequest("Code is required")
+	}
+	return h.api.runPackageChallenge(r.Context(), challenge, request.Code), nil
+}
+
+func (h *V1Handler) savePackageChallenge(r *http.Request) (interface{}, *apiError) {
+	username := currentUsername(r)
+	_, challenge, err := h.packageChallengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	var request v1SaveRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return h.api.savePackageSubmission(packageSaveRequest{
+		Username:    username,
+		PackageName: router.PathParam(r, "package"),
+		ChallengeID: challenge.ID,
+		Code:        request.Code,
+		Commit:      request.Commit,
+		DryRun:      request.DryRun,
+	}), nil
+}
+
+func (h *V1Handler) packageChallengeHints(r *http.Request) (interface{}, *apiError) {
+	challenge, _, err := h.packageChallengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.hintProgress(r, challenge)
+}
+
+func (h *V1Handler) nextPackageChallengeHint(r *http.Request) (interface{}, *apiError) {
+	challenge, _, err := h.packageChallengeParam(r)
+	if err != nil {
+		return nil, err
+	}
+	return h.nextHint(r, challenge)
+}
+
+func (h *V1Handler) aiStatus(r *http.Request) (interface{}, *apiError) {
+	return h.api.aiStatus(r), nil
+}
+
+// aiRequest decodes the body into request and loads the challenge it names
+func (h *V1Handler) aiRequest(r *http.Request, request interface{}, ref *aiChallengeRef) (*services.ChallengeContext, *apiError) {
+	if err := decodeJSON(r, request); err != nil {
+		return nil, err
+	}
+	return lookupChallengeContext(*ref, h.api.challengeService, h.api.packageService)
+}
+
+func (h *V1Handler) aiReview(r *http.Request) (interface{}, *apiError) {
+	var request v1ReviewRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		return nil, err
+	}
+	review, reviewErr := h.api.aiService.ReviewCode(aiContext(r), request.Code, challenge, request.Context)
+	if reviewErr != nil {
+		return nil, aiAPIError(reviewErr)
+	}
+	return review, nil
+}
+
+// aiReviewStream streams a code review; errors before the stream starts use the envelope
+func (h *V1Handler) aiReviewStream(w http.ResponseWriter, r *http.Request) {
+	var request v1ReviewRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	stream, ok := newSSEStream(w)
+	if !ok {
+		return
+	}
+	stream.Send("status", map[string]string{"text": "Running tests, go vet and coverage..."})
+
+	review, reviewErr := h.api.aiService.StreamCodeReview(aiContext(r), request.Code, challenge, request.Context,
+		func(text string) error {
+			return stream.Send("feedback", map[string]string{"text": text})
+		},
+		func(received int) error {
+			return stream.Send("progress", map[string]int{"received": received})
+		},
+	)
+	if reviewErr != nil {
+		stream.SendAIError(reviewErr)
+		return
+	}
+	stream.Send("review", v1Value(reflect.ValueOf(review)))
+}
+
+func (h *V1Handler) aiHint(r *http.Request) (interface{}, *apiError) {
+	if err := h.api.freeHintsError(); err != nil {
+		return nil, err
+	}
+	var request v1AIHintRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		return nil, err
+	}
+	if request.HintLevel < 1 || request.HintLevel > 4 {
+		request.HintLevel = 1
+	}
+	hint, promptVersion, hintErr := h.api.aiService.GetCodeHint(aiContext(r), request.Code, challenge, request.HintLevel)
+	if hintErr != nil {
+		return nil, aiAPIError(hintErr)
+	}
+	return v1AIHint{Hint: hint, HintLevel: request.HintLevel, PromptVersion: promptVersion}, nil
+}
+
+// aiHintStream streams a hint; errors before the stream starts use the envelope
+func (h *V1Handler) aiHintStream(w http.ResponseWriter, r *http.Request) {
+	if err := h.api.freeHintsError(); err != nil {
+		writeError(w, err)
+		return
+	}
+	var request v1AIHintRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if request.HintLevel < 1 || request.HintLevel > 4 {
+		request.HintLevel = 1
+	}
+
+	stream, ok := newSSEStream(w)
+	if !ok {
+		return
+	}
+	hint, promptVersion, hintErr := h.api.aiService.StreamCodeHint(aiContext(r), request.Code, challenge, request.HintLevel, func(text string) error {
+		return stream.Send("hint", map[string]string{"text": text})
+	})
+	if hintErr != nil {
+		stream.SendAIError(hintErr)
+		return
+	}
+	stream.Send("done", v1AIHint{Hint: hint, HintLevel: request.HintLevel, PromptVersion: promptVersion})
+}
+
+func (h *V1Handler) aiQuestions(r *http.Request) (interface{}, *apiError) {
+	var request v1QuestionsRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		return nil, err
+	}
+	questions, promptVersion, questionsErr := h.api.aiService.GetInterviewerQuestions(aiContext(r), request.Code, challenge, request.UserProgress)
+	if questionsErr != nil {
+		return nil, aiAPIError(questionsErr)
+	}
+	return v1Questions{Questions: questions, PromptVersion: promptVersion}, nil
+}
+
+func (h *V1Handler) aiBreakSolution(r *http.Request) (interface{}, *apiError) {
+	var request v1ReviewRequest
+	challenge, err := h.aiRequest(r, &request, &request.aiChallengeRef)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(request.Code) == "" {
+		return nil, badRequest("Code is required")
+	}
+	report, breakErr := h.api.aiService.BreakSolution(aiContext(r), request.Code, challenge, currentUsername(r))
+	if breakErr == services.ErrNoReferenceSolution {
+		return nil, newAPIError(http.StatusNotFound, CodeNoReference,
+			"There is no known-passing solution for this challenge to compare against yet")
+	}
+	if breakErr != nil {
+		return nil, aiAPIError(breakErr)
+	}
+	return report, nil
+}
+
+func (h *V1Handler) aiUsage(r *http.Request) (interface{}, *apiError) {
+	if _, err := adminUser(r); err != nil {
+		return nil, err
+	}
+	usageService := h.api.aiService.UsageService()
+	if usageService == nil {
+		return nil, notFound("AI usage is not being recorded")
+	}
+	days := 7
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 30 {
+			return nil, badRequest("days must be between 1 and 30")
+		}
+		days = parsed
+	}
+	report := usageService.Report(days)
+	report.Cache = h.api.aiService.CacheStats()
+	return report, nil
+}
+
+// interviewResult wraps a session in the interview response, or classifies the error
+func interviewResult(session *services.InterviewSession, err error) (interface{}, *apiError) {
+	if err != nil {
+		return nil, interviewAPIError(err)
+	}
+	return v1Interview{Session: session, Rubric: services.InterviewRubric}, nil
+}
+
+func (h *V1Handler) startInterview(r *http.Request) (interface{}, *apiError) {
+	var request v1StartInterviewRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	hintPolicy := h.interviews.hintService.DefaultPolicy()
+	if request.HintPolicy != nil {
+		hintPolicy = *request.HintPolicy
+	}
+	return interviewResult(h.interviews.interviewService.Start(aiContext(r), currentUsername(r), request.ChallengeID, request.Code, hintPolicy))
+}
+
+func (h *V1Handler) getInterview(r *http.Request) (interface{}, *apiError) {
+	return interviewResult(h.interviews.interviewService.Get(currentUsername(r), router.PathParam(r, "id")))
+}
+
+func (h *V1Handler) answerInterview(r *http.Request) (interface{}, *apiError) {
+	var request v1AnswerRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return interviewResult(h.interviews.interviewService.Answer(aiContext(r), currentUsername(r), router.PathParam(r, "id"),
+		strings.TrimSpace(request.Answer), request.Code))
+}
+
+func (h *V1Handler) recordInterviewRun(r *http.Request) (interface{}, *apiError) {
+	var request v1InterviewRunRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return interviewResult(h.interviews.interviewService.RecordRun(currentUsername(r), router.PathParam(r, "id"), request.Code, request.Run))
+}
+
+func (h *V1Handler) finishInterview(r *http.Request) (interface{}, *apiError) {
+	var request v1CodeRequest
+	if err := decodeJSON(r, &request); err != nil {
+		return nil, err
+	}
+	return interviewResult(h.interviews.interviewService.Finish(aiContext(r), currentUsername(r), router.PathParam(r, "id"), request.Code))
+}
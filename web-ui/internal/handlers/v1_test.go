@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"web-ui/internal/services"
+)
+
+func TestV1RoutesEnforceDocScope(t *testing.T) {
+	// The handlers have no services; a request that got past the router would panic
+	h := NewV1Handler(nil, nil, nil, nil, nil, nil)
+	readOnly := &services.Identity{Username: "alice", Token: &services.APIToken{Username: "alice", Scopes: []string{services.ScopeRead}}}
+	runner := &services.Identity{Username: "alice", Token: &services.APIToken{Username: "alice", Scopes: []string{services.ScopeRead, services.ScopeRun}}}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		identity *services.Identity
+		status   int
+	}{
+		{"run without the run scope", "POST", "/api/v1/challenges/1/runs", readOnly, http.StatusForbidden},
+		{"submit anonymously", "POST", "/api/v1/challenges/1/submissions", nil, http.StatusUnauthorized},
+		{"submit without the submit scope", "POST", "/api/v1/challenges/1/submissions", runner, http.StatusForbidden},
+		{"package submit without the submit scope", "POST", "/api/v1/packages/gin/challenges/challenge-1/submissions", runner, http.StatusForbidden},
+		{"submissions anonymously", "GET", "/api/v1/submissions", nil, http.StatusUnauthorized},
+		{"next hint without the run scope", "POST", "/api/v1/challenges/1/hints/next", readOnly, http.StatusForbidden},
+		{"AI review stream without the run scope", "POST", "/api/v1/ai/reviews/stream", readOnly, http.StatusForbidden},
+		{"interview answer without the run scope", "POST", "/api/v1/interviews/0123456789abcdef0123456789abcdef/answers", readOnly, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.identity != nil {
+				r = r.WithContext(services.WithIdentity(r.Context(), tt.identity))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d", w.Code, tt.status)
+			}
+			var envelope errorEnvelope
+			if err := json.NewDecoder(w.Body).Decode(&envelope); err != nil || envelope.Error == nil {
+				t.Fatalf("error is not in the envelope: %v", err)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package handlers
 import (
 	"embed"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
@@ -25,6 +25,7 @@ type WebHandler struct {
 	scoreboardService *services.ScoreboardService
 	userService       *services.UserService
 	packageService    *services.PackageService
+	sponsorService    *services.SponsorService
 }
 
 // NewWebHandler creates a new web handler
@@ -34,6 +35,7 @@ func NewWebHandler(
 	scoreboardService *services.ScoreboardService,
 	userService *services.UserService,
 	packageService *services.PackageService,
+	sponsorService *services.SponsorService,
 ) *WebHandler {
 	return &WebHandler{
 		content:           content,
@@ -41,6 +43,7 @@ func NewWebHandler(
 		scoreboardService: scoreboardService,
 		userService:       userService,
 		packageService:    packageService,
+		sponsorService:    sponsorService,
 	}
 }
 
@@ -53,7 +56,7 @@ func (h *WebHandler) HomePage(w http.ResponseWriter, r *http.Request) {
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/home.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -86,8 +89,8 @@ func (h *WebHandler) HomePage(w http.ResponseWriter, r *http.Request) {
 		return packagesList[i].Stars > packagesList[j].Stars
 	})
 
-	// Get the username from cookie if available
-	username := h.getUsernameFromCookie(r)
+	// Get the username from the authenticated session
+	username := currentUsername(r)
 
 	// Get user attempts if username is set
 	var userAttempt *models.UserAttemptedChallenges
@@ -132,7 +135,7 @@ func (h *WebHandler) HomePage(w http.ResponseWriter, r *http.Request) {
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
@@ -153,18 +156,8 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get username from cookie first
-	username := h.getUsernameFromCookie(r)
-
-	// If no username from cookie, try to get it from Git config
-	if username == "" {
-		gitInfo := utils.GetGitUsername()
-		if gitInfo.Username != "" {
-			username = gitInfo.Username
-			// Set the cookie for future requests
-			h.setUsernameCookie(w, username)
-		}
-	}
+	// Get username from the authenticated session
+	username := currentUsername(r)
 
 	existingSolution := ""
 	hasAttempted := false
@@ -178,7 +171,7 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/challenge.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -197,7 +190,7 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
@@ -206,7 +199,7 @@ func (h *WebHandler) ChallengePage(w http.ResponseWriter, r *http.Request) {
 func (h *WebHandler) ScoreboardPage(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/scoreboard.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -225,7 +218,7 @@ func (h *WebHandler) ScoreboardPage(w http.ResponseWriter, r *http.Request) {
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
@@ -250,7 +243,7 @@ func (h *WebHandler) ScoreChallengeHandler(w http.ResponseWriter, r *http.Reques
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/challenge_scoreboard.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -265,7 +258,7 @@ func (h *WebHandler) ScoreChallengeHandler(w http.ResponseWriter, r *http.Reques
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
@@ -306,7 +299,7 @@ func (h *WebHandler) PackageScoreboardPage(w http.ResponseWriter, r *http.Reques
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/package_scoreboard.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -323,7 +316,7 @@ func (h *WebHandler) PackageScoreboardPage(w http.ResponseWriter, r *http.Reques
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 	}
 }
 
@@ -331,7 +324,7 @@ func (h *WebHandler) PackageScoreboardPage(w http.ResponseWriter, r *http.Reques
 func (h *WebHandler) InterviewPage(w http.ResponseWriter, r *http.Request) {
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/interview.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -342,8 +335,8 @@ func (h *WebHandler) InterviewPage(w http.ResponseWriter, r *http.Request) {
 		challengeList = append(challengeList, challenge)
 	}
 
-	// Get username from cookie if available
-	username := h.getUsernameFromCookie(r)
+	// Get username from the authenticated session
+	username := currentUsername(r)
 
 	data := struct {
 		Challenges []*models.Challenge
@@ -355,34 +348,11 @@ func (h *WebHandler) InterviewPage(w http.ResponseWriter, r *http.Request) {
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
 
-// getUsernameFromCookie retrieves the username from cookie
-func (h *WebHandler) getUsernameFromCookie(r *http.Request) string {
-	cookie, err := r.Cookie("username")
-	if err != nil {
-		return ""
-	}
-	return cookie.Value
-}
-
-// setUsernameCookie sets the username cookie
-func (h *WebHandler) setUsernameCookie(w http.ResponseWriter, username string) {
-	// Cookie expires in 30 days
-	expiration := time.Now().Add(30 * 24 * time.Hour)
-	cookie := http.Cookie{
-		Name:     "username",
-		Value:    username,
-		Expires:  expiration,
-		Path:     "/",
-		HttpOnly: false, // Allow JavaScript to access it
-	}
-	http.SetCookie(w, &cookie)
-}
-
 // PackageDetailPage renders the package detail page
 func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 	// Extract package name from URL: /packages/gin
@@ -397,7 +367,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 	// Get package data
 	pkg, err := h.packageService.GetPackage(packageName)
 	if err != nil {
-		log.Printf("Package not found: %v", err)
+		slog.InfoContext(r.Context(), "Package not found", "error", err)
 		http.NotFound(w, r)
 		return
 	}
@@ -405,7 +375,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 	// Get challenges for this package
 	challengesMap, err := h.packageService.GetPackageChallenges(packageName)
 	if err != nil {
-		log.Printf("Error getting package challenges: %v", err)
+		slog.ErrorContext(r.Context(), "Could not get package challenges", "error", err)
 		challengesMap = make(map[string]*models.PackageChallenge)
 	}
 
@@ -428,13 +398,13 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/package_detail.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Get the username from cookie if available
-	username := h.getUsernameFromCookie(r)
+	// Get the username from the authenticated session
+	username := currentUsername(r)
 
 	// Check which package challenges the user has attempted
 	packageAttempts := make(map[string]bool)
@@ -489,7 +459,7 @@ func (h *WebHandler) PackageDetailPage(w http.ResponseWriter, r *http.Request) {
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		http.Error(w, "Failed to execute template: "+err.Error(), http.StatusInternalServerError)
 	}
 }
@@ -509,7 +479,7 @@ func (h *WebHandler) PackageChallengePage(w http.ResponseWriter, r *http.Request
 	// Get package data
 	pkg, err := h.packageService.GetPackage(packageName)
 	if err != nil {
-		log.Printf("Package not found: %v", err)
+		slog.InfoContext(r.Context(), "Package not found", "error", err)
 		http.Error(w, "Package not found", http.StatusNotFound)
 		return
 	}
@@ -517,30 +487,20 @@ func (h *WebHandler) PackageChallengePage(w http.ResponseWriter, r *http.Request
 	// Get challenge data
 	challenge, err := h.packageService.GetPackageChallenge(packageName, challengeID)
 	if err != nil {
-		log.Printf("Challenge not found: %v", err)
+		slog.InfoContext(r.Context(), "Challenge not found", "error", err)
 		http.Error(w, "Challenge not found", http.StatusNotFound)
 		return
 	}
 
 	tmpl, err := template.New("").Funcs(utils.GetTemplateFuncs()).ParseFS(h.content, "templates/base.html", "templates/package_challenge.html")
 	if err != nil {
-		log.Printf("Template error: %v", err)
+		slog.ErrorContext(r.Context(), "Template error", "error", err)
 		http.Error(w, "Failed to parse template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Get username from cookie first
-	username := h.getUsernameFromCookie(r)
-
-	// If no username from cookie, try to get it from Git config
-	if username == "" {
-		gitInfo := utils.GetGitUsername()
-		if gitInfo.Username != "" {
-			username = gitInfo.Username
-			// Set the cookie for future requests
-			h.setUsernameCookie(w, username)
-		}
-	}
+	// Get username from the authenticated session
+	username := currentUsername(r)
 
 	// Check if user has attempted this challenge
 	hasAttempted := false
@@ -568,21 +528,25 @@ func (h *WebHandler) PackageChallengePage(w http.ResponseWriter, r *http.Request
 
 	err = tmpl.ExecuteTemplate(w, "base", data)
 	if err != nil {
-		log.Printf("Template execution error: %v", err)
+		slog.ErrorContext(r.Context(), "Template execution error", "error", err)
 		// Don't call http.Error here since headers may already be sent during template execution
 	}
 }
 
 // hasUserAttemptedPackageChallenge checks if a user has attempted a package challenge
 func (h *WebHandler) hasUserAttemptedPackageChallenge(username, packageName, challengeID string) bool {
-	// Check if submission file exists in ../packages/{packageName}/{challengeID}/submissions/{username}/solution.go
-	submissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution.go")
+	if !services.ValidUsername(username) {
+		return false
+	}
+
+	// Check if submission file exists in packages/{packageName}/{challengeID}/submissions/{username}/solution.go
+	submissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution.go")
 	if _, err := os.Stat(submissionPath); err == nil {
 		return true
 	}
 
 	// Try alternative path in case of different file naming
-	altSubmissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution-template.go")
+	altSubmissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution-template.go")
 	if _, err := os.Stat(altSubmissionPath); err == nil {
 		return true
 	}
@@ -592,19 +556,19 @@ func (h *WebHandler) hasUserAttemptedPackageChallenge(username, packageName, cha
 
 // getUserPackageChallengeSolution retrieves a user's existing solution for a package challenge
 func (h *WebHandler) getUserPackageChallengeSolution(username, packageName, challengeID string) string {
-	if username == "" {
+	if !services.ValidUsername(username) {
 		return ""
 	}
 
 	// Try solution.go first
-	submissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution.go")
+	submissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution.go")
 	content, err := ioutil.ReadFile(submissionPath)
 	if err == nil {
 		return string(content)
 	}
 
 	// Try solution-template.go as fallback
-	altSubmissionPath := filepath.Join("..", "packages", packageName, challengeID, "submissions", username, "solution-template.go")
+	altSubmissionPath := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions", username, "solution-template.go")
 	content, err = ioutil.ReadFile(altSubmissionPath)
 	if err == nil {
 		return string(content)
@@ -615,7 +579,7 @@ func (h *WebHandler) getUserPackageChallengeSolution(username, packageName, chal
 
 // countPackageChallengeSubmissions counts the number of submissions for a package challenge
 func (h *WebHandler) countPackageChallengeSubmissions(packageName, challengeID string) int {
-	submissionsDir := filepath.Join("..", "packages", packageName, challengeID, "submissions")
+	submissionsDir := filepath.Join(h.packageService.ChallengeDir(packageName, challengeID), "submissions")
 
 	// Check if submissions directory exists
 	if _, err := os.Stat(submissionsDir); os.IsNotExist(err) {
@@ -651,15 +615,13 @@ func (h *WebHandler) countPackageChallengeSubmissions(packageName, challengeID s
 func (h *WebHandler) createPackageLeaderboard(packageName string, challenges []*models.PackageChallenge) []models.PackageScoreboardEntry {
 	var leaderboard []models.PackageScoreboardEntry
 	userStats := make(map[string]*userPackageStats)
-	
-	// Load sponsors for package leaderboard (reuse from API handler)
-	// Create a temporary API handler instance to access LoadSponsors
-	tempHandler := &APIHandler{}
-	sponsors := tempHandler.LoadSponsors()
+
+	// Load sponsors for package leaderboard
+	sponsors := h.sponsorService.Sponsors()
 
 	// Collect submission data for each challenge
 	for _, challenge := range challenges {
-		submissionsDir := filepath.Join("..", "packages", packageName, challenge.ID, "submissions")
+		submissionsDir := filepath.Join(h.packageService.ChallengeDir(packageName, challenge.ID), "submissions")
 
 		// Check if submissions directory exists
 		if _, err := os.Stat(submissionsDir); os.IsNotExist(err) {
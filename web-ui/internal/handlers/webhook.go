@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"web-ui/internal/metrics"
+	"web-ui/internal/services"
+)
+
+// WebhookHandler receives GitHub webhooks and publishes them on the event bus
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	events         *services.EventBus
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService *services.WebhookService, events *services.EventBus) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		events:         events,
+	}
+}
+
+// GitHubWebhookHandler verifies a GitHub delivery's X-Hub-Signature-256, skips deliveries
+// already seen by their X-GitHub-Delivery ID and queues push, pull_request and sponsorship
+// events for their subscribers. It answers before the events are handled.
+func (h *WebhookHandler) GitHubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	log := slog.With("event", eventType, "delivery", delivery)
+
+	if !h.webhookService.Enabled() {
+		metrics.WebhookDeliveries.Inc(eventType, "disabled")
+		http.Error(w, "Webhooks are disabled: GITHUB_WEBHOOK_SECRET is not set", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+	if !h.webhookService.VerifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		metrics.WebhookDeliveries.Inc(eventType, "invalid_signature")
+		log.WarnContext(r.Context(), "Rejected webhook with an invalid signature")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if eventType == "" || delivery == "" {
+		http.Error(w, "Missing X-GitHub-Event or X-GitHub-Delivery header", http.StatusBadRequest)
+		return
+	}
+
+	if eventType == "ping" {
+		metrics.WebhookDeliveries.Inc(eventType, "ok")
+		writeWebhookStatus(w, http.StatusOK, "pong")
+		return
+	}
+
+	payload, err := services.ParseWebhookEvent(eventType, body)
+	if err != nil {
+		metrics.WebhookDeliveries.Inc(eventType, "invalid_payload")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload == nil {
+		metrics.WebhookDeliveries.Inc(eventType, "ignored")
+		writeWebhookStatus(w, http.StatusOK, "ignored")
+		return
+	}
+
+	if !h.webhookService.Remember(delivery) {
+		metrics.WebhookDeliveries.Inc(eventType, "duplicate")
+		log.InfoContext(r.Context(), "Skipped duplicate webhook delivery")
+		writeWebhookStatus(w, http.StatusOK, "duplicate")
+		return
+	}
+	if err := h.events.Publish(services.Event{Type: eventType, Delivery: delivery, Payload: payload}); err != nil {
+		h.webhookService.Forget(delivery)
+		metrics.WebhookDeliveries.Inc(eventType, "error")
+		log.ErrorContext(r.Context(), "Could not queue webhook event", "error", err)
+		http.Error(w, "Too many pending events, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	metrics.WebhookDeliveries.Inc(eventType, "accepted")
+	log.InfoContext(r.Context(), "Queued webhook event")
+	writeWebhookStatus(w, http.StatusAccepted, "queued")
+}
+
+func writeWebhookStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": message})
+}
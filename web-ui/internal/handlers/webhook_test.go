@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"web-ui/internal/services"
+)
+
+func TestGitHubWebhookHandler(t *testing.T) {
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	events := services.NewEventBus()
+	received := make(chan services.Event, 10)
+	events.Subscribe(services.EventPush, func(event services.Event) { received <- event })
+	h := NewWebhookHandler(services.NewWebhookService(), events)
+
+	body := `{"ref":"refs/heads/main","after":"abc","repository":{"full_name":"o/r"}}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	valid := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	deliver := func(delivery, signature string) int {
+		r := httptest.NewRequest("POST", "/webhook/github", strings.NewReader(body))
+		r.Header.Set("X-GitHub-Event", services.EventPush)
+		r.Header.Set("X-GitHub-Delivery", delivery)
+		if signature != "" {
+			r.Header.Set("X-Hub-Signature-256", signature)
+		}
+		w := httptest.NewRecorder()
+		h.GitHubWebhookHandler(w, r)
+		return w.Code
+	}
+
+	tests := []struct {
+		name      string
+		delivery  string
+		signature string
+		status    int
+	}{
+		{"missing signature", "1", "", http.StatusUnauthorized},
+		{"invalid signature", "1", "sha256=" + strings.Repeat("0", 64), http.StatusUnauthorized},
+		{"valid", "1", valid, http.StatusAccepted},
+		{"duplicate delivery", "1", valid, http.StatusOK},
+		{"new delivery", "2", valid, http.StatusAccepted},
+		{"missing delivery ID", "", valid, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if status := deliver(tt.delivery, tt.signature); status != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	// Only the two accepted deliveries reach the subscribers
+	for _, want := range []string{"1", "2"} {
+		select {
+		case event := <-received:
+			if event.Delivery != want {
+				t.Errorf("got delivery %s, want %s", event.Delivery, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("delivery %s was not published", want)
+		}
+	}
+	select {
+	case event := <-received:
+		t.Errorf("unexpected delivery %s published", event.Delivery)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Without a secret every delivery is refused
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "")
+	h = NewWebhookHandler(services.NewWebhookService(), events)
+	if status := deliver("3", valid); status != http.StatusForbidden {
+		t.Errorf("without a secret: got status %d, want %d", status, http.StatusForbidden)
+	}
+}
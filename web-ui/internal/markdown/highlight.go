@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"go/scanner"
+	"go/token"
+	"html"
+	"strings"
+)
+
+// goTypes, goLiterals and goBuiltins are the predeclared identifiers highlight.js
+// colors in Go code
+var (
+	goTypes = map[string]bool{
+		"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true,
+		"complex128": true, "error": true, "float32": true, "float64": true, "int": true,
+		"int8": true, "int16": true, "int32": true, "int64": true, "rune": true, "string": true,
+		"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	}
+	goLiterals = map[string]bool{"true": true, "false": true, "nil": true, "iota": true}
+	goBuiltins = map[string]bool{
+		"append": true, "cap": true, "clear": true, "close": true, "complex": true, "copy": true,
+		"delete": true, "imag": true, "len": true, "make": true, "max": true, "min": true,
+		"new": true, "panic": true, "print": true, "println": true, "real": true, "recover": true,
+	}
+)
+
+// highlightGo returns Go source as HTML marked up with highlight.js classes, so it
+// is colored by the page's highlight.js theme without running highlight.js. Snippets
+// that do not compile are highlighted as far as they scan.
+func highlightGo(src string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var b strings.Builder
+	last := 0
+	// Declared function names are titled: after "func" at the start of a line,
+	// past the receiver when there is one
+	const (
+		none = iota
+		afterFunc
+		inReceiver
+		afterReceiver
+	)
+	state, parens := none, 0
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // Inserted automatically
+		}
+		offset := file.Offset(pos)
+		text := lit
+		if text == "" {
+			text = tok.String()
+		}
+		end := offset + len(text)
+		if offset < last || end > len(src) {
+			continue
+		}
+		b.WriteString(html.EscapeString(src[last:offset]))
+		last = end
+		text = src[offset:end]
+
+		class := ""
+		switch {
+		case tok == token.COMMENT:
+			class = "hljs-comment"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "hljs-string"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "hljs-number"
+		case tok.IsKeyword():
+			class = "hljs-keyword"
+		case tok == token.IDENT && (state == afterFunc || state == afterReceiver):
+			class = "hljs-title function_"
+		case tok == token.IDENT && goTypes[text]:
+			class = "hljs-type"
+		case tok == token.IDENT && goLiterals[text]:
+			class = "hljs-literal"
+		case tok == token.IDENT && goBuiltins[text]:
+			class = "hljs-built_in"
+		}
+
+		switch {
+		case tok == token.FUNC && lineStart(src, offset):
+			state = afterFunc
+		case state == afterFunc && tok == token.LPAREN:
+			state, parens = inReceiver, 1
+		case state == inReceiver && tok == token.LPAREN:
+			parens++
+		case state == inReceiver && tok == token.RPAREN:
+			if parens--; parens == 0 {
+				state = afterReceiver
+			}
+		case state != inReceiver:
+			state = none
+		}
+
+		if class == "" {
+			b.WriteString(html.EscapeString(text))
+		} else {
+			b.WriteString(`<span class="` + class + `">` + html.EscapeString(text) + "</span>")
+		}
+	}
+	b.WriteString(html.EscapeString(src[last:]))
+	return b.String()
+}
+
+// lineStart reports whether only whitespace precedes offset on its line
+func lineStart(src string, offset int) bool {
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+	return strings.TrimLeft(src[start:offset], " \t") == ""
+}
@@ -0,0 +1,511 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inlineNode is a piece of rendered inline HTML, or a run of emphasis delimiters
+// that is resolved once the whole text is read
+type inlineNode struct {
+	html     string
+	delim    byte // '*', '_' or '~' for delimiter runs
+	n, orig  int  // Delimiters left unmatched, and the run's length
+	canOpen  bool
+	canClose bool
+	opens    string // Tags opened after the run
+	closes   string // Tags closed before the run
+}
+
+var (
+	autolinkRe  = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	emailLinkRe = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	bareURLRe   = regexp.MustCompile(`^(?:https?://|www\.)[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*[^\s<]*`)
+	rawTagRe    = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--[\s\S]*?-->)`)
+	entityRe    = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// inline renders the inline Markdown of a paragraph, heading or table cell
+func (r *renderer) inline(s string) string {
+	var nodes []*inlineNode
+	var text strings.Builder // Literal text not yet escaped
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &inlineNode{html: html.EscapeString(text.String())})
+			text.Reset()
+		}
+	}
+	emit := func(h string) {
+		flush()
+		nodes = append(nodes, &inlineNode{html: h})
+	}
+	depth := len(r.html.open)
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			emit("<br>\n")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+
+		case c == '`':
+			if code, n := codeSpan(s[i:]); n > 0 {
+				emit(code)
+				i += n
+				continue
+			}
+			n := runLength(s[i:], '`')
+			text.WriteString(s[i : i+n])
+			i += n
+
+		case c == '\n':
+			line := text.String()
+			trimmed := strings.TrimRight(line, " ")
+			text.Reset()
+			text.WriteString(trimmed)
+			if len(line)-len(trimmed) >= 2 {
+				emit("<br>\n")
+			} else {
+				text.WriteByte('\n')
+			}
+			for i++; i < len(s) && (s[i] == ' ' || s[i] == '\t'); i++ {
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(s[i:], c)
+			if c == '~' && n > 2 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			flush()
+			nodes = append(nodes, delimiterRun(s, i, n))
+			i += n
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if link, n := r.link(s[i+1:], true); n > 0 {
+				emit(link)
+				i += 1 + n
+				continue
+			}
+			text.WriteByte(c)
+			i++
+
+		case c == '[':
+			if link, n := r.link(s[i:], false); n > 0 {
+				emit(link)
+				i += n
+				continue
+			}
+			text.WriteByte(c)
+			i++
+
+		case c == '<':
+			if m := autolinkRe.FindStringSubmatch(s[i:]); m != nil {
+				if href := safeURL(m[1], false); href != "" {
+					emit(r.anchor(href, "", html.EscapeString(m[1])))
+				} else {
+					text.WriteString(m[0])
+				}
+				i += len(m[0])
+				continue
+			}
+			if m := emailLinkRe.FindStringSubmatch(s[i:]); m != nil {
+				emit(r.anchor("mailto:"+m[1], "", html.EscapeString(m[1])))
+				i += len(m[0])
+				continue
+			}
+			if tag := rawTagRe.FindString(s[i:]); tag != "" {
+				emit(r.html.sanitize(tag))
+				i += len(tag)
+				// The content of a dropped tag such as <script> goes with it
+				if r.html.skip != "" {
+					n, _ := r.html.skipContent(s[i:])
+					i += n
+				}
+				continue
+			}
+			text.WriteByte(c)
+			i++
+
+		case c == '&':
+			if entity := entityRe.FindString(s[i:]); entity != "" && html.UnescapeString(entity) != entity {
+				text.WriteString(html.UnescapeString(entity))
+				i += len(entity)
+				continue
+			}
+			text.WriteByte(c)
+			i++
+
+		case (c == 'h' || c == 'w') && !r.inLink && (i == 0 || strings.IndexByte(" \t\n*_~(", s[i-1]) >= 0):
+			if url := trimBareURL(bareURLRe.FindString(s[i:])); url != "" {
+				href := url
+				if strings.HasPrefix(url, "www.") {
+					href = "http://" + url
+				}
+				emit(r.anchor(href, "", html.EscapeString(url)))
+				i += len(url)
+				continue
+			}
+			text.WriteByte(c)
+			i++
+
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	matchEmphasis(nodes)
+
+	var out strings.Builder
+	for _, node := range nodes {
+		if node.delim == 0 {
+			out.WriteString(node.html)
+			continue
+		}
+		out.WriteString(node.closes)
+		out.WriteString(strings.Repeat(string(node.delim), node.n))
+		out.WriteString(node.opens)
+	}
+	// Close raw inline HTML the text left open
+	out.WriteString(r.html.closeTo(depth))
+	r.html.skip = ""
+	return out.String()
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// delimiterRun classifies the run of n delimiters at s[i] as CommonMark does, by
+// whether it is left- or right-flanking
+func delimiterRun(s string, i, n int) *inlineNode {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+	leftFlanking := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	rightFlanking := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	node := &inlineNode{delim: s[i], n: n, orig: n}
+	if s[i] == '_' {
+		node.canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		node.canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	} else {
+		node.canOpen = leftFlanking
+		node.canClose = rightFlanking
+	}
+	return node
+}
+
+// matchEmphasis pairs delimiter runs into em, strong and del tags
+func matchEmphasis(nodes []*inlineNode) {
+	for ci, closer := range nodes {
+		if closer.delim == 0 || !closer.canClose {
+			continue
+		}
+		for closer.n > 0 {
+			var opener *inlineNode
+			oi := ci - 1
+			for ; oi >= 0; oi-- {
+				o := nodes[oi]
+				if o.delim != closer.delim || !o.canOpen || o.n == 0 {
+					continue
+				}
+				if closer.delim == '~' {
+					if o.orig != closer.orig {
+						continue
+					}
+				} else if (o.canClose || closer.canOpen) && (o.orig+closer.orig)%3 == 0 && (o.orig%3 != 0 || closer.orig%3 != 0) {
+					continue
+				}
+				opener = o
+				break
+			}
+			if opener == nil {
+				break
+			}
+
+			use, tag := 1, "em"
+			switch {
+			case closer.delim == '~':
+				use, tag = closer.n, "del"
+			case opener.n >= 2 && closer.n >= 2:
+				use, tag = 2, "strong"
+			}
+			opener.n -= use
+			closer.n -= use
+			opener.opens = "<" + tag + ">" + opener.opens
+			closer.closes += "</" + tag + ">"
+			// Delimiters between the pair can no longer match
+			for _, between := range nodes[oi+1 : ci] {
+				between.canOpen, between.canClose = false, false
+			}
+		}
+	}
+}
+
+// codeSpan renders the code span at the start of s and returns its length, or 0 if
+// the opening backticks are not closed
+func codeSpan(s string) (string, int) {
+	n := runLength(s, '`')
+	for j := n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		k := j + runLength(s[j:], '`')
+		if k-j == n {
+			code := strings.ReplaceAll(s[n:j], "\n", " ")
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			return "<code>" + html.EscapeString(code) + "</code>", k
+		}
+		j = k
+	}
+	return "", 0
+}
+
+// link renders the link or image whose text starts with the '[' at s[0] and returns
+// its length, or 0 if s does not start a link
+func (r *renderer) link(s string, image bool) (string, int) {
+	if r.inLink && !image {
+		return "", 0
+	}
+	end := closingBracket(s)
+	if end < 0 {
+		return "", 0
+	}
+	label := s[1:end]
+	n := end + 1
+
+	var url, title string
+	found := false
+	if dest, t, length, ok := inlineDestination(s[n:]); ok {
+		url, title, found = dest, t, true
+		n += length
+	} else {
+		ref := label
+		if strings.HasPrefix(s[n:], "[") {
+			if closing := strings.IndexByte(s[n:], ']'); closing >= 0 && !strings.Contains(s[n+1:n+closing], "[") {
+				if closing > 1 {
+					ref = s[n+1 : n+closing]
+				}
+				n += closing + 1
+			}
+		}
+		if def, ok := r.refs[normalizeLabel(ref)]; ok {
+			url, title, found = def.url, def.title, true
+		}
+	}
+	if !found {
+		return "", 0
+	}
+
+	if image {
+		content := r.inline(label)
+		src := safeURL(url, true)
+		if src == "" {
+			return html.EscapeString(plainText(content)), n
+		}
+		img := `<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(plainText(content)) + `"`
+		if title != "" {
+			img += ` title="` + html.EscapeString(title) + `"`
+		}
+		return img + ">", n
+	}
+
+	r.inLink = true
+	content := r.inline(label)
+	r.inLink = false
+	href := safeURL(url, false)
+	if href == "" {
+		return content, n
+	}
+	return r.anchor(href, title, content), n
+}
+
+// anchor renders a link to a URL that passed safeURL. Fragment links are pointed at
+// the prefixed heading IDs and links off the site open in a new tab.
+func (r *renderer) anchor(href, title, content string) string {
+	if strings.HasPrefix(href, "#") && !strings.HasPrefix(href, "#"+AnchorPrefix) {
+		href = "#" + AnchorPrefix + href[1:]
+	}
+	a := `<a href="` + html.EscapeString(href) + `"`
+	if title != "" {
+		a += ` title="` + html.EscapeString(title) + `"`
+	}
+	if isExternal(href) {
+		a += ` target="_blank" rel="nofollow noopener noreferrer"`
+	}
+	return a + ">" + content + "</a>"
+}
+
+// closingBracket returns the index of the ']' matching the '[' at s[0], skipping
+// escapes and code spans, or -1
+func closingBracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			if _, n := codeSpan(s[i:]); n > 0 {
+				i += n - 1
+			} else {
+				i += runLength(s[i:], '`') - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// inlineDestination reads "(url "title")" at the start of s
+func inlineDestination(s string) (url, title string, length int, ok bool) {
+	if !strings.HasPrefix(s, "(") {
+		return "", "", 0, false
+	}
+	i := skipSpace(s, 1)
+
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", "", 0, false
+		}
+		url = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start, parens := i, 0
+	loop:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+				i++
+			case c == '(':
+				parens++
+			case c == ')':
+				if parens == 0 {
+					break loop
+				}
+				parens--
+			case c <= ' ':
+				break loop
+			}
+		}
+		url = s[start:i]
+	}
+
+	j := skipSpace(s, i)
+	if j > i && j < len(s) && (s[j] == '"' || s[j] == '\'' || s[j] == '(') {
+		closer := s[j]
+		if closer == '(' {
+			closer = ')'
+		}
+		k := j + 1
+		for ; k < len(s) && s[k] != closer; k++ {
+			if s[k] == '\\' {
+				k++
+			}
+		}
+		if k >= len(s) {
+			return "", "", 0, false
+		}
+		title = s[j+1 : k]
+		j = skipSpace(s, k+1)
+	}
+	if j >= len(s) || s[j] != ')' {
+		return "", "", 0, false
+	}
+	return unescapeText(url), unescapeText(title), j + 1, true
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// trimBareURL drops the trailing punctuation and unbalanced parentheses GitHub leaves
+// out of autolinked URLs
+func trimBareURL(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		switch {
+		case strings.IndexByte("?!.,:*_~'\"", last) >= 0:
+			url = url[:len(url)-1]
+		case last == ')' && strings.Count(url, ")") > strings.Count(url, "("):
+			url = url[:len(url)-1]
+		default:
+			return url
+		}
+	}
+	return url
+}
+
+// unescapeText resolves backslash escapes and entities in link destinations, titles
+// and info strings
+func unescapeText(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			b.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if s[i] == '&' {
+			if entity := entityRe.FindString(s[i:]); entity != "" {
+				b.WriteString(html.UnescapeString(entity))
+				i += len(entity) - 1
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isPunct(c rune) bool {
+	return unicode.IsPunct(c) || unicode.IsSymbol(c)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetterOrDigit(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.IsMark(c)
+}
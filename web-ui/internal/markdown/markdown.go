@@ -0,0 +1,808 @@
+// Package markdown renders CommonMark with the GitHub extensions the repository's
+// READMEs use: tables, task lists, strikethrough, bare URLs and heading anchors.
+// Raw HTML is sanitized against an allowlist, link and image targets are limited to
+// safe schemes and Go code blocks are highlighted on the server.
+package markdown
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnchorPrefix is prepended to heading IDs, as GitHub does, so a heading cannot
+// clobber an element of the page it is shown on. Links to #fragment are rewritten
+// to match.
+const AnchorPrefix = "user-content-"
+
+// Render converts Markdown to sanitized HTML
+func Render(src string) template.HTML {
+	p := &parser{refs: make(map[string]linkRef)}
+	doc := p.parse(splitLines(src))
+	r := &renderer{refs: p.refs, slugs: make(map[string]int)}
+	r.blocks(doc, false)
+	r.out.WriteString(r.html.closeTo(0))
+	return template.HTML(r.out.String())
+}
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	codeBlock
+	htmlBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	tableBlock
+	ruleBlock
+)
+
+// block is a node of the document tree
+type block struct {
+	kind        blockKind
+	text        string   // Inline Markdown of paragraphs and headings, literal text of code and HTML
+	level       int      // Heading level
+	lang        string   // First word of a fenced code block's info string
+	children    []*block // Of quotes, lists and list items
+	blankBefore bool     // A blank line separates the block from the previous one
+	ordered     bool
+	start       int
+	tight       bool
+	task        string     // "[ ]" or "[x]" for task list items
+	align       []string   // Table column alignment
+	rows        [][]string // Table cells, header first
+}
+
+type linkRef struct {
+	url, title string
+}
+
+type parser struct {
+	refs map[string]linkRef
+}
+
+// splitLines normalizes line endings and splits src into lines
+func splitLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	src = strings.TrimSuffix(src, "\n")
+	if src == "" {
+		return nil
+	}
+	return strings.Split(src, "\n")
+}
+
+func isBlank(line string) bool {
+	return strings.TrimLeft(line, " \t") == ""
+}
+
+// indentOf returns the width of a line's leading whitespace, with tab stops of 4
+func indentOf(line string) int {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// stripIndent removes up to n columns of leading whitespace, splitting a tab that
+// straddles the boundary into spaces
+func stripIndent(line string, n int) string {
+	col := 0
+	for i := 0; i < len(line); i++ {
+		if col >= n {
+			return line[i:]
+		}
+		switch line[i] {
+		case ' ':
+			col++
+		case '\t':
+			width := 4 - col%4
+			if col+width > n {
+				return strings.Repeat(" ", col+width-n) + line[i+1:]
+			}
+			col += width
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+var (
+	atxHeadingRe     = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRe           = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextRe         = regexp.MustCompile(`^(=+|-+)[ \t]*$`)
+	orderedMarkerRe  = regexp.MustCompile(`^([0-9]{1,9})([.)])`)
+	tableDelimiterRe = regexp.MustCompile(`^\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	linkRefRe        = regexp.MustCompile(`^\[((?:[^\[\]\\]|\\.){1,999})\]:[ \t]*(<[^<>\n]*>|\S+)(?:[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\)))?[ \t]*$`)
+	htmlTagLineRe    = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>)[ \t]*$`)
+)
+
+// htmlBlockTags start an HTML block that ends at a blank line
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"caption": true, "center": true, "col": true, "colgroup": true, "dd": true, "details": true,
+	"dialog": true, "dir": true, "div": true, "dl": true, "dt": true, "fieldset": true,
+	"figcaption": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "head": true, "header": true, "hr": true,
+	"html": true, "iframe": true, "legend": true, "li": true, "link": true, "main": true,
+	"menu": true, "nav": true, "ol": true, "p": true, "param": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true, "th": true,
+	"thead": true, "title": true, "tr": true, "ul": true,
+}
+
+// htmlBlockStart reports whether line opens an HTML block and the text that ends it:
+// a closing tag or marker on some line, or "" for the next blank line. canInterrupt
+// is false for blocks that start with an arbitrary tag, which may not interrupt a
+// paragraph.
+func htmlBlockStart(line string) (end string, canInterrupt, ok bool) {
+	if !strings.HasPrefix(line, "<") {
+		return "", false, false
+	}
+	lower := strings.ToLower(line)
+	for _, tag := range []string{"script", "pre", "style", "textarea"} {
+		if strings.HasPrefix(lower, "<"+tag) {
+			rest := lower[len(tag)+1:]
+			if rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '>' {
+				return "</" + tag + ">", true, true
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(line, "<!--"):
+		return "-->", true, true
+	case strings.HasPrefix(line, "<?"):
+		return "?>", true, true
+	case strings.HasPrefix(line, "<![CDATA["):
+		return "]]>", true, true
+	case strings.HasPrefix(line, "<!") && len(line) > 2 && isLetter(line[2]):
+		return ">", true, true
+	}
+
+	name := strings.TrimPrefix(lower[1:], "/")
+	n := 0
+	for n < len(name) && (isLetter(name[n]) || isDigit(name[n])) {
+		n++
+	}
+	if htmlBlockTags[name[:n]] {
+		rest := name[n:]
+		if rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '>' || strings.HasPrefix(rest, "/>") {
+			return "", true, true
+		}
+	}
+	if htmlTagLineRe.MatchString(line) {
+		return "", false, true
+	}
+	return "", false, false
+}
+
+// fenceStart reads an opening code fence: its character, length and info string
+func fenceStart(line string) (fence byte, length int, info string, ok bool) {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return 0, 0, "", false
+	}
+	fence = line[0]
+	for length < len(line) && line[length] == fence {
+		length++
+	}
+	if length < 3 {
+		return 0, 0, "", false
+	}
+	info = strings.TrimSpace(line[length:])
+	if fence == '`' && strings.Contains(info, "`") {
+		return 0, 0, "", false
+	}
+	return fence, length, info, true
+}
+
+// listMarker is the start of a list item
+type listMarker struct {
+	bullet  byte // '-', '+' or '*', or the '.' or ')' of an ordered marker
+	ordered bool
+	start   int
+	width   int // Of the marker and the spaces up to the item's content
+	content string
+}
+
+// listItemStart reads the marker of a list item starting line
+func listItemStart(line string) (listMarker, bool) {
+	var m listMarker
+	n := 0
+	if line != "" && (line[0] == '-' || line[0] == '+' || line[0] == '*') {
+		m.bullet = line[0]
+		n = 1
+	} else if match := orderedMarkerRe.FindStringSubmatch(line); match != nil {
+		m.ordered = true
+		m.start, _ = strconv.Atoi(match[1])
+		m.bullet = match[2][0]
+		n = len(match[0])
+	} else {
+		return m, false
+	}
+
+	rest := line[n:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return m, false
+	}
+	spaces := indentOf(rest)
+	switch {
+	case isBlank(rest):
+		m.width = n + 1
+		return m, true
+	case spaces > 4:
+		// The content is indented code; the item starts one space after the marker
+		m.width = n + 1
+		m.content = stripIndent(rest, 1)
+	default:
+		m.width = n + spaces
+		m.content = stripIndent(rest, spaces)
+	}
+	return m, true
+}
+
+// splitTableRow splits a table row into trimmed cells on unescaped pipes
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableStart reports whether a table with header line starts at lines[0]
+func tableStart(lines []string) bool {
+	if len(lines) < 2 || !strings.Contains(lines[0], "|") || indentOf(lines[1]) >= 4 {
+		return false
+	}
+	delimiter := strings.TrimSpace(lines[1])
+	if !tableDelimiterRe.MatchString(delimiter) {
+		return false
+	}
+	return len(splitTableRow(lines[0])) == len(splitTableRow(delimiter))
+}
+
+// interrupts reports whether line, with at most three spaces of indentation
+// removed, starts a block that ends a paragraph
+func interrupts(line string) bool {
+	if _, _, _, ok := fenceStart(line); ok {
+		return true
+	}
+	if atxHeadingRe.MatchString(line) || ruleRe.MatchString(line) || strings.HasPrefix(line, ">") {
+		return true
+	}
+	if m, ok := listItemStart(line); ok && m.content != "" && (!m.ordered || m.start == 1) {
+		return true
+	}
+	_, canInterrupt, ok := htmlBlockStart(line)
+	return ok && canInterrupt
+}
+
+// startsBlock reports whether a line that is not indented as a continuation starts
+// a new block rather than continuing a paragraph lazily
+func startsBlock(line string) bool {
+	if indentOf(line) >= 4 {
+		return false
+	}
+	line = stripIndent(line, 3)
+	if interrupts(line) {
+		return true
+	}
+	_, ok := listItemStart(line)
+	return ok
+}
+
+// parse builds the blocks of lines
+func (p *parser) parse(lines []string) []*block {
+	var blocks []*block
+	blank := false
+	add := func(b *block) {
+		b.blankBefore = blank && len(blocks) > 0
+		blank = false
+		blocks = append(blocks, b)
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			blank = true
+			i++
+			continue
+		}
+
+		indent := indentOf(line)
+		if indent >= 4 {
+			var code []string
+			j := i
+			for ; j < len(lines) && (isBlank(lines[j]) || indentOf(lines[j]) >= 4); j++ {
+				code = append(code, stripIndent(lines[j], 4))
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			add(&block{kind: codeBlock, text: strings.Join(code, "\n") + "\n"})
+			i = j
+			continue
+		}
+		rest := stripIndent(line, indent)
+
+		if fence, length, info, ok := fenceStart(rest); ok {
+			var code []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				closing := strings.TrimRight(stripIndent(lines[j], 3), " \t")
+				if indentOf(lines[j]) < 4 && len(closing) >= length && strings.Trim(closing, string(fence)) == "" {
+					j++
+					break
+				}
+				code = append(code, stripIndent(lines[j], indent))
+			}
+			text := strings.Join(code, "\n")
+			if len(code) > 0 {
+				text += "\n"
+			}
+			lang, _, _ := strings.Cut(unescapeText(info), " ")
+			add(&block{kind: codeBlock, text: text, lang: lang})
+			i = j
+			continue
+		}
+
+		if m := atxHeadingRe.FindStringSubmatch(rest); m != nil {
+			add(&block{kind: headingBlock, level: len(m[1]), text: m[2]})
+			i++
+			continue
+		}
+
+		if ruleRe.MatchString(rest) {
+			add(&block{kind: ruleBlock})
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(rest, ">") {
+			var inner []string
+			j := i
+			for ; j < len(lines); j++ {
+				l := lines[j]
+				if indentOf(l) < 4 {
+					if r := stripIndent(l, 3); strings.HasPrefix(r, ">") {
+						inner = append(inner, stripIndent(r[1:], 1))
+						continue
+					}
+				}
+				// A lazy continuation line of a paragraph in the quote
+				if !isBlank(l) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(l) {
+					inner = append(inner, l)
+					continue
+				}
+				break
+			}
+			add(&block{kind: quoteBlock, children: p.parse(inner)})
+			i = j
+			continue
+		}
+
+		if m, ok := listItemStart(rest); ok {
+			list, j := p.parseList(lines, i, indent, m)
+			add(list)
+			i = j
+			continue
+		}
+
+		if end, _, ok := htmlBlockStart(rest); ok {
+			j := i
+			var raw []string
+			for ; j < len(lines); j++ {
+				if end == "" && isBlank(lines[j]) {
+					break
+				}
+				raw = append(raw, lines[j])
+				if end != "" && strings.Contains(strings.ToLower(lines[j]), end) {
+					j++
+					break
+				}
+			}
+			add(&block{kind: htmlBlock, text: strings.Join(raw, "\n") + "\n"})
+			i = j
+			continue
+		}
+
+		if tableStart(lines[i:]) {
+			table := &block{kind: tableBlock}
+			for _, cell := range splitTableRow(strings.TrimSpace(lines[i+1])) {
+				switch {
+				case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+					table.align = append(table.align, "center")
+				case strings.HasSuffix(cell, ":"):
+					table.align = append(table.align, "right")
+				case strings.HasPrefix(cell, ":"):
+					table.align = append(table.align, "left")
+				default:
+					table.align = append(table.align, "")
+				}
+			}
+			table.rows = append(table.rows, splitTableRow(lines[i]))
+			j := i + 2
+			for ; j < len(lines) && !isBlank(lines[j]) && !startsBlock(lines[j]); j++ {
+				table.rows = append(table.rows, splitTableRow(lines[j]))
+			}
+			add(table)
+			i = j
+			continue
+		}
+
+		// A paragraph, possibly underlined as a setext heading
+		para := []string{strings.TrimLeft(rest, " \t")}
+		level := 0
+		j := i + 1
+		for ; j < len(lines); j++ {
+			l := lines[j]
+			if isBlank(l) {
+				break
+			}
+			if indentOf(l) < 4 {
+				r := stripIndent(l, 3)
+				if m := setextRe.FindStringSubmatch(r); m != nil && !p.onlyLinkRefs(para) {
+					level = 1
+					if m[1][0] == '-' {
+						level = 2
+					}
+					j++
+					break
+				}
+				if interrupts(r) || tableStart(lines[j:]) {
+					break
+				}
+			}
+			para = append(para, strings.TrimLeft(l, " \t"))
+		}
+		i = j
+
+		para = p.takeLinkRefs(para)
+		if len(para) == 0 {
+			continue
+		}
+		text := strings.TrimRight(strings.Join(para, "\n"), " \t")
+		if level > 0 {
+			add(&block{kind: headingBlock, level: level, text: text})
+		} else {
+			add(&block{kind: paragraphBlock, text: text})
+		}
+	}
+	return blocks
+}
+
+// parseList reads the list whose first item starts at lines[i] and returns it with
+// the index of the line after it
+func (p *parser) parseList(lines []string, i, indent int, first listMarker) (*block, int) {
+	list := &block{kind: listBlock, ordered: first.ordered, start: first.start, tight: true}
+	m := first
+	for {
+		contentIndent := indent + m.width
+		itemLines := []string{m.content}
+		j := i + 1
+		// An item that starts with a blank line ends at the next one
+		if m.content != "" || j >= len(lines) || !isBlank(lines[j]) {
+			for ; j < len(lines); j++ {
+				l := lines[j]
+				if isBlank(l) {
+					itemLines = append(itemLines, "")
+					continue
+				}
+				if indentOf(l) >= contentIndent {
+					itemLines = append(itemLines, stripIndent(l, contentIndent))
+					continue
+				}
+				// A lazy continuation line of the item's paragraph
+				if last := itemLines[len(itemLines)-1]; !isBlank(last) && !startsBlock(l) && !inOpenFence(itemLines) {
+					itemLines = append(itemLines, strings.TrimLeft(l, " \t"))
+					continue
+				}
+				break
+			}
+		}
+
+		trailingBlank := false
+		for len(itemLines) > 0 && isBlank(itemLines[len(itemLines)-1]) {
+			itemLines = itemLines[:len(itemLines)-1]
+			trailingBlank = true
+		}
+		item := &block{kind: itemBlock, children: p.parse(itemLines)}
+		for _, child := range item.children {
+			if child.blankBefore {
+				list.tight = false
+			}
+		}
+		if len(item.children) > 0 && item.children[0].kind == paragraphBlock {
+			text := item.children[0].text
+			for _, box := range []string{"[ ]", "[x]", "[X]"} {
+				if strings.HasPrefix(text, box) && (len(text) == len(box) || text[len(box)] == ' ' || text[len(box)] == '\t' || text[len(box)] == '\n') {
+					item.task = strings.ToLower(box)
+					item.children[0].text = strings.TrimLeft(text[len(box):], " \t")
+					break
+				}
+			}
+		}
+		list.children = append(list.children, item)
+
+		// The next item must use the same kind of marker
+		if j >= len(lines) || indentOf(lines[j]) >= 4 {
+			return list, j
+		}
+		nextIndent := indentOf(lines[j])
+		next, ok := listItemStart(stripIndent(lines[j], nextIndent))
+		if !ok || next.ordered != list.ordered || next.bullet != first.bullet || ruleRe.MatchString(stripIndent(lines[j], nextIndent)) {
+			return list, j
+		}
+		if trailingBlank {
+			list.tight = false
+		}
+		i, indent, m = j, nextIndent, next
+	}
+}
+
+// inOpenFence reports whether lines end inside a fenced code block
+func inOpenFence(lines []string) bool {
+	var fence byte
+	length := 0
+	for _, line := range lines {
+		if indentOf(line) >= 4 {
+			continue
+		}
+		l := strings.TrimRight(stripIndent(line, 3), " \t")
+		if fence == 0 {
+			if f, n, _, ok := fenceStart(l); ok {
+				fence, length = f, n
+			}
+		} else if len(l) >= length && l[0] == fence && strings.Trim(l, string(fence)) == "" {
+			fence = 0
+		}
+	}
+	return fence != 0
+}
+
+// takeLinkRefs records the link reference definitions at the start of a paragraph
+// and returns the lines after them
+func (p *parser) takeLinkRefs(para []string) []string {
+	for len(para) > 0 {
+		m := linkRefRe.FindStringSubmatch(para[0])
+		if m == nil {
+			break
+		}
+		label := normalizeLabel(m[1])
+		if _, exists := p.refs[label]; !exists && label != "" {
+			url := strings.TrimSuffix(strings.TrimPrefix(m[2], "<"), ">")
+			title := ""
+			if len(m[3]) >= 2 {
+				title = m[3][1 : len(m[3])-1]
+			}
+			p.refs[label] = linkRef{url: unescapeText(url), title: unescapeText(title)}
+		}
+		para = para[1:]
+	}
+	return para
+}
+
+func (p *parser) onlyLinkRefs(para []string) bool {
+	for _, line := range para {
+		if !linkRefRe.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLabel makes link labels match case-insensitively and regardless of spacing
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// renderer writes the HTML of a document
+type renderer struct {
+	out    strings.Builder
+	refs   map[string]linkRef
+	slugs  map[string]int // Heading anchors used so far, with the last number added to each
+	html   sanitizer
+	inLink bool
+}
+
+func (r *renderer) blocks(blocks []*block, tight bool) {
+	for _, b := range blocks {
+		r.block(b, tight)
+	}
+}
+
+func (r *renderer) block(b *block, tight bool) {
+	out := &r.out
+	switch b.kind {
+	case paragraphBlock:
+		if tight {
+			out.WriteString(r.inline(b.text))
+			return
+		}
+		out.WriteString("<p>" + r.inline(b.text) + "</p>\n")
+
+	case headingBlock:
+		content := r.inline(b.text)
+		id := AnchorPrefix + r.slug(plainText(content))
+		level := strconv.Itoa(b.level)
+		out.WriteString(`<h` + level + ` id="` + html.EscapeString(id) + `">`)
+		out.WriteString(`<a class="heading-anchor" href="#` + html.EscapeString(id) + `" aria-hidden="true">#</a>`)
+		out.WriteString(content + "</h" + level + ">\n")
+
+	case codeBlock:
+		lang := languageName(b.lang)
+		switch {
+		case lang == "go":
+			out.WriteString(`<pre><code class="hljs language-go">` + highlightGo(b.text) + "</code></pre>\n")
+		case lang != "":
+			out.WriteString(`<pre><code class="language-` + lang + `">` + html.EscapeString(b.text) + "</code></pre>\n")
+		default:
+			out.WriteString("<pre><code>" + html.EscapeString(b.text) + "</code></pre>\n")
+		}
+
+	case htmlBlock:
+		out.WriteString(r.html.sanitize(b.text))
+		r.html.skip = ""
+
+	case quoteBlock:
+		// Raw HTML opened inside a container is closed with it
+		depth := len(r.html.open)
+		out.WriteString("<blockquote>\n")
+		r.blocks(b.children, false)
+		out.WriteString(r.html.closeTo(depth) + "</blockquote>\n")
+
+	case listBlock:
+		tag := "ul"
+		if b.ordered {
+			tag = "ol"
+		}
+		out.WriteString("<" + tag)
+		if b.ordered && b.start != 1 {
+			out.WriteString(` start="` + strconv.Itoa(b.start) + `"`)
+		}
+		for _, item := range b.children {
+			if item.task != "" {
+				out.WriteString(` class="contains-task-list"`)
+				break
+			}
+		}
+		out.WriteString(">\n")
+		for _, item := range b.children {
+			switch item.task {
+			case "":
+				out.WriteString("<li>")
+			case "[x]":
+				out.WriteString(`<li class="task-list-item"><input type="checkbox" class="task-list-item-checkbox" disabled checked> `)
+			default:
+				out.WriteString(`<li class="task-list-item"><input type="checkbox" class="task-list-item-checkbox" disabled> `)
+			}
+			if !b.tight && len(item.children) > 0 {
+				out.WriteString("\n")
+			}
+			depth := len(r.html.open)
+			r.blocks(item.children, b.tight)
+			out.WriteString(r.html.closeTo(depth) + "</li>\n")
+		}
+		out.WriteString("</" + tag + ">\n")
+
+	case tableBlock:
+		out.WriteString("<table>\n<thead>\n")
+		for i, row := range b.rows {
+			cellTag := "td"
+			if i == 0 {
+				cellTag = "th"
+			} else if i == 1 {
+				out.WriteString("<tbody>\n")
+			}
+			out.WriteString("<tr>\n")
+			for c, align := range b.align {
+				out.WriteString("<" + cellTag)
+				if align != "" {
+					out.WriteString(` align="` + align + `"`)
+				}
+				out.WriteString(">")
+				if c < len(row) {
+					out.WriteString(r.inline(row[c]))
+				}
+				out.WriteString("</" + cellTag + ">\n")
+			}
+			out.WriteString("</tr>\n")
+			if i == 0 {
+				out.WriteString("</thead>\n")
+			}
+		}
+		if len(b.rows) > 1 {
+			out.WriteString("</tbody>\n")
+		}
+		out.WriteString("</table>\n")
+
+	case ruleBlock:
+		out.WriteString("<hr>\n")
+	}
+}
+
+// languageName returns the language of a code block's info string, or "" if it is
+// not a plain name
+func languageName(info string) string {
+	lang := strings.ToLower(info)
+	if lang == "golang" {
+		return "go"
+	}
+	for i := 0; i < len(lang); i++ {
+		if c := lang[i]; !isLetter(c) && !isDigit(c) && c != '-' && c != '_' && c != '+' && c != '#' {
+			return ""
+		}
+	}
+	return lang
+}
+
+// slug returns a GitHub-style anchor for a heading's text, numbered if it was used before
+func (r *renderer) slug(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case c == ' ':
+			b.WriteByte('-')
+		case c == '-' || c == '_' || isLetterOrDigit(c):
+			b.WriteRune(c)
+		}
+	}
+	// Like GitHub, skip numbers taken by other headings, e.g. a heading "Intro-1"
+	base := b.String()
+	slug := base
+	for {
+		if _, used := r.slugs[slug]; !used {
+			break
+		}
+		r.slugs[base]++
+		slug = base + "-" + strconv.Itoa(r.slugs[base])
+	}
+	r.slugs[slug] = 0
+	return slug
+}
+
+var (
+	tagRe           = regexp.MustCompile(`<[^>]*>`)
+	headingAnchorRe = regexp.MustCompile(`<a class="heading-anchor"[^>]*>#</a>`)
+)
+
+// Text returns the text of HTML from Render, with heading anchors left out and runs
+// of whitespace collapsed to one space
+func Text(rendered template.HTML) string {
+	text := headingAnchorRe.ReplaceAllString(string(rendered), "")
+	text = tagRe.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// plainText returns the text of rendered inline HTML
+func plainText(inlineHTML string) string {
+	return html.UnescapeString(tagRe.ReplaceAllString(inlineHTML, ""))
+}
@@ -0,0 +1,108 @@
+package markdown
+
+import "testing"
+
+// anchor is the link GitHub puts before a heading's text
+func anchor(slug string) string {
+	return `<a class="heading-anchor" href="#user-content-` + slug + `" aria-hidden="true">#</a>`
+}
+
+func TestRenderNestedLists(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"three levels", "- a\n  - b\n    - c\n- d",
+			"<ul>\n<li>a<ul>\n<li>b<ul>\n<li>c</li>\n</ul>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+		{"bullets in ordered", "1. one\n2. two\n   - nested\n   - more\n3. three",
+			"<ol>\n<li>one</li>\n<li>two<ul>\n<li>nested</li>\n<li>more</li>\n</ul>\n</li>\n<li>three</li>\n</ol>\n"},
+		{"ordered in bullets", "* a\n    1. x\n    2. y",
+			"<ul>\n<li>a<ol>\n<li>x</li>\n<li>y</li>\n</ol>\n</li>\n</ul>\n"},
+		{"loose item with paragraphs", "- a\n\n  para\n- b",
+			"<ul>\n<li>\n<p>a</p>\n<p>para</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n"},
+		{"blockquote in item", "- a\n\n  > quoted\n- b",
+			"<ul>\n<li>\n<p>a</p>\n<blockquote>\n<p>quoted</p>\n</blockquote>\n</li>\n<li>\n<p>b</p>\n</li>\n</ul>\n"},
+	})
+}
+
+func TestRenderTables(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"alignment", "| A | B |\n|---|:-:|\n| 1 | 2 |",
+			"<table>\n<thead>\n<tr>\n<th>A</th>\n<th align=\"center\">B</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td>1</td>\n<td align=\"center\">2</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"without outer pipes", "a | b\n--|--\n1 | 2",
+			"<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"short row is padded", "| A | B |\n|--:|---|\n| only |",
+			"<table>\n<thead>\n<tr>\n<th align=\"right\">A</th>\n<th>B</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td align=\"right\">only</td>\n<td></td>\n</tr>\n</tbody>\n</table>\n"},
+		{"escaped pipe in code and unsafe link", "| Code | Note |\n|---|---|\n| `a\\|b` | [x](javascript:alert(1)) |",
+			"<table>\n<thead>\n<tr>\n<th>Code</th>\n<th>Note</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td><code>a|b</code></td>\n<td>x</td>\n</tr>\n</tbody>\n</table>\n"},
+	})
+}
+
+func TestRenderBlockquotes(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"two lines", "> quote\n> more", "<blockquote>\n<p>quote\nmore</p>\n</blockquote>\n"},
+		{"lazy continuation", "> lazy\ncontinuation", "<blockquote>\n<p>lazy\ncontinuation</p>\n</blockquote>\n"},
+		{"nested", "> outer\n>> inner\n\nafter",
+			"<blockquote>\n<p>outer</p>\n<blockquote>\n<p>inner</p>\n</blockquote>\n</blockquote>\n<p>after</p>\n"},
+		{"list", "> - item\n> - item2", "<blockquote>\n<ul>\n<li>item</li>\n<li>item2</li>\n</ul>\n</blockquote>\n"},
+		{"heading", "> # Title\n> text",
+			"<blockquote>\n<h1 id=\"user-content-title\">" + anchor("title") + "Title</h1>\n<p>text</p>\n</blockquote>\n"},
+	})
+}
+
+func TestRenderTaskLists(t *testing.T) {
+	const (
+		unchecked = `<input type="checkbox" class="task-list-item-checkbox" disabled>`
+		checked   = `<input type="checkbox" class="task-list-item-checkbox" disabled checked>`
+	)
+	runRenderTests(t, []renderTest{
+		{"unchecked and checked", "- [ ] todo\n- [x] done\n- [X] Done too",
+			"<ul class=\"contains-task-list\">\n<li class=\"task-list-item\">" + unchecked + " todo</li>\n" +
+				"<li class=\"task-list-item\">" + checked + " done</li>\n" +
+				"<li class=\"task-list-item\">" + checked + " Done too</li>\n</ul>\n"},
+		{"ordered", "1. [x] ordered task",
+			"<ol class=\"contains-task-list\">\n<li class=\"task-list-item\">" + checked + " ordered task</li>\n</ol>\n"},
+		{"nested", "- [ ] a\n  - [x] b",
+			"<ul class=\"contains-task-list\">\n<li class=\"task-list-item\">" + unchecked + " a<ul class=\"contains-task-list\">\n" +
+				"<li class=\"task-list-item\">" + checked + " b</li>\n</ul>\n</li>\n</ul>\n"},
+		{"not tasks", "- [ ]not a task\n- [y] no", "<ul>\n<li>[ ]not a task</li>\n<li>[y] no</li>\n</ul>\n"},
+	})
+}
+
+func TestRenderHeadingAnchors(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"duplicates are numbered", "# Intro\n## Intro\n### Intro",
+			"<h1 id=\"user-content-intro\">" + anchor("intro") + "Intro</h1>\n" +
+				"<h2 id=\"user-content-intro-1\">" + anchor("intro-1") + "Intro</h2>\n" +
+				"<h3 id=\"user-content-intro-2\">" + anchor("intro-2") + "Intro</h3>\n"},
+		{"punctuation is dropped", "# Hello World!\n# Hello World",
+			"<h1 id=\"user-content-hello-world\">" + anchor("hello-world") + "Hello World!</h1>\n" +
+				"<h1 id=\"user-content-hello-world-1\">" + anchor("hello-world-1") + "Hello World</h1>\n"},
+		{"number taken by another heading", "# Intro\n# Intro-1\n# Intro",
+			"<h1 id=\"user-content-intro\">" + anchor("intro") + "Intro</h1>\n" +
+				"<h1 id=\"user-content-intro-1\">" + anchor("intro-1") + "Intro-1</h1>\n" +
+				"<h1 id=\"user-content-intro-2\">" + anchor("intro-2") + "Intro</h1>\n"},
+		{"inline markup", "## `code` & <b>x</b>",
+			"<h2 id=\"user-content-code--x\">" + anchor("code--x") + "<code>code</code> &amp; <b>x</b></h2>\n"},
+		{"link to a duplicate", "# Intro\n\n- [x](#intro-1)\n\n# Intro",
+			"<h1 id=\"user-content-intro\">" + anchor("intro") + "Intro</h1>\n" +
+				"<ul>\n<li><a href=\"#user-content-intro-1\">x</a></li>\n</ul>\n" +
+				"<h1 id=\"user-content-intro-1\">" + anchor("intro-1") + "Intro</h1>\n"},
+	})
+}
+
+func TestRenderUnsafeInlineLinks(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"with title", `[x](javascript:alert(1) "t")`, "<p>x</p>\n"},
+		{"inside emphasis", "**[x](javascript:alert(1))**", "<p><strong>x</strong></p>\n"},
+		{"mid sentence", "see [x](  javascript:alert(1)  ) now", "<p>see x now</p>\n"},
+		{"leading space", "[x]( javascript:alert(1))", "<p>x</p>\n"},
+		{"file scheme", "[x](file:///etc/passwd)", "<p>x</p>\n"},
+		{"unsafe beside safe", "[x](http://a.com) and [y](ftp://a.com)",
+			`<p><a href="http://a.com" target="_blank" rel="nofollow noopener noreferrer">x</a> and y</p>` + "\n"},
+		{"vbscript and javascript image", `[x](vbscript:msgbox "t") and ![i](javascript:1)`, "<p>x and i</p>\n"},
+		{"reference with title", "[x][r]\n\n[r]: data:text/html,x \"t\"", "<p>x</p>\n"},
+		{"percent encoded colon stays relative", "[x](JAVASCRIPT%3Aalert(1))", `<p><a href="JAVASCRIPT%3Aalert(1)">x</a></p>` + "\n"},
+	})
+}
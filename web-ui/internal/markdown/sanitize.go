@@ -0,0 +1,303 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// allowedTags may appear in raw HTML; other tags are dropped and their text kept
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "blockquote": true, "br": true, "caption": true,
+	"center": true, "code": true, "dd": true, "del": true, "details": true, "div": true,
+	"dl": true, "dt": true, "em": true, "figcaption": true, "figure": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "i": true,
+	"img": true, "ins": true, "kbd": true, "li": true, "mark": true, "ol": true, "p": true,
+	"picture": true, "pre": true, "q": true, "s": true, "samp": true, "source": true,
+	"span": true, "strike": true, "strong": true, "sub": true, "summary": true, "sup": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"tr": true, "tt": true, "u": true, "ul": true, "var": true, "wbr": true,
+}
+
+// droppedTags are removed together with everything inside them
+var droppedTags = map[string]bool{
+	"applet": true, "embed": true, "frame": true, "frameset": true, "head": true,
+	"iframe": true, "math": true, "noembed": true, "noscript": true, "object": true,
+	"script": true, "select": true, "style": true, "svg": true, "template": true,
+	"textarea": true, "title": true, "xmp": true,
+}
+
+// voidTags have no closing tag
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true, "source": true, "wbr": true}
+
+// allowedAttrs may be kept on allowed tags. Event handlers, style, class and id are
+// never kept; URL attributes are checked by safeURL.
+var allowedAttrs = map[string]bool{
+	"abbr": true, "align": true, "alt": true, "cite": true, "colspan": true, "datetime": true,
+	"dir": true, "height": true, "href": true, "lang": true, "media": true, "open": true,
+	"reversed": true, "rowspan": true, "scope": true, "src": true,
+	"start": true, "title": true, "type": true, "valign": true, "width": true,
+}
+
+// sanitizer filters the raw HTML of a document. It keeps the allowed tags open
+// across HTML blocks, so a <details> block can wrap Markdown, and closes whatever is
+// left open at the end. A dropped tag's content is skipped up to its closing tag or
+// the end of the HTML block or paragraph it is in.
+type sanitizer struct {
+	open []string // Allowed tags not yet closed, innermost last
+	skip string   // Dropped tag whose content is being skipped
+}
+
+// sanitize returns raw HTML with disallowed tags, attributes, comments and URLs removed
+func (s *sanitizer) sanitize(raw string) string {
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		if s.skip != "" {
+			n, closed := s.skipContent(raw[i:])
+			if !closed {
+				break
+			}
+			i += n
+			continue
+		}
+
+		switch c := raw[i]; c {
+		case '<':
+			if strings.HasPrefix(raw[i:], "<!--") {
+				end := strings.Index(raw[i+4:], "-->")
+				if end < 0 {
+					return b.String()
+				}
+				i += 4 + end + 3
+				continue
+			}
+			if t, n := parseTag(raw[i:]); n > 0 {
+				b.WriteString(s.tag(t))
+				i += n
+				continue
+			}
+			if strings.HasPrefix(raw[i:], "<!") || strings.HasPrefix(raw[i:], "<?") {
+				if end := strings.IndexByte(raw[i:], '>'); end >= 0 {
+					i += end + 1
+					continue
+				}
+			}
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			if entity := entityRe.FindString(raw[i:]); entity != "" && html.UnescapeString(entity) != entity {
+				b.WriteString(entity)
+				i += len(entity)
+				continue
+			}
+			b.WriteString("&amp;")
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+	return b.String()
+}
+
+// skipContent returns the length of raw up to the end of the closing tag of the
+// dropped tag being skipped, and whether it was found; if not, all of raw is skipped
+// and the tag stays open
+func (s *sanitizer) skipContent(raw string) (int, bool) {
+	lower := strings.ToLower(raw)
+	for i := 0; ; {
+		end := strings.Index(lower[i:], "</"+s.skip)
+		if end < 0 {
+			return len(raw), false
+		}
+		i += end
+		if t, n := parseTag(raw[i:]); n > 0 && t.closing && t.name == s.skip {
+			s.skip = ""
+			return i + n, true
+		}
+		i += len(s.skip) + 2
+	}
+}
+
+// closeTo closes the open tags above depth
+func (s *sanitizer) closeTo(depth int) string {
+	var b strings.Builder
+	for len(s.open) > depth {
+		b.WriteString("</" + s.open[len(s.open)-1] + ">")
+		s.open = s.open[:len(s.open)-1]
+	}
+	if depth == 0 {
+		s.skip = ""
+	}
+	return b.String()
+}
+
+// tag returns the sanitized form of a tag, or "" to drop it
+func (s *sanitizer) tag(t htmlTag) string {
+	if droppedTags[t.name] {
+		if !t.closing && !t.selfClosing {
+			s.skip = t.name
+		}
+		return ""
+	}
+	if !allowedTags[t.name] {
+		return ""
+	}
+
+	if t.closing {
+		for i := len(s.open) - 1; i >= 0; i-- {
+			if s.open[i] == t.name {
+				return s.closeTo(i)
+			}
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + t.name)
+	for _, attr := range t.attrs {
+		if !allowedAttrs[attr.name] {
+			continue
+		}
+		value := attr.value
+		if attr.name == "href" || attr.name == "src" || attr.name == "cite" {
+			if value = safeURL(value, attr.name == "src"); value == "" {
+				continue
+			}
+		}
+		b.WriteString(" " + attr.name + `="` + html.EscapeString(value) + `"`)
+	}
+	if t.name == "a" {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	b.WriteString(">")
+	if !voidTags[t.name] && !t.selfClosing {
+		s.open = append(s.open, t.name)
+	}
+	return b.String()
+}
+
+type htmlTag struct {
+	name        string
+	closing     bool
+	selfClosing bool
+	attrs       []htmlAttr
+}
+
+type htmlAttr struct {
+	name, value string
+}
+
+// parseTag reads the opening or closing tag at the start of s and returns its length,
+// or 0 if s does not start with a well-formed tag
+func parseTag(s string) (htmlTag, int) {
+	var t htmlTag
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && (isLetter(s[i]) || i > start && (isDigit(s[i]) || s[i] == '-')) {
+		i++
+	}
+	if i == start {
+		return t, 0
+	}
+	t.name = strings.ToLower(s[start:i])
+
+	for {
+		space := i
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		switch {
+		case i >= len(s):
+			return t, 0
+		case s[i] == '>':
+			return t, i + 1
+		case s[i] == '/' && i+1 < len(s) && s[i+1] == '>':
+			t.selfClosing = true
+			return t, i + 2
+		case t.closing || i == space:
+			return t, 0
+		}
+
+		nameStart := i
+		for i < len(s) && !isHTMLSpace(s[i]) && strings.IndexByte(`"'<>/=`, s[i]) < 0 {
+			i++
+		}
+		if i == nameStart {
+			return t, 0
+		}
+		attr := htmlAttr{name: strings.ToLower(s[nameStart:i])}
+
+		j := i
+		for j < len(s) && isHTMLSpace(s[j]) {
+			j++
+		}
+		if j < len(s) && s[j] == '=' {
+			j++
+			for j < len(s) && isHTMLSpace(s[j]) {
+				j++
+			}
+			if j >= len(s) {
+				return t, 0
+			}
+			if quote := s[j]; quote == '"' || quote == '\'' {
+				end := strings.IndexByte(s[j+1:], quote)
+				if end < 0 {
+					return t, 0
+				}
+				attr.value = html.UnescapeString(s[j+1 : j+1+end])
+				i = j + end + 2
+			} else {
+				valueStart := j
+				for j < len(s) && !isHTMLSpace(s[j]) && strings.IndexByte("\"'=<>`", s[j]) < 0 {
+					j++
+				}
+				if j == valueStart {
+					return t, 0
+				}
+				attr.value = html.UnescapeString(s[valueStart:j])
+				i = j
+			}
+		}
+		t.attrs = append(t.attrs, attr)
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// safeURL returns url if it is relative or uses http, https or mailto (not mailto
+// for images), and "" otherwise. Whitespace and control characters a browser would
+// ignore in the scheme are removed first, so "java\tscript:" is caught.
+func safeURL(url string, image bool) string {
+	url = strings.Map(func(c rune) rune {
+		if c < 0x20 || c == 0x7f {
+			return -1
+		}
+		return c
+	}, strings.TrimSpace(url))
+
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return url
+	}
+	switch strings.ToLower(url[:colon]) {
+	case "http", "https":
+		return url
+	case "mailto":
+		if !image {
+			return url
+		}
+	}
+	return ""
+}
+
+// isExternal reports whether href leaves the site
+func isExternal(href string) bool {
+	lower := strings.ToLower(href)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(href, "//")
+}
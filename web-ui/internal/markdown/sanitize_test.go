@@ -0,0 +1,113 @@
+package markdown
+
+import "testing"
+
+type renderTest struct {
+	name string
+	src  string
+	want string
+}
+
+func runRenderTests(t *testing.T, tests []renderTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Render(tt.src)); got != tt.want {
+				t.Errorf("Render(%q)\n got: %q\nwant: %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderURLSchemes(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		// Markdown links and images
+		{"javascript link", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+		{"vbscript link", "[x](vbscript:msgbox)", "<p>x</p>\n"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"data image", "![x](data:image/png;base64,AAA)", "<p>x</p>\n"},
+		{"mailto image", "![x](mailto:a@example.com)", "<p>x</p>\n"},
+		{"angle bracket destination", "[x](<javascript:alert(1)>)", "<p>x</p>\n"},
+		{"reference definition", "[x][1]\n\n[1]: javascript:alert(1)", "<p>x</p>\n"},
+		{"autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"entity encoded tab", "[x](java&#x09;script:alert(1))", "<p>x</p>\n"},
+		{"entity encoded letter", "[x](&#106;avascript:alert(1))", "<p>x</p>\n"},
+		{"percent encoded newline", "[x](java%0ascript:alert(1))", "<p>x</p>\n"},
+		{"https link", "[x](https://example.com)",
+			`<p><a href="https://example.com" target="_blank" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"mailto link", "[x](mailto:a@example.com)", `<p><a href="mailto:a@example.com">x</a></p>` + "\n"},
+		{"colon after the path", "[x](/challenge/1?x=javascript:1)", `<p><a href="/challenge/1?x=javascript:1">x</a></p>` + "\n"},
+		{"fragment", "[x](#Intro)", `<p><a href="#user-content-Intro">x</a></p>` + "\n"},
+
+		// Raw HTML attributes
+		{"raw javascript href", `<a href="javascript:alert(1)" title="t">x</a>`,
+			`<p><a title="t" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw entity encoded tab", `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw entity encoded letter", `<a href="&#x6A;avascript:alert(1)">x</a>`, `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw zero padded entity", `<a href="&#0000106;avascript:alert(1)">x</a>`, `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw named entity colon", `<a href="javascript&colon;alert(1)">x</a>`, `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw leading space", `<a href=" javascript:alert(1)">x</a>`, `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw newline in scheme", "<a href=\"java\nscript:alert(1)\">x</a>", `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw NUL in scheme", "<a href=\"java\x00script:alert(1)\">x</a>", `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw control character in scheme", "<a href=\"java\x01script:alert(1)\">x</a>", `<p><a rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"raw data image", `<img src="data:image/png;base64,AAA" alt="a">`, `<img alt="a">` + "\n"},
+		{"raw relative href", `<a href="/docs">x</a>`, `<p><a href="/docs" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+	})
+}
+
+func TestRenderDroppedTags(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"script block", "<script>alert(1)</script>\n\nafter", "\n<p>after</p>\n"},
+		{"unclosed script block", "<script>alert(1)\n\nafter", ""},
+		{"inline script", "text <script>alert(1)</script> more", "<p>text  more</p>\n"},
+		{"unclosed inline script", "text <script>alert(1)", "<p>text </p>\n"},
+		{"script in svg", "<svg><script>alert(1)</script></svg>after", "<p>after</p>\n"},
+		{"svg with handler", "<svg onload=alert(1)><circle/></svg>", "<p></p>\n"},
+		{"style block", "<style>body{display:none}</style>\n\nafter", "\n<p>after</p>\n"},
+		{"upper case style", "<STYLE>x</STYLE >y", "y\n"},
+		{"unclosed style in paragraph", "<p>a <style>x", "<p>a </p>"},
+		{"longer closing tag name", "<script>a</scriptx>b</script>c", "c\n"},
+		{"split tag", "<scr<script>ipt>alert(1)</script>", "<p>&lt;scr</p>\n"},
+		{"iframe", `<iframe src="https://example.com">inside</iframe>after`, "after\n"},
+		{"textarea", "<textarea>x</textarea>after", "after\n"},
+		{"form controls", "<form action=x><input name=y></form>", "\n"},
+		{"link inside unclosed svg", `x <svg><a href="javascript:1">y</a>`, "<p>x </p>\n"},
+		{"svg content ends with its block", "<div>\n<svg>\n\n**shown**\n\n</svg>\n</div>",
+			"<div>\n<p><strong>shown</strong></p>\n\n</div>\n"},
+	})
+}
+
+func TestRenderEventHandlers(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"onerror", `<img src=x onerror=alert(1)>`, `<img src="x">` + "\n"},
+		{"upper case", `<IMG SRC=x OnError=alert(1)>`, `<img src="x">` + "\n"},
+		{"quoted self-closing", `<img src=x onerror="alert(1)"/>`, `<img src="x">` + "\n"},
+		{"several handlers", `<div onclick="x" ONMOUSEOVER=y>z</div>`, "<div>z</div>\n"},
+		{"spaces around equals", `<a href="/" onclick = "alert(1)">x</a>`,
+			`<p><a href="/" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"autofocus", `<a href="/" onfocus=alert(1) autofocus>x</a>`,
+			`<p><a href="/" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+		{"ontoggle", `<details ontoggle=alert(1) open>x</details>`, `<details open="">x</details>` + "\n"},
+		{"slash instead of space", "<p/onmouseover=alert(1)>x", "<p>&lt;p/onmouseover=alert(1)&gt;x</p>\n"},
+		{"style, class, id and data", `<span style="position:fixed" data-x=1 class=c id=i>x</span>`, "<p><span>x</span></p>\n"},
+	})
+}
+
+func TestRenderDetails(t *testing.T) {
+	runRenderTests(t, []renderTest{
+		{"wraps Markdown", "<details>\n<summary>Hint</summary>\n\n**bold**\n\n</details>\n\nafter",
+			"<details>\n<summary>Hint</summary>\n<p><strong>bold</strong></p>\n</details>\n<p>after</p>\n"},
+		{"inline", "<details><summary>S</summary>x</details>", "<details><summary>S</summary>x</details>\n"},
+		{"nested", "<details>\n<summary>A</summary>\n\n<details>\n<summary>B</summary>\n\ninner\n\n</details>\n\nouter\n\n</details>",
+			"<details>\n<summary>A</summary>\n<details>\n<summary>B</summary>\n<p>inner</p>\n</details>\n<p>outer</p>\n</details>\n"},
+		{"wraps a list", "<details>\n\n- item\n\n</details>", "<details>\n<ul>\n<li>item</li>\n</ul>\n</details>\n"},
+		{"unclosed", "<details>\n\nunclosed", "<details>\n<p>unclosed</p>\n</details>"},
+		{"stray closing tag", "</details>\n\nstray", "\n<p>stray</p>\n"},
+		{"closes inner tags", "<details>\n<div>\n\nx\n\n</details>\n\nafter", "<details>\n<div>\n<p>x</p>\n</div></details>\n<p>after</p>\n"},
+		{"closed with its blockquote", "> <details>\n\nquoted\n\n</details>",
+			"<blockquote>\n<details>\n</details></blockquote>\n<p>quoted</p>\n\n"},
+		{"closed with its list item", "- <details>\n\n  item\n\n- next",
+			"<ul>\n<li>\n<details>\n<p>item</p>\n</details></li>\n<li>\n<p>next</p>\n</li>\n</ul>\n"},
+	})
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default is the registry served at /metrics
+var Default = NewRegistry()
+
+// runBuckets suit `go test` runs, from under a second to the two minute timeout
+var runBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300}
+
+// aiBuckets suit AI provider calls, which take seconds rather than milliseconds
+var aiBuckets = []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120}
+
+// The web UI's metrics
+var (
+	HTTPRequestDuration = Default.NewHistogram("gip_http_request_duration_seconds",
+		"Time to serve HTTP requests, by route pattern.", DefaultBuckets, "method", "route", "status")
+
+	TestRunDuration = Default.NewHistogram("gip_test_run_duration_seconds",
+		"Time to run a submission's tests, by challenge and verdict.", runBuckets, "challenge", "verdict")
+	TestRunsRunning = Default.NewGauge("gip_test_runs_running",
+		"Test runs in progress.")
+	TestRunsWaiting = Default.NewGauge("gip_test_runs_waiting",
+		"Test runs waiting for a free slot.")
+
+	AIRequestDuration = Default.NewHistogram("gip_ai_request_duration_seconds",
+		"Time for AI provider calls including retries, by task and outcome.", aiBuckets, "provider", "task", "outcome")
+	AIErrors = Default.NewCounter("gip_ai_errors_total",
+		"Failed AI provider calls, by error kind.", "provider", "task", "kind")
+	AITokens = Default.NewCounter("gip_ai_tokens_total",
+		"Tokens used by AI provider calls, by type (prompt or completion).", "provider", "model", "type")
+
+	GitHubAPICalls = Default.NewCounter("gip_github_api_calls_total",
+		"Calls to GitHub, by API and response status.", "api", "status")
+	WebhookDeliveries = Default.NewCounter("gip_webhook_deliveries_total",
+		"GitHub webhook deliveries, by event and outcome.", "event", "outcome")
+)
+
+// Handler serves the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// ObserveGitHubCall counts a GitHub call; status is the response code, or 0 if the call failed
+func ObserveGitHubCall(api string, status int) {
+	label := "error"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	GitHubAPICalls.Inc(api, label)
+}
+
+// Since returns the seconds elapsed since start, for Observe
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// routeKey holds the *string a request's route label is written to
+type routeKey struct{}
+
+// WithRoute returns a context through which handlers can name the request's route
+func WithRoute(ctx context.Context, route *string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// SetRoute names the route serving r, for routers that match more precisely than the
+// pattern the request was first dispatched on
+func SetRoute(r *http.Request, route string) {
+	if holder, ok := r.Context().Value(routeKey{}).(*string); ok {
+		*holder = route
+	}
+}
@@ -0,0 +1,302 @@
+// Package metrics keeps counters, gauges and histograms and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector is a metric family that can write itself in the text format
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metric families in registration order
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic("metrics: duplicate metric " + c.name())
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every metric in the Prometheus text format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// family is the shared part of a metric with labels
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (f *family) name() string { return f.metricName }
+
+// key joins label values into a map key
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (f *family) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, escapeHelp(f.help), f.metricName, kind)
+}
+
+// labelPairs formats {a="x",b="y"} with an optional extra pair such as le="0.5"
+func (f *family) labelPairs(values []string, extraName, extraValue string) string {
+	if len(values) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+	}
+	if extraName != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName + `="` + escapeLabel(extraValue) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter is a value that only goes up, per combination of label values
+type Counter struct {
+	family
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter registers a counter
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: family{metricName: name, help: help, labels: labels}, values: make(map[string]*counterValue)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds delta, which must not be negative
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	key := c.key(labels)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string(nil), labels...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(v.labels, "", ""), formatFloat(v.value))
+	}
+}
+
+// Gauge is a value that goes up and down, per combination of label values
+type Gauge struct {
+	family
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+// NewGauge registers a gauge
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: family{metricName: name, help: help, labels: labels}, values: make(map[string]*counterValue)}
+	r.register(g)
+	return g
+}
+
+// Add changes the gauge by delta
+func (g *Gauge) Add(delta float64, labels ...string) {
+	key := g.key(labels)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, ok := g.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string(nil), labels...)}
+		g.values[key] = v
+	}
+	v.value += delta
+}
+
+// Inc adds one
+func (g *Gauge) Inc(labels ...string) { g.Add(1, labels...) }
+
+// Dec subtracts one
+func (g *Gauge) Dec(labels ...string) { g.Add(-1, labels...) }
+
+// Set replaces the value
+func (g *Gauge) Set(value float64, labels ...string) {
+	key := g.key(labels)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[key] = &counterValue{labels: append([]string(nil), labels...), value: value}
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w, "gauge")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.labels) == 0 && len(g.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", g.metricName)
+		return
+	}
+	for _, key := range sortedKeys(g.values) {
+		v := g.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labelPairs(v.labels, "", ""), formatFloat(v.value))
+	}
+}
+
+// Histogram counts observations in cumulative buckets, per combination of label values
+type Histogram struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets suit latencies in seconds from a few milliseconds to ten seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// NewHistogram registers a histogram with the given upper bucket bounds
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &Histogram{family: family{metricName: name, help: help, labels: labels}, buckets: buckets, values: make(map[string]*histogramValue)}
+	r.register(h)
+	return h
+}
+
+// Observe records one value
+func (h *Histogram) Observe(value float64, labels ...string) {
+	key := h.key(labels)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+			break
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(v.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(v.labels, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(v.labels, "", ""), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(v.labels, "", ""), v.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
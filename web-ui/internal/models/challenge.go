@@ -1,6 +1,7 @@
 package models
 
 import (
+	"html/template"
 	"time"
 )
 
@@ -14,6 +15,18 @@ type Challenge struct {
 	TestFile          string `json:"testFile"`
 	LearningMaterials string `json:"learningMaterials"`
 	Hints             string `json:"hints"`
+	// Tags and LearningObjectives come from the optional metadata.json
+	Tags               []string `json:"tags,omitempty"`
+	LearningObjectives []string `json:"learningObjectives,omitempty"`
+	// AIPrompts adds challenge-specific instructions to the AI prompts, keyed by task
+	// (e.g. "code_review"). Loaded from an optional metadata.json; not sent to clients.
+	AIPrompts map[string]string `json:"-"`
+	// Dir is the challenge's directory in the repository; not sent to clients
+	Dir string `json:"-"`
+	// DescriptionHTML and LearningHTML are Description and LearningMaterials rendered
+	// from Markdown when the challenge is loaded; not sent to clients
+	DescriptionHTML template.HTML `json:"-"`
+	LearningHTML    template.HTML `json:"-"`
 }
 
 // Submission represents a user's submitted solution
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"html/template"
 	"time"
 )
 
@@ -13,6 +14,9 @@ type Package struct {
 	GitHubURL        string                    `json:"github_url"`
 	DocumentationURL string                    `json:"documentation_url"`
 	Stars            int                       `json:"stars"`
+	LatestRelease    string                    `json:"latest_release,omitempty"` // Latest release tag on GitHub
+	LastCommitAt     *time.Time                `json:"last_commit_at,omitempty"` // Last commit on GitHub
+	Outdated         bool                      `json:"outdated"`                 // Version is behind the latest release
 	Category         string                    `json:"category"`
 	Difficulty       string                    `json:"difficulty"`
 	Prerequisites    []string                  `json:"prerequisites"`
@@ -54,6 +58,9 @@ type ChallengeMetadata struct {
 	BonusPoints         []string `json:"bonus_points"`
 	Icon                string   `json:"icon,omitempty"`
 	Order               int      `json:"order"`
+	// AIPrompts adds challenge-specific instructions to the AI prompts, keyed by task
+	// (e.g. "code_review", "code_hint")
+	AIPrompts map[string]string `json:"ai_prompts,omitempty"`
 }
 
 // PackageChallenge represents a challenge specific to a package
@@ -78,6 +85,14 @@ type PackageChallenge struct {
 	Icon                string   `json:"icon,omitempty"`
 	Order               int      `json:"order"`
 	Status              string   `json:"status,omitempty"` // "available", "coming-soon", etc.
+	// AIPrompts holds the ai_prompts from metadata.json; not sent to clients
+	AIPrompts map[string]string `json:"-"`
+	// Dir is the challenge's directory in the repository; not sent to clients
+	Dir string `json:"-"`
+	// DescriptionHTML and LearningHTML are Description and LearningMaterials rendered
+	// from Markdown when the challenge is loaded; not sent to clients
+	DescriptionHTML template.HTML `json:"-"`
+	LearningHTML    template.HTML `json:"-"`
 }
 
 // PackageSubmission represents a user's submitted solution for a package challenge
@@ -0,0 +1,306 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON object in the OpenAPI document
+type Schema = map[string]interface{}
+
+// Spec configures the OpenAPI document generated from the routes
+type Spec struct {
+	Title       string
+	Version     string
+	Description string
+	// FieldName maps a json tag name to the name clients see; the tag name if nil
+	FieldName func(string) string
+	// Envelope wraps the schema of a response's data, for routes not marked Raw
+	Envelope func(data Schema) Schema
+	// Error is a value of the error response body's type, documented as every route's default response
+	Error interface{}
+}
+
+// OpenAPI generates an OpenAPI 3 document describing the routes. Request and response
+// schemas are derived from the Go types in each route's Doc through their json tags.
+func (rt *Router) OpenAPI(spec Spec) Schema {
+	g := &schemaGenerator{
+		fieldName: spec.FieldName,
+		names:     make(map[reflect.Type]string),
+		schemas:   make(Schema),
+	}
+	if g.fieldName == nil {
+		g.fieldName = func(name string) string { return name }
+	}
+
+	components := Schema{
+		"schemas": g.schemas,
+		"securitySchemes": Schema{
+			"bearerAuth": Schema{"type": "http", "scheme": "bearer", "description": "A personal API token"},
+			"cookieAuth": Schema{"type": "apiKey", "in": "cookie", "name": "session", "description": "A browser session; requests that change state also send the csrf_token cookie's value in the X-CSRF-Token header"},
+		},
+	}
+	if spec.Error != nil {
+		components["responses"] = Schema{
+			"Error": Schema{
+				"description": "The request failed",
+				"content":     Schema{"application/json": Schema{"schema": g.schema(reflect.TypeOf(spec.Error))}},
+			},
+		}
+	}
+
+	paths := make(Schema)
+	for _, route := range rt.routes {
+		item, _ := paths[route.Pattern].(Schema)
+		if item == nil {
+			item = make(Schema)
+			paths[route.Pattern] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(route, spec)
+	}
+
+	return Schema{
+		"openapi": "3.0.3",
+		"info": Schema{
+			"title":       spec.Title,
+			"version":     spec.Version,
+			"description": spec.Description,
+		},
+		"paths":      paths,
+		"components": components,
+	}
+}
+
+// operation describes one route
+func (g *schemaGenerator) operation(route *Route, spec Spec) Schema {
+	doc := route.Doc
+	op := Schema{
+		"operationId": operationID(route),
+		"summary":     doc.Summary,
+	}
+	description := doc.Description
+	if doc.Scope != "" {
+		description = strings.TrimSpace(description + "\n\nAPI tokens need the `" + doc.Scope + "` scope.")
+	}
+	if description != "" {
+		op["description"] = description
+	}
+	if doc.Tag != "" {
+		op["tags"] = []string{doc.Tag}
+	}
+	if doc.Auth {
+		op["security"] = []Schema{{"bearerAuth": []string{}}, {"cookieAuth": []string{}}}
+	}
+
+	var parameters []Schema
+	declared := make(map[string]Parameter)
+	for _, p := range doc.Path {
+		declared[p.Name] = p
+	}
+	for _, segment := range route.segments {
+		if name, ok := paramName(segment); ok {
+			p := declared[name]
+			p.Name, p.Required = name, true
+			parameters = append(parameters, parameter(p, "path"))
+		}
+	}
+	for _, p := range doc.Query {
+		parameters = append(parameters, parameter(p, "query"))
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if doc.Request != nil {
+		op["requestBody"] = Schema{
+			"required": true,
+			"content":  Schema{"application/json": Schema{"schema": g.schema(reflect.TypeOf(doc.Request))}},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := Schema{"description": http.StatusText(status)}
+	switch {
+	case doc.Stream:
+		response["content"] = Schema{"text/event-stream": Schema{"schema": Schema{"type": "string"}}}
+	case doc.Response != nil || !doc.Raw:
+		data := Schema{}
+		if doc.Response != nil {
+			data = g.schema(reflect.TypeOf(doc.Response))
+		}
+		if !doc.Raw && spec.Envelope != nil {
+			data = spec.Envelope(data)
+		}
+		response["content"] = Schema{"application/json": Schema{"schema": data}}
+	}
+	responses := Schema{strconv.Itoa(status): response}
+	if spec.Error != nil {
+		responses["default"] = Schema{"$ref": "#/components/responses/Error"}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// parameter describes a path or query parameter
+func parameter(p Parameter, in string) Schema {
+	kind := p.Type
+	if kind == "" {
+		kind = "string"
+	}
+	schema := Schema{
+		"name":     p.Name,
+		"in":       in,
+		"required": p.Required,
+		"schema":   Schema{"type": kind},
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	return schema
+}
+
+// operationID names a route from its method and static segments,
+// e.g. GET /api/v1/challenges/{id}/scoreboard is getChallengesScoreboard
+func operationID(route *Route) string {
+	id := strings.ToLower(route.Method)
+	for _, segment := range route.segments {
+		if _, ok := paramName(segment); ok || segment == "api" || segment == "v1" {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// schemaGenerator turns Go types into schemas, collecting named structs as components
+type schemaGenerator struct {
+	fieldName func(string) string
+	names     map[reflect.Type]string
+	schemas   Schema
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage(nil))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema returns the schema for t, a $ref for named structs
+func (g *schemaGenerator) schema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case t == rawJSONType, t.Kind() == reflect.Interface:
+		return Schema{}
+	case t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType):
+		return Schema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t.PkgPath() == "time" && t.Name() == "Duration" {
+			return Schema{"type": "integer", "description": "Nanoseconds"}
+		}
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			g.schemas[name] = Schema{} // Reserve the name for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	return Schema{}
+}
+
+// componentName names a struct's schema, capitalized, qualifying it with its package when two
+// packages have types of the same name
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+// object describes a struct's JSON fields, flattening embedded structs the way
+// encoding/json does. Fields without omitempty are required.
+func (g *schemaGenerator) object(t reflect.Type) Schema {
+	properties := make(Schema)
+	var required []string
+	g.fields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *schemaGenerator) fields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.fields(fieldType, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		name = g.fieldName(name)
+		properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package router matches requests by method and path pattern, with named path
+// parameters such as /challenges/{id}, and keeps a description of every route from
+// which the OpenAPI document is generated.
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Doc describes a route for the OpenAPI document
+type Doc struct {
+	Summary     string
+	Description string
+	Tag         string
+	Auth        bool        // Requires a signed-in user or an API token; enforced by Router.Authorize
+	Scope       string      // API token scope the route requires, if any; enforced by Router.Authorize
+	Path        []Parameter // Types and descriptions of the path parameters; undeclared ones are strings
+	Query       []Parameter
+	Request     interface{} // A value of the JSON request body's type, nil if there is no body
+	Response    interface{} // A value of the response data's type, nil if there is no data
+	Status      int         // Success status; 200 if zero
+	Stream      bool        // The response is a text/event-stream
+	Raw         bool        // The response is written as is, without the envelope
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string
+	Type        string // OpenAPI type: string (default), integer or boolean
+	Description string
+	Required    bool
+}
+
+// Route is a registered method and pattern
+type Route struct {
+	Method   string
+	Pattern  string
+	Handler  http.HandlerFunc
+	Doc      Doc
+	segments []string
+}
+
+// Router dispatches to the route matching the method and path. Static segments win
+// over parameters, so /interviews/active matches before /interviews/{id}.
+type Router struct {
+	routes []*Route
+
+	// NotFound handles paths that match no route
+	NotFound http.HandlerFunc
+	// MethodNotAllowed handles paths that match only routes for other methods; the
+	// Allow header is already set
+	MethodNotAllowed http.HandlerFunc
+	// Matched, if set, is told which route serves a request before its handler runs
+	Matched func(r *http.Request, route *Route)
+	// Authorize, if set, checks a request against its route's Doc.Auth and Doc.Scope
+	// before the handler runs. It returns false to refuse the request, having written
+	// the response.
+	Authorize func(w http.ResponseWriter, r *http.Request, route *Route) bool
+}
+
+// New creates an empty router that answers unmatched requests with plain 404 and 405 errors
+func New() *Router {
+	return &Router{
+		NotFound: http.NotFound,
+		MethodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		},
+	}
+}
+
+// Handle registers handler for method and pattern. Pattern segments written {name} match
+// any single segment, available to the handler through PathParam.
+func (rt *Router) Handle(method, pattern string, handler http.HandlerFunc, doc Doc) {
+	rt.routes = append(rt.routes, &Route{
+		Method:   method,
+		Pattern:  pattern,
+		Handler:  handler,
+		Doc:      doc,
+		segments: splitPath(pattern),
+	})
+}
+
+// Routes returns the registered routes in registration order
+func (rt *Router) Routes() []Route {
+	routes := make([]Route, len(rt.routes))
+	for i, route := range rt.routes {
+		routes[i] = *route
+	}
+	return routes
+}
+
+type paramsKey struct{}
+
+// PathParam returns the value of the named path parameter, or "" if the route has none
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// ServeHTTP dispatches the request to the best matching route
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.EscapedPath())
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	var best *Route
+	var bestParams map[string]string
+	bestScore := -1
+	allowed := make(map[string]bool)
+	for _, route := range rt.routes {
+		params, score, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		allowed[route.Method] = true
+		if route.Method == method && score > bestScore {
+			best, bestParams, bestScore = route, params, score
+		}
+	}
+
+	if best == nil {
+		if len(allowed) == 0 {
+			rt.NotFound(w, r)
+			return
+		}
+		methods := make([]string, 0, len(allowed))
+		for m := range allowed {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		rt.MethodNotAllowed(w, r)
+		return
+	}
+
+	if len(bestParams) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, bestParams))
+	}
+	if rt.Matched != nil {
+		rt.Matched(r, best)
+	}
+	if rt.Authorize != nil && !rt.Authorize(w, r, best) {
+		return
+	}
+	best.Handler(w, r)
+}
+
+// match reports whether the route matches the path segments, with the parameters and a
+// score that ranks earlier static segments above parameters
+func (route *Route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(route.segments) {
+		return nil, 0, false
+	}
+	var params map[string]string
+	score := 0
+	for i, pattern := range route.segments {
+		if name, ok := paramName(pattern); ok {
+			if segments[i] == "" {
+				return nil, 0, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if pattern != segments[i] {
+			return nil, 0, false
+		}
+		score |= 1 << uint(len(route.segments)-i)
+	}
+	return params, score, true
+}
+
+// paramName returns the name of a {name} pattern segment
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// splitPath splits a path into segments, ignoring the leading and a trailing slash
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// readHeaderTimeout bounds reading request headers, so slow clients cannot hold connections
+const readHeaderTimeout = 10 * time.Second
+
+// cancelGracePeriod is how long handlers get to answer once their test runs are canceled
+const cancelGracePeriod = 5 * time.Second
+
+// ListenOptions configure the HTTP server around the routes
+type ListenOptions struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	APIWriteTimeout time.Duration // Replaces WriteTimeout for API calls that change state
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // Waiting for in-flight requests before test runs are canceled
+	MaxBodyBytes    int64
+	TLSCertFile     string // HTTPS when both files are set
+	TLSKeyFile      string
+}
+
+// ListenAndServe serves handler until SIGINT or SIGTERM. It then reports not ready, stops
+// accepting connections and waits up to the shutdown timeout for in-flight requests,
+// including test runs; runs still going after that are canceled.
+func (s *Server) ListenAndServe(handler http.Handler, options ListenOptions) error {
+	httpServer := &http.Server{
+		Addr:              options.Addr,
+		Handler:           withBodyLimit(withAPIWriteTimeout(handler, options.APIWriteTimeout), options.MaxBodyBytes),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       options.ReadTimeout,
+		WriteTimeout:      options.WriteTimeout,
+		IdleTimeout:       options.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if options.TLSCertFile != "" {
+			slog.Info("Server starting", "url", "https://"+displayAddr(options.Addr))
+			serveErr <- httpServer.ListenAndServeTLS(options.TLSCertFile, options.TLSKeyFile)
+		} else {
+			slog.Info("Server starting", "url", "http://"+displayAddr(options.Addr))
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process
+
+	s.shuttingDown.Store(true)
+	slog.Info("Shutting down, waiting for running tests and other requests",
+		"timeout", options.ShutdownTimeout, "running_tests", s.executionService.RunningTests())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Requests still running, canceling test runs", "timeout", options.ShutdownTimeout, "running_tests", s.executionService.RunningTests())
+		s.executionService.CancelRuns()
+
+		graceCtx, cancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+		defer cancel()
+		if err := httpServer.Shutdown(graceCtx); err != nil {
+			httpServer.Close()
+		}
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// readiness reports whether the server should receive traffic: content is loaded and
+// it is not shutting down. /health only reports that the process is up.
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	status, state := http.StatusOK, "ready"
+	switch {
+	case s.shuttingDown.Load():
+		status, state = http.StatusServiceUnavailable, "shutting down"
+	case len(s.challengeService.GetChallenges()) == 0:
+		status, state = http.StatusServiceUnavailable, "no challenges loaded"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       state,
+		"runningTests": s.executionService.RunningTests(),
+	})
+}
+
+// displayAddr turns a listen address such as ":8080" into one to open in a browser
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
+	}
+	return addr
+}
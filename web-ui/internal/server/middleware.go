@@ -0,0 +1,179 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-ui/internal/handlers"
+	"web-ui/internal/logging"
+	"web-ui/internal/metrics"
+	"web-ui/internal/services"
+)
+
+// withObservability gives each request an ID, taken from a well-formed X-Request-ID header
+// or generated, and echoes it back. It logs the request once it is served and records its
+// latency by route: the mux pattern, or the more precise one a router names with metrics.SetRoute.
+func withObservability(next http.Handler, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = metrics.WithRoute(ctx, &route)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		duration := time.Since(start)
+		metrics.HTTPRequestDuration.Observe(duration.Seconds(), r.Method, route, strconv.Itoa(recorder.status))
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration_ms", duration.Milliseconds(),
+			"remote", r.RemoteAddr)
+	})
+}
+
+// statusRecorder remembers the status and size of a response. It flushes through, so
+// server-sent event streams keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// webhookMaxBodyBytes is the body limit for GitHub webhook deliveries, which GitHub
+// caps at 25 MB
+const webhookMaxBodyBytes = 25 << 20
+
+// withBodyLimit rejects request bodies larger than limit bytes, or webhookMaxBodyBytes
+// for GitHub webhooks: declared sizes up front with 413, and undeclared ones when a
+// handler reads past the limit
+func withBodyLimit(next http.Handler, limit int64) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := limit
+		if r.URL.Path == "/webhook/github" {
+			limit = max(limit, webhookMaxBodyBytes)
+		}
+		if r.ContentLength > limit {
+			handlers.WriteError(w, r, http.StatusRequestEntityTooLarge, handlers.CodePayloadTooLarge, "Request body too large")
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withAPIWriteTimeout gives API calls that change state, such as test runs, AI calls
+// and AI streams, timeout to write their response. Everything else keeps the server's
+// shorter write timeout.
+func withAPIWriteTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if changesState(r) && strings.HasPrefix(r.URL.Path, "/api/") {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+				slog.Warn("Could not extend the write deadline", "path", r.URL.Path, "error", err)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withLegacyAPIHeaders marks the unversioned /api routes as deprecated in favor of
+// /api/v1 and points clients at its description
+func withLegacyAPIHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") && !handlers.IsV1Path(r.URL.Path) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", `</api/v1/openapi.json>; rel="service-desc"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withIdentity resolves the client address and the bearer token or session cookie and
+// binds them to the request context
+func (s *Server) withIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(services.WithClientIP(r.Context(), s.clientIP(r)))
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token, err := s.tokenService.Authenticate(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+			if err != nil {
+				handlers.WriteError(w, r, http.StatusUnauthorized, handlers.CodeUnauthorized, err.Error())
+				return
+			}
+
+			if allowed, retryAfter := s.tokenService.Allow(token); !allowed {
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+				handlers.WriteError(w, r, http.StatusTooManyRequests, handlers.CodeRateLimited, "Rate limit exceeded for API token")
+				return
+			}
+
+			identity := &services.Identity{
+				Username: token.Username,
+				Provider: "token",
+				Token:    token,
+			}
+			next.ServeHTTP(w, r.WithContext(services.WithIdentity(r.Context(), identity)))
+			return
+		}
+
+		if identity := s.authService.IdentityFromRequest(r); identity != nil {
+			r = r.WithContext(services.WithIdentity(r.Context(), identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	handler := withBodyLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), 1024)
+
+	tests := []struct {
+		name   string
+		path   string
+		size   int
+		chunks bool // Send without Content-Length, so the limit applies while reading
+		status int
+	}{
+		{"within the limit", "/api/run", 1024, false, http.StatusOK},
+		{"declared too large", "/api/run", 1025, false, http.StatusRequestEntityTooLarge},
+		{"undeclared too large", "/api/run", 1025, true, http.StatusRequestEntityTooLarge},
+		{"webhook over the general limit", "/webhook/github", 2 << 20, false, http.StatusOK},
+		{"webhook over GitHub's cap", "/webhook/github", webhookMaxBodyBytes + 1, false, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(strings.Repeat("x", tt.size))
+			if tt.chunks {
+				body = io.MultiReader(body)
+			}
+			r := httptest.NewRequest("POST", tt.path, body)
+			if tt.chunks {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("got status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
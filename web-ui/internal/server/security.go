@@ -0,0 +1,148 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"web-ui/internal/handlers"
+	"web-ui/internal/services"
+)
+
+// SecurityOptions configure rate limits and how the client is identified behind a proxy
+type SecurityOptions struct {
+	UserRateLimit int  // Requests that change state per minute for each signed-in user; 0 disables
+	IPRateLimit   int  // Requests that change state per minute for each client IP; 0 disables
+	TrustProxy    bool // Take the client IP from X-Forwarded-For and the scheme from X-Forwarded-Proto
+	ProxyHops     int  // Trusted proxies in front of the server; 1 when unset
+}
+
+// contentSecurityPolicy allows the CDNs the templates load scripts, styles and fonts
+// from, GitHub avatars and badges, and the star button frame. Templates use inline
+// scripts and handlers, so scripts cannot be restricted further without rewriting them.
+var contentSecurityPolicy = strings.Join([]string{
+	"default-src 'self'",
+	"script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://cdnjs.cloudflare.com https://www.googletagmanager.com",
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://cdnjs.cloudflare.com",
+	"font-src 'self' data: https://cdn.jsdelivr.net https://cdnjs.cloudflare.com",
+	"img-src 'self' data: https:",
+	"connect-src 'self' https://*.google-analytics.com https://www.googletagmanager.com",
+	"worker-src 'self' blob:",
+	"frame-src https://ghbtns.com",
+	"frame-ancestors 'none'",
+	"base-uri 'self'",
+	"form-action 'self'",
+	"object-src 'none'",
+}, "; ")
+
+// withSecurityHeaders sets the Content-Security-Policy and related headers on every
+// response, and Strict-Transport-Security on those served over HTTPS
+func (s *Server) withSecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Content-Security-Policy", contentSecurityPolicy)
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if r.TLS != nil || (s.security.TrustProxy && r.Header.Get("X-Forwarded-Proto") == "https") {
+			header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCSRF protects browser sessions from cross-site requests: requests that change
+// state and are authenticated by the session cookie must send the session's CSRF token
+// in the X-CSRF-Token header. The token is handed to the page's scripts in a cookie.
+// Requests with an API token are not affected, as browsers never add those themselves.
+func (s *Server) withCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := services.IdentityFromContext(r.Context())
+		if identity == nil || identity.Token != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := s.authService.CSRFToken(r)
+		if cookie, err := r.Cookie(services.CSRFCookieName); err != nil || cookie.Value != token {
+			http.SetCookie(w, s.authService.CSRFCookie(token))
+		}
+		if changesState(r) && !s.authService.VerifyCSRF(r) {
+			handlers.WriteError(w, r, http.StatusForbidden, handlers.CodeInvalidCSRF,
+				"Missing or invalid CSRF token; send the csrf_token cookie's value in the "+services.CSRFHeaderName+" header")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRateLimit limits requests that change state under /api, such as test runs,
+// saves and AI calls, per client IP and per signed-in user
+func (s *Server) withRateLimit(next http.Handler, byUser, byIP *services.RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !changesState(r) || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if byIP != nil {
+			ip := services.ClientIPFromContext(r.Context())
+			if ip == "" {
+				ip = s.clientIP(r)
+			}
+			if allowed, retryAfter := byIP.Allow(ip); !allowed {
+				writeRateLimited(w, r, retryAfter, "Too many requests from this address")
+				return
+			}
+		}
+		if identity := services.IdentityFromContext(r.Context()); byUser != nil && identity != nil {
+			if allowed, retryAfter := byUser.Allow(identity.Username); !allowed {
+				writeRateLimited(w, r, retryAfter, "Too many requests for user "+identity.Username)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, message string) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+	handlers.WriteError(w, r, http.StatusTooManyRequests, handlers.CodeRateLimited, message+"; try again later")
+}
+
+// clientIP returns the address of the client, from X-Forwarded-For when the server is
+// configured to trust its proxies. Clients can send X-Forwarded-For themselves and each
+// proxy appends the address it received the request from, so only the entries added by
+// the trusted proxies, counted from the right, are believed.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.security.TrustProxy {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(header, ",")...)
+		}
+		if len(entries) > 0 {
+			hops := max(s.security.ProxyHops, 1)
+			entry := entries[max(len(entries)-hops, 0)]
+			if ip := net.ParseIP(strings.TrimSpace(entry)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// changesState reports whether the request's method may change server state
+func changesState(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
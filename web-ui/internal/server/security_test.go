@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"web-ui/internal/services"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     bool
+		hops      int
+		forwarded []string
+		want      string
+	}{
+		{"proxy not trusted", false, 1, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"no header", true, 1, nil, "192.0.2.1"},
+		{"one proxy", true, 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entries on the left", true, 1, []string{"10.0.0.1, 198.51.100.9, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", true, 2, []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"repeated headers", true, 2, []string{"10.0.0.1", "203.0.113.7", "198.51.100.2"}, "203.0.113.7"},
+		{"fewer entries than proxies", true, 3, []string{"203.0.113.7, 198.51.100.2"}, "203.0.113.7"},
+		{"unset hops trusts one proxy", true, 0, []string{"10.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"not an address", true, 1, []string{"203.0.113.7, unknown"}, "192.0.2.1"},
+		{"IPv6", true, 1, []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{security: SecurityOptions{TrustProxy: tt.trust, ProxyHops: tt.hops}}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	t.Setenv("AUTH_PROVIDER", "")
+	t.Setenv("GITHUB_CLIENT_ID", "")
+	t.Setenv("SESSION_SECRET", "test-secret")
+	authService, err := services.NewAuthService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{authService: authService}
+	handler := s.withCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	session, err := authService.SessionCookie(&services.Identity{Username: "alice", Provider: "github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := authService.SessionCookie(&services.Identity{Username: "bob", Provider: "github"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := authService.CSRFToken(requestWithCookie(session))
+	otherToken := authService.CSRFToken(requestWithCookie(other))
+	if token == "" || token == otherToken {
+		t.Fatalf("tokens %q and %q should differ per session", token, otherToken)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		identity *services.Identity
+		header   string
+		status   int
+	}{
+		{"read without token", "GET", &services.Identity{Username: "alice"}, "", http.StatusOK},
+		{"write with token", "POST", &services.Identity{Username: "alice"}, token, http.StatusOK},
+		{"write without token", "POST", &services.Identity{Username: "alice"}, "", http.StatusForbidden},
+		{"write with another session's token", "DELETE", &services.Identity{Username: "alice"}, otherToken, http.StatusForbidden},
+		{"write with a wrong token", "PUT", &services.Identity{Username: "alice"}, "not-the-token", http.StatusForbidden},
+		{"anonymous write", "POST", nil, "", http.StatusOK},
+		{"API token write", "POST", &services.Identity{Username: "alice", Token: &services.APIToken{}}, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := requestWithCookie(session)
+			r.Method = tt.method
+			if tt.identity != nil {
+				r = r.WithContext(services.WithIdentity(r.Context(), tt.identity))
+			}
+			if tt.header != "" {
+				r.Header.Set(services.CSRFHeaderName, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Errorf("got status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+
+	// Session requests without the cookie get the token to send back
+	r := requestWithCookie(session)
+	r = r.WithContext(services.WithIdentity(r.Context(), &services.Identity{Username: "alice"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == services.CSRFCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value != token {
+		t.Errorf("got CSRF cookie %v, want the session's token", cookie)
+	}
+}
+
+func requestWithCookie(cookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest("GET", "/api/submissions", nil)
+	r.AddCookie(cookie)
+	return r
+}
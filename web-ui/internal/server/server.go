@@ -3,14 +3,15 @@ package server
 import (
 	"embed"
 	"encoding/json"
-	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"web-ui/internal/handlers"
+	"web-ui/internal/metrics"
 	"web-ui/internal/services"
 )
 
@@ -23,6 +24,17 @@ type Server struct {
 	executionService  *services.ExecutionService
 	packageService    *services.PackageService
 	aiService         *services.AIService
+	authService       *services.AuthService
+	tokenService      *services.TokenService
+	gitService        *services.GitService
+	interviewService  *services.InterviewService
+	hintService       *services.HintService
+	searchService     *services.SearchService
+	sponsorService    *services.SponsorService
+	webhookService    *services.WebhookService
+	events            *services.EventBus
+	security          SecurityOptions
+	shuttingDown      atomic.Bool
 }
 
 // NewServer creates a new server instance
@@ -34,6 +46,15 @@ func NewServer(
 	executionService *services.ExecutionService,
 	packageService *services.PackageService,
 	aiService *services.AIService,
+	authService *services.AuthService,
+	tokenService *services.TokenService,
+	gitService *services.GitService,
+	interviewService *services.InterviewService,
+	hintService *services.HintService,
+	searchService *services.SearchService,
+	sponsorService *services.SponsorService,
+	webhookService *services.WebhookService,
+	events *services.EventBus,
 ) *Server {
 	return &Server{
 		content:           content,
@@ -43,11 +64,22 @@ func NewServer(
 		executionService:  executionService,
 		packageService:    packageService,
 		aiService:         aiService,
+		authService:       authService,
+		tokenService:      tokenService,
+		gitService:        gitService,
+		interviewService:  interviewService,
+		hintService:       hintService,
+		searchService:     searchService,
+		sponsorService:    sponsorService,
+		webhookService:    webhookService,
+		events:            events,
 	}
 }
 
-// SetupRoutes configures all HTTP routes
-func (s *Server) SetupRoutes() *http.ServeMux {
+// SetupRoutes configures all HTTP routes and wraps them with the identity, CSRF, rate
+// limit, security header, request ID, logging and metrics middleware
+func (s *Server) SetupRoutes(security SecurityOptions) http.Handler {
+	s.security = security
 	mux := http.NewServeMux()
 
 	// Setup static file handling
@@ -61,6 +93,9 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		s.executionService,
 		s.packageService,
 		s.aiService,
+		s.gitService,
+		s.hintService,
+		s.sponsorService,
 	)
 
 	webHandler := handlers.NewWebHandler(
@@ -69,8 +104,41 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		s.scoreboardService,
 		s.userService,
 		s.packageService,
+		s.sponsorService,
 	)
 
+	authHandler := handlers.NewAuthHandler(
+		s.authService,
+		s.challengeService,
+		s.userService,
+		security.TrustProxy,
+	)
+
+	tokenHandler := handlers.NewTokenHandler(s.tokenService)
+
+	interviewHandler := handlers.NewInterviewHandler(s.interviewService, s.hintService)
+
+	hintHandler := handlers.NewHintHandler(
+		s.hintService,
+		s.interviewService,
+		s.challengeService,
+		s.packageService,
+	)
+
+	searchHandler := handlers.NewSearchHandler(s.searchService)
+
+	// Versioned REST API; the unversioned /api routes below remain as deprecated aliases
+	v1Handler := handlers.NewV1Handler(apiHandler, authHandler, tokenHandler, interviewHandler, hintHandler, searchHandler)
+	mux.Handle("/api/v1/", v1Handler)
+
+	// Authentication routes
+	mux.HandleFunc("/auth/login", authHandler.Login)
+	mux.HandleFunc("/auth/callback", authHandler.Callback)
+	mux.HandleFunc("/auth/logout", authHandler.Logout)
+	mux.HandleFunc("/api/auth/me", authHandler.Me)
+	mux.HandleFunc("/api/tokens", tokenHandler.HandleTokens)
+	mux.HandleFunc("/api/tokens/", tokenHandler.RevokeToken)
+
 	// API routes
 	mux.HandleFunc("/api/challenges", apiHandler.GetAllChallenges)
 	mux.HandleFunc("/api/challenges/", apiHandler.GetChallengeByID)
@@ -79,7 +147,6 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 	mux.HandleFunc("/api/run", apiHandler.RunCode)
 	mux.HandleFunc("/api/save-to-filesystem", apiHandler.SaveSubmissionToFilesystem)
 	mux.HandleFunc("/api/refresh-attempts", apiHandler.RefreshUserAttempts)
-	mux.HandleFunc("/api/git-username", apiHandler.GetGitUsername)
 	mux.HandleFunc("/api/main-scoreboard-rank", apiHandler.GetMainScoreboardRank)
 	mux.HandleFunc("/api/main-leaderboard", apiHandler.GetMainLeaderboard)
 
@@ -92,10 +159,27 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 	mux.HandleFunc("/api/ai/code-review", apiHandler.AICodeReview)
 	mux.HandleFunc("/api/ai/interviewer-questions", apiHandler.AIInterviewerQuestions)
 	mux.HandleFunc("/api/ai/code-hint", apiHandler.AICodeHint)
+	mux.HandleFunc("/api/ai/code-review/stream", apiHandler.AICodeReviewStream)
+	mux.HandleFunc("/api/ai/code-hint/stream", apiHandler.AICodeHintStream)
+	mux.HandleFunc("/api/ai/break-solution", apiHandler.AIBreakSolution)
 	mux.HandleFunc("/api/ai/debug", apiHandler.AIDebugResponse)
+	mux.HandleFunc("/api/ai/status", apiHandler.AIStatus)
+	mux.HandleFunc("/api/admin/ai/usage", apiHandler.AIUsageReport)
+
+	// AI mock interview sessions
+	mux.HandleFunc("/api/interviews", interviewHandler.StartInterview)
+	mux.HandleFunc("/api/interviews/", interviewHandler.HandleInterview)
 
-	// GitHub webhook route
-	mux.HandleFunc("/webhook/github", apiHandler.GitHubWebhookHandler)
+	// Hint ladder: authored hints, then AI hints
+	mux.HandleFunc("/api/hints", hintHandler.GetHints)
+	mux.HandleFunc("/api/hints/next", hintHandler.NextHint)
+
+	// Search
+	mux.HandleFunc("/api/search", searchHandler.Search)
+
+	// GitHub webhook route; events are handled by the repository sync and sponsor services
+	webhookHandler := handlers.NewWebhookHandler(s.webhookService, s.events)
+	mux.HandleFunc("/webhook/github", webhookHandler.GitHubWebhookHandler)
 
 	// Health check endpoint for Railway
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -108,44 +192,14 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		})
 	})
 
+	// Prometheus metrics
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Readiness for load balancers and deploys: 503 while starting or shutting down
+	mux.HandleFunc("/ready", s.readiness)
+
 	// Debug route for sponsors
 	mux.HandleFunc("/api/debug/sponsors", apiHandler.GetSponsorsDebug)
-	mux.HandleFunc("/api/ai/status", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		provider := os.Getenv("AI_PROVIDER")
-		if provider == "" {
-			provider = "gemini"
-		}
-		var apiKey string
-		switch strings.ToLower(provider) {
-		case "openai":
-			apiKey = os.Getenv("OPENAI_API_KEY")
-		case "claude":
-			apiKey = os.Getenv("CLAUDE_API_KEY")
-		default:
-			apiKey = os.Getenv("GEMINI_API_KEY")
-		}
-
-		// Check if API key looks valid
-		hasValidKey := apiKey != "" && !strings.Contains(apiKey, "Example") && len(apiKey) > 30
-
-		response := map[string]interface{}{
-			"provider":    provider,
-			"status":      "ready",
-			"message":     fmt.Sprintf("AI provider set to: %s", provider),
-			"has_api_key": apiKey != "",
-			"key_length":  len(apiKey),
-			"key_preview": func() string {
-				if len(apiKey) > 10 {
-					return apiKey[:10] + "..."
-				}
-				return apiKey + "..."
-			}(),
-			"is_example_key": strings.Contains(apiKey, "Example"),
-			"has_valid_key":  hasValidKey,
-		}
-		json.NewEncoder(w).Encode(response)
-	})
 
 	// Web routes
 	mux.HandleFunc("/", webHandler.HomePage)
@@ -172,7 +226,17 @@ func (s *Server) SetupRoutes() *http.ServeMux {
 		}
 	})
 
-	return mux
+	var byUser, byIP *services.RateLimiter
+	if security.UserRateLimit > 0 {
+		byUser = services.NewRateLimiter(security.UserRateLimit, time.Minute)
+	}
+	if security.IPRateLimit > 0 {
+		byIP = services.NewRateLimiter(security.IPRateLimit, time.Minute)
+	}
+
+	handler := s.withRateLimit(withLegacyAPIHeaders(mux), byUser, byIP)
+	handler = s.withIdentity(s.withCSRF(handler))
+	return withObservability(s.withSecurityHeaders(handler), mux)
 }
 
 // setupStaticFiles configures static file serving
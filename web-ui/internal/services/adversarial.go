@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+const (
+	// adversarialResultMarker prefixes each case result printed by the generated test
+	adversarialResultMarker = "ADVERSARIAL_RESULT "
+	// adversarialTestFile is the name of the generated test in the workspace
+	adversarialTestFile = "solution_test.go"
+	// maxReferenceCandidates is how many accepted submissions are tried as the reference
+	maxReferenceCandidates = 5
+	// adversarialRunTimeout bounds one `go test` run of the generated cases
+	adversarialRunTimeout = 90 * time.Second
+)
+
+// Outcomes of running one adversarial case
+const (
+	CaseReturned = "returned" // The call returned; Value holds the results
+	CasePanicked = "panicked"
+	CaseTimedOut = "timed_out"
+	CaseCrashed  = "crashed" // The test binary died, e.g. on a stack overflow
+	CaseNotRun   = "not_run" // An earlier case crashed the test binary
+)
+
+var (
+	packageClausePattern = regexp.MustCompile(`(?m)^package\s+(\w+)`)
+	testFileErrorPattern = regexp.MustCompile(`(?m)^(?:\./)?` + regexp.QuoteMeta(adversarialTestFile) + `:(\d+):(?:\d+:)? (.+)$`)
+)
+
+// AdversarialCase is an input the model expects a solution to get wrong
+type AdversarialCase struct {
+	Name      string `json:"name"`
+	Rationale string `json:"rationale"`
+	Setup     string `json:"setup"` // Go statements run before the call, may be empty
+	Call      string `json:"call"`  // A Go call expression returning at least one value
+}
+
+// CaseResult is what one case did when run against a solution
+type CaseResult struct {
+	Outcome string `json:"outcome"`
+	Value   string `json:"value,omitempty"` // The rendered results, or the panic message
+}
+
+// agrees reports whether two solutions behaved the same on a case. Error values are
+// compared by whether they are nil, and panics by having happened, since their
+// messages legitimately differ between solutions.
+func (r CaseResult) agrees(other CaseResult) bool {
+	if r.Outcome != other.Outcome {
+		return false
+	}
+	return r.Outcome != CaseReturned || r.Value == other.Value
+}
+
+// ReferenceSolution is an accepted submission known to pass the challenge's tests
+type ReferenceSolution struct {
+	Author string
+	Code   string
+}
+
+// CaseRun is the outcome of running adversarial cases against two solutions
+type CaseRun struct {
+	Cases         []AdversarialCase
+	Submission    []CaseResult
+	Reference     []CaseResult
+	Dropped       []string // Cases that did not compile against the reference, with the reason
+	BuildError    string   // Set when the cases do not compile against the submission
+	ReferenceFail string   // Set when the cases could not be run against the reference
+}
+
+// ReferenceSolution returns an accepted submission from submissionsDir that passes the
+// challenge's tests, skipping the given author's own. The choice is cached per directory.
+func (es *ExecutionService) ReferenceSolution(ctx context.Context, submissionsDir string, challenge *models.Challenge, excludeAuthor string) (*ReferenceSolution, error) {
+	es.mutex.Lock()
+	cached, ok := es.references[submissionsDir]
+	es.mutex.Unlock()
+	if ok && cached.Author != excludeAuthor {
+		return cached, nil
+	}
+
+	entries, err := ioutil.ReadDir(submissionsDir)
+	if err != nil {
+		return nil, fmt.Errorf("no submissions found for this challenge")
+	}
+	authors := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != excludeAuthor {
+			authors = append(authors, entry.Name())
+		}
+	}
+	sort.Strings(authors)
+
+	tried := 0
+	for _, author := range authors {
+		if tried == maxReferenceCandidates {
+			break
+		}
+		code := readSolutionFile(filepath.Join(submissionsDir, author))
+		if code == "" {
+			continue
+		}
+		tried++
+		if !es.RunCode(ctx, code, challenge).Passed {
+			slog.InfoContext(ctx, "reference candidate does not pass the tests", "author", author, "dir", submissionsDir)
+			continue
+		}
+
+		reference := &ReferenceSolution{Author: author, Code: code}
+		es.mutex.Lock()
+		es.references[submissionsDir] = reference
+		es.mutex.Unlock()
+		return reference, nil
+	}
+	return nil, fmt.Errorf("no passing submission found for this challenge")
+}
+
+// readSolutionFile returns the Go source in a submission directory, ignoring tests
+func readSolutionFile(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	sort.Strings(files)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		if content, err := ioutil.ReadFile(file); err == nil {
+			return string(content)
+		}
+	}
+	return ""
+}
+
+// RunAdversarialCases runs the cases against the reference and then the submission.
+// Cases that do not compile against the reference are dropped, along with unused imports.
+func (es *ExecutionService) RunAdversarialCases(ctx context.Context, code string, reference *ReferenceSolution, challengeID int, cases []AdversarialCase, imports []string) *CaseRun {
+	run := &CaseRun{Cases: cases}
+
+	// Each build can reveal problems with several cases; a few rounds are enough in practice
+	for attempt := 0; ; attempt++ {
+		if len(run.Cases) == 0 {
+			run.ReferenceFail = "none of the proposed cases compile against the reference solution"
+			return run
+		}
+		test, layout, err := generateAdversarialTest(packageName(reference.Code), run.Cases, imports)
+		if err != nil {
+			run.ReferenceFail = err.Error()
+			return run
+		}
+		output, err := es.runAdversarialTest(ctx, reference.Code, test, challengeID)
+		if err != nil {
+			run.ReferenceFail = err.Error()
+			return run
+		}
+		problems := testFileErrors(output)
+		if len(problems) == 0 && strings.Contains(output, "[build failed]") {
+			run.ReferenceFail = "the reference solution does not build: " + truncateText(output, 2000)
+			return run
+		}
+		if len(problems) == 0 {
+			run.Reference = parseCaseResults(output, len(run.Cases))
+			break
+		}
+		if attempt == 2 {
+			run.ReferenceFail = "the proposed cases do not compile against the reference solution"
+			return run
+		}
+		kept, dropped, remaining, changed := pruneCases(run.Cases, imports, layout, problems)
+		if !changed {
+			// The errors are not in any case or import, e.g. a package name mismatch
+			run.ReferenceFail = "the proposed cases do not compile against the reference solution"
+			return run
+		}
+		run.Cases, run.Dropped, imports = kept, append(run.Dropped, dropped...), remaining
+	}
+
+	test, layout, err := generateAdversarialTest(packageName(code), run.Cases, imports)
+	if err != nil {
+		run.BuildError = err.Error()
+		return run
+	}
+	output, err := es.runAdversarialTest(ctx, code, test, challengeID)
+	if err != nil {
+		run.BuildError = err.Error()
+		return run
+	}
+	if problems := testFileErrors(output); len(problems) > 0 || strings.Contains(output, "[build failed]") {
+		lines := make([]string, 0, len(problems))
+		for line, message := range problems {
+			if index := layout.caseAt(line); index >= 0 {
+				message = fmt.Sprintf("%s: %s", run.Cases[index].Name, message)
+			}
+			lines = append(lines, message)
+		}
+		sort.Strings(lines)
+		if len(lines) == 0 {
+			lines = append(lines, truncateText(output, 2000))
+		}
+		run.BuildError = "the cases do not compile against your solution: " + strings.Join(lines, "; ")
+		return run
+	}
+	run.Submission = parseCaseResults(output, len(run.Cases))
+	return run
+}
+
+// runAdversarialTest runs the generated test against code and returns the output.
+// Failing tests are expected; only failures to run at all are returned as errors.
+func (es *ExecutionService) runAdversarialTest(ctx context.Context, code, test string, challengeID int) (string, error) {
+	tempDir, err := es.prepareWorkspace(ctx, code, &models.Challenge{ID: challengeID, TestFile: test})
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	release := es.acquire()
+	defer release()
+	ctx, cancel := context.WithTimeout(ctx, adversarialRunTimeout)
+	defer cancel()
+	defer context.AfterFunc(es.runs, cancel)()
+	cmd := exec.CommandContext(ctx, "go", "test", "-v", "-vet=off", "-count=1", "-run", "^TestAdversarialCases$", ".")
+	cmd.Dir = tempDir
+	killProcessGroup(cmd)
+	output, err := cmd.CombinedOutput()
+	if es.runs.Err() != nil {
+		return "", fmt.Errorf("the server is shutting down")
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("running the cases took longer than %v", adversarialRunTimeout)
+	}
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return "", fmt.Errorf("failed to run tests: %v", err)
+	}
+	return string(output), nil
+}
+
+// adversarialTestLayout records where each case and import is in the generated test,
+// so compiler errors can be traced back to them
+type adversarialTestLayout struct {
+	caseLines   [][2]int // First and last line of each case
+	importLines map[int]string
+}
+
+func (l adversarialTestLayout) caseAt(line int) int {
+	for i, lines := range l.caseLines {
+		if line >= lines[0] && line <= lines[1] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l adversarialTestLayout) importAt(line int) string {
+	return l.importLines[line]
+}
+
+var adversarialTestTemplate = template.Must(template.New("adversarial").Parse(`package {{.Package}}
+
+import (
+	advjson "encoding/json"
+	advfmt "fmt"
+	advpointers "regexp"
+	"testing"
+	advtime "time"
+{{- range .Imports}}
+	{{printf "%q" .}}
+{{- end}}
+)
+
+// Generated from AI-proposed cases; each case's results are printed for comparison
+
+type adversarialCase struct {
+	name string
+	run  func() []interface{}
+}
+
+var adversarialCases = []adversarialCase{
+{{- range .Cases}}
+	{name: {{printf "%q" .Name}}, run: func() []interface{} {
+{{.Setup}}
+		return adversarialResults({{.Call}})
+	}},
+{{- end}}
+}
+
+func adversarialResults(values ...interface{}) []interface{} {
+	return values
+}
+
+var adversarialPointer = advpointers.MustCompile("0x[0-9a-f]{6,}")
+
+// adversarialRender prints results so two solutions can be compared; errors are
+// reduced to whether they are nil since their messages differ between solutions
+func adversarialRender(values []interface{}) string {
+	rendered := ""
+	for i, value := range values {
+		if i > 0 {
+			rendered += ", "
+		}
+		if err, ok := value.(error); ok && err != nil {
+			rendered += "error"
+			continue
+		}
+		rendered += adversarialPointer.ReplaceAllString(advfmt.Sprintf("%#v", value), "0x?")
+	}
+	return rendered
+}
+
+func adversarialRun(run func() []interface{}) (outcome, value string) {
+	done := make(chan [2]string, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- [2]string{"panicked", advfmt.Sprint(r)}
+			}
+		}()
+		done <- [2]string{"returned", adversarialRender(run())}
+	}()
+	select {
+	case result := <-done:
+		return result[0], result[1]
+	case <-advtime.After(5 * advtime.Second):
+		return "timed_out", ""
+	}
+}
+
+func TestAdversarialCases(t *testing.T) {
+	for i, c := range adversarialCases {
+		outcome, value := adversarialRun(c.run)
+		line, _ := advjson.Marshal(map[string]interface{}{"case": i, "outcome": outcome, "value": value})
+		advfmt.Printf("` + adversarialResultMarker + `%s\n", line)
+	}
+}
+`))
+
+// generateAdversarialTest renders the cases as a table-driven test in package pkg
+func generateAdversarialTest(pkg string, cases []AdversarialCase, imports []string) (string, adversarialTestLayout, error) {
+	var text strings.Builder
+	err := adversarialTestTemplate.Execute(&text, map[string]interface{}{
+		"Package": pkg,
+		"Imports": imports,
+		"Cases":   cases,
+	})
+	if err != nil {
+		return "", adversarialTestLayout{}, fmt.Errorf("failed to generate the test for the cases: %v", err)
+	}
+	test := text.String()
+
+	// Locate the imports and cases by line so build errors can be mapped back to them
+	layout := adversarialTestLayout{importLines: make(map[int]string)}
+	inCase := false
+	for number, line := range strings.Split(test, "\n") {
+		lineNumber := number + 1
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "{name: ") && len(layout.caseLines) < len(cases):
+			layout.caseLines = append(layout.caseLines, [2]int{lineNumber, lineNumber})
+			inCase = true
+		case inCase:
+			layout.caseLines[len(layout.caseLines)-1][1] = lineNumber
+			inCase = trimmed != "}},"
+		default:
+			if unquoted, err := strconv.Unquote(trimmed); err == nil && containsString(imports, unquoted) {
+				layout.importLines[lineNumber] = unquoted
+			}
+		}
+	}
+	return test, layout, nil
+}
+
+// pruneCases drops the cases and removes the imports that the compiler errors point at.
+// It returns the kept cases, why each case was dropped, the remaining imports, and
+// whether anything was pruned at all.
+func pruneCases(cases []AdversarialCase, imports []string, layout adversarialTestLayout, problems map[int]string) ([]AdversarialCase, []string, []string, bool) {
+	dropped := make(map[int]string)
+	importsRemoved := false
+	for line, message := range problems {
+		if index := layout.caseAt(line); index >= 0 {
+			dropped[index] = message
+		} else if imp := layout.importAt(line); imp != "" {
+			imports = removeString(imports, imp)
+			importsRemoved = true
+		}
+	}
+	var kept []AdversarialCase
+	var reasons []string
+	for i, c := range cases {
+		if reason, ok := dropped[i]; ok {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", c.Name, reason))
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept, reasons, imports, len(dropped) > 0 || importsRemoved
+}
+
+// testFileErrors returns the compiler errors in the generated test, by line
+func testFileErrors(output string) map[int]string {
+	problems := make(map[int]string)
+	for _, match := range testFileErrorPattern.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(match[1])
+		if _, ok := problems[line]; !ok {
+			problems[line] = match[2]
+		}
+	}
+	return problems
+}
+
+// parseCaseResults reads the printed case results. Cases missing from the output were
+// cut short by a crash: the first is reported as the crash and the rest as not run.
+func parseCaseResults(output string, count int) []CaseResult {
+	results := make([]CaseResult, count)
+	seen := make([]bool, count)
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, adversarialResultMarker) {
+			continue
+		}
+		var result struct {
+			Case    int    `json:"case"`
+			Outcome string `json:"outcome"`
+			Value   string `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, adversarialResultMarker)), &result); err != nil || result.Case < 0 || result.Case >= count {
+			continue
+		}
+		results[result.Case] = CaseResult{Outcome: result.Outcome, Value: result.Value}
+		seen[result.Case] = true
+	}
+
+	crashed := false
+	for i := range results {
+		if seen[i] {
+			continue
+		}
+		if !crashed {
+			results[i] = CaseResult{Outcome: CaseCrashed, Value: crashMessage(output)}
+			crashed = true
+			continue
+		}
+		results[i] = CaseResult{Outcome: CaseNotRun}
+	}
+	return results
+}
+
+// crashMessage picks the reason a test binary died from its output
+func crashMessage(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "fatal error:") || strings.HasPrefix(line, "runtime:") || strings.HasPrefix(line, "panic:") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return "the test binary exited early"
+}
+
+// packageName returns the package clause of a Go file, main if there is none
+func packageName(code string) string {
+	if match := packageClausePattern.FindStringSubmatch(code); match != nil {
+		return match[1]
+	}
+	return "main"
+}
+
+func removeString(values []string, value string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var adversarialFixtureCases = []AdversarialCase{
+	{Name: "empty", Call: "Sum(nil)"},
+	{Name: "large", Setup: "\t\tnums := []int{1, 2}\n\t\tnums = append(nums, Big)", Call: "Sum(nums)"},
+}
+
+// generateFixtureTest renders the fixture cases and returns a function giving the
+// line number of the first line containing a string
+func generateFixtureTest(t *testing.T, imports []string) (adversarialTestLayout, func(string) int) {
+	t.Helper()
+	test, layout, err := generateAdversarialTest("main", adversarialFixtureCases, imports)
+	if err != nil {
+		t.Fatalf("generateAdversarialTest: %v", err)
+	}
+	lineOf := func(text string) int {
+		for i, line := range strings.Split(test, "\n") {
+			if strings.Contains(line, text) {
+				return i + 1
+			}
+		}
+		t.Fatalf("%q is not in the generated test:\n%s", text, test)
+		return 0
+	}
+	return layout, lineOf
+}
+
+func TestAdversarialTestLayout(t *testing.T) {
+	layout, lineOf := generateFixtureTest(t, []string{"strings", "math"})
+	tests := []struct {
+		name       string
+		line       string
+		wantCase   int
+		wantImport string
+	}{
+		{"package clause", "package main", -1, ""},
+		{"case name", `{name: "empty"`, 0, ""},
+		{"case without setup", "adversarialResults(Sum(nil))", 0, ""},
+		{"first setup line", "nums := []int{1, 2}", 1, ""},
+		{"last setup line", "nums = append(nums, Big)", 1, ""},
+		{"case call", "adversarialResults(Sum(nums))", 1, ""},
+		{"helper after the cases", "func adversarialResults", -1, ""},
+		{"proposed import", `"strings"`, -1, "strings"},
+		{"second proposed import", `"math"`, -1, "math"},
+		{"fixed import", `"testing"`, -1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := lineOf(tt.line)
+			if got := layout.caseAt(line); got != tt.wantCase {
+				t.Errorf("caseAt(%d) = %d, want %d", line, got, tt.wantCase)
+			}
+			if got := layout.importAt(line); got != tt.wantImport {
+				t.Errorf("importAt(%d) = %q, want %q", line, got, tt.wantImport)
+			}
+		})
+	}
+}
+
+func TestTestFileErrors(t *testing.T) {
+	output := "# challenge-1 [challenge-1.test]\n" +
+		"./solution_test.go:12:3: undefined: Big\n" +
+		"./solution_test.go:12:8: too many errors\n" +
+		"solution_test.go:7: \"math\" imported and not used\n" +
+		"./solution.go:4:2: undefined: x\n" +
+		"FAIL\tchallenge-1 [build failed]\n"
+	want := map[int]string{12: "undefined: Big", 7: `"math" imported and not used`}
+	if got := testFileErrors(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("testFileErrors = %v, want %v", got, want)
+	}
+}
+
+func TestPruneCases(t *testing.T) {
+	imports := []string{"strings", "math"}
+	layout, lineOf := generateFixtureTest(t, imports)
+	caseError := fmt.Sprintf("./solution_test.go:%d:23: undefined: Big\n", lineOf("nums = append(nums, Big)"))
+	importError := fmt.Sprintf("./solution_test.go:%d:2: \"math\" imported and not used\n", lineOf(`"math"`))
+	tests := []struct {
+		name        string
+		output      string
+		wantKept    []AdversarialCase
+		wantDropped []string
+		wantImports []string
+		wantChanged bool
+	}{
+		{"non-compiling case", caseError,
+			adversarialFixtureCases[:1], []string{"large: undefined: Big"}, imports, true},
+		{"unused import", importError,
+			adversarialFixtureCases, nil, []string{"strings"}, true},
+		{"case and import", caseError + importError,
+			adversarialFixtureCases[:1], []string{"large: undefined: Big"}, []string{"strings"}, true},
+		{"error outside the cases", "./solution_test.go:1:9: package main; expected package solution\n",
+			adversarialFixtureCases, nil, imports, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped, remaining, changed := pruneCases(adversarialFixtureCases, imports, layout, testFileErrors(tt.output))
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !reflect.DeepEqual(dropped, tt.wantDropped) {
+				t.Errorf("dropped = %q, want %q", dropped, tt.wantDropped)
+			}
+			if !reflect.DeepEqual(remaining, tt.wantImports) {
+				t.Errorf("imports = %q, want %q", remaining, tt.wantImports)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestParseCaseResults(t *testing.T) {
+	result := func(i int, outcome, value string) string {
+		return fmt.Sprintf("%s{\"case\":%d,\"outcome\":%q,\"value\":%q}\n", adversarialResultMarker, i, outcome, value)
+	}
+	tests := []struct {
+		name   string
+		output string
+		count  int
+		want   []CaseResult
+	}{
+		{"all cases ran",
+			"=== RUN   TestAdversarialCases\n" + result(1, CasePanicked, "index out of range") + result(0, CaseReturned, "3") + "PASS\n",
+			2, []CaseResult{{CaseReturned, "3"}, {CasePanicked, "index out of range"}}},
+		{"crash partway through",
+			result(0, CaseReturned, "0") + "runtime: goroutine stack exceeds 1000000000-byte limit\nfatal error: stack overflow\n",
+			4, []CaseResult{{CaseReturned, "0"}, {CaseCrashed, "runtime: goroutine stack exceeds 1000000000-byte limit"}, {Outcome: CaseNotRun}, {Outcome: CaseNotRun}}},
+		{"crash without a reason",
+			result(0, CaseTimedOut, "") + "signal: killed\n",
+			2, []CaseResult{{Outcome: CaseTimedOut}, {CaseCrashed, "the test binary exited early"}}},
+		{"malformed and unknown results ignored",
+			adversarialResultMarker + "{\"case\":\n" + result(5, CaseReturned, "1") + result(0, CaseReturned, "2") + "panic: boom [recovered]\n",
+			2, []CaseResult{{CaseReturned, "2"}, {CaseCrashed, "panic: boom [recovered]"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCaseResults(tt.output, tt.count); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCaseResults = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseResultAgrees(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  CaseResult
+		agree bool
+	}{
+		{"same values", CaseResult{CaseReturned, "3, <nil>"}, CaseResult{CaseReturned, "3, <nil>"}, true},
+		{"different values", CaseResult{CaseReturned, "3"}, CaseResult{CaseReturned, "4"}, false},
+		{"both panicked with different messages", CaseResult{CasePanicked, "index out of range"}, CaseResult{CasePanicked, "nil map"}, true},
+		{"returned and panicked", CaseResult{CaseReturned, "3"}, CaseResult{CasePanicked, "3"}, false},
+		{"both timed out", CaseResult{Outcome: CaseTimedOut}, CaseResult{Outcome: CaseTimedOut}, true},
+		{"crashed and not run", CaseResult{CaseCrashed, "fatal error: stack overflow"}, CaseResult{Outcome: CaseNotRun}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.agrees(tt.b); got != tt.agree {
+				t.Errorf("%+v.agrees(%+v) = %v, want %v", tt.a, tt.b, got, tt.agree)
+			}
+			if got := tt.b.agrees(tt.a); got != tt.agree {
+				t.Errorf("%+v.agrees(%+v) = %v, want %v", tt.b, tt.a, got, tt.agree)
+			}
+		})
+	}
+}
@@ -1,104 +1,118 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
 
-	"web-ui/internal/models"
+	"web-ui/internal/metrics"
 )
 
-// LLMProvider represents different LLM providers
+// LLMProvider names a registered LLM backend
 type LLMProvider string
 
 const (
-	ProviderGemini LLMProvider = "gemini"
-	ProviderOpenAI LLMProvider = "openai"
-	ProviderClaude LLMProvider = "claude"
+	ProviderGemini           LLMProvider = "gemini"
+	ProviderOpenAI           LLMProvider = "openai"
+	ProviderClaude           LLMProvider = "claude"
+	ProviderOpenAICompatible LLMProvider = "openai-compatible" // Ollama, llama.cpp, vLLM, ...
+	ProviderMock             LLMProvider = "mock"              // Offline, deterministic; replays AI_REPLAY_FILE
+	ProviderNone             LLMProvider = "none"              // AI features turned off
 )
 
 // LLMConfig holds configuration for different LLM providers
 type LLMConfig struct {
-	Provider    LLMProvider
-	APIKey      string
-	Model       string
-	BaseURL     string
-	MaxTokens   int
-	Temperature float64
+	Provider      LLMProvider
+	APIKey        string
+	Model         string
+	BaseURL       string // Overrides the provider's default API base URL
+	MaxTokens     int
+	Temperature   float64
+	ReplayFile    string        // Recorded responses served by the mock provider
+	RecordFile    string        // Where to record responses from any provider
+	Timeout       time.Duration // Per-request timeout for non-streaming calls
+	StreamTimeout time.Duration // Upper bound for a streamed response
+	// Rate-limited and failed requests are retried with exponential backoff from RetryBaseDelay
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	// Responses that fail schema validation are sent back to the model this many times
+	MaxRepairAttempts int
+	// Identical prompts are answered from a cache of up to CacheSize responses for CacheTTL
+	CacheTTL  time.Duration
+	CacheSize int
 }
 
 // AIService handles AI-powered code review and interview simulation
 type AIService struct {
-	config     LLMConfig
-	httpClient *http.Client
-}
-
-// NewAIService creates a new AI service with the specified provider
-func NewAIService() *AIService {
-	cfgProvider := getProviderFromEnv()
-	config := LLMConfig{
-		Provider:    cfgProvider,
-		APIKey:      getAPIKeyFromEnvFor(cfgProvider),
-		Model:       getModelFromEnv(),
-		MaxTokens:   4000, // Increased for longer responses
-		Temperature: 0.3,
+	config           LLMConfig
+	provider         Provider
+	providerErr      error             // Why no provider is available, reported instead of calling it
+	executionService *ExecutionService // Runs the code so reviews are grounded in real results
+	usageService     *AIUsageService   // Token accounting and daily quotas; nil disables both
+	prompts          *PromptService
+	cache            *aiCache
+}
+
+// NewAIService creates an AI service for the configured provider, model and base URL,
+// any of which may be empty for the defaults. The API key is read from the environment.
+func NewAIService(provider, model, baseURL string, executionService *ExecutionService, usageService *AIUsageService, promptService *PromptService) *AIService {
+	cfgProvider := ProviderGemini
+	if provider != "" {
+		cfgProvider = LLMProvider(strings.ToLower(provider))
 	}
+	config := LLMConfig{
+		Provider:      cfgProvider,
+		APIKey:        getAPIKeyFromEnvFor(cfgProvider),
+		Model:         model,
+		BaseURL:       baseURL,
+		MaxTokens:     4000, // Increased for longer responses
+		Temperature:   0.3,
+		ReplayFile:    os.Getenv("AI_REPLAY_FILE"),
+		RecordFile:    os.Getenv("AI_RECORD_FILE"),
+		Timeout:       30 * time.Second,
+		StreamTimeout: 3 * time.Minute,
 
-	// Set provider-specific defaults
-	switch config.Provider {
-	case ProviderGemini:
-		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta/models"
-		if config.Model == "" {
-			config.Model = "gemini-2.5-flash"
-		}
-	case ProviderOpenAI:
-		config.BaseURL = "https://api.openai.com/v1/chat/completions"
-		if config.Model == "" {
-			// Use a modern default that supports structured outputs well
-			config.Model = "gpt-4o-mini"
-		}
-	case ProviderClaude:
-		config.BaseURL = "https://api.anthropic.com/v1/messages"
-		if config.Model == "" {
-			config.Model = "claude-3-sonnet-20240229"
-		}
-	default:
-		// Default to Gemini if provider is not recognized
-		config.Provider = ProviderGemini
-		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta/models"
-		if config.Model == "" {
-			config.Model = "gemini-2.5-flash"
-		}
-	}
+		MaxRetries:        3,
+		RetryBaseDelay:    time.Second,
+		MaxRepairAttempts: 2,
 
-	return &AIService{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		CacheTTL:  24 * time.Hour,
+		CacheSize: 500,
 	}
+	return NewAIServiceWithConfig(config, executionService, usageService, promptService)
 }
 
-// Helper functions to get configuration from environment
-func getProviderFromEnv() LLMProvider {
-	provider := strings.ToLower(os.Getenv("AI_PROVIDER"))
-	switch provider {
-	case "gemini":
-		return ProviderGemini
-	case "openai":
-		return ProviderOpenAI
-	case "claude":
-		return ProviderClaude
-	default:
-		return ProviderGemini // Default to Gemini
+// NewAIServiceWithConfig creates an AI service for an explicit configuration.
+// executionService may be nil, in which case reviews are not grounded in test runs,
+// and usageService may be nil, in which case usage is neither recorded nor limited.
+// A nil promptService uses the built-in prompts.
+func NewAIServiceWithConfig(config LLMConfig, executionService *ExecutionService, usageService *AIUsageService, promptService *PromptService) *AIService {
+	if promptService == nil {
+		promptService = NewPromptService("")
+	}
+	ai := &AIService{config: config, executionService: executionService, usageService: usageService, prompts: promptService}
+	if config.CacheTTL > 0 && config.CacheSize > 0 {
+		ai.cache = newAICache(config.CacheTTL, config.CacheSize)
+	}
+	if config.Provider == ProviderNone {
+		ai.providerErr = ErrAIDisabled
+		return ai
 	}
+	ai.provider, ai.providerErr = NewProvider(config)
+	if ai.providerErr != nil && ai.providerErr != ErrMissingAPIKey {
+		slog.Warn("AI provider unavailable", "error", ai.providerErr)
+	}
+	if ai.provider != nil {
+		ai.config.Model = ai.provider.Model()
+	}
+	return ai
 }
 
+// getAPIKeyFromEnvFor reads the API key for a provider, which is kept out of config files
 func getAPIKeyFromEnvFor(provider LLMProvider) string {
 	switch provider {
 	case ProviderGemini:
@@ -118,8 +132,25 @@ func getAPIKeyFromEnvFor(provider LLMProvider) string {
 	return os.Getenv("AI_API_KEY")
 }
 
-func getModelFromEnv() string {
-	return os.Getenv("AI_MODEL")
+// Provider returns the active provider, or nil when none is available
+func (ai *AIService) Provider() Provider {
+	return ai.provider
+}
+
+// ProviderName returns the configured provider name, whether or not it is available
+func (ai *AIService) ProviderName() string {
+	return string(ai.config.Provider)
+}
+
+// UnavailableMessage explains why AI features cannot be used
+func (ai *AIService) UnavailableMessage() string {
+	if ai.providerErr == ErrAIDisabled {
+		return "⚠️ AI features are turned off on this server."
+	}
+	if ai.providerErr == ErrMissingAPIKey {
+		return "⚠️ AI features require an API key. Please add GEMINI_API_KEY to your .env file. Get your free key at: https://makersuite.google.com/app/apikey"
+	}
+	return fmt.Sprintf("⚠️ AI features are unavailable: %v", ai.providerErr)
 }
 
 // AICodeReview represents the response from AI code review
@@ -132,13 +163,15 @@ type AICodeReview struct {
 	Complexity          ComplexityAnalysis `json:"complexity"`           // Time/space complexity analysis
 	ReadabilityScore    float64            `json:"readability_score"`    // 0-100 readability score
 	TestCoverage        string             `json:"test_coverage"`        // Coverage assessment
+	Analysis            *CodeAnalysis      `json:"analysis,omitempty"`   // Test, vet and coverage results the review is based on
+	PromptVersion       string             `json:"prompt_version,omitempty"`
 }
 
 // CodeIssue represents a specific issue in the code
 type CodeIssue struct {
 	Type        string `json:"type"`        // "bug", "performance", "style", "logic"
 	Severity    string `json:"severity"`    // "low", "medium", "high", "critical"
-	LineNumber  int    `json:"line_number"` // Approximate line number
+	LineNumber  int    `json:"line_number"` // Line in the submitted code, 0 if not tied to a line
 	Description string `json:"description"` // Human-readable description
 	Solution    string `json:"solution"`    // Suggested fix
 }
@@ -159,619 +192,267 @@ type ComplexityAnalysis struct {
 	OptimizedApproach string `json:"optimized_approach"` // How to optimize
 }
 
-// Universal request/response structures for different LLM providers
-
-// GeminiRequest represents the request structure for Gemini API
-type GeminiRequest struct {
-	Contents         []GeminiContent         `json:"contents"`
-	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
-}
-
-type GeminiContent struct {
-	Parts []GeminiPart `json:"parts"`
-}
-
-type GeminiPart struct {
-	Text string `json:"text"`
-}
-
-type GeminiGenerationConfig struct {
-	Temperature     *float64 `json:"temperature,omitempty"`
-	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
-	ResponseMIME    string   `json:"responseMimeType,omitempty"`
-}
-
-// GeminiResponse represents the response from Gemini API
-type GeminiResponse struct {
-	Candidates []GeminiCandidate `json:"candidates"`
-	Error      *GeminiError      `json:"error,omitempty"`
-}
-
-type GeminiCandidate struct {
-	Content GeminiContent `json:"content"`
-}
-
-type GeminiError struct {
-	Message string `json:"message"`
-	Code    int    `json:"code"`
-}
-
-// ClaudeRequest represents the request structure for Claude API
-type ClaudeRequest struct {
-	Model       string          `json:"model"`
-	Messages    []ClaudeMessage `json:"messages"`
-	MaxTokens   int             `json:"max_tokens"`
-	Temperature float64         `json:"temperature"`
-}
-
-type ClaudeMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-// ClaudeResponse represents the response from Claude API
-type ClaudeResponse struct {
-	Content []ClaudeContent `json:"content"`
-	Error   *ClaudeError    `json:"error,omitempty"`
-}
-
-type ClaudeContent struct {
-	Text string `json:"text"`
-	Type string `json:"type"`
-}
-
-type ClaudeError struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
-}
-
-// OpenAIRequest represents the request structure for OpenAI API
-type OpenAIRequest struct {
-	Model          string                `json:"model"`
-	Messages       []Message             `json:"messages"`
-	MaxTokens      int                   `json:"max_tokens"`
-	Temperature    float64               `json:"temperature"`
-	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
-}
-
-// Message represents a message in the OpenAI chat
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+// ReviewCode performs AI-powered code review. Failures are returned as *AIError.
+func (ai *AIService) ReviewCode(ctx context.Context, code string, challenge *ChallengeContext, context string) (*AICodeReview, error) {
+	if ai.provider == nil {
+		return nil, ai.notConfigured()
+	}
 
-// OpenAIResponse represents the response from OpenAI API
-type OpenAIResponse struct {
-	Choices []Choice     `json:"choices"`
-	Error   *OpenAIError `json:"error,omitempty"`
-}
+	analysis := ai.analyze(ctx, code, challenge)
+	prompt, promptVersion := ai.buildCodeReviewPrompt(code, challenge, context, analysis)
 
-// Choice represents a choice in OpenAI response
-type Choice struct {
-	Message Message `json:"message"`
-}
+	var review *AICodeReview
+	err := ai.completeValidated(ctx, TaskCodeReview, prompt, promptVersion, jsonObjectResponse, func(response string) []string {
+		var problems []string
+		review, problems = parseCodeReview(response, countLines(code))
+		return problems
+	})
+	if err != nil {
+		return nil, err
+	}
 
-// OpenAIError represents an error from OpenAI API
-type OpenAIError struct {
-	Message string `json:"message"`
-	Type    string `json:"type"`
+	groundReview(review, analysis)
+	review.PromptVersion = promptVersion
+	return review, nil
 }
 
-// ReviewCode performs AI-powered code review
-func (ai *AIService) ReviewCode(code string, challenge *models.Challenge, context string) (*AICodeReview, error) {
-
-	if ai.config.APIKey == "" {
-		return &AICodeReview{
-			OverallScore:        0,
-			Issues:              []CodeIssue{},
-			Suggestions:         []CodeSuggestion{},
-			InterviewerFeedback: "⚠️ AI features require an API key. Please add GEMINI_API_KEY to your .env file. Get your free key at: https://makersuite.google.com/app/apikey",
-			FollowUpQuestions:   []string{"Would you like to set up AI code review?"},
-			Complexity: ComplexityAnalysis{
-				TimeComplexity:    "N/A",
-				SpaceComplexity:   "N/A",
-				CanOptimize:       false,
-				OptimizedApproach: "Set up your API key first",
-			},
-			ReadabilityScore: 0,
-			TestCoverage:     "API key required for AI analysis",
-		}, nil
+// GetInterviewerQuestions generates follow-up questions based on code. It also returns
+// the version of the prompt used.
+func (ai *AIService) GetInterviewerQuestions(ctx context.Context, code string, challenge *ChallengeContext, userProgress string) ([]string, string, error) {
+	if ai.provider == nil {
+		return nil, "", ai.notConfigured()
 	}
 
-	prompt := ai.buildCodeReviewPrompt(code, challenge, context)
-
-	response, err := ai.callLLMWithOpts(prompt, true /* expectJSON */)
-	if err != nil {
-		return &AICodeReview{
-			OverallScore:        0,
-			Issues:              []CodeIssue{},
-			Suggestions:         []CodeSuggestion{},
-			InterviewerFeedback: fmt.Sprintf("❌ AI service temporarily unavailable: %v. Please try again later.", err),
-			FollowUpQuestions:   []string{"Would you like to try again?"},
-			Complexity: ComplexityAnalysis{
-				TimeComplexity:    "N/A",
-				SpaceComplexity:   "N/A",
-				CanOptimize:       false,
-				OptimizedApproach: "API service temporarily unavailable",
-			},
-			ReadabilityScore: 0,
-			TestCoverage:     "AI service unavailable",
-		}, nil
-	}
+	prompt, promptVersion := ai.buildQuestionPrompt(code, challenge, userProgress)
 
-	review, err := ai.parseAIResponse(response)
+	var questions []string
+	err := ai.completeValidated(ctx, TaskInterviewerQuestions, prompt, promptVersion, jsonResponse, func(response string) []string {
+		var problems []string
+		questions, problems = parseQuestions(response)
+		return problems
+	})
 	if err != nil {
-		// This shouldn't happen anymore since parseAIResponse returns fallback instead of error
-		return ai.createFallbackReview("Unexpected parsing error", response), nil
+		return nil, "", err
 	}
-
-	return review, nil
+	return questions, promptVersion, nil
 }
 
-// GetInterviewerQuestions generates follow-up questions based on code
-func (ai *AIService) GetInterviewerQuestions(code string, challenge *models.Challenge, userProgress string) ([]string, error) {
-	if ai.config.APIKey == "" {
-		return []string{"⚠️ AI features require an API key. Get your free key at: https://makersuite.google.com/app/apikey"}, nil
+// GetCodeHint provides context-aware hints. It also returns the version of the prompt used.
+func (ai *AIService) GetCodeHint(ctx context.Context, code string, challenge *ChallengeContext, hintLevel int) (string, string, error) {
+	if ai.provider == nil {
+		return "", "", ai.notConfigured()
 	}
 
-	prompt := ai.buildQuestionPrompt(code, challenge, userProgress)
+	prompt, promptVersion := ai.buildHintPrompt(code, challenge, hintLevel, "", nil)
 
-	response, err := ai.callLLMWithOpts(prompt, true /* expectJSON */)
+	response, err := ai.complete(ctx, TaskCodeHint, prompt, promptVersion, textResponse)
 	if err != nil {
-		return []string{fmt.Sprintf("❌ AI service unavailable: %v", err)}, nil
+		return "", "", err
 	}
 
-	questions := ai.parseQuestions(response)
-	return questions, nil
+	return ai.parseHint(response), promptVersion, nil
 }
 
-// GetCodeHint provides context-aware hints
-func (ai *AIService) GetCodeHint(code string, challenge *models.Challenge, hintLevel int) (string, error) {
-	if ai.config.APIKey == "" {
-		return "⚠️ AI features require an API key. Get your free key at: https://makersuite.google.com/app/apikey", nil
+// GetLadderHint provides a hint that follows on from the authored hints already shown,
+// aimed at failingTest, the problem found by running the code. It also returns the
+// version of the prompt used.
+func (ai *AIService) GetLadderHint(ctx context.Context, code string, challenge *ChallengeContext, hintLevel int, failingTest string, shown []HintSection) (string, string, error) {
+	if ai.provider == nil {
+		return "", "", ai.notConfigured()
 	}
 
-	prompt := ai.buildHintPrompt(code, challenge, hintLevel)
+	prompt, promptVersion := ai.buildHintPrompt(code, challenge, hintLevel, failingTest, shown)
 
-	response, err := ai.callLLMWithOpts(prompt, false /* expectJSON */)
+	response, err := ai.complete(ctx, TaskCodeHint, prompt, promptVersion, textResponse)
 	if err != nil {
-		return fmt.Sprintf("❌ AI service unavailable: %v", err), nil
+		return "", "", err
 	}
 
-	return ai.parseHint(response), nil
+	return ai.parseHint(response), promptVersion, nil
 }
 
-// BuildCodeReviewPrompt exposes the prompt builder for debugging
-func (ai *AIService) BuildCodeReviewPrompt(code string, challenge *models.Challenge, context string) string {
-	return ai.buildCodeReviewPrompt(code, challenge, context)
+// BuildCodeReviewPrompt exposes the prompt builder for debugging, running the code as a review would
+func (ai *AIService) BuildCodeReviewPrompt(ctx context.Context, code string, challenge *ChallengeContext, reviewContext string) string {
+	prompt, _ := ai.buildCodeReviewPrompt(code, challenge, reviewContext, ai.analyze(ctx, code, challenge))
+	return prompt
 }
 
-// CallLLMRaw calls the LLM and returns raw response for debugging
-func (ai *AIService) CallLLMRaw(prompt string) (string, error) {
-	return ai.callLLMWithOpts(prompt, true)
-}
+// rawPromptVersion is the usage version for prompts sent as given rather than rendered
+const rawPromptVersion = "raw"
 
-// buildCodeReviewPrompt creates the prompt for code review
-func (ai *AIService) buildCodeReviewPrompt(code string, challenge *models.Challenge, context string) string {
-	return fmt.Sprintf(`You are a senior Go interviewer. Respond ONLY with a single JSON object. Do NOT include markdown or code fences. All numeric fields must be JSON numbers, not strings.
-
-SCHEMA:
-{
-  "overall_score": integer (0..100),
-  "issues": [
-    {
-      "type": "bug|performance|style|logic",
-      "severity": "low|medium|high|critical",
-      "line_number": integer,
-      "description": string,
-      "solution": string
-    }
-  ],
-  "suggestions": [
-    {
-      "category": "optimization|best_practice|alternative",
-      "priority": "low|medium|high",
-      "description": string,
-      "example": string
-    }
-  ],
-  "interviewer_feedback": string,
-  "follow_up_questions": [string],
-  "complexity": {
-    "time_complexity": string,
-    "space_complexity": string,
-    "can_optimize": boolean,
-    "optimized_approach": string
-  },
-  "readability_score": integer (0..100),
-  "test_coverage": string
+// CallLLMRaw calls the LLM and returns raw response for debugging
+func (ai *AIService) CallLLMRaw(ctx context.Context, prompt string) (string, error) {
+	if ai.provider == nil {
+		return "", ai.providerErr
+	}
+	return ai.complete(ctx, TaskCodeReview, prompt, rawPromptVersion, jsonObjectResponse)
 }
 
-CHALLENGE: %s
-CONTEXT: %s
-
-CODE (Go):
-BEGIN_CODE
-%s
-END_CODE
+// buildCodeReviewPrompt creates the prompt for code review, returning it with the template version
+func (ai *AIService) buildCodeReviewPrompt(code string, challenge *ChallengeContext, context string, analysis *CodeAnalysis) (string, string) {
+	facts := "EXECUTION RESULTS: not available. Do not guess test results or coverage."
+	if analysis != nil {
+		facts = analysis.promptSection()
+	}
 
-Focus on: (1) correctness and edge cases, (2) Go idioms, (3) performance, (4) readability, (5) interviewer follow-ups.`, challenge.Title, context, code)
+	return ai.prompts.Render(TaskCodeReview, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		ReviewCriteria:   challenge.reviewCriteriaSection(),
+		Code:             code,
+		NumberedCode:     numberLines(code),
+		Context:          context,
+		Facts:            facts,
+	})
 }
 
 // buildQuestionPrompt creates the prompt for generating interview questions
-func (ai *AIService) buildQuestionPrompt(code string, challenge *models.Challenge, userProgress string) string {
-	return fmt.Sprintf(`You are a technical interviewer. Respond ONLY with a JSON array of strings. No markdown, no prose outside the array.
-
-CHALLENGE: %s
-USER PROGRESS: %s
-
-CODE (Go):
-BEGIN_CODE
-%s
-END_CODE
-
-Generate 3-5 follow-up questions that probe: deeper understanding, edge cases, optimizations, Go-specific concepts, and trade-offs.`, challenge.Title, userProgress, code)
+func (ai *AIService) buildQuestionPrompt(code string, challenge *ChallengeContext, userProgress string) (string, string) {
+	return ai.prompts.Render(TaskInterviewerQuestions, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		Code:             code,
+		UserProgress:     userProgress,
+	})
 }
 
 // buildHintPrompt creates the prompt for generating hints
-func (ai *AIService) buildHintPrompt(code string, challenge *models.Challenge, hintLevel int) string {
-	hintTypes := map[int]string{
-		1: "a subtle nudge in the right direction",
-		2: "a more direct hint about the approach",
-		3: "a specific suggestion about implementation",
-		4: "a detailed explanation with partial code example",
-	}
-
-	return fmt.Sprintf(`You are a helpful coding mentor. Return only the hint text as plain text. No JSON, no code fences.
-
-CHALLENGE: %s
-CURRENT CODE:
-%s
-
-Provide %s (level %d/4). Be encouraging and educational, not just giving the answer.
-
-Return only the hint text.`, challenge.Title, code, hintTypes[hintLevel], hintLevel)
-}
-
-// callLLM makes a request to the configured LLM provider
-func (ai *AIService) callLLM(prompt string) (string, error) {
-	return ai.callLLMWithOpts(prompt, false)
-}
-
-// callLLMWithOpts allows specifying whether JSON output is expected (to enforce provider features)
-func (ai *AIService) callLLMWithOpts(prompt string, expectJSON bool) (string, error) {
-	switch ai.config.Provider {
-	case ProviderGemini:
-		return ai.callGeminiWithOpts(prompt, expectJSON)
-	case ProviderOpenAI:
-		return ai.callOpenAIWithOpts(prompt, expectJSON)
-	case ProviderClaude:
-		return ai.callClaudeWithOpts(prompt, expectJSON)
-	default:
-		return "", fmt.Errorf("unsupported provider: %s", ai.config.Provider)
-	}
-}
-
-// callGemini makes a request to the Gemini API
-func (ai *AIService) callGeminiWithOpts(prompt string, expectJSON bool) (string, error) {
-	url := fmt.Sprintf("%s/%s:generateContent?key=%s", ai.config.BaseURL, ai.config.Model, ai.config.APIKey)
-
-	requestBody := GeminiRequest{
-		Contents: []GeminiContent{
-			{
-				Parts: []GeminiPart{
-					{Text: prompt},
-				},
-			},
-		},
-		GenerationConfig: &GeminiGenerationConfig{
-			Temperature:     &ai.config.Temperature,
-			MaxOutputTokens: &ai.config.MaxTokens,
-			ResponseMIME: func() string {
-				if expectJSON {
-					return "application/json"
-				}
-				return ""
-			}(),
-		},
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := ai.httpClient.Do(req)
+func (ai *AIService) buildHintPrompt(code string, challenge *ChallengeContext, hintLevel int, failingTest string, shown []HintSection) (string, string) {
+	return ai.prompts.Render(TaskCodeHint, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		Code:             code,
+		HintLevel:        hintLevel,
+		HintStyle:        hintStyles[hintLevel],
+		FailingTest:      failingTest,
+		HintsShown:       shown,
+	})
+}
+
+// complete sends a prompt to the provider with the service's defaults, answering from
+// the cache when it can and retrying transient failures. Usage is charged to the user in
+// ctx under the version of the template the prompt was rendered from, and requests over
+// quota are refused. Errors are returned as *AIError.
+func (ai *AIService) complete(ctx context.Context, task, prompt, promptVersion string, format responseFormat) (string, error) {
+	request := ai.completionRequest(task, prompt, format)
+	user := AIUserFromContext(ctx)
+
+	key := aiCacheKey(ai.provider.Name(), ai.provider.Model(), request)
+	if response, ok := ai.cache.Get(key); ok {
+		ai.recordUsage(user, task, promptVersion, Usage{}, true)
+		return response, nil
+	}
+	reservation, err := ai.reserveQuota(user, request)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	defer reservation.Release()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	var response string
+	err = ai.withRetries(ctx, task, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, ai.config.Timeout)
+		defer cancel()
 
-	var geminiResp GeminiResponse
-	err = json.Unmarshal(body, &geminiResp)
+		request.Usage = &Usage{}
+		var err error
+		response, err = ai.provider.Complete(attemptCtx, request)
+		if err == nil {
+			ai.commitUsage(reservation, task, promptVersion, estimateUsage(request, response))
+		}
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if geminiResp.Error != nil {
-		return "", fmt.Errorf("Gemini API error: %s", geminiResp.Error.Message)
-	}
-
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no response from Gemini")
-	}
-
-	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
-}
-
-// callClaude makes a request to the Claude API
-// Claude Messages API requires content blocks and benefits from a system message
-type claudeContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	ai.cache.Put(key, response)
+	return response, nil
 }
 
-type claudeMessage struct {
-	Role    string               `json:"role"`
-	Content []claudeContentBlock `json:"content"`
+// forget drops a cached response that turned out to be unusable
+func (ai *AIService) forget(task, prompt string, format responseFormat) {
+	ai.cache.Delete(aiCacheKey(ai.provider.Name(), ai.provider.Model(), ai.completionRequest(task, prompt, format)))
 }
 
-func (ai *AIService) callClaudeWithOpts(prompt string, expectJSON bool) (string, error) {
-	systemText := "You are a senior Go interviewer. Be concise."
-	if expectJSON {
-		systemText += " Respond ONLY with strict JSON. No markdown."
-	}
-	requestBody := struct {
-		Model       string          `json:"model"`
-		Messages    []claudeMessage `json:"messages"`
-		MaxTokens   int             `json:"max_tokens"`
-		Temperature float64         `json:"temperature"`
-	}{
-		Model: ai.config.Model,
-		Messages: []claudeMessage{
-			{Role: "system", Content: []claudeContentBlock{{Type: "text", Text: systemText}}},
-			{Role: "user", Content: []claudeContentBlock{{Type: "text", Text: prompt}}},
-		},
-		MaxTokens:   ai.config.MaxTokens,
-		Temperature: ai.config.Temperature,
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", ai.config.BaseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", ai.config.APIKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
-
-	resp, err := ai.httpClient.Do(req)
-	if err != nil {
-		return "", err
+// reserveQuota refuses requests from users, or a site, over the daily quota and holds
+// the request's prompt and maximum completion tokens against the quota until it is
+// answered. The reservation is nil when usage is not tracked.
+func (ai *AIService) reserveQuota(user string, request CompletionRequest) (*AIReservation, error) {
+	if ai.usageService == nil {
+		return nil, nil
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	tokens := estimateUsage(CompletionRequest{System: request.System, Prompt: request.Prompt}, "").PromptTokens + request.MaxTokens
+	reservation, err := ai.usageService.Reserve(user, tokens)
 	if err != nil {
-		return "", err
+		var aiErr *AIError
+		if !errors.As(err, &aiErr) {
+			aiErr = ai.classifyError(err)
+		}
+		aiErr.Provider = string(ai.config.Provider)
+		return nil, aiErr
 	}
+	return reservation, nil
+}
 
-	var claudeResp ClaudeResponse
-	err = json.Unmarshal(body, &claudeResp)
-	if err != nil {
-		return "", err
+// commitUsage charges a provider response's tokens in place of its reservation
+func (ai *AIService) commitUsage(reservation *AIReservation, task, promptVersion string, usage Usage) {
+	metrics.AITokens.Add(float64(usage.PromptTokens), ai.provider.Name(), ai.provider.Model(), "prompt")
+	metrics.AITokens.Add(float64(usage.CompletionTokens), ai.provider.Name(), ai.provider.Model(), "completion")
+	if reservation != nil {
+		reservation.Commit(ai.provider.Name(), ai.provider.Model(), task, promptVersion, usage)
 	}
+}
 
-	if claudeResp.Error != nil {
-		return "", fmt.Errorf("Claude API error: %s", claudeResp.Error.Message)
+// recordUsage charges a request's tokens to user
+func (ai *AIService) recordUsage(user, task, promptVersion string, usage Usage, cached bool) {
+	if !cached {
+		metrics.AITokens.Add(float64(usage.PromptTokens), ai.provider.Name(), ai.provider.Model(), "prompt")
+		metrics.AITokens.Add(float64(usage.CompletionTokens), ai.provider.Name(), ai.provider.Model(), "completion")
 	}
-
-	if len(claudeResp.Content) == 0 {
-		return "", fmt.Errorf("no response from Claude")
+	if ai.usageService != nil {
+		ai.usageService.Record(user, ai.provider.Name(), ai.provider.Model(), task, promptVersion, usage, cached)
 	}
-
-	return claudeResp.Content[0].Text, nil
 }
 
-// callOpenAI makes a request to the OpenAI API
-type OpenAIResponseFormat struct {
-	Type string `json:"type"`
+// UsageService returns the usage accounting, or nil when usage is not tracked
+func (ai *AIService) UsageService() *AIUsageService {
+	return ai.usageService
 }
 
-func (ai *AIService) callOpenAIWithOpts(prompt string, expectJSON bool) (string, error) {
-	// Add a system message to better steer responses
-	messages := []Message{
-		{Role: "system", Content: func() string {
-			if expectJSON {
-				return "You are a senior Go interviewer. Respond ONLY with strict JSON. No markdown."
-			}
-			return "You are a senior Go interviewer."
-		}()},
-		{Role: "user", Content: prompt},
-	}
-
-	requestBody := OpenAIRequest{
-		Model:       ai.config.Model,
-		Messages:    messages,
-		MaxTokens:   ai.config.MaxTokens,
-		Temperature: ai.config.Temperature,
-	}
-	if expectJSON {
-		// Only force json_object when the prompt expects a single JSON object, not an array
-		if strings.Contains(strings.ToLower(prompt), "single json object") {
-			requestBody.ResponseFormat = &OpenAIResponseFormat{Type: "json_object"}
-		}
-	}
-
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", ai.config.BaseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ai.config.APIKey)
-
-	resp, err := ai.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var openAIResp OpenAIResponse
-	err = json.Unmarshal(body, &openAIResp)
-	if err != nil {
-		return "", err
-	}
-
-	if openAIResp.Error != nil {
-		return "", fmt.Errorf("OpenAI API error: %s", openAIResp.Error.Message)
-	}
-
-	if len(openAIResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
-	}
-
-	return openAIResp.Choices[0].Message.Content, nil
+// PromptVersions returns the version of the prompt used for each AI task
+func (ai *AIService) PromptVersions() map[string]string {
+	return ai.prompts.Versions()
 }
 
-// parseAIResponse parses the AI response into a structured review
-func (ai *AIService) parseAIResponse(response string) (*AICodeReview, error) {
-	// Remove markdown code blocks if present
-	response = strings.TrimSpace(response)
-	response = strings.TrimPrefix(response, "```json")
-	response = strings.TrimPrefix(response, "```")
-	response = strings.TrimSuffix(response, "```")
-	response = strings.TrimSpace(response)
-
-	// Try to extract JSON from the response
-	start := strings.Index(response, "{")
-	end := strings.LastIndex(response, "}")
-
-	if start == -1 || end == -1 {
-		// Log the raw response for debugging
-		fmt.Printf("AI Response parsing failed - no JSON braces found. Raw response: %s\n", response)
-		return ai.createFallbackReview("No JSON found in AI response", response), nil
-	}
-
-	jsonStr := response[start : end+1]
-
-	// Check if JSON is complete by counting braces
-	openBraces := strings.Count(jsonStr, "{")
-	closeBraces := strings.Count(jsonStr, "}")
-	if openBraces != closeBraces {
-		fmt.Printf("AI Response parsing failed - mismatched braces. JSON: %s\n", jsonStr)
-		return ai.createFallbackReview("Incomplete JSON response", jsonStr), nil
-	}
-
-	var review AICodeReview
-	err := json.Unmarshal([]byte(jsonStr), &review)
-	if err != nil {
-		fmt.Printf("AI Response JSON unmarshal error: %v. JSON: %s\n", err, jsonStr)
-		return ai.createFallbackReview("JSON parsing error", jsonStr), nil
-	}
-
-	// Validate critical fields and provide defaults
-	if review.OverallScore == 0 && review.ReadabilityScore == 0 && review.InterviewerFeedback == "" {
-		fmt.Printf("AI Response appears incomplete - all key fields empty. JSON: %s\n", jsonStr)
-		return ai.createFallbackReview("Incomplete AI response", jsonStr), nil
-	}
-
-	return &review, nil
+// CacheStats returns the response cache's size and hit counts
+func (ai *AIService) CacheStats() AICacheStats {
+	return ai.cache.Stats()
 }
 
-// createFallbackReview creates a reasonable fallback when AI parsing fails
-func (ai *AIService) createFallbackReview(reason, rawResponse string) *AICodeReview {
-	// Try to extract any useful text from the response
-	feedback := rawResponse
-	if len(feedback) > 500 {
-		feedback = feedback[:500] + "..."
-	}
-	if feedback == "" {
-		feedback = "AI provided an empty or malformed response. Please try again."
-	}
+// responseFormat is the shape of the answer a prompt asks for
+type responseFormat int
 
-	return &AICodeReview{
-		OverallScore: 50, // Neutral score
-		Issues: []CodeIssue{
-			{
-				Type:        "parsing",
-				Severity:    "medium",
-				LineNumber:  0,
-				Description: fmt.Sprintf("AI response parsing issue: %s", reason),
-				Solution:    "Try running the AI review again, or check your code for syntax issues.",
-			},
-		},
-		Suggestions: []CodeSuggestion{
-			{
-				Category:    "troubleshooting",
-				Priority:    "medium",
-				Description: "If this keeps happening, try simplifying your code or breaking it into smaller functions.",
-				Example:     "",
-			},
-		},
-		InterviewerFeedback: fmt.Sprintf("I'm having trouble analyzing your code automatically. %s Let's focus on the core logic - can you walk me through your approach?", feedback),
-		FollowUpQuestions: []string{
-			"Can you explain your algorithm step by step?",
-			"What's the time complexity of your solution?",
-			"How would you handle edge cases?",
-		},
-		Complexity: ComplexityAnalysis{
-			TimeComplexity:    "Unable to analyze",
-			SpaceComplexity:   "Unable to analyze",
-			CanOptimize:       false,
-			OptimizedApproach: "Rerun analysis after fixing any syntax issues",
-		},
-		ReadabilityScore: 50,
-		TestCoverage:     "Unable to assess due to parsing error",
-	}
-}
-
-// parseQuestions parses questions from AI response
-func (ai *AIService) parseQuestions(response string) []string {
-	// Try to extract JSON array
-	start := strings.Index(response, "[")
-	end := strings.LastIndex(response, "]")
+const (
+	textResponse       responseFormat = iota
+	jsonResponse                      // Any JSON value, such as an array
+	jsonObjectResponse                // A single JSON object
+)
 
-	if start == -1 || end == -1 {
-		return []string{"What's the time complexity of your solution?", "How would you handle edge cases?", "Can you optimize this further?"}
+// completionRequest builds a provider request with the service's defaults
+func (ai *AIService) completionRequest(task, prompt string, format responseFormat) CompletionRequest {
+	system := "You are a senior Go interviewer."
+	if format != textResponse {
+		system += " Respond ONLY with strict JSON. No markdown."
 	}
-
-	jsonStr := response[start : end+1]
-
-	var questions []string
-	err := json.Unmarshal([]byte(jsonStr), &questions)
-	if err != nil {
-		return []string{"What's the time complexity of your solution?", "How would you handle edge cases?", "Can you optimize this further?"}
+	return CompletionRequest{
+		Task:        task,
+		System:      system,
+		Prompt:      prompt,
+		MaxTokens:   ai.config.MaxTokens,
+		Temperature: ai.config.Temperature,
+		JSON:        format != textResponse,
+		JSONObject:  format == jsonObjectResponse,
 	}
-
-	return questions
 }
 
 // parseHint extracts hint from AI response
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxAdversarialCases is how many cases the model may propose for one submission
+const maxAdversarialCases = 10
+
+// adversarialImports are the packages generated cases may import
+var adversarialImports = []string{
+	"bytes", "errors", "fmt", "math", "math/big", "sort", "strconv", "strings", "unicode", "unicode/utf8",
+}
+
+// ErrNoReferenceSolution is returned when a challenge has no accepted submission that
+// passes its tests to compare against
+var ErrNoReferenceSolution = errors.New("no known-passing solution is available for this challenge")
+
+// adversarialProposal is the model's answer: the cases and the imports they need
+type adversarialProposal struct {
+	Imports []string          `json:"imports"`
+	Cases   []AdversarialCase `json:"cases"`
+}
+
+// CaseDisagreement is a case on which the submission and the reference behave differently
+type CaseDisagreement struct {
+	AdversarialCase
+	Submission CaseResult `json:"submission"`
+	Reference  CaseResult `json:"reference"`
+}
+
+// AdversarialReport is the result of trying to break a submission. Only the cases on
+// which it disagrees with a known-passing solution are reported.
+type AdversarialReport struct {
+	Reference     string             `json:"reference"` // Author of the passing submission compared against
+	CasesProposed int                `json:"cases_proposed"`
+	CasesCompared int                `json:"cases_compared"`
+	Disagreements []CaseDisagreement `json:"disagreements"`
+	Dropped       []string           `json:"dropped,omitempty"`     // Proposed cases that did not compile, with the reason
+	BuildError    string             `json:"build_error,omitempty"` // Set when the cases do not compile against the submission
+	PromptVersion string             `json:"prompt_version"`
+	ExecutionMs   int64              `json:"execution_ms"`
+}
+
+// BreakSolution asks the model for inputs likely to break the submission, runs them
+// against it and a known-passing submission by someone other than username, and reports
+// where the two disagree. It returns ErrNoReferenceSolution when there is nothing to
+// compare against; AI failures are returned as *AIError.
+func (ai *AIService) BreakSolution(ctx context.Context, code string, challenge *ChallengeContext, username string) (*AdversarialReport, error) {
+	if ai.provider == nil {
+		return nil, ai.notConfigured()
+	}
+	if ai.executionService == nil || challenge.SubmissionsDir == "" {
+		return nil, ErrNoReferenceSolution
+	}
+
+	start := time.Now()
+	reference, err := ai.executionService.ReferenceSolution(ctx, challenge.SubmissionsDir, challenge.executionChallenge(), username)
+	if err != nil {
+		return nil, ErrNoReferenceSolution
+	}
+
+	prompt, promptVersion := ai.prompts.Render(TaskAdversarialCases, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		Code:             code,
+		AllowedImports:   adversarialImports,
+		MaxCases:         maxAdversarialCases,
+	})
+	var proposal *adversarialProposal
+	err = ai.completeValidated(ctx, TaskAdversarialCases, prompt, promptVersion, jsonObjectResponse, func(response string) []string {
+		var problems []string
+		proposal, problems = parseAdversarialProposal(response)
+		return problems
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	run := ai.executionService.RunAdversarialCases(ctx, code, reference, challenge.ChallengeID, proposal.Cases, proposal.Imports)
+	if run.ReferenceFail != "" {
+		return nil, &AIError{
+			Kind:      AIErrorInvalidResponse,
+			Provider:  string(ai.config.Provider),
+			Message:   "The AI's test cases could not be run: " + run.ReferenceFail,
+			Retryable: true,
+			Details:   run.Dropped,
+		}
+	}
+
+	report := &AdversarialReport{
+		Reference:     reference.Author,
+		CasesProposed: len(proposal.Cases),
+		Disagreements: []CaseDisagreement{},
+		Dropped:       run.Dropped,
+		BuildError:    run.BuildError,
+		PromptVersion: promptVersion,
+	}
+	for i := range run.Submission {
+		submission, expected := run.Submission[i], run.Reference[i]
+		if submission.Outcome == CaseNotRun || expected.Outcome == CaseNotRun {
+			continue
+		}
+		report.CasesCompared++
+		if !submission.agrees(expected) {
+			report.Disagreements = append(report.Disagreements, CaseDisagreement{
+				AdversarialCase: run.Cases[i],
+				Submission:      submission,
+				Reference:       expected,
+			})
+		}
+	}
+	report.ExecutionMs = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+// parseAdversarialProposal decodes and checks the proposed cases
+func parseAdversarialProposal(response string) (*adversarialProposal, []string) {
+	text, err := extractJSON(response)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var proposal adversarialProposal
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&proposal); err != nil {
+		return nil, []string{fmt.Sprintf("response does not match the schema: %v", err)}
+	}
+
+	var problems []string
+	if len(proposal.Cases) == 0 || len(proposal.Cases) > maxAdversarialCases {
+		problems = append(problems, fmt.Sprintf("expected 1 to %d cases, got %d", maxAdversarialCases, len(proposal.Cases)))
+	}
+	for _, imp := range proposal.Imports {
+		if !containsString(adversarialImports, imp) {
+			problems = append(problems, fmt.Sprintf("import %q is not allowed; use only %s", imp, strings.Join(adversarialImports, ", ")))
+		}
+	}
+	names := make(map[string]bool)
+	for i, c := range proposal.Cases {
+		if strings.TrimSpace(c.Name) == "" {
+			problems = append(problems, fmt.Sprintf("cases[%d].name must not be empty", i))
+		} else if names[c.Name] {
+			problems = append(problems, fmt.Sprintf("cases[%d].name %q is used twice", i, c.Name))
+		}
+		names[c.Name] = true
+		if strings.TrimSpace(c.Call) == "" {
+			problems = append(problems, fmt.Sprintf("cases[%d].call must not be empty", i))
+		} else if strings.ContainsAny(c.Call, ";\n") {
+			problems = append(problems, fmt.Sprintf("cases[%d].call must be a single expression; put statements in setup", i))
+		}
+		if strings.Contains(c.Setup, "import") || strings.Contains(c.Setup, "func main") {
+			problems = append(problems, fmt.Sprintf("cases[%d].setup must only contain statements", i))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	if proposal.Imports == nil {
+		proposal.Imports = []string{}
+	}
+	return &proposal, nil
+}
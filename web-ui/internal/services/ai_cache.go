@@ -0,0 +1,110 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// aiCache keeps recent provider responses so identical prompts are not paid for twice
+type aiCache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]aiCacheEntry
+	hits       int
+	misses     int
+	mutex      sync.Mutex
+}
+
+type aiCacheEntry struct {
+	response string
+	expires  time.Time
+}
+
+// AICacheStats describes the response cache for the status and usage endpoints
+type AICacheStats struct {
+	Entries int `json:"entries"`
+	Hits    int `json:"hits"`
+	Misses  int `json:"misses"`
+}
+
+func newAICache(ttl time.Duration, maxEntries int) *aiCache {
+	return &aiCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]aiCacheEntry),
+	}
+}
+
+// aiCacheKey identifies a request by provider, model and a hash of the prompt
+func aiCacheKey(provider, model string, request CompletionRequest) string {
+	sum := sha256.Sum256([]byte(provider + "\x00" + model + "\x00" + RecordingKey(request)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached response for key, if it has not expired
+func (c *aiCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		c.misses++
+		return "", false
+	}
+	c.hits++
+	return entry.response, true
+}
+
+// Put stores a response, evicting expired entries and then the oldest when the cache is full
+func (c *aiCache) Put(key, response string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		oldestKey := ""
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestKey == "" || entry.expires.Before(oldest) {
+				oldestKey, oldest = k, entry.expires
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = aiCacheEntry{response: response, expires: time.Now().Add(c.ttl)}
+}
+
+// Delete drops a response, e.g. one that failed validation
+func (c *aiCache) Delete(key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key)
+}
+
+// Stats returns the cache size and hit counts
+func (c *aiCache) Stats() AICacheStats {
+	if c == nil {
+		return AICacheStats{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return AICacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"web-ui/internal/models"
+)
+
+// ChallengeContext is what the AI prompts know about a challenge, classic or package
+type ChallengeContext struct {
+	ChallengeID        int // Classic challenge number; 0 for package challenges
+	Title              string
+	Description        string
+	Difficulty         string
+	Package            string // e.g. "gin"; empty for classic challenges
+	PackageChallengeID string
+	Requirements       []string
+	LearningObjectives []string
+	TestFile           string
+	ReviewCriteria     []string          // Framework-specific points for the reviewer to check
+	PromptAdditions    map[string]string // Extra instructions per AI task, from the challenge's metadata.json
+	SubmissionsDir     string            // Accepted submissions, one directory per author
+	Hints              string            // The authored hints.md
+}
+
+// frameworkReviewCriteria are review points specific to each package's framework
+var frameworkReviewCriteria = map[string][]string{
+	"gin": {
+		"Middleware is registered in the right order (logging/recovery first, auth before protected routes) and calls c.Next() or c.Abort() correctly",
+		"Handlers return after writing an error response instead of falling through",
+		"Request binding uses ShouldBind* with validation tags and handles binding errors",
+		"Route groups are used for shared prefixes and middleware",
+		"No gin.Context is used after the handler returns or shared across goroutines without c.Copy()",
+	},
+	"echo": {
+		"Middleware order is correct and handlers return errors instead of writing responses twice",
+		"echo.HTTPError is used for error responses with meaningful status codes",
+		"Request binding and validation use c.Bind with a registered validator",
+		"Route groups share prefixes and middleware",
+	},
+	"fiber": {
+		"Values from fiber.Ctx (params, body, headers) are copied before being used after the handler returns, since fiber reuses buffers",
+		"Middleware order is correct and c.Next() is called where intended",
+		"Errors are returned to fiber's error handler rather than ignored",
+		"BodyParser errors are handled and inputs validated",
+	},
+	"cobra": {
+		"Commands use RunE and return errors instead of calling os.Exit inside commands",
+		"Flags are defined on the right command (persistent vs local) and required flags are marked",
+		"Args validators (cobra.ExactArgs etc.) are used instead of manual length checks",
+		"Output goes through cmd.OutOrStdout() so commands are testable",
+	},
+	"gorm": {
+		"No N+1 queries: related records are loaded with Preload or Joins instead of per-row queries in a loop",
+		"Errors from every query are checked (result.Error) and gorm.ErrRecordNotFound is handled",
+		"Multi-step writes use transactions (db.Transaction)",
+		"Queries use parameter binding, never string concatenation",
+		"Migrations and model tags (indexes, constraints) match the requirements",
+	},
+	"mongodb": {
+		"Every operation uses a context with a timeout and cursors are closed",
+		"mongo.ErrNoDocuments is handled separately from other errors",
+		"Filters use bson.M/bson.D correctly and queried fields are indexed",
+		"Client connections are reused rather than created per request",
+	},
+}
+
+// ClassicChallengeContext describes a numbered challenge for the AI prompts
+func ClassicChallengeContext(challenge *models.Challenge) *ChallengeContext {
+	return &ChallengeContext{
+		ChallengeID:     challenge.ID,
+		Title:           challenge.Title,
+		Description:     challenge.Description,
+		Difficulty:      challenge.Difficulty,
+		TestFile:        challenge.TestFile,
+		PromptAdditions: challenge.AIPrompts,
+		SubmissionsDir:  filepath.Join(challenge.Dir, "submissions"),
+		Hints:           challenge.Hints,
+	}
+}
+
+// PackageChallengeContext describes a package challenge, including its framework review criteria
+func PackageChallengeContext(packageName string, challenge *models.PackageChallenge) *ChallengeContext {
+	return &ChallengeContext{
+		Title:              challenge.Title,
+		Description:        challenge.Description,
+		Difficulty:         challenge.Difficulty,
+		Package:            packageName,
+		PackageChallengeID: challenge.ID,
+		Requirements:       challenge.Requirements,
+		LearningObjectives: challenge.LearningObjectives,
+		TestFile:           challenge.TestFile,
+		ReviewCriteria:     frameworkReviewCriteria[packageName],
+		PromptAdditions:    challenge.AIPrompts,
+		SubmissionsDir:     filepath.Join(challenge.Dir, "submissions"),
+		Hints:              challenge.Hints,
+	}
+}
+
+// Key identifies the challenge in per-user records: "challenge-<n>" or "<package>/<challenge id>"
+func (c *ChallengeContext) Key() string {
+	if c.Package == "" {
+		return fmt.Sprintf("challenge-%d", c.ChallengeID)
+	}
+	return c.Package + "/" + c.PackageChallengeID
+}
+
+// promptSection describes the challenge in a prompt. Classic challenges are identified
+// by title alone; package challenges include what the solution is expected to do.
+func (c *ChallengeContext) promptSection() string {
+	if c.Package == "" {
+		return "CHALLENGE: " + c.Title
+	}
+
+	var section strings.Builder
+	fmt.Fprintf(&section, "CHALLENGE: %s (package challenge for the %s package, %s)\n", c.Title, c.Package, c.PackageChallengeID)
+	writePromptList(&section, "REQUIREMENTS", c.Requirements)
+	writePromptList(&section, "LEARNING OBJECTIVES", c.LearningObjectives)
+	if c.TestFile != "" {
+		fmt.Fprintf(&section, "TESTS THE SOLUTION MUST PASS (Go):\nBEGIN_TESTS\n%s\nEND_TESTS\n", truncateText(c.TestFile, 6000))
+	}
+	return strings.TrimRight(section.String(), "\n")
+}
+
+// reviewCriteriaSection lists framework-specific review points, if any
+func (c *ChallengeContext) reviewCriteriaSection() string {
+	if len(c.ReviewCriteria) == 0 {
+		return ""
+	}
+	var section strings.Builder
+	writePromptList(&section, fmt.Sprintf("\n%s-SPECIFIC REVIEW CRITERIA (report violations as issues)", strings.ToUpper(c.Package)), c.ReviewCriteria)
+	return strings.TrimRight(section.String(), "\n")
+}
+
+func writePromptList(section *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(section, "%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(section, "- %s\n", item)
+	}
+}
+
+// executionChallenge is the challenge in the form ExecutionService runs
+func (c *ChallengeContext) executionChallenge() *models.Challenge {
+	return &models.Challenge{
+		ID:       c.ChallengeID,
+		Title:    c.Title,
+		TestFile: c.TestFile,
+		Dir:      filepath.Dir(c.SubmissionsDir),
+	}
+}
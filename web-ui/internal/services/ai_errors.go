@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"web-ui/internal/metrics"
+)
+
+// Kinds of AI failure, reported to the UI so it can explain what went wrong
+const (
+	AIErrorNotConfigured   = "not_configured"   // No provider or API key
+	AIErrorAuth            = "auth"             // The provider rejected the API key
+	AIErrorRateLimited     = "rate_limited"     // Too many requests; retrying later helps
+	AIErrorQuotaExceeded   = "quota_exceeded"   // The account is out of credit or daily quota
+	AIErrorInvalidRequest  = "invalid_request"  // The provider refused the request, e.g. unknown model
+	AIErrorUnavailable     = "unavailable"      // The provider failed or is overloaded
+	AIErrorTimeout         = "timeout"          // No answer within the configured timeout
+	AIErrorNetwork         = "network"          // The provider could not be reached
+	AIErrorInvalidResponse = "invalid_response" // The answer did not match the schema, even after repair
+	AIErrorCanceled        = "canceled"         // The client went away
+	AIErrorUsageLimit      = "usage_limit"      // The user or the site used up today's AI quota
+)
+
+// AIError is a classified AI failure. Message is safe to show to users.
+type AIError struct {
+	Kind       string        `json:"kind"`
+	Provider   string        `json:"provider"`
+	Message    string        `json:"message"`
+	Retryable  bool          `json:"retryable"`
+	StatusCode int           `json:"status_code,omitempty"` // HTTP status from the provider, if any
+	Details    []string      `json:"details,omitempty"`     // Validation errors for invalid responses
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *AIError) Error() string {
+	return e.Message
+}
+
+// classifyError turns a provider failure into an AIError, taking each provider's
+// way of reporting key, quota and overload problems into account
+func (ai *AIService) classifyError(err error) *AIError {
+	if aiErr, ok := err.(*AIError); ok {
+		return aiErr
+	}
+
+	provider := string(ai.config.Provider)
+	switch err {
+	case ErrMissingAPIKey, ErrAIDisabled:
+		return ai.notConfigured()
+	case context.DeadlineExceeded:
+		return &AIError{Kind: AIErrorTimeout, Provider: provider, Retryable: true,
+			Message: fmt.Sprintf("The %s API did not answer in time. Please try again.", provider)}
+	case context.Canceled:
+		return &AIError{Kind: AIErrorCanceled, Provider: provider, Message: "The request was canceled."}
+	}
+
+	if providerErr, ok := err.(*ProviderError); ok {
+		return classifyProviderError(ai.config.Provider, providerErr)
+	}
+	if netErr, ok := err.(net.Error); ok {
+		if netErr.Timeout() {
+			return &AIError{Kind: AIErrorTimeout, Provider: provider, Retryable: true,
+				Message: fmt.Sprintf("The %s API did not answer in time. Please try again.", provider)}
+		}
+		return &AIError{Kind: AIErrorNetwork, Provider: provider, Retryable: true,
+			Message: fmt.Sprintf("Could not reach the %s API: %v", provider, err)}
+	}
+	return &AIError{Kind: AIErrorUnavailable, Provider: provider, Message: fmt.Sprintf("AI service unavailable: %v", err)}
+}
+
+// notConfigured is the error for requests made while no provider is available
+func (ai *AIService) notConfigured() *AIError {
+	return &AIError{Kind: AIErrorNotConfigured, Provider: string(ai.config.Provider), Message: ai.UnavailableMessage()}
+}
+
+// classifyProviderError interprets an HTTP error response from a provider
+func classifyProviderError(provider LLMProvider, err *ProviderError) *AIError {
+	message := strings.ToLower(err.Message)
+	aiErr := &AIError{Provider: string(provider), StatusCode: err.StatusCode, RetryAfter: err.RetryAfter}
+
+	switch {
+	case err.StatusCode == 401 || err.StatusCode == 403,
+		// Gemini reports a bad key as 400 INVALID_ARGUMENT
+		provider == ProviderGemini && err.StatusCode == 400 && strings.Contains(message, "api key"):
+		aiErr.Kind = AIErrorAuth
+		aiErr.Message = fmt.Sprintf("The %s API rejected the API key. Check the key configured for the server.", provider)
+
+	case err.StatusCode == 429 && isQuotaExhausted(provider, message):
+		aiErr.Kind = AIErrorQuotaExceeded
+		aiErr.Message = fmt.Sprintf("The %s account is out of quota or credit: %s", provider, err.Message)
+
+	case err.StatusCode == 429:
+		aiErr.Kind = AIErrorRateLimited
+		aiErr.Retryable = true
+		aiErr.Message = fmt.Sprintf("The %s API is rate limiting requests. Please wait a moment and try again.", provider)
+
+	case err.StatusCode >= 500:
+		// Includes Claude's 529 "overloaded"
+		aiErr.Kind = AIErrorUnavailable
+		aiErr.Retryable = true
+		aiErr.Message = fmt.Sprintf("The %s API is having problems (HTTP %d). Please try again later.", provider, err.StatusCode)
+
+	default:
+		aiErr.Kind = AIErrorInvalidRequest
+		aiErr.Message = fmt.Sprintf("The %s API refused the request: %s", provider, err.Message)
+	}
+	return aiErr
+}
+
+// isQuotaExhausted tells a spent quota, which waiting will not fix, from a rate limit
+func isQuotaExhausted(provider LLMProvider, message string) bool {
+	switch provider {
+	case ProviderOpenAI, ProviderOpenAICompatible:
+		return strings.Contains(message, "insufficient_quota") || strings.Contains(message, "exceeded your current quota")
+	case ProviderGemini:
+		return strings.Contains(message, "per day") || strings.Contains(message, "billing")
+	case ProviderClaude:
+		return strings.Contains(message, "credit balance")
+	}
+	return false
+}
+
+// withRetries runs call, retrying retryable failures with exponential backoff and jitter.
+// A provider's Retry-After is honoured when it asks for a longer wait.
+func (ai *AIService) withRetries(ctx context.Context, task string, call func() error) error {
+	start := time.Now()
+	provider := ai.provider.Name()
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil {
+			metrics.AIRequestDuration.Observe(metrics.Since(start), provider, task, "ok")
+			return nil
+		}
+
+		aiErr := ai.classifyError(err)
+		metrics.AIErrors.Inc(provider, task, aiErr.Kind)
+		if !aiErr.Retryable || attempt >= ai.config.MaxRetries {
+			metrics.AIRequestDuration.Observe(metrics.Since(start), provider, task, "error")
+			slog.WarnContext(ctx, "AI request failed", "task", task, "provider", provider, "kind", aiErr.Kind, "error", aiErr.Message)
+			return aiErr
+		}
+
+		wait := ai.config.RetryBaseDelay << uint(attempt)
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		if aiErr.RetryAfter > wait {
+			wait = aiErr.RetryAfter
+		}
+		slog.InfoContext(ctx, "AI request failed, retrying", "task", task, "kind", aiErr.Kind,
+			"wait", wait.Round(time.Millisecond), "attempt", attempt+1, "max_retries", ai.config.MaxRetries)
+
+		select {
+		case <-ctx.Done():
+			metrics.AIRequestDuration.Observe(metrics.Since(start), provider, task, "error")
+			return ai.classifyError(ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
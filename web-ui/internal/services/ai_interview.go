@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RubricCriterion is one dimension of the interview evaluation
+type RubricCriterion struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// InterviewRubric is what every mock interview is scored on, each from 1 to 5
+var InterviewRubric = []RubricCriterion{
+	{Key: "problem_solving", Name: "Problem solving", Description: "Understands the problem, chooses a sound approach and reaches a working solution"},
+	{Key: "code_quality", Name: "Code quality", Description: "Readable, idiomatic Go with sensible naming, structure and error handling"},
+	{Key: "complexity", Name: "Complexity analysis", Description: "Correctly analyses time and space complexity and discusses trade-offs"},
+	{Key: "testing", Name: "Testing and edge cases", Description: "Identifies edge cases and verifies the solution with tests"},
+	{Key: "communication", Name: "Communication", Description: "Explains reasoning clearly and responds well to follow-up questions"},
+}
+
+// Interview recommendations, strongest first
+var interviewRecommendations = []string{"strong_hire", "hire", "lean_hire", "lean_no_hire", "no_hire"}
+
+// RubricScore is the score for one rubric criterion
+type RubricScore struct {
+	Criterion string `json:"criterion"` // RubricCriterion.Key
+	Score     int    `json:"score"`     // 1-5
+	Comment   string `json:"comment"`
+}
+
+// InterviewEvaluation is the rubric-based assessment produced when an interview ends
+type InterviewEvaluation struct {
+	Scores         []RubricScore `json:"scores"`
+	OverallScore   int           `json:"overall_score"` // 0-100
+	Summary        string        `json:"summary"`
+	Strengths      []string      `json:"strengths"`
+	Improvements   []string      `json:"improvements"`
+	Recommendation string        `json:"recommendation"`         // One of strong_hire, hire, lean_hire, lean_no_hire, no_hire
+	HintPenalty    int           `json:"hint_penalty,omitempty"` // Deducted from the overall score for hints used
+	PromptVersion  string        `json:"prompt_version,omitempty"`
+}
+
+// InterviewerTurn returns the interviewer's next message for the session and the
+// version of the prompt used
+func (ai *AIService) InterviewerTurn(ctx context.Context, session *InterviewSession, challenge *ChallengeContext) (string, string, error) {
+	if ai.provider == nil {
+		return "", "", ai.notConfigured()
+	}
+
+	prompt, promptVersion := ai.buildInterviewTurnPrompt(session, challenge)
+	response, err := ai.complete(ctx, TaskInterviewTurn, prompt, promptVersion, textResponse)
+	if err != nil {
+		return "", "", err
+	}
+
+	reply := strings.TrimSpace(response)
+	reply = strings.TrimPrefix(reply, "Interviewer:")
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		reply = "Can you walk me through your approach so far?"
+	}
+	return reply, promptVersion, nil
+}
+
+// EvaluateInterview scores the session against InterviewRubric
+func (ai *AIService) EvaluateInterview(ctx context.Context, session *InterviewSession, challenge *ChallengeContext) (*InterviewEvaluation, error) {
+	if ai.provider == nil {
+		return nil, ai.notConfigured()
+	}
+
+	var evaluation InterviewEvaluation
+	prompt, promptVersion := ai.buildInterviewEvaluationPrompt(session, challenge)
+	err := ai.completeValidated(ctx, TaskInterviewEvaluation, prompt, promptVersion, jsonObjectResponse, func(response string) []string {
+		evaluation = InterviewEvaluation{}
+		if err := decodeJSONResponse(response, &evaluation); err != nil {
+			return []string{fmt.Sprintf("response is not a valid evaluation object: %v", err)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	normalizeEvaluation(&evaluation)
+	if session.HintPenalty > 0 {
+		evaluation.HintPenalty = session.HintPenalty
+		evaluation.OverallScore -= session.HintPenalty
+		if evaluation.OverallScore < 0 {
+			evaluation.OverallScore = 0
+		}
+	}
+	evaluation.PromptVersion = promptVersion
+	return &evaluation, nil
+}
+
+// normalizeEvaluation keeps exactly one score per rubric criterion within range and
+// derives the overall score and recommendation when the model leaves them out
+func normalizeEvaluation(evaluation *InterviewEvaluation) {
+	byKey := make(map[string]RubricScore)
+	for _, score := range evaluation.Scores {
+		byKey[score.Criterion] = score
+	}
+
+	scores := make([]RubricScore, 0, len(InterviewRubric))
+	total := 0
+	for _, criterion := range InterviewRubric {
+		score, ok := byKey[criterion.Key]
+		if !ok {
+			score = RubricScore{Criterion: criterion.Key, Score: 1, Comment: "Not assessed."}
+		}
+		if score.Score < 1 {
+			score.Score = 1
+		}
+		if score.Score > 5 {
+			score.Score = 5
+		}
+		total += score.Score
+		scores = append(scores, score)
+	}
+	evaluation.Scores = scores
+
+	if evaluation.OverallScore <= 0 || evaluation.OverallScore > 100 {
+		evaluation.OverallScore = total * 100 / (5 * len(InterviewRubric))
+	}
+
+	valid := false
+	for _, recommendation := range interviewRecommendations {
+		if evaluation.Recommendation == recommendation {
+			valid = true
+		}
+	}
+	if !valid {
+		switch {
+		case evaluation.OverallScore >= 85:
+			evaluation.Recommendation = "strong_hire"
+		case evaluation.OverallScore >= 70:
+			evaluation.Recommendation = "hire"
+		case evaluation.OverallScore >= 55:
+			evaluation.Recommendation = "lean_hire"
+		case evaluation.OverallScore >= 40:
+			evaluation.Recommendation = "lean_no_hire"
+		default:
+			evaluation.Recommendation = "no_hire"
+		}
+	}
+
+	if evaluation.Strengths == nil {
+		evaluation.Strengths = []string{}
+	}
+	if evaluation.Improvements == nil {
+		evaluation.Improvements = []string{}
+	}
+}
+
+// buildInterviewTurnPrompt creates the prompt for the interviewer's next message
+func (ai *AIService) buildInterviewTurnPrompt(session *InterviewSession, challenge *ChallengeContext) (string, string) {
+	opening := "This is the start of the interview. Greet the candidate briefly and ask your first question about their approach."
+	if len(session.Turns) > 0 {
+		opening = "Respond to the candidate's last answer. Probe vague or incorrect answers; move on to a new topic when an answer is solid."
+	}
+
+	return ai.prompts.Render(TaskInterviewTurn, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		Opening:          opening,
+		InterviewState:   interviewStateSection(session),
+		Transcript:       interviewTranscript(session),
+	})
+}
+
+// buildInterviewEvaluationPrompt creates the prompt for the final rubric evaluation
+func (ai *AIService) buildInterviewEvaluationPrompt(session *InterviewSession, challenge *ChallengeContext) (string, string) {
+	return ai.prompts.Render(TaskInterviewEvaluation, &PromptData{
+		Challenge:        challenge,
+		ChallengeSection: challenge.promptSection(),
+		InterviewState:   interviewStateSection(session),
+		Transcript:       interviewTranscript(session),
+		Rubric:           InterviewRubric,
+		Recommendations:  interviewRecommendations,
+	})
+}
+
+// interviewStateSection describes the candidate's latest code and test runs
+func interviewStateSection(session *InterviewSession) string {
+	var section strings.Builder
+
+	code := session.LatestCode()
+	if code == "" {
+		code = "(no code written yet)"
+	}
+	fmt.Fprintf(&section, "CURRENT CODE (Go):\nBEGIN_CODE\n%s\nEND_CODE\n\nTEST RUNS:\n", code)
+
+	runs := session.Runs
+	if len(runs) == 0 {
+		section.WriteString("(tests not run yet)\n")
+	}
+	if len(runs) > 5 {
+		fmt.Fprintf(&section, "(%d earlier runs omitted)\n", len(runs)-5)
+		runs = runs[len(runs)-5:]
+	}
+	for _, run := range runs {
+		status := "FAIL"
+		if run.Passed {
+			status = "PASS"
+		}
+		fmt.Fprintf(&section, "- %s %s: %d/%d tests passed\n", run.At.Format("15:04"), status, run.TestsPassed, run.TestsTotal)
+	}
+	if session.HintsUsed > 0 {
+		fmt.Fprintf(&section, "\nHINTS USED: %d\n", session.HintsUsed)
+	}
+	return section.String()
+}
+
+// interviewTranscript formats the dialogue so far
+func interviewTranscript(session *InterviewSession) string {
+	if len(session.Turns) == 0 {
+		return "(none yet)"
+	}
+	var transcript strings.Builder
+	for _, turn := range session.Turns {
+		speaker := "Interviewer"
+		if turn.Role == RoleCandidate {
+			speaker = "Candidate"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, turn.Content)
+	}
+	return transcript.String()
+}
+
+// truncateText shortens text to at most max bytes
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max] + "..."
+}
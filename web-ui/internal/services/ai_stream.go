@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamCodeHint streams a hint, calling onText with each piece of hint text as it arrives.
+// It returns the full hint and the version of the prompt used.
+func (ai *AIService) StreamCodeHint(ctx context.Context, code string, challenge *ChallengeContext, hintLevel int, onText func(text string) error) (string, string, error) {
+	if ai.provider == nil {
+		return "", "", ai.notConfigured()
+	}
+
+	prompt, promptVersion := ai.buildHintPrompt(code, challenge, hintLevel, "", nil)
+	response, err := ai.stream(ctx, TaskCodeHint, prompt, promptVersion, textResponse, onText)
+	if err != nil {
+		return "", "", err
+	}
+	return ai.parseHint(response), promptVersion, nil
+}
+
+// StreamCodeReview streams the interviewer feedback while the review is generated.
+// onProgress receives the number of characters received so far; the full review is
+// parsed and validated once the stream completes.
+func (ai *AIService) StreamCodeReview(ctx context.Context, code string, challenge *ChallengeContext, reviewContext string, onFeedback func(text string) error, onProgress func(received int) error) (*AICodeReview, error) {
+	if ai.provider == nil {
+		return nil, ai.notConfigured()
+	}
+
+	feedback := newJSONFieldStreamer("interviewer_feedback")
+	received := 0
+
+	analysis := ai.analyze(ctx, code, challenge)
+	prompt, promptVersion := ai.buildCodeReviewPrompt(code, challenge, reviewContext, analysis)
+	response, err := ai.stream(ctx, TaskCodeReview, prompt, promptVersion, jsonObjectResponse, func(chunk string) error {
+		received += len(chunk)
+		if text := feedback.Write(chunk); text != "" {
+			if err := onFeedback(text); err != nil {
+				return err
+			}
+		}
+		return onProgress(received)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// An invalid review is repaired without streaming; the feedback shown so far stays
+	var review *AICodeReview
+	err = ai.repair(ctx, TaskCodeReview, prompt, promptVersion, jsonObjectResponse, response, func(response string) []string {
+		var problems []string
+		review, problems = parseCodeReview(response, countLines(code))
+		return problems
+	})
+	if err != nil {
+		return nil, err
+	}
+	groundReview(review, analysis)
+	review.PromptVersion = promptVersion
+	return review, nil
+}
+
+// stream sends a prompt to the provider and streams the response to onChunk. Like complete,
+// it uses the cache and quotas; a cached response arrives as a single chunk. Transient
+// failures are retried only while nothing has been streamed yet. Errors are returned as *AIError.
+func (ai *AIService) stream(ctx context.Context, task, prompt, promptVersion string, format responseFormat, onChunk func(chunk string) error) (string, error) {
+	request := ai.completionRequest(task, prompt, format)
+	user := AIUserFromContext(ctx)
+
+	key := aiCacheKey(ai.provider.Name(), ai.provider.Model(), request)
+	if response, ok := ai.cache.Get(key); ok {
+		ai.recordUsage(user, task, promptVersion, Usage{}, true)
+		return response, onChunk(response)
+	}
+	reservation, err := ai.reserveQuota(user, request)
+	if err != nil {
+		return "", err
+	}
+	defer reservation.Release()
+
+	ctx, cancel := context.WithTimeout(ctx, ai.config.StreamTimeout)
+	defer cancel()
+
+	streamed := false
+	var response string
+	err = ai.withRetries(ctx, task, func() error {
+		request.Usage = &Usage{}
+		var err error
+		response, err = ai.provider.Stream(ctx, request, func(chunk string) error {
+			streamed = true
+			return onChunk(chunk)
+		})
+		if err != nil && streamed {
+			// Part of the answer is already on screen; report the error instead of repeating it
+			aiErr := ai.classifyError(err)
+			aiErr.Retryable = false
+			return aiErr
+		}
+		if err == nil {
+			ai.commitUsage(reservation, task, promptVersion, estimateUsage(request, response))
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ai.cache.Put(key, response)
+	return response, nil
+}
+
+// jsonFieldStreamer decodes the string value of a single JSON field from a response
+// that is still streaming in, so it can be shown before the document is complete
+type jsonFieldStreamer struct {
+	key     string
+	buffer  string
+	pos     int // Offset of the next undecoded byte of the value, once found
+	inValue bool
+	done    bool
+}
+
+func newJSONFieldStreamer(key string) *jsonFieldStreamer {
+	return &jsonFieldStreamer{key: `"` + key + `"`}
+}
+
+// Write adds a chunk of the response and returns any newly decoded text of the field
+func (s *jsonFieldStreamer) Write(chunk string) string {
+	if s.done {
+		return ""
+	}
+	s.buffer += chunk
+
+	if !s.inValue && !s.findValue() {
+		return ""
+	}
+
+	var text strings.Builder
+	for s.pos < len(s.buffer) {
+		c := s.buffer[s.pos]
+		switch {
+		case c == '"':
+			s.done = true
+			return text.String()
+		case c == '\\':
+			decoded, size := decodeJSONEscape(s.buffer[s.pos:])
+			if size == 0 {
+				return text.String() // Escape sequence not complete yet
+			}
+			text.WriteString(decoded)
+			s.pos += size
+		default:
+			if !utf8.FullRuneInString(s.buffer[s.pos:]) {
+				return text.String() // Multi-byte character split across chunks
+			}
+			_, size := utf8.DecodeRuneInString(s.buffer[s.pos:])
+			text.WriteString(s.buffer[s.pos : s.pos+size])
+			s.pos += size
+		}
+	}
+	return text.String()
+}
+
+// findValue locates the opening quote of the field's value. The key only counts when
+// followed by a colon, since the same text may appear as another field's value.
+func (s *jsonFieldStreamer) findValue() bool {
+	for from := 0; ; {
+		start := strings.Index(s.buffer[from:], s.key)
+		if start == -1 {
+			return false
+		}
+		i := from + start + len(s.key)
+		i = skipJSONSpace(s.buffer, i)
+		if i >= len(s.buffer) {
+			return false // Wait for what follows the key
+		}
+		if s.buffer[i] != ':' {
+			from = i
+			continue
+		}
+		i = skipJSONSpace(s.buffer, i+1)
+		if i >= len(s.buffer) {
+			return false
+		}
+		if s.buffer[i] != '"' {
+			s.done = true // Not a string value; nothing to stream
+			return false
+		}
+		s.inValue = true
+		s.pos = i + 1
+		return true
+	}
+}
+
+// skipJSONSpace returns the offset of the first byte at or after i that is not JSON whitespace
+func skipJSONSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) != -1 {
+		i++
+	}
+	return i
+}
+
+// decodeJSONEscape decodes the escape sequence at the start of s, returning its size or 0 if incomplete
+func decodeJSONEscape(s string) (string, int) {
+	if len(s) < 2 {
+		return "", 0
+	}
+	size := 2
+	if s[1] == 'u' {
+		size = 6
+		if len(s) < size {
+			return "", 0
+		}
+		// A high surrogate is only meaningful together with the low surrogate that follows,
+		// so wait for the next escape; without one it decodes to U+FFFD on its own
+		if (s[2] == 'd' || s[2] == 'D') && strings.IndexByte("89abAB", s[3]) != -1 {
+			if strings.HasPrefix(`\u`, s[6:]) {
+				return "", 0
+			}
+			if strings.HasPrefix(s[6:], `\u`) {
+				size = 12
+			}
+		}
+		if len(s) < size {
+			return "", 0
+		}
+	}
+
+	var decoded string
+	if err := json.Unmarshal([]byte(`"`+s[:size]+`"`), &decoded); err != nil {
+		return s[:size], size
+	}
+	return decoded, size
+}
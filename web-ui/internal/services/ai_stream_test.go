@@ -0,0 +1,79 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONFieldStreamer(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string // Text returned by each Write
+	}{
+		{"whole document", []string{`{"feedback": "Looks good"}`}, []string{"Looks good"}},
+		{"value across chunks", []string{`{"feedback": "Looks`, ` good", "x": 1}`}, []string{"Looks", " good"}},
+		{"key before its colon", []string{`{"feedback"`, ` `, `: "ok"}`}, []string{"", "", "ok"}},
+		{"key split across chunks", []string{`{"feed`, `back":"ok"}`}, []string{"", "ok"}},
+		{"key as another field's value", []string{`{"title": "feedback", "feedback": "ok"}`}, []string{"ok"}},
+		{"key as a value before its colon arrives", []string{`{"title": "feedback"`, `, "feedback": "ok"}`}, []string{"", "ok"}},
+		{"escape split after the backslash", []string{`{"feedback": "a\`, `nb"}`}, []string{"a", "\nb"}},
+		{"escaped quote", []string{`{"feedback": "say \`, `"hi\""}`}, []string{"say ", `"hi"`}},
+		{"unicode escape split", []string{`{"feedback": "\u00`, `e9té"}`}, []string{"", "été"}},
+		{"surrogate pair split between halves", []string{`{"feedback": "\uD83D`, `\uDE00!"}`}, []string{"", "😀!"}},
+		{"surrogate pair split inside the low half", []string{`{"feedback": "\ud83d\ude`, `00"}`}, []string{"", "😀"}},
+		{"lone high surrogate", []string{`{"feedback": "\uD83Dx`, `"}`}, []string{"\uFFFDx", ""}},
+		{"multi-byte rune split", []string{"{\"feedback\": \"caf\xc3", "\xa9 \xf0\x9f", "\x98\x80\"}"}, []string{"caf", "é ", "😀"}},
+		{"non-string value", []string{`{"feedback": null, "other": "x"}`}, []string{""}},
+		{"number value", []string{`{"feedback": `, `42}`}, []string{"", ""}},
+		{"nothing after the value", []string{`{"feedback": "done"}`, `, "feedback": "again"`}, []string{"done", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newJSONFieldStreamer("feedback")
+			for i, chunk := range tt.chunks {
+				if got := s.Write(chunk); got != tt.want[i] {
+					t.Errorf("Write(%q) = %q, want %q", chunk, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJSONFieldStreamerByteAtATime(t *testing.T) {
+	document := `{"score": 7, "feedback": "Tab\there, quote \" and emoji 😀 and é", "done": true}`
+	s := newJSONFieldStreamer("feedback")
+	var got strings.Builder
+	for i := 0; i < len(document); i++ {
+		got.WriteString(s.Write(document[i : i+1]))
+	}
+	if want := "Tab\there, quote \" and emoji 😀 and é"; got.String() != want {
+		t.Errorf("got %q, want %q", got.String(), want)
+	}
+}
+
+func TestDecodeJSONEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		size int
+	}{
+		{`\`, "", 0},
+		{`\n`, "\n", 2},
+		{`\/rest`, "/", 2},
+		{`\u00e`, "", 0},
+		{`\u00e9rest`, "é", 6},
+		{`\uD83D`, "", 0},
+		{`\uD83D\uDE0`, "", 0},
+		{`\uD83D\uDE00rest`, "😀", 12},
+		{`\uD83Dabcdef`, "\uFFFD", 6},
+		{`\uD83D\n`, "\uFFFD", 6},
+		{`\uD83D\`, "", 0},
+		{`\x`, `\x`, 2},
+	}
+	for _, tt := range tests {
+		if got, size := decodeJSONEscape(tt.in); got != tt.want || size != tt.size {
+			t.Errorf("decodeJSONEscape(%q) = %q, %d; want %q, %d", tt.in, got, size, tt.want, tt.size)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageHistoryDays is how many days of AI usage are kept for the usage report
+const usageHistoryDays = 30
+
+// usageFlushInterval is how often recorded AI usage is written to disk
+const usageFlushInterval = 30 * time.Second
+
+type aiUserContextKey struct{}
+
+// WithAIUser attributes AI requests made with ctx to user: a username, or an
+// "anonymous:<address>" key for visitors who are not signed in
+func WithAIUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, aiUserContextKey{}, user)
+}
+
+// AIUserFromContext returns who AI usage is charged to, "anonymous" if unknown
+func AIUserFromContext(ctx context.Context) string {
+	if user, ok := ctx.Value(aiUserContextKey{}).(string); ok && user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// AIQuota limits daily token use per user and for the whole site; 0 means unlimited
+type AIQuota struct {
+	UserTokens   int `json:"user_tokens"`
+	GlobalTokens int `json:"global_tokens"`
+}
+
+// UsageTotals accumulates AI usage
+type UsageTotals struct {
+	Requests         int `json:"requests"`   // Calls made to the provider
+	CacheHits        int `json:"cache_hits"` // Requests answered from the cache
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Tokens returns the prompt and completion tokens together
+func (t UsageTotals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+func (t *UsageTotals) add(usage Usage, cached bool) {
+	if cached {
+		t.CacheHits++
+		return
+	}
+	t.Requests++
+	t.PromptTokens += usage.PromptTokens
+	t.CompletionTokens += usage.CompletionTokens
+}
+
+// dailyUsage is one UTC day of usage, broken down by user, task and model
+type dailyUsage struct {
+	Total   UsageTotals             `json:"total"`
+	Users   map[string]*UsageTotals `json:"users"`
+	Tasks   map[string]*UsageTotals `json:"tasks"`
+	Models  map[string]*UsageTotals `json:"models"`  // Keyed by "provider/model"
+	Prompts map[string]*UsageTotals `json:"prompts"` // Keyed by "task@version", for comparing prompt versions
+}
+
+// QuotaStatus is how much of a daily quota has been used
+type QuotaStatus struct {
+	Used      int       `json:"used"`
+	Limit     int       `json:"limit"`     // 0 when unlimited
+	Remaining int       `json:"remaining"` // -1 when unlimited
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// AIUsageReport is the admin view of AI usage
+type AIUsageReport struct {
+	Quota AIQuota      `json:"quota"`
+	Cache AICacheStats `json:"cache"`
+	Days  []AIUsageDay `json:"days"` // Most recent first
+}
+
+// AIUsageDay is the usage for one day in the report
+type AIUsageDay struct {
+	Date    string          `json:"date"`
+	Total   UsageTotals     `json:"total"`
+	Users   []AIUsageByName `json:"users"` // Heaviest users first
+	Tasks   []AIUsageByName `json:"tasks"`
+	Models  []AIUsageByName `json:"models"`
+	Prompts []AIUsageByName `json:"prompts"`
+}
+
+// AIUsageByName is usage attributed to one user, task or model
+type AIUsageByName struct {
+	Name string `json:"name"`
+	UsageTotals
+}
+
+// AIUsageService records token usage per day and enforces the daily quotas
+type AIUsageService struct {
+	path          string
+	quota         AIQuota
+	days          map[string]*dailyUsage // Keyed by UTC date, 2006-01-02
+	reserved      map[string]int         // Tokens held by requests in flight, by user
+	reservedTotal int
+	dirty         bool // Usage recorded since the last flush
+	mutex         sync.Mutex
+	flushMutex    sync.Mutex // Serializes writes of the file, which happen outside mutex
+}
+
+// AIReservation holds a request's expected tokens against the quotas while it is in
+// flight, until it is committed with the actual usage or released
+type AIReservation struct {
+	service *AIUsageService
+	user    string
+	tokens  int
+	done    bool
+}
+
+// NewAIUsageService loads recorded usage from dataDir and enforces the daily quotas
+func NewAIUsageService(dataDir string, quota AIQuota) *AIUsageService {
+	service := &AIUsageService{
+		path:     filepath.Join(dataDir, "ai-usage.json"),
+		quota:    quota,
+		days:     make(map[string]*dailyUsage),
+		reserved: make(map[string]int),
+	}
+
+	data, err := os.ReadFile(service.path)
+	if err == nil {
+		if err := json.Unmarshal(data, &service.days); err != nil {
+			slog.Warn("Could not read AI usage", "path", service.path, "error", err)
+			service.days = make(map[string]*dailyUsage)
+		}
+	} else if !os.IsNotExist(err) {
+		slog.Warn("Could not read AI usage", "path", service.path, "error", err)
+	}
+	return service
+}
+
+// Quota returns the configured daily quotas
+func (s *AIUsageService) Quota() AIQuota {
+	return s.quota
+}
+
+// Check returns an AIError if user or the site has used up today's quota, counting
+// the tokens reserved by requests in flight
+func (s *AIUsageService) Check(user string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.checkLocked(user)
+}
+
+// Reserve checks the quotas and holds tokens for a request about to be sent, in one
+// step, so concurrent requests cannot all pass the check before any usage is recorded
+func (s *AIUsageService) Reserve(user string, tokens int) (*AIReservation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.checkLocked(user); err != nil {
+		return nil, err
+	}
+	s.reserved[user] += tokens
+	s.reservedTotal += tokens
+	return &AIReservation{service: s, user: user, tokens: tokens}, nil
+}
+
+// Commit records the request's actual usage in place of its reservation
+func (r *AIReservation) Commit(provider, model, task, promptVersion string, usage Usage) {
+	r.service.mutex.Lock()
+	defer r.service.mutex.Unlock()
+	r.releaseLocked()
+	r.service.recordLocked(r.user, provider, model, task, promptVersion, usage, false)
+}
+
+// Release gives the reserved tokens back; it does nothing once committed or on a nil
+// reservation
+func (r *AIReservation) Release() {
+	if r == nil {
+		return
+	}
+	r.service.mutex.Lock()
+	defer r.service.mutex.Unlock()
+	r.releaseLocked()
+}
+
+func (r *AIReservation) releaseLocked() {
+	if r.done {
+		return
+	}
+	r.done = true
+	s := r.service
+	s.reservedTotal -= r.tokens
+	if s.reserved[r.user] -= r.tokens; s.reserved[r.user] <= 0 {
+		delete(s.reserved, r.user)
+	}
+}
+
+func (s *AIUsageService) checkLocked(user string) error {
+	today := s.dayLocked(time.Now())
+	resetsAt := nextUTCMidnight(time.Now())
+
+	if s.quota.GlobalTokens > 0 && today.Total.Tokens()+s.reservedTotal >= s.quota.GlobalTokens {
+		return &AIError{
+			Kind:       AIErrorUsageLimit,
+			Message:    fmt.Sprintf("The site's daily AI budget is used up. It resets at %s.", resetsAt.Format("15:04 MST")),
+			RetryAfter: time.Until(resetsAt),
+		}
+	}
+	used := s.reserved[user]
+	if totals, ok := today.Users[user]; ok {
+		used += totals.Tokens()
+	}
+	if s.quota.UserTokens > 0 && used >= s.quota.UserTokens {
+		return &AIError{
+			Kind:       AIErrorUsageLimit,
+			Message:    fmt.Sprintf("You have used your daily AI allowance of %d tokens. It resets at %s.", s.quota.UserTokens, resetsAt.Format("15:04 MST")),
+			RetryAfter: time.Until(resetsAt),
+		}
+	}
+	return nil
+}
+
+// Record adds a request's usage to today's totals
+func (s *AIUsageService) Record(user, provider, model, task, promptVersion string, usage Usage, cached bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.recordLocked(user, provider, model, task, promptVersion, usage, cached)
+}
+
+func (s *AIUsageService) recordLocked(user, provider, model, task, promptVersion string, usage Usage, cached bool) {
+	today := s.dayLocked(time.Now())
+	today.Total.add(usage, cached)
+	usageEntry(today.Users, user).add(usage, cached)
+	usageEntry(today.Tasks, task).add(usage, cached)
+	usageEntry(today.Models, provider+"/"+model).add(usage, cached)
+	usageEntry(today.Prompts, task+"@"+promptVersion).add(usage, cached)
+	s.dirty = true
+}
+
+// Start saves recorded usage in the background every usageFlushInterval. Call Flush at
+// shutdown for the usage recorded since.
+func (s *AIUsageService) Start() {
+	go func() {
+		for range time.Tick(usageFlushInterval) {
+			if err := s.Flush(); err != nil {
+				slog.Warn("Could not save AI usage", "error", err)
+			}
+		}
+	}()
+}
+
+// Flush writes the usage to disk if any was recorded since the last flush
+func (s *AIUsageService) Flush() error {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	if !s.dirty {
+		s.mutex.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.days)
+	s.dirty = false
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(s.path, json.RawMessage(data)); err != nil {
+		s.mutex.Lock()
+		s.dirty = true // Try again on the next flush
+		s.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// UserQuota returns how much of today's quota user and the site have used
+func (s *AIUsageService) UserQuota(user string) (userStatus, globalStatus QuotaStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	today := s.dayLocked(time.Now())
+	used := 0
+	if totals, ok := today.Users[user]; ok {
+		used = totals.Tokens()
+	}
+	resetsAt := nextUTCMidnight(time.Now())
+	return quotaStatus(used, s.quota.UserTokens, resetsAt), quotaStatus(today.Total.Tokens(), s.quota.GlobalTokens, resetsAt)
+}
+
+// Report summarizes the last days of usage, most recent first
+func (s *AIUsageService) Report(days int) AIUsageReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	report := AIUsageReport{Quota: s.quota, Days: []AIUsageDay{}}
+	now := time.Now().UTC()
+	for i := 0; i < days; i++ {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		day, ok := s.days[date]
+		if !ok {
+			continue
+		}
+		report.Days = append(report.Days, AIUsageDay{
+			Date:    date,
+			Total:   day.Total,
+			Users:   sortedUsage(day.Users),
+			Tasks:   sortedUsage(day.Tasks),
+			Models:  sortedUsage(day.Models),
+			Prompts: sortedUsage(day.Prompts),
+		})
+	}
+	return report
+}
+
+// dayLocked returns the usage for the day containing t, dropping days beyond the history
+func (s *AIUsageService) dayLocked(t time.Time) *dailyUsage {
+	date := t.UTC().Format("2006-01-02")
+	day, ok := s.days[date]
+	if ok {
+		if day.Prompts == nil {
+			// Recorded before usage was broken down by prompt version
+			day.Prompts = make(map[string]*UsageTotals)
+		}
+		return day
+	}
+
+	day = &dailyUsage{
+		Users:   make(map[string]*UsageTotals),
+		Tasks:   make(map[string]*UsageTotals),
+		Models:  make(map[string]*UsageTotals),
+		Prompts: make(map[string]*UsageTotals),
+	}
+	s.days[date] = day
+
+	cutoff := t.UTC().AddDate(0, 0, -usageHistoryDays).Format("2006-01-02")
+	for old := range s.days {
+		if old < cutoff {
+			delete(s.days, old)
+		}
+	}
+	return day
+}
+
+func usageEntry(entries map[string]*UsageTotals, name string) *UsageTotals {
+	totals, ok := entries[name]
+	if !ok {
+		totals = &UsageTotals{}
+		entries[name] = totals
+	}
+	return totals
+}
+
+func sortedUsage(entries map[string]*UsageTotals) []AIUsageByName {
+	sorted := make([]AIUsageByName, 0, len(entries))
+	for name, totals := range entries {
+		sorted = append(sorted, AIUsageByName{Name: name, UsageTotals: *totals})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Tokens() != sorted[j].Tokens() {
+			return sorted[i].Tokens() > sorted[j].Tokens()
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func quotaStatus(used, limit int, resetsAt time.Time) QuotaStatus {
+	status := QuotaStatus{Used: used, Limit: limit, Remaining: -1, ResetsAt: resetsAt}
+	if limit > 0 {
+		status.Remaining = limit - used
+		if status.Remaining < 0 {
+			status.Remaining = 0
+		}
+	}
+	return status
+}
+
+func nextUTCMidnight(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAIUsageReserveIsAtomic(t *testing.T) {
+	usage := NewAIUsageService(t.TempDir(), AIQuota{UserTokens: 1000})
+
+	// Requests are refused once the tokens used and reserved reach the quota, so only
+	// four of ten concurrent 300-token requests may proceed
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var reservations []*AIReservation
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if reservation, err := usage.Reserve("alice", 300); err == nil {
+				mutex.Lock()
+				reservations = append(reservations, reservation)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(reservations) != 4 {
+		t.Fatalf("%d reservations succeeded, want 4", len(reservations))
+	}
+	if _, err := usage.Reserve("bob", 300); err != nil {
+		t.Errorf("another user was refused: %v", err)
+	}
+
+	// Committing charges the actual usage; releasing gives the rest back
+	reservations[0].Commit("mock", "m", TaskCodeReview, "v1", Usage{PromptTokens: 50, CompletionTokens: 50})
+	for _, reservation := range reservations {
+		reservation.Release()
+	}
+	if _, err := usage.Reserve("alice", 300); err != nil {
+		t.Errorf("refused after releasing: %v", err)
+	}
+	if status, _ := usage.UserQuota("alice"); status.Used != 100 {
+		t.Errorf("recorded %d tokens, want 100", status.Used)
+	}
+
+	// Release is safe on a nil reservation, as when usage is not tracked
+	var none *AIReservation
+	none.Release()
+}
+
+func TestAIUsageFlush(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ai-usage.json")
+	usage := NewAIUsageService(dir, AIQuota{})
+
+	// Recording only marks the usage for the next flush
+	usage.Record("alice", "mock", "m", TaskCodeReview, "v1", Usage{PromptTokens: 10, CompletionTokens: 5}, false)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("usage written before a flush: %v", err)
+	}
+
+	if err := usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing new, nothing written
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("flushed again without new usage")
+	}
+
+	usage.Record("alice", "mock", "m", TaskCodeReview, "v1", Usage{}, true)
+	if err := usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("new usage not flushed: %v", err)
+	}
+
+	// The flushed usage is loaded on the next start
+	reloaded := NewAIUsageService(dir, AIQuota{})
+	if status, _ := reloaded.UserQuota("alice"); status.Used != 15 {
+		t.Errorf("reloaded %d tokens, want 15", status.Used)
+	}
+	day := reloaded.Report(1).Days[0]
+	if day.Total.Requests != 1 || day.Total.CacheHits != 1 {
+		t.Errorf("reloaded totals %+v, want 1 request and 1 cache hit", day.Total)
+	}
+}
+
+func TestAIUsageFlushFailureIsRetried(t *testing.T) {
+	dir := t.TempDir()
+	usage := NewAIUsageService(dir, AIQuota{})
+	usage.Record("alice", "mock", "m", TaskCodeReview, "v1", Usage{PromptTokens: 1}, false)
+
+	// A directory in the file's place makes the write fail
+	if err := os.Mkdir(filepath.Join(dir, "ai-usage.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := usage.Flush(); err == nil {
+		t.Fatal("flush over a directory succeeded")
+	}
+	if err := os.Remove(filepath.Join(dir, "ai-usage.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := NewAIUsageService(dir, AIQuota{}).UserQuota("alice"); status.Used != 1 {
+		t.Errorf("usage lost after a failed flush: reloaded %d tokens, want 1", status.Used)
+	}
+}
+
+func TestReserveQuotaError(t *testing.T) {
+	ai := newTestAIService(&fakeProvider{})
+	ai.usageService = NewAIUsageService(t.TempDir(), AIQuota{UserTokens: 1})
+	ai.usageService.Record("alice", "mock", "m", TaskCodeReview, "v1", Usage{PromptTokens: 1}, false)
+
+	_, err := ai.reserveQuota("alice", CompletionRequest{Prompt: "x"})
+	aiErr, ok := err.(*AIError)
+	if !ok || aiErr.Kind != AIErrorUsageLimit || aiErr.Provider != string(ProviderMock) {
+		t.Errorf("got %#v, want a usage limit AIError naming the provider", err)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Allowed values for the enumerated fields of a code review
+var (
+	reviewIssueTypes           = []string{"bug", "performance", "style", "logic"}
+	reviewIssueSeverities      = []string{"low", "medium", "high", "critical"}
+	reviewSuggestionTypes      = []string{"optimization", "best_practice", "alternative"}
+	reviewSuggestionPriorities = []string{"low", "medium", "high"}
+)
+
+// reviewFields are the top-level fields a code review must have, and the only ones it may have
+var reviewFields = []string{
+	"interviewer_feedback", "overall_score", "issues", "suggestions",
+	"follow_up_questions", "complexity", "readability_score", "test_coverage",
+}
+
+// extractJSON returns the JSON value in a model response, tolerating code fences and prose
+func extractJSON(response string) (string, error) {
+	text := strings.TrimSpace(response)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	start := strings.IndexAny(text, "{[")
+	if start == -1 {
+		return "", fmt.Errorf("no JSON found in response")
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return "", fmt.Errorf("incomplete JSON in response")
+	}
+	return text[start : end+1], nil
+}
+
+// parseCodeReview decodes a review of code with the given number of lines and checks it
+// against the schema in the review prompt. It returns the problems found; the review is
+// only usable when there are none.
+func parseCodeReview(response string, lines int) (*AICodeReview, []string) {
+	text, err := extractJSON(response)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return nil, []string{fmt.Sprintf("response is not a valid JSON object: %v", err)}
+	}
+	var problems []string
+	for _, field := range reviewFields {
+		if _, ok := fields[field]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required field %q", field))
+		}
+	}
+	for field := range fields {
+		if !containsString(reviewFields, field) {
+			problems = append(problems, fmt.Sprintf("unexpected field %q", field))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	var review AICodeReview
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&review); err != nil {
+		return nil, []string{fmt.Sprintf("response does not match the schema: %v", err)}
+	}
+
+	checkRange := func(field string, value float64) {
+		if value < 0 || value > 100 {
+			problems = append(problems, fmt.Sprintf("%s must be between 0 and 100, got %v", field, value))
+		}
+	}
+	checkEnum := func(field, value string, allowed []string) {
+		if !containsString(allowed, value) {
+			problems = append(problems, fmt.Sprintf("%s must be one of %s, got %q", field, strings.Join(allowed, "|"), value))
+		}
+	}
+	checkText := func(field, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("%s must not be empty", field))
+		}
+	}
+
+	checkRange("overall_score", review.OverallScore)
+	checkRange("readability_score", review.ReadabilityScore)
+	checkText("interviewer_feedback", review.InterviewerFeedback)
+	checkText("complexity.time_complexity", review.Complexity.TimeComplexity)
+	checkText("complexity.space_complexity", review.Complexity.SpaceComplexity)
+	for i, issue := range review.Issues {
+		checkEnum(fmt.Sprintf("issues[%d].type", i), issue.Type, reviewIssueTypes)
+		checkEnum(fmt.Sprintf("issues[%d].severity", i), issue.Severity, reviewIssueSeverities)
+		checkText(fmt.Sprintf("issues[%d].description", i), issue.Description)
+		if issue.LineNumber < 0 || issue.LineNumber > lines {
+			problems = append(problems, fmt.Sprintf("issues[%d].line_number must be a line of CODE from 1 to %d, or 0, got %d", i, lines, issue.LineNumber))
+		}
+	}
+	for i, suggestion := range review.Suggestions {
+		checkEnum(fmt.Sprintf("suggestions[%d].category", i), suggestion.Category, reviewSuggestionTypes)
+		checkEnum(fmt.Sprintf("suggestions[%d].priority", i), suggestion.Priority, reviewSuggestionPriorities)
+		checkText(fmt.Sprintf("suggestions[%d].description", i), suggestion.Description)
+	}
+	for i, question := range review.FollowUpQuestions {
+		checkText(fmt.Sprintf("follow_up_questions[%d]", i), question)
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	if review.Issues == nil {
+		review.Issues = []CodeIssue{}
+	}
+	if review.Suggestions == nil {
+		review.Suggestions = []CodeSuggestion{}
+	}
+	if review.FollowUpQuestions == nil {
+		review.FollowUpQuestions = []string{}
+	}
+	return &review, nil
+}
+
+// parseQuestions decodes interviewer questions: a JSON array of 1 to 10 non-empty strings
+func parseQuestions(response string) ([]string, []string) {
+	text, err := extractJSON(response)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var questions []string
+	if err := json.Unmarshal([]byte(text), &questions); err != nil {
+		return nil, []string{fmt.Sprintf("response must be a JSON array of strings: %v", err)}
+	}
+	var problems []string
+	if len(questions) == 0 || len(questions) > 10 {
+		problems = append(problems, fmt.Sprintf("expected 1 to 10 questions, got %d", len(questions)))
+	}
+	for i, question := range questions {
+		if strings.TrimSpace(question) == "" {
+			problems = append(problems, fmt.Sprintf("question %d is empty", i))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return questions, nil
+}
+
+// completeValidated requests JSON in the format and checks it with validate. Invalid
+// answers are sent back to the model with the problems found, up to MaxRepairAttempts times.
+func (ai *AIService) completeValidated(ctx context.Context, task, prompt, promptVersion string, format responseFormat, validate func(response string) []string) error {
+	response, err := ai.complete(ctx, task, prompt, promptVersion, format)
+	if err != nil {
+		return err
+	}
+	return ai.repair(ctx, task, prompt, promptVersion, format, response, validate)
+}
+
+// repair re-prompts the model until its response passes validate or the attempts run out.
+// Invalid responses are dropped from the cache so they are not served again.
+func (ai *AIService) repair(ctx context.Context, task, prompt, promptVersion string, format responseFormat, response string, validate func(response string) []string) error {
+	problems := validate(response)
+	lastPrompt := prompt
+	for attempt := 1; len(problems) > 0; attempt++ {
+		ai.forget(task, lastPrompt, format)
+		if attempt > ai.config.MaxRepairAttempts {
+			slog.WarnContext(ctx, "AI response still invalid after repairs", "task", task, "attempts", ai.config.MaxRepairAttempts, "problems", strings.Join(problems, "; "))
+			return &AIError{
+				Kind:      AIErrorInvalidResponse,
+				Provider:  string(ai.config.Provider),
+				Message:   "The AI returned an answer that could not be read. Please try again.",
+				Retryable: true,
+				Details:   problems,
+			}
+		}
+
+		slog.InfoContext(ctx, "AI response failed validation, asking for a repair", "task", task, "problems", strings.Join(problems, "; "), "attempt", attempt, "max_attempts", ai.config.MaxRepairAttempts)
+		var err error
+		lastPrompt = buildRepairPrompt(prompt, response, problems)
+		response, err = ai.complete(ctx, task, lastPrompt, promptVersion, format)
+		if err != nil {
+			return err
+		}
+		problems = validate(response)
+	}
+	return nil
+}
+
+// buildRepairPrompt asks the model to fix its previous answer
+func buildRepairPrompt(prompt, response string, problems []string) string {
+	var list strings.Builder
+	for _, problem := range problems {
+		fmt.Fprintf(&list, "- %s\n", problem)
+	}
+	return fmt.Sprintf(`%s
+
+YOUR PREVIOUS RESPONSE:
+%s
+
+It was rejected because:
+%s
+Respond again with the corrected JSON only, following the schema exactly.`, prompt, truncateText(response, 8000), list.String())
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
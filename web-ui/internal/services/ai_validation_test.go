@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// reviewJSON returns a valid code review with changes applied to its top-level
+// fields; a nil value removes the field
+func reviewJSON(t *testing.T, changes map[string]interface{}) string {
+	t.Helper()
+	review := map[string]interface{}{
+		"interviewer_feedback": "Clear solution.",
+		"overall_score":        80,
+		"issues": []map[string]interface{}{
+			{"type": "style", "severity": "low", "line_number": 2, "description": "Name the result.", "solution": "Use sum."},
+		},
+		"suggestions": []map[string]interface{}{
+			{"category": "best_practice", "priority": "low", "description": "Add a doc comment.", "example": ""},
+		},
+		"follow_up_questions": []string{"What about overflow?"},
+		"complexity":          map[string]interface{}{"time_complexity": "O(1)", "space_complexity": "O(1)", "can_optimize": false, "optimized_approach": ""},
+		"readability_score":   90,
+		"test_coverage":       "Covers the basics.",
+	}
+	for field, value := range changes {
+		if value == nil {
+			delete(review, field)
+		} else {
+			review[field] = value
+		}
+	}
+	data, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// issueAtLine returns an issues value with a single issue at line
+func issueAtLine(line int) []map[string]interface{} {
+	return []map[string]interface{}{{"type": "bug", "severity": "high", "line_number": line, "description": "Off by one."}}
+}
+
+func TestParseCodeReviewLineNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    int
+		problem string // "" when valid
+	}{
+		{"first line", 1, ""},
+		{"last line", 3, ""},
+		{"not tied to a line", 0, ""},
+		{"past the end", 4, "issues[0].line_number must be a line of CODE from 1 to 3, or 0, got 4"},
+		{"negative", -1, "issues[0].line_number must be a line of CODE from 1 to 3, or 0, got -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review, problems := parseCodeReview(reviewJSON(t, map[string]interface{}{"issues": issueAtLine(tt.line)}), 3)
+			if tt.problem == "" {
+				if len(problems) > 0 || review == nil || review.Issues[0].LineNumber != tt.line {
+					t.Errorf("got %v, %v; want a review citing line %d", review, problems, tt.line)
+				}
+				return
+			}
+			if review != nil || len(problems) != 1 || problems[0] != tt.problem {
+				t.Errorf("got problems %q, want %q", problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestReviewCodeRepairsLineNumbers(t *testing.T) {
+	code := "package main\n\nfunc Sum(a, b int) int { return a + b }"
+	var prompts []string
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+		prompts = append(prompts, request.Prompt)
+		if call == 1 {
+			return reviewJSON(t, map[string]interface{}{"issues": issueAtLine(42)}), nil
+		}
+		return reviewJSON(t, map[string]interface{}{"issues": issueAtLine(3)}), nil
+	}}
+	ai := newTestAIService(provider)
+
+	review, err := ai.ReviewCode(context.Background(), code, &ChallengeContext{Title: "Sum"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Issues[0].LineNumber != 3 {
+		t.Errorf("got line %d, want the repaired line 3", review.Issues[0].LineNumber)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "issues[0].line_number must be a line of CODE from 1 to 3, or 0, got 42") {
+		t.Errorf("the repair prompt does not report the invalid line: %q", prompts)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		err      bool
+	}{
+		{"bare object", `{"a": 1}`, `{"a": 1}`, false},
+		{"json fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`, false},
+		{"plain fence", "```\n[\"q\"]\n```", `["q"]`, false},
+		{"prose around", "Here is the review:\n{\"a\": {\"b\": 2}}\nHope this helps!", `{"a": {"b": 2}}`, false},
+		{"prose around an array", "Questions: [\"a\", \"b\"] - done", `["a", "b"]`, false},
+		{"no JSON", "I cannot review this code.", "", true},
+		{"truncated", `{"a": [1, 2`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractJSON(tt.response)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("got %q, %v; want %q, error %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseCodeReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		problems []string
+	}{
+		{"valid", reviewJSON(t, nil), nil},
+		{"fenced", "```json\n" + reviewJSON(t, nil) + "\n```", nil},
+		{"wrapped in prose", "Sure! Here is my review:\n" + reviewJSON(t, nil) + "\nLet me know.", nil},
+		{"empty lists", reviewJSON(t, map[string]interface{}{"issues": []string{}, "suggestions": []string{}, "follow_up_questions": []string{}}), nil},
+		{"missing fields", reviewJSON(t, map[string]interface{}{"overall_score": nil, "complexity": nil}),
+			[]string{`missing required field "overall_score"`, `missing required field "complexity"`}},
+		{"unknown field", reviewJSON(t, map[string]interface{}{"verdict": "hire"}), []string{`unexpected field "verdict"`}},
+		{"unknown nested field", reviewJSON(t, map[string]interface{}{"complexity": map[string]string{"time_complexity": "O(1)", "space_complexity": "O(1)", "big_o": "1"}}),
+			[]string{"response does not match the schema:"}},
+		{"wrong type", reviewJSON(t, map[string]interface{}{"overall_score": "high"}),
+			[]string{"response does not match the schema:"}},
+		{"scores out of range", reviewJSON(t, map[string]interface{}{"overall_score": 101, "readability_score": -5}),
+			[]string{"overall_score must be between 0 and 100, got 101", "readability_score must be between 0 and 100, got -5"}},
+		{"enum violations", reviewJSON(t, map[string]interface{}{
+			"issues":      []map[string]interface{}{{"type": "security", "severity": "blocker", "line_number": 1, "description": "x"}},
+			"suggestions": []map[string]interface{}{{"category": "refactor", "priority": "urgent", "description": "y"}},
+		}), []string{
+			`issues[0].type must be one of bug|performance|style|logic, got "security"`,
+			`issues[0].severity must be one of low|medium|high|critical, got "blocker"`,
+			`suggestions[0].category must be one of optimization|best_practice|alternative, got "refactor"`,
+			`suggestions[0].priority must be one of low|medium|high, got "urgent"`,
+		}},
+		{"empty text", reviewJSON(t, map[string]interface{}{"interviewer_feedback": " ", "follow_up_questions": []string{""}}),
+			[]string{"interviewer_feedback must not be empty", "follow_up_questions[0] must not be empty"}},
+		{"array instead of object", `["a"]`, []string{"response is not a valid JSON object:"}},
+		{"no JSON", "No review today.", []string{"no JSON found in response"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review, problems := parseCodeReview(tt.response, 3)
+			if !sameProblems(problems, tt.problems) {
+				t.Fatalf("got problems\n%q\nwant\n%q", problems, tt.problems)
+			}
+			if len(tt.problems) == 0 && (review == nil || review.Issues == nil || review.Suggestions == nil || review.FollowUpQuestions == nil) {
+				t.Errorf("got review %+v, want one with non-nil lists", review)
+			}
+			if len(tt.problems) > 0 && review != nil {
+				t.Errorf("got review %+v along with problems", review)
+			}
+		})
+	}
+}
+
+// sameProblems compares problem lists regardless of order, since missing and unknown
+// fields are reported in map order. Wanted problems ending in a colon match any
+// problem they prefix, as the JSON decoder's messages vary between Go releases.
+func sameProblems(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for _, problem := range want {
+		found := false
+		for _, g := range got {
+			found = found || g == problem || (strings.HasSuffix(problem, ":") && strings.HasPrefix(g, problem))
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseQuestions(t *testing.T) {
+	ten := `["1","2","3","4","5","6","7","8","9","10"]`
+	tests := []struct {
+		name     string
+		response string
+		want     int
+		problems []string
+	}{
+		{"valid", `["Why a map?", "What is the complexity?"]`, 2, nil},
+		{"fenced", "```json\n[\"Why?\"]\n```", 1, nil},
+		{"ten questions", ten, 10, nil},
+		{"eleven questions", strings.Replace(ten, `"10"`, `"10","11"`, 1), 0, []string{"expected 1 to 10 questions, got 11"}},
+		{"none", `[]`, 0, []string{"expected 1 to 10 questions, got 0"}},
+		{"empty question", `["Why?", "  "]`, 0, []string{"question 1 is empty"}},
+		{"not strings", `[1, 2]`, 0, []string{"response must be a JSON array of strings:"}},
+		{"object", `{"questions": ["Why?"]}`, 0, []string{"response must be a JSON array of strings:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, problems := parseQuestions(tt.response)
+			if !sameProblems(problems, tt.problems) || len(questions) != tt.want {
+				t.Errorf("got %d questions and problems %q, want %d and %q", len(questions), problems, tt.want, tt.problems)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string // One per call; the last repeats
+		calls     int
+		invalid   bool
+	}{
+		{"valid at once", []string{`["Why?"]`}, 1, false},
+		{"repaired on the second attempt", []string{`[]`, `["Why?"]`}, 2, false},
+		{"repaired on the last attempt", []string{`[]`, `[""]`, `["Why?"]`}, 3, false},
+		{"gives up after MaxRepairAttempts", []string{`[]`}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+				return tt.responses[min(call, len(tt.responses))-1], nil
+			}}
+			ai := newTestAIService(provider)
+
+			questions, _, err := ai.GetInterviewerQuestions(context.Background(), "package main", &ChallengeContext{Title: "Sum"}, "")
+			if provider.Calls() != tt.calls {
+				t.Errorf("got %d calls, want %d", provider.Calls(), tt.calls)
+			}
+			if !tt.invalid {
+				if err != nil || len(questions) != 1 {
+					t.Fatalf("got %q, %v; want one question", questions, err)
+				}
+				return
+			}
+			aiErr, ok := err.(*AIError)
+			if !ok || aiErr.Kind != AIErrorInvalidResponse || !aiErr.Retryable || len(aiErr.Details) == 0 {
+				t.Fatalf("got %v, want a retryable %s error with details", err, AIErrorInvalidResponse)
+			}
+
+			// The invalid answers were evicted from the cache, so a retry asks the model again
+			provider.respond = func(request CompletionRequest, call int) (string, error) { return `["Why?"]`, nil }
+			if questions, _, err := ai.GetInterviewerQuestions(context.Background(), "package main", &ChallengeContext{Title: "Sum"}, ""); err != nil || len(questions) != 1 {
+				t.Errorf("retry got %q, %v; want the model's new answer", questions, err)
+			}
+			if provider.Calls() != tt.calls+1 {
+				t.Errorf("retry was answered from the cache")
+			}
+		})
+	}
+}
+
+func TestValidAnswersAreCached(t *testing.T) {
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) { return `["Why?"]`, nil }}
+	ai := newTestAIService(provider)
+	for i := 0; i < 2; i++ {
+		if _, _, err := ai.GetInterviewerQuestions(context.Background(), "package main", &ChallengeContext{Title: "Sum"}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if provider.Calls() != 1 {
+		t.Errorf("got %d calls, want the second answered from the cache", provider.Calls())
+	}
+}
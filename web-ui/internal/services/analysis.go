@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+var (
+	diagnosticPattern       = regexp.MustCompile(`^(?:vet: )?(?:\./)?([^\s:]+\.go):(\d+):(?:(\d+):)? (.+)$`)
+	functionCoveragePattern = regexp.MustCompile(`^\S+\.go:(\d+):\s+(\S+)\s+([0-9.]+)%$`)
+	totalCoveragePattern    = regexp.MustCompile(`^total:\s+\(statements\)\s+([0-9.]+)%$`)
+)
+
+// Diagnostic is a compiler or `go vet` finding in the submitted code
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// FunctionCoverage is the statement coverage of one function in the submitted code
+type FunctionCoverage struct {
+	Function string  `json:"function"`
+	Line     int     `json:"line"`
+	Percent  float64 `json:"percent"`
+}
+
+// CodeAnalysis is what actually happens when the code is built, vetted and tested
+type CodeAnalysis struct {
+	Passed           bool               `json:"passed"`
+	BuildFailed      bool               `json:"build_failed"`
+	TestsPassed      int                `json:"tests_passed"`
+	TestsTotal       int                `json:"tests_total"`
+	Tests            []TestCase         `json:"tests"`
+	Diagnostics      []Diagnostic       `json:"diagnostics"` // go vet and compiler messages for the submitted file
+	Coverage         float64            `json:"coverage"`    // Total statement coverage in percent, -1 if unknown
+	FunctionCoverage []FunctionCoverage `json:"function_coverage"`
+	Error            string             `json:"error,omitempty"` // Set when the analysis could not run
+	ExecutionMs      int64              `json:"execution_ms"`
+}
+
+// Analyze runs the challenge's tests with coverage and `go vet` on the code
+func (es *ExecutionService) Analyze(ctx context.Context, code string, challenge *models.Challenge) *CodeAnalysis {
+	start := time.Now()
+	analysis := &CodeAnalysis{
+		Tests:            []TestCase{},
+		Diagnostics:      []Diagnostic{},
+		Coverage:         -1,
+		FunctionCoverage: []FunctionCoverage{},
+	}
+
+	tempDir, err := es.prepareWorkspace(ctx, code, challenge)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		analysis.Error = err.Error()
+		return analysis
+	}
+
+	release := es.acquire()
+	defer release()
+	runCtx, cancel := es.testContext()
+	defer cancel()
+
+	// go vet also reports compile errors, so it tells us whether the code builds
+	vetCmd := exec.CommandContext(runCtx, "go", "vet", ".")
+	vetCmd.Dir = tempDir
+	vetOutput, vetErr := vetCmd.CombinedOutput()
+	analysis.Diagnostics = parseDiagnostics(string(vetOutput))
+
+	testCmd := es.testCommand(runCtx, tempDir, "-v", "-coverprofile=coverage.out", "-covermode=set")
+	testOutput, testErr := testCmd.CombinedOutput()
+	output := string(testOutput)
+	analysis.Error = es.stoppedMessage(runCtx)
+
+	analysis.Passed = testErr == nil
+	analysis.Tests = ParseTestCases(output)
+	if analysis.Tests == nil {
+		analysis.Tests = []TestCase{}
+	}
+	analysis.TestsPassed, analysis.TestsTotal = CountTestResults(output)
+	if testErr != nil && analysis.TestsTotal == 0 && strings.Contains(output, "[build failed]") {
+		analysis.BuildFailed = true
+		if vetErr == nil {
+			// vet succeeded, so the failure is in the tests; keep the compiler messages
+			analysis.Diagnostics = append(analysis.Diagnostics, parseDiagnostics(output)...)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "coverage.out")); err == nil {
+		coverCmd := exec.Command("go", "tool", "cover", "-func=coverage.out")
+		coverCmd.Dir = tempDir
+		if coverOutput, err := coverCmd.CombinedOutput(); err == nil {
+			analysis.Coverage, analysis.FunctionCoverage = parseCoverage(string(coverOutput))
+		}
+	}
+
+	analysis.ExecutionMs = time.Since(start).Milliseconds()
+	return analysis
+}
+
+// parseDiagnostics extracts messages about the submitted file from vet or compiler output
+func parseDiagnostics(output string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, line := range strings.Split(output, "\n") {
+		match := diagnosticPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil || match[1] != solutionFileName {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		diagnostics = append(diagnostics, Diagnostic{Line: lineNumber, Column: column, Message: match[4]})
+	}
+	return diagnostics
+}
+
+// parseCoverage reads `go tool cover -func` output for the submitted file
+func parseCoverage(output string) (float64, []FunctionCoverage) {
+	total := -1.0
+	functions := []FunctionCoverage{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if match := totalCoveragePattern.FindStringSubmatch(line); match != nil {
+			total, _ = strconv.ParseFloat(match[1], 64)
+			continue
+		}
+		if !strings.Contains(line, solutionFileName+":") {
+			continue
+		}
+		if match := functionCoveragePattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[1])
+			percent, _ := strconv.ParseFloat(match[3], 64)
+			functions = append(functions, FunctionCoverage{Function: match[2], Line: lineNumber, Percent: percent})
+		}
+	}
+	return total, functions
+}
+
+// promptSection summarizes the analysis for the code review prompt
+func (a *CodeAnalysis) promptSection() string {
+	if a.Error != "" {
+		return fmt.Sprintf("EXECUTION RESULTS: the code could not be run (%s). Do not guess test results.", truncateText(a.Error, 500))
+	}
+
+	var section strings.Builder
+	section.WriteString("EXECUTION RESULTS (facts from running the code; base your review on these):\n")
+	switch {
+	case a.BuildFailed:
+		section.WriteString("Build: FAILED, no tests ran\n")
+	case a.Passed:
+		fmt.Fprintf(&section, "Tests: all passed (%d/%d)\n", a.TestsPassed, a.TestsTotal)
+	default:
+		fmt.Fprintf(&section, "Tests: %d/%d passed\n", a.TestsPassed, a.TestsTotal)
+	}
+
+	for _, test := range a.Tests {
+		if test.Passed() {
+			continue
+		}
+		fmt.Fprintf(&section, "- FAIL %s\n", test.Name)
+		if test.Output != "" {
+			fmt.Fprintf(&section, "  %s\n", strings.ReplaceAll(truncateText(strings.TrimSpace(test.Output), 400), "\n", "\n  "))
+		}
+	}
+
+	if len(a.Diagnostics) == 0 {
+		section.WriteString("go vet: no findings\n")
+	} else {
+		section.WriteString("go vet / compiler findings:\n")
+		for _, diagnostic := range a.Diagnostics {
+			fmt.Fprintf(&section, "- line %d: %s\n", diagnostic.Line, diagnostic.Message)
+		}
+	}
+
+	if a.Coverage >= 0 {
+		fmt.Fprintf(&section, "Statement coverage by the challenge tests: %.1f%%\n", a.Coverage)
+		for _, function := range a.FunctionCoverage {
+			fmt.Fprintf(&section, "- %s (line %d): %.1f%%\n", function.Function, function.Line, function.Percent)
+		}
+	}
+	return strings.TrimRight(section.String(), "\n")
+}
+
+// analyze runs the code for a review, or returns nil when it cannot be run
+func (ai *AIService) analyze(ctx context.Context, code string, challenge *ChallengeContext) *CodeAnalysis {
+	if ai.executionService == nil || challenge.TestFile == "" || strings.TrimSpace(code) == "" {
+		return nil
+	}
+	return ai.executionService.Analyze(ctx, code, challenge.executionChallenge())
+}
+
+// groundReview adds the analysis to the review, with the measured test results in
+// front of the model's own assessment of the coverage
+func groundReview(review *AICodeReview, analysis *CodeAnalysis) {
+	if analysis == nil || analysis.Error != "" {
+		return
+	}
+	review.Analysis = analysis
+
+	measured := fmt.Sprintf("%d/%d tests passed", analysis.TestsPassed, analysis.TestsTotal)
+	if analysis.BuildFailed {
+		measured = "Build failed, no tests ran"
+	}
+	if analysis.Coverage >= 0 {
+		measured += fmt.Sprintf(", %.1f%% statement coverage", analysis.Coverage)
+	}
+	if review.TestCoverage == "" {
+		review.TestCoverage = measured + "."
+	} else {
+		review.TestCoverage = measured + ". " + review.TestCoverage
+	}
+}
+
+// countLines returns the number of lines numberLines shows for code
+func countLines(code string) int {
+	return strings.Count(code, "\n") + 1
+}
+
+// numberLines prefixes each line of code with its line number so reviews can cite them
+func numberLines(code string) string {
+	lines := strings.Split(code, "\n")
+	width := len(strconv.Itoa(len(lines)))
+	var numbered strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&numbered, "%*d | %s\n", width, i+1, line)
+	}
+	return strings.TrimRight(numbered.String(), "\n")
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumberLines(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"one line", "package main", "1 | package main"},
+		{"empty", "", "1 | "},
+		{"trailing newline", "a\nb\n", "1 | a\n2 | b\n3 | "},
+		{"padded to the widest number", "a\nb\nc\nd\ne\nf\ng\nh\ni\nj", " 1 | a\n 2 | b\n 3 | c\n 4 | d\n 5 | e\n 6 | f\n 7 | g\n 8 | h\n 9 | i\n10 | j"},
+		{"blank lines keep their number", "a\n\nb", "1 | a\n2 | \n3 | b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := numberLines(tt.code); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if shown := len(strings.Split(numberLines(tt.code), "\n")); countLines(tt.code) != shown {
+				t.Errorf("countLines = %d, numberLines shows %d", countLines(tt.code), shown)
+			}
+		})
+	}
+}
+
+func TestGroundReview(t *testing.T) {
+	tests := []struct {
+		name     string
+		analysis *CodeAnalysis
+		coverage string // The model's assessment
+		want     string
+		attached bool
+	}{
+		{"no analysis", nil, "Looks tested.", "Looks tested.", false},
+		{"analysis failed", &CodeAnalysis{Error: "timeout", Coverage: -1}, "Looks tested.", "Looks tested.", false},
+		{"measured results first", &CodeAnalysis{TestsPassed: 3, TestsTotal: 4, Coverage: 87.5}, "Edge cases missing.",
+			"3/4 tests passed, 87.5% statement coverage. Edge cases missing.", true},
+		{"unknown coverage", &CodeAnalysis{TestsPassed: 4, TestsTotal: 4, Coverage: -1}, "", "4/4 tests passed.", true},
+		{"build failed", &CodeAnalysis{BuildFailed: true, Coverage: -1}, "Does not compile.", "Build failed, no tests ran. Does not compile.", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review := &AICodeReview{TestCoverage: tt.coverage}
+			groundReview(review, tt.analysis)
+			if review.TestCoverage != tt.want {
+				t.Errorf("got %q, want %q", review.TestCoverage, tt.want)
+			}
+			if (review.Analysis != nil) != tt.attached {
+				t.Errorf("analysis attached: %v, want %v", review.Analysis != nil, tt.attached)
+			}
+		})
+	}
+}
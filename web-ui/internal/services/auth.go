@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"web-ui/internal/metrics"
+	"web-ui/internal/utils"
+)
+
+// Cookie names used by the authentication flow
+const (
+	SessionCookieName = "session"
+	StateCookieName   = "oauth_state"
+	CSRFCookieName    = "csrf_token" // Readable by scripts, which echo it in CSRFHeaderName
+)
+
+// CSRFHeaderName is the header cookie-authenticated requests that change state send the CSRF token in
+const CSRFHeaderName = "X-CSRF-Token"
+
+// ErrInvalidSession is returned when a signed cookie is missing, tampered with or expired
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// Identity represents the authenticated user bound to a request
+type Identity struct {
+	Username  string `json:"username"`
+	Name      string `json:"name,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	Provider  string `json:"provider"`
+
+	// Token is set when the request was authenticated with a personal API token
+	Token *APIToken `json:"-"`
+}
+
+// HasScope reports whether the request may perform actions in the scope.
+// Browser sessions carry every scope; API tokens only carry the scopes they were granted.
+func (id *Identity) HasScope(scope string) bool {
+	if id.Token == nil {
+		return true
+	}
+	return id.Token.HasScope(scope)
+}
+
+// AuthProvider is implemented by every supported login backend
+type AuthProvider interface {
+	// Name returns the provider identifier stored in sessions
+	Name() string
+	// AuthCodeURL returns the URL the browser is redirected to for login
+	AuthCodeURL(state, redirectURL string) string
+	// Exchange turns the code received on the callback into an identity
+	Exchange(ctx context.Context, code, redirectURL string) (*Identity, error)
+}
+
+// GitHubAuthProvider implements the GitHub OAuth2 web application flow.
+// BaseURL and APIURL can point at a local stand-in server for testing.
+type GitHubAuthProvider struct {
+	ClientID     string
+	ClientSecret string
+	BaseURL      string // e.g. https://github.com
+	APIURL       string // e.g. https://api.github.com
+	httpClient   *http.Client
+}
+
+// NewGitHubAuthProvider creates a GitHub OAuth provider from environment variables
+func NewGitHubAuthProvider() *GitHubAuthProvider {
+	baseURL := os.Getenv("GITHUB_OAUTH_URL")
+	if baseURL == "" {
+		baseURL = "https://github.com"
+	}
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+
+	return &GitHubAuthProvider{
+		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIURL:       strings.TrimRight(apiURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+	}
+}
+
+// Name returns the provider identifier
+func (p *GitHubAuthProvider) Name() string {
+	return "github"
+}
+
+// AuthCodeURL returns the GitHub authorize URL
+func (p *GitHubAuthProvider) AuthCodeURL(state, redirectURL string) string {
+	params := url.Values{}
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("state", state)
+	params.Set("scope", "read:user")
+	return p.BaseURL + "/login/oauth/authorize?" + params.Encode()
+}
+
+// Exchange trades the authorization code for an access token and looks up the user
+func (p *GitHubAuthProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitHubCall("oauth_token", 0)
+		return nil, fmt.Errorf("token exchange failed: %v", err)
+	}
+	defer resp.Body.Close()
+	metrics.ObserveGitHubCall("oauth_token", resp.StatusCode)
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %v", err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("GitHub OAuth error: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("GitHub OAuth returned no access token")
+	}
+
+	userReq, err := http.NewRequestWithContext(ctx, "GET", p.APIURL+"/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	userReq.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	userReq.Header.Set("Accept", "application/vnd.github+json")
+	userReq.Header.Set("User-Agent", "go-interview-practice-web-ui/1.0")
+
+	userResp, err := p.httpClient.Do(userReq)
+	if err != nil {
+		metrics.ObserveGitHubCall("user", 0)
+		return nil, fmt.Errorf("user lookup failed: %v", err)
+	}
+	defer userResp.Body.Close()
+	metrics.ObserveGitHubCall("user", userResp.StatusCode)
+
+	if userResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub user API returned status %d", userResp.StatusCode)
+	}
+
+	var user struct {
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := json.NewDecoder(userResp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("invalid user response: %v", err)
+	}
+	if user.Login == "" {
+		return nil, fmt.Errorf("GitHub user API returned no login")
+	}
+
+	return &Identity{
+		Username:  user.Login,
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+		Provider:  p.Name(),
+	}, nil
+}
+
+// LocalAuthProvider signs in the owner of the local clone using git configuration.
+// It is meant for single-user installs where the server runs on the contributor's machine.
+type LocalAuthProvider struct {
+	repoRoot string // Clone whose git configuration identifies the user
+}
+
+// Name returns the provider identifier
+func (p *LocalAuthProvider) Name() string {
+	return "local"
+}
+
+// AuthCodeURL skips the external hop and goes straight to the callback
+func (p *LocalAuthProvider) AuthCodeURL(state, redirectURL string) string {
+	params := url.Values{}
+	params.Set("state", state)
+	params.Set("code", "local")
+	return redirectURL + "?" + params.Encode()
+}
+
+// Exchange resolves the identity from the server's git configuration
+func (p *LocalAuthProvider) Exchange(ctx context.Context, code, redirectURL string) (*Identity, error) {
+	gitInfo := utils.GetGitUsername(p.repoRoot)
+	if gitInfo.Username == "" {
+		return nil, fmt.Errorf("could not determine username from git configuration")
+	}
+	if !ValidUsername(gitInfo.Username) {
+		return nil, fmt.Errorf("git username %q is not a valid GitHub username; set the origin remote to your fork", gitInfo.Username)
+	}
+	return &Identity{
+		Username: gitInfo.Username,
+		Provider: p.Name(),
+	}, nil
+}
+
+// AuthService issues and verifies signed session cookies
+type AuthService struct {
+	provider      AuthProvider
+	secret        []byte
+	sessionTTL    time.Duration
+	secureCookies bool
+}
+
+// NewAuthService creates an auth service using AUTH_PROVIDER and SESSION_SECRET.
+// Local sign-in reads the git identity of the clone at repoRoot. It must be chosen
+// explicitly and is refused when GO_ENV is production, since it signs every visitor in
+// as the server's git user. Without AUTH_PROVIDER, GitHub is used when GITHUB_CLIENT_ID
+// is set and sign-in is disabled otherwise.
+func NewAuthService(repoRoot string) (*AuthService, error) {
+	production := os.Getenv("GO_ENV") == "production"
+
+	var provider AuthProvider
+	switch name := strings.ToLower(os.Getenv("AUTH_PROVIDER")); name {
+	case "github":
+		if os.Getenv("GITHUB_CLIENT_ID") == "" {
+			return nil, errors.New("AUTH_PROVIDER=github requires GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET")
+		}
+		provider = NewGitHubAuthProvider()
+	case "local":
+		if production {
+			return nil, errors.New("AUTH_PROVIDER=local signs every visitor in as the server's git user and is not allowed when GO_ENV=production")
+		}
+		provider = &LocalAuthProvider{repoRoot: repoRoot}
+	case "":
+		if os.Getenv("GITHUB_CLIENT_ID") != "" {
+			provider = NewGitHubAuthProvider()
+		} else {
+			slog.Warn("Sign-in is disabled; set AUTH_PROVIDER=github, or AUTH_PROVIDER=local for a single-user install")
+		}
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q; use github or local", name)
+	}
+
+	secret := []byte(os.Getenv("SESSION_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate session secret: %v", err)
+		}
+		slog.Warn("SESSION_SECRET not set, sessions will not survive a restart")
+	}
+
+	return &AuthService{
+		provider:      provider,
+		secret:        secret,
+		sessionTTL:    30 * 24 * time.Hour,
+		secureCookies: production,
+	}, nil
+}
+
+// RequireSecureCookies marks every cookie Secure, for servers that serve HTTPS themselves.
+// Production deployments get Secure cookies regardless.
+func (as *AuthService) RequireSecureCookies() {
+	as.secureCookies = true
+}
+
+// Provider returns the configured login provider, or nil when sign-in is disabled
+func (as *AuthService) Provider() AuthProvider {
+	return as.provider
+}
+
+// NewState generates a random OAuth state value
+func (as *AuthService) NewState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// StateCookie returns a short-lived signed cookie holding the OAuth state
+func (as *AuthService) StateCookie(state string) *http.Cookie {
+	expires := time.Now().Add(10 * time.Minute)
+	return as.cookie(StateCookieName, as.sign(as.encodeClaims(state, expires)), expires)
+}
+
+// VerifyState checks the state returned by the provider against the state cookie
+func (as *AuthService) VerifyState(r *http.Request, state string) bool {
+	cookie, err := r.Cookie(StateCookieName)
+	if err != nil || state == "" {
+		return false
+	}
+	var claims struct {
+		Value     string `json:"v"`
+		ExpiresAt int64  `json:"exp"`
+	}
+	if err := as.verify(cookie.Value, &claims); err != nil {
+		return false
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(claims.Value), []byte(state))
+}
+
+// SessionCookie returns a signed session cookie for the identity
+func (as *AuthService) SessionCookie(identity *Identity) (*http.Cookie, error) {
+	expires := time.Now().Add(as.sessionTTL)
+	payload, err := json.Marshal(struct {
+		*Identity
+		ExpiresAt int64 `json:"exp"`
+	}{identity, expires.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	return as.cookie(SessionCookieName, as.sign(payload), expires), nil
+}
+
+// ClearSessionCookie returns a cookie that removes the session from the browser
+func (as *AuthService) ClearSessionCookie() *http.Cookie {
+	return as.expiredCookie(SessionCookieName)
+}
+
+// ClearCSRFCookie returns a cookie that removes the CSRF token from the browser
+func (as *AuthService) ClearCSRFCookie() *http.Cookie {
+	cookie := as.expiredCookie(CSRFCookieName)
+	cookie.HttpOnly = false
+	return cookie
+}
+
+// ClearStateCookie returns a cookie that removes the OAuth state from the browser
+func (as *AuthService) ClearStateCookie() *http.Cookie {
+	return as.expiredCookie(StateCookieName)
+}
+
+// IdentityFromRequest returns the identity from a valid session cookie, or nil
+func (as *AuthService) IdentityFromRequest(r *http.Request) *Identity {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil
+	}
+	identity, err := as.ParseSession(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	return identity
+}
+
+// ParseSession verifies a session cookie value
+func (as *AuthService) ParseSession(value string) (*Identity, error) {
+	var claims struct {
+		Identity
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := as.verify(value, &claims); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > claims.ExpiresAt || !ValidUsername(claims.Username) {
+		return nil, ErrInvalidSession
+	}
+	identity := claims.Identity
+	return &identity, nil
+}
+
+// CSRFToken returns the CSRF token for the request's session cookie, or "" without one.
+// It is derived from the session, so it changes with every sign-in and cannot be
+// produced without the secret.
+func (as *AuthService) CSRFToken(r *http.Request) string {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, as.secret)
+	mac.Write([]byte("csrf:" + cookie.Value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCSRF reports whether the request sends its session's CSRF token in the header
+func (as *AuthService) VerifyCSRF(r *http.Request) bool {
+	expected := as.CSRFToken(r)
+	sent := r.Header.Get(CSRFHeaderName)
+	return expected != "" && hmac.Equal([]byte(sent), []byte(expected))
+}
+
+// CSRFCookie returns a cookie handing the CSRF token to the page's scripts
+func (as *AuthService) CSRFCookie(token string) *http.Cookie {
+	cookie := as.cookie(CSRFCookieName, token, time.Time{})
+	cookie.HttpOnly = false
+	cookie.SameSite = http.SameSiteStrictMode
+	return cookie
+}
+
+func (as *AuthService) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Expires:  expires,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   as.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (as *AuthService) expiredCookie(name string) *http.Cookie {
+	cookie := as.cookie(name, "", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	return cookie
+}
+
+func (as *AuthService) encodeClaims(value string, expires time.Time) []byte {
+	payload, _ := json.Marshal(struct {
+		Value     string `json:"v"`
+		ExpiresAt int64  `json:"exp"`
+	}{value, expires.Unix()})
+	return payload
+}
+
+// sign returns base64(payload) + "." + base64(HMAC-SHA256(payload))
+func (as *AuthService) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, as.secret)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature of a signed value and decodes its JSON payload into v
+func (as *AuthService) verify(value string, v interface{}) error {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return ErrInvalidSession
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidSession
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidSession
+	}
+
+	mac := hmac.New(sha256.New, as.secret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrInvalidSession
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidSession
+	}
+	return nil
+}
+
+type identityContextKey struct{}
+
+// WithIdentity returns a context carrying the authenticated identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated identity, or nil for anonymous requests
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(*Identity)
+	return identity
+}
+
+type clientIPContextKey struct{}
+
+// WithClientIP returns a context carrying the client address the server resolved for
+// the request, so rate limits and quotas agree on who the client is
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
+
+// ClientIPFromContext returns the resolved client address, or "" if none was bound
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+	return ip
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuthService(t *testing.T) *AuthService {
+	t.Setenv("AUTH_PROVIDER", "")
+	t.Setenv("GITHUB_CLIENT_ID", "")
+	t.Setenv("SESSION_SECRET", "test-secret")
+	as, err := NewAuthService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return as
+}
+
+// signedSession returns a session cookie value for username expiring at expires
+func signedSession(as *AuthService, username string, expires time.Time) string {
+	payload, _ := json.Marshal(map[string]interface{}{"username": username, "provider": "github", "exp": expires.Unix()})
+	return as.sign(payload)
+}
+
+func TestParseSession(t *testing.T) {
+	as := newTestAuthService(t)
+	valid := signedSession(as, "alice", time.Now().Add(time.Hour))
+	payload, signature, _ := strings.Cut(valid, ".")
+	forged, _, _ := strings.Cut(signedSession(as, "bob", time.Now().Add(time.Hour)), ".")
+
+	other := &AuthService{secret: []byte("other-secret")}
+	tests := []struct {
+		name  string
+		value string
+		want  string // "" when rejected
+	}{
+		{"valid", valid, "alice"},
+		{"expired", signedSession(as, "alice", time.Now().Add(-time.Minute)), ""},
+		{"signed with another secret", signedSession(other, "alice", time.Now().Add(time.Hour)), ""},
+		{"altered payload", forged + "." + signature, ""},
+		{"altered signature", payload + "." + strings.Repeat("A", len(signature)), ""},
+		{"missing signature", payload, ""},
+		{"extra part", valid + ".x", ""},
+		{"not base64", "!!!." + signature, ""},
+		{"invalid username", signedSession(as, "../alice", time.Now().Add(time.Hour)), ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := as.ParseSession(tt.value)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("accepted as %+v", identity)
+				}
+				return
+			}
+			if err != nil || identity.Username != tt.want {
+				t.Errorf("got %+v, %v; want %s", identity, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyState(t *testing.T) {
+	as := newTestAuthService(t)
+	valid := as.StateCookie("state").Value
+	expired := as.sign(as.encodeClaims("state", time.Now().Add(-time.Minute)))
+	payload, _, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name   string
+		cookie string // "" sends no cookie
+		state  string
+		want   bool
+	}{
+		{"valid", valid, "state", true},
+		{"other state", valid, "other", false},
+		{"empty state", valid, "", false},
+		{"no cookie", "", "state", false},
+		{"expired", expired, "state", false},
+		{"tampered", payload + ".AAAA", "state", false},
+		{"signed with another secret", (&AuthService{secret: []byte("other")}).StateCookie("state").Value, "state", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/auth/callback", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: StateCookieName, Value: tt.cookie})
+			}
+			if got := as.VerifyState(r, tt.state); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureCookies(t *testing.T) {
+	as := newTestAuthService(t)
+	if as.StateCookie("s").Secure {
+		t.Error("cookies Secure outside production without TLS")
+	}
+	as.RequireSecureCookies()
+	if !as.StateCookie("s").Secure || !as.ClearSessionCookie().Secure {
+		t.Error("cookies not Secure when the server serves TLS")
+	}
+
+	t.Setenv("GO_ENV", "production")
+	if production := newTestAuthService(t); !production.StateCookie("s").Secure {
+		t.Error("cookies not Secure in production")
+	}
+}
@@ -1,41 +1,58 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
+	"web-ui/internal/markdown"
 	"web-ui/internal/models"
 )
 
 // ChallengeService handles challenge-related operations
 type ChallengeService struct {
+	root       string
+	mu         sync.RWMutex
 	challenges models.ChallengeMap
 }
 
-// NewChallengeService creates a new challenge service
-func NewChallengeService() *ChallengeService {
+// NewChallengeService creates a challenge service for the repository at repoRoot
+func NewChallengeService(repoRoot string) *ChallengeService {
 	return &ChallengeService{
+		root:       repoRoot,
 		challenges: make(models.ChallengeMap),
 	}
 }
 
-// LoadChallenges loads all challenges from the filesystem
+// RepoRoot returns the repository the challenges are loaded from
+func (cs *ChallengeService) RepoRoot() string {
+	return cs.root
+}
+
+// ChallengeDir returns the directory of a classic challenge
+func (cs *ChallengeService) ChallengeDir(id int) string {
+	return filepath.Join(cs.root, fmt.Sprintf("challenge-%d", id))
+}
+
+// LoadChallenges loads all challenges from the filesystem, replacing those loaded before
 func (cs *ChallengeService) LoadChallenges() error {
 	// Find challenge directories (challenge-1, challenge-2, etc.)
-	challengeDirs, err := filepath.Glob("../challenge-*")
+	challengeDirs, err := filepath.Glob(filepath.Join(cs.root, "challenge-*"))
 	if err != nil {
 		return fmt.Errorf("failed to find challenge directories: %v", err)
 	}
 
+	challenges := make(models.ChallengeMap)
 	for _, dir := range challengeDirs {
 		// Extract challenge number
-		re := regexp.MustCompile(`challenge-(\d+)`)
-		match := re.FindStringSubmatch(dir)
+		re := regexp.MustCompile(`^challenge-(\d+)$`)
+		match := re.FindStringSubmatch(filepath.Base(dir))
 		if len(match) < 2 {
 			continue
 		}
@@ -47,14 +64,18 @@ func (cs *ChallengeService) LoadChallenges() error {
 
 		challenge, err := cs.loadSingleChallenge(id, dir)
 		if err != nil {
-			log.Printf("Warning: Could not load challenge %d: %v", id, err)
+			slog.Warn("Could not load challenge", "challenge", id, "error", err)
 			continue
 		}
 
-		cs.challenges[id] = challenge
+		challenges[id] = challenge
 	}
 
-	log.Printf("Loaded %d challenges", len(cs.challenges))
+	cs.mu.Lock()
+	cs.challenges = challenges
+	cs.mu.Unlock()
+
+	slog.Info("Loaded challenges", "count", len(challenges))
 	return nil
 }
 
@@ -84,7 +105,7 @@ func (cs *ChallengeService) loadSingleChallenge(id int, dir string) (*models.Cha
 	testPath := filepath.Join(dir, "solution-template_test.go")
 	testContent, err := ioutil.ReadFile(testPath)
 	if err != nil {
-		log.Printf("Warning: Could not read test file for challenge %d: %v", id, err)
+		slog.Warn("Could not read test file", "challenge", id, "error", err)
 	}
 
 	// Read learning materials if available
@@ -111,6 +132,21 @@ func (cs *ChallengeService) loadSingleChallenge(id int, dir string) (*models.Cha
 		TestFile:          string(testContent),
 		LearningMaterials: string(learningContent),
 		Hints:             string(hintsContent),
+		Dir:               dir,
+	}
+	challenge.DescriptionHTML = markdown.Render(challenge.Description)
+	challenge.LearningHTML = markdown.Render(challenge.LearningMaterials)
+
+	// Read AI prompt additions, tags and learning objectives from metadata.json if available
+	if metadataContent, err := ioutil.ReadFile(filepath.Join(dir, "metadata.json")); err == nil {
+		var metadata models.ChallengeMetadata
+		if err := json.Unmarshal(metadataContent, &metadata); err != nil {
+			slog.Warn("Could not parse metadata.json", "challenge", id, "error", err)
+		} else {
+			challenge.AIPrompts = metadata.AIPrompts
+			challenge.Tags = metadata.Tags
+			challenge.LearningObjectives = metadata.LearningObjectives
+		}
 	}
 
 	return challenge, nil
@@ -152,6 +188,11 @@ func (cs *ChallengeService) filterWebUIDescription(content string) string {
 	for _, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 
+		// The scoreboard has its own page
+		if strings.Contains(strings.ToLower(trimmedLine), "scoreboard.md") {
+			continue
+		}
+
 		// Check if we're starting a section to skip
 		if strings.HasPrefix(trimmedLine, "## Instructions") ||
 			strings.HasPrefix(trimmedLine, "## Testing Your Solution Locally") ||
@@ -179,13 +220,17 @@ func (cs *ChallengeService) filterWebUIDescription(content string) string {
 	return strings.Join(filteredLines, "\n")
 }
 
-// GetChallenges returns all challenges
+// GetChallenges returns all challenges. The map is replaced, not modified, on reload.
 func (cs *ChallengeService) GetChallenges() models.ChallengeMap {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	return cs.challenges
 }
 
 // GetChallenge returns a specific challenge by ID
 func (cs *ChallengeService) GetChallenge(id int) (*models.Challenge, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
 	challenge, exists := cs.challenges[id]
 	return challenge, exists
 }
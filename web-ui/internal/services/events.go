@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Event types published on the event bus
+const (
+	EventPush        = "push"
+	EventPullRequest = "pull_request"
+	EventSponsorship = "sponsorship"
+)
+
+// eventQueueSize is how many events may wait for the subscribers before Publish refuses more
+const eventQueueSize = 100
+
+// Event is something that happened outside the server, such as a GitHub webhook delivery
+type Event struct {
+	Type     string      // One of the Event* constants
+	Delivery string      // Identifies the delivery that reported the event, for logs
+	Payload  interface{} // *PushEvent, *PullRequestEvent or *SponsorshipEvent
+}
+
+// EventBus hands events to their subscribers on a background goroutine, one event at a
+// time, so that slow work such as pulling the repository or judging a pull request
+// neither blocks the publisher nor runs concurrently with itself
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]func(Event)
+	queue       chan Event
+}
+
+// NewEventBus creates an event bus and starts delivering its events
+func NewEventBus() *EventBus {
+	bus := &EventBus{
+		subscribers: make(map[string][]func(Event)),
+		queue:       make(chan Event, eventQueueSize),
+	}
+	go bus.run()
+	return bus
+}
+
+// Subscribe calls handler for every event of the given type
+func (b *EventBus) Subscribe(eventType string, handler func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], handler)
+}
+
+// Publish queues an event for its subscribers. It fails when the queue is full.
+func (b *EventBus) Publish(event Event) error {
+	select {
+	case b.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("event queue is full")
+	}
+}
+
+func (b *EventBus) run() {
+	for event := range b.queue {
+		b.mu.RLock()
+		handlers := b.subscribers[event.Type]
+		b.mu.RUnlock()
+		for _, handler := range handlers {
+			b.deliver(event, handler)
+		}
+	}
+}
+
+// deliver calls one subscriber, so that a panic in it does not stop the bus
+func (b *EventBus) deliver(event Event, handler func(Event)) {
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Error("Event handler panicked", "event", event.Type, "delivery", event.Delivery, "error", err)
+		}
+	}()
+	handler(event)
+}
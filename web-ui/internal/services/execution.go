@@ -1,91 +1,163 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"web-ui/internal/metrics"
 	"web-ui/internal/models"
 )
 
+// solutionFileName is the name the submitted code is written to when running tests
+const solutionFileName = "solution-template.go"
+
+// ExecutionLimits bounds how submitted code is run
+type ExecutionLimits struct {
+	TestTimeout       time.Duration // Upper bound for one `go test` run; 0 means none
+	MaxConcurrentRuns int           // `go test` runs at once, further runs wait; 0 means no limit
+}
+
 // ExecutionService handles code execution and testing
-type ExecutionService struct{}
+type ExecutionService struct {
+	root       string
+	limits     ExecutionLimits
+	slots      chan struct{}   // One entry per running test, when runs are limited
+	running    atomic.Int32    // Tests running now
+	runs       context.Context // Parent of every test run; canceled on shutdown
+	cancelRuns context.CancelFunc
+	references map[string]*ReferenceSolution // Passing submissions, keyed by submissions directory
+	mutex      sync.Mutex
+}
 
-// NewExecutionService creates a new execution service
-func NewExecutionService() *ExecutionService {
-	return &ExecutionService{}
+// NewExecutionService creates an execution service for the repository at repoRoot
+func NewExecutionService(repoRoot string, limits ExecutionLimits) *ExecutionService {
+	es := &ExecutionService{
+		root:       repoRoot,
+		limits:     limits,
+		references: make(map[string]*ReferenceSolution),
+	}
+	es.runs, es.cancelRuns = context.WithCancel(context.Background())
+	if limits.MaxConcurrentRuns > 0 {
+		es.slots = make(chan struct{}, limits.MaxConcurrentRuns)
+	}
+	return es
 }
 
-// ExecutionResult represents the result of code execution
-type ExecutionResult struct {
-	Passed      bool   `json:"passed"`
-	Output      string `json:"output"`
-	ExecutionMs int64  `json:"executionMs"`
+// RunningTests returns how many test runs are in progress
+func (es *ExecutionService) RunningTests() int {
+	return int(es.running.Load())
 }
 
-// RunCode executes the provided code against a challenge's tests
-func (es *ExecutionService) RunCode(code string, challenge *models.Challenge) ExecutionResult {
-	start := time.Now()
+// CancelRuns stops the test runs in progress and any started later, e.g. when the
+// server shuts down
+func (es *ExecutionService) CancelRuns() {
+	es.cancelRuns()
+}
 
-	// Create temporary directory for execution
-	tempDir, err := ioutil.TempDir("", "challenge-exec")
-	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to create temporary directory: %v", err),
+// acquire waits for a free test slot and returns the function that releases it.
+// It stops waiting when runs are canceled; the run then fails at once.
+func (es *ExecutionService) acquire() func() {
+	if es.slots != nil {
+		metrics.TestRunsWaiting.Inc()
+		select {
+		case es.slots <- struct{}{}:
+			metrics.TestRunsWaiting.Dec()
+		case <-es.runs.Done():
+			metrics.TestRunsWaiting.Dec()
+			return func() {}
 		}
 	}
-	defer os.RemoveAll(tempDir)
-
-	// Write the submitted code to temporary file
-	codePath := filepath.Join(tempDir, "solution-template.go")
-	err = ioutil.WriteFile(codePath, []byte(code), 0644)
-	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to write code file: %v", err),
+	es.running.Add(1)
+	metrics.TestRunsRunning.Inc()
+	return func() {
+		es.running.Add(-1)
+		metrics.TestRunsRunning.Dec()
+		if es.slots != nil {
+			<-es.slots
 		}
 	}
+}
 
-	// Write the test file to temporary directory
-	testPath := filepath.Join(tempDir, "solution_test.go")
-	err = ioutil.WriteFile(testPath, []byte(challenge.TestFile), 0644)
-	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to write test file: %v", err),
-		}
+// testContext bounds a test run by the configured timeout and by CancelRuns
+func (es *ExecutionService) testContext() (context.Context, context.CancelFunc) {
+	if es.limits.TestTimeout <= 0 {
+		return context.WithCancel(es.runs)
 	}
+	return context.WithTimeout(es.runs, es.limits.TestTimeout)
+}
 
-	// Initialize Go module
-	err = es.initGoModule(tempDir, challenge.ID)
-	if err != nil {
-		return ExecutionResult{
-			Passed: false,
-			Output: fmt.Sprintf("Failed to initialize Go module: %v", err),
-		}
+// stoppedMessage explains why a test run's context ended early, or returns ""
+func (es *ExecutionService) stoppedMessage(ctx context.Context) string {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Sprintf("Tests stopped after the %v time limit", es.limits.TestTimeout)
+	case context.Canceled:
+		return "Tests stopped because the server is shutting down"
 	}
+	return ""
+}
 
-	// Automatically detect and install dependencies based on imports
-	err = es.installDependencies(tempDir, code, challenge.ID)
+// testCommand builds a `go test` command in dir that stops at the time limit: the test
+// binary times itself out, and ctx ends the build when that is what takes too long
+func (es *ExecutionService) testCommand(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	if es.limits.TestTimeout > 0 {
+		args = append([]string{"-timeout", es.limits.TestTimeout.String()}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "go", append([]string{"test"}, args...)...)
+	cmd.Dir = dir
+	cmd.WaitDelay = 5 * time.Second // Don't wait on a killed build's children for the output
+	killProcessGroup(cmd)
+	return cmd
+}
+
+// ExecutionResult represents the result of code execution
+type ExecutionResult struct {
+	Passed      bool   `json:"passed"`
+	Output      string `json:"output"`
+	ExecutionMs int64  `json:"executionMs"`
+}
+
+// RunCode executes the provided code against a challenge's tests. ctx carries the
+// request's logging attributes; the run itself is bounded by the time limit and CancelRuns.
+func (es *ExecutionService) RunCode(ctx context.Context, code string, challenge *models.Challenge) ExecutionResult {
+	start := time.Now()
+	label := challengeLabel(es.root, challenge)
+
+	tempDir, err := es.prepareWorkspace(ctx, code, challenge)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
 	if err != nil {
+		slog.WarnContext(ctx, "test workspace setup failed", "challenge", label, "error", err)
+		metrics.TestRunDuration.Observe(metrics.Since(start), label, "error")
 		return ExecutionResult{
 			Passed: false,
-			Output: fmt.Sprintf("Failed to install dependencies: %v", err),
+			Output: err.Error(),
 		}
 	}
 
 	// Run tests
-	cmd := exec.Command("go", "test", "-v")
-	cmd.Dir = tempDir
+	release := es.acquire()
+	defer release()
+	runCtx, cancel := es.testContext()
+	defer cancel()
+	cmd := es.testCommand(runCtx, tempDir, "-v")
 
 	output, err := cmd.CombinedOutput()
 	executionTime := time.Since(start).Milliseconds()
 	outputStr := string(output)
+	if message := es.stoppedMessage(runCtx); message != "" {
+		outputStr += "\n" + message + "\n"
+	}
 
 	result := ExecutionResult{
 		Output:      outputStr,
@@ -106,9 +178,81 @@ func (es *ExecutionService) RunCode(code string, challenge *models.Challenge) Ex
 		}
 	}
 
+	verdict := runVerdict(runCtx, result.Passed, err)
+	metrics.TestRunDuration.Observe(metrics.Since(start), label, verdict)
+	slog.InfoContext(ctx, "test run finished", "challenge", label, "verdict", verdict, "duration_ms", executionTime)
 	return result
 }
 
+// runVerdict classifies a finished test run for the metrics
+func runVerdict(runCtx context.Context, passed bool, err error) string {
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		return "timeout"
+	case runCtx.Err() == context.Canceled:
+		return "canceled"
+	case passed:
+		return "passed"
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return "failed"
+	}
+	return "error"
+}
+
+// challengeLabel names a challenge in metrics and logs: "challenge-5" for classic
+// challenges, "gin/challenge-1-basic-routing" for package challenges
+func challengeLabel(root string, challenge *models.Challenge) string {
+	if challenge.ID > 0 {
+		return fmt.Sprintf("challenge-%d", challenge.ID)
+	}
+	if challenge.Dir != "" {
+		if rel, err := filepath.Rel(filepath.Join(root, "packages"), challenge.Dir); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.Base(challenge.Dir)
+	}
+	return "unknown"
+}
+
+// prepareWorkspace writes the code and the challenge's tests into a new module in a
+// temporary directory. The caller removes the directory, which is returned even on error.
+func (es *ExecutionService) prepareWorkspace(ctx context.Context, code string, challenge *models.Challenge) (string, error) {
+	// Create temporary directory for execution
+	tempDir, err := ioutil.TempDir("", "challenge-exec")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create temporary directory: %v", err)
+	}
+
+	// Write the submitted code to temporary file
+	codePath := filepath.Join(tempDir, solutionFileName)
+	err = ioutil.WriteFile(codePath, []byte(code), 0644)
+	if err != nil {
+		return tempDir, fmt.Errorf("Failed to write code file: %v", err)
+	}
+
+	// Write the test file to temporary directory
+	testPath := filepath.Join(tempDir, "solution_test.go")
+	err = ioutil.WriteFile(testPath, []byte(challenge.TestFile), 0644)
+	if err != nil {
+		return tempDir, fmt.Errorf("Failed to write test file: %v", err)
+	}
+
+	// Initialize Go module
+	err = es.initGoModule(tempDir, challenge.ID)
+	if err != nil {
+		return tempDir, fmt.Errorf("Failed to initialize Go module: %v", err)
+	}
+
+	// Automatically detect and install dependencies based on imports
+	err = es.installDependencies(ctx, tempDir, code, challenge.ID)
+	if err != nil {
+		return tempDir, fmt.Errorf("Failed to install dependencies: %v", err)
+	}
+
+	return tempDir, nil
+}
+
 // initGoModule initializes a Go module in the temporary directory
 func (es *ExecutionService) initGoModule(tempDir string, challengeID int) error {
 	// Initialize go.mod
@@ -118,7 +262,7 @@ func (es *ExecutionService) initGoModule(tempDir string, challengeID int) error
 }
 
 // installDependencies installs dependencies for the given challenge
-func (es *ExecutionService) installDependencies(tempDir string, code string, challengeID int) error {
+func (es *ExecutionService) installDependencies(ctx context.Context, tempDir string, code string, challengeID int) error {
 	// Detect imports from the code
 	requiredPackages := es.detectRequiredPackages(code, challengeID)
 
@@ -128,7 +272,7 @@ func (es *ExecutionService) installDependencies(tempDir string, code string, cha
 
 	// Install each required package
 	for _, pkg := range requiredPackages {
-		fmt.Printf("Installing dependency: %s\n", pkg)
+		slog.InfoContext(ctx, "installing dependency", "package", pkg)
 		cmd := exec.Command("go", "get", pkg)
 		cmd.Dir = tempDir
 
@@ -280,69 +424,53 @@ type SaveSubmissionRequest struct {
 	Username    string `json:"username"`
 	ChallengeID int    `json:"challengeId"`
 	Code        string `json:"code"`
+	Commit      bool   `json:"commit"` // Commit the saved file on a per-challenge branch
+	DryRun      bool   `json:"dryRun"` // Report the git plan without running it
 }
 
 // SaveSubmissionResponse represents the response from saving a submission
 type SaveSubmissionResponse struct {
-	Success     bool     `json:"success"`
-	Message     string   `json:"message"`
-	FilePath    string   `json:"filePath"`
-	GitCommands []string `json:"gitCommands"`
+	Success     bool             `json:"success"`
+	Message     string           `json:"message"`
+	FilePath    string           `json:"filePath"`
+	GitCommands []string         `json:"gitCommands"`
+	Git         *GitCommitResult `json:"git,omitempty"`
 }
 
 // SaveSubmissionToFilesystem saves a user's submission to the filesystem
 func (es *ExecutionService) SaveSubmissionToFilesystem(request SaveSubmissionRequest) SaveSubmissionResponse {
-	// Get working directory for correct relative paths
-	workDir, _ := os.Getwd()
-
-	// Try different path approaches to handle potential path issues
-	var submissionDir string
-	var fileSaved bool
-
-	// Try multiple path options to ensure it works in different environments
-	pathOptions := []string{
-		// Option 1: From web-ui directory (standard case)
-		filepath.Join("..", fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
-		// Option 2: From root workspace
-		filepath.Join(fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
-		// Option 3: Absolute path from detected workspace root
-		filepath.Join(workDir, "..", fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username),
-	}
-
-	for _, dirPath := range pathOptions {
-		err := os.MkdirAll(dirPath, 0755)
-		if err != nil {
-			continue
+	if !ValidUsername(request.Username) {
+		return SaveSubmissionResponse{
+			Success: false,
+			Message: fmt.Sprintf("Invalid username %q", request.Username),
 		}
-
-		solutionFile := filepath.Join(dirPath, "solution-template.go")
-		err = ioutil.WriteFile(solutionFile, []byte(request.Code), 0644)
-		if err != nil {
-			continue
+	}
+	submissionDir := filepath.Join(es.root, fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username)
+	if err := os.MkdirAll(submissionDir, 0755); err != nil {
+		return SaveSubmissionResponse{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create submission directory: %v", err),
 		}
-
-		submissionDir = dirPath
-		fileSaved = true
-		break
 	}
-
-	if !fileSaved {
+	if err := ioutil.WriteFile(filepath.Join(submissionDir, "solution-template.go"), []byte(request.Code), 0644); err != nil {
 		return SaveSubmissionResponse{
 			Success: false,
-			Message: "Failed to save solution to any available path",
+			Message: fmt.Sprintf("Failed to save solution: %v", err),
 		}
 	}
 
-	// Return success response with git commands
+	// Return success response with git commands for a per-challenge branch
+	relativePath := filepath.Join(fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username, "solution-template.go")
+	branch := SubmissionBranch(request.Username, fmt.Sprintf("challenge-%d", request.ChallengeID))
 	return SaveSubmissionResponse{
 		Success:  true,
 		Message:  "Solution saved to filesystem",
 		FilePath: filepath.Join(submissionDir, "solution-template.go"),
-		GitCommands: []string{
-			"cd " + filepath.Join(workDir, ".."),
-			fmt.Sprintf("git add %s", filepath.Join(fmt.Sprintf("challenge-%d", request.ChallengeID), "submissions", request.Username, "solution-template.go")),
-			fmt.Sprintf("git commit -m \"Add solution for Challenge %d\"", request.ChallengeID),
-			"git push origin main",
-		},
+		GitCommands: SubmissionGitCommands(
+			es.root,
+			branch,
+			relativePath,
+			SubmissionCommitMessage(fmt.Sprintf("challenge-%d", request.ChallengeID), request.Username),
+		),
 	}
 }
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// writeJSONFile atomically replaces path with the indented JSON encoding of v
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// protectedBranches are never committed to by the git integration
+var protectedBranches = map[string]bool{
+	"main":   true,
+	"master": true,
+}
+
+var branchUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// GitCommitRequest describes a submission file to commit on its own branch
+type GitCommitRequest struct {
+	FilePath string // Absolute or working-directory relative path of the saved solution
+	Branch   string // Branch to commit on, e.g. "challenge-5-alice"
+	Message  string // Commit message
+	Base     string // Ref a new branch starts from; the remote's default branch when empty
+	DryRun   bool   // Report the plan without touching the repository
+}
+
+// GitStep is a single git command run (or planned) by the integration
+type GitStep struct {
+	Command string `json:"command"`
+	Output  string `json:"output,omitempty"`
+}
+
+// GitCommitResult reports what the git integration did
+type GitCommitResult struct {
+	Success   bool      `json:"success"`
+	DryRun    bool      `json:"dryRun"`
+	Message   string    `json:"message"`
+	RepoRoot  string    `json:"repoRoot,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	CommitSHA string    `json:"commitSha,omitempty"`
+	Path      string    `json:"path,omitempty"` // Repository-relative path that was committed
+	Steps     []GitStep `json:"steps"`
+}
+
+// GitService commits saved submissions in the local clone
+type GitService struct {
+	enabled bool
+}
+
+// NewGitService creates a git service that commits submissions only when enabled
+func NewGitService(enabled bool) *GitService {
+	return &GitService{enabled: enabled}
+}
+
+// Enabled reports whether the server may create branches and commits
+func (gs *GitService) Enabled() bool {
+	return gs.enabled
+}
+
+// SetEnabled overrides the configured setting, e.g. for command-line tools acting on the user's behalf
+func (gs *GitService) SetEnabled(enabled bool) {
+	gs.enabled = enabled
+}
+
+// SubmissionBranch returns the per-challenge branch name for a user
+func SubmissionBranch(username string, parts ...string) string {
+	name := strings.Join(append(parts, username), "-")
+	name = branchUnsafeChars.ReplaceAllString(name, "-")
+	return strings.Trim(name, "-.")
+}
+
+// SubmissionCommitMessage returns the conventional commit message for a submission
+func SubmissionCommitMessage(scope, username string) string {
+	return fmt.Sprintf("feat(%s): add solution by %s", scope, username)
+}
+
+// SubmissionGitCommands returns the manual commands to commit a submission on its own branch
+func SubmissionGitCommands(repoRoot, branch, relPath, message string) []string {
+	return []string{
+		"cd " + repoRoot,
+		"git fetch origin",
+		"git switch -c " + branch + " origin/HEAD",
+		"git add " + relPath,
+		fmt.Sprintf("git commit -m %q -- %s", message, relPath),
+		"git push -u origin " + branch,
+	}
+}
+
+// CommitSubmission commits only the submission file on its own branch. The commit is
+// built with a temporary index and git commit-tree, so the checkout's HEAD, index and
+// working tree are left alone. A new branch starts from request.Base, or the remote's
+// default branch, rather than from whatever is checked out, so it carries no earlier
+// submissions; an existing branch gets the commit on top.
+func (gs *GitService) CommitSubmission(request GitCommitRequest) GitCommitResult {
+	result := GitCommitResult{
+		DryRun: request.DryRun,
+		Branch: request.Branch,
+		Steps:  []GitStep{},
+	}
+
+	if !gs.enabled && !request.DryRun {
+		result.Message = "Git integration is disabled. Set GIT_INTEGRATION=true to let the server commit for you."
+		return result
+	}
+
+	if request.Branch == "" || protectedBranches[request.Branch] {
+		result.Message = fmt.Sprintf("Refusing to commit to branch %q", request.Branch)
+		return result
+	}
+
+	absFile, err := filepath.Abs(request.FilePath)
+	if err != nil {
+		result.Message = fmt.Sprintf("Invalid file path: %v", err)
+		return result
+	}
+
+	fileDir := filepath.Dir(absFile)
+	root, err := gs.git(fileDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		result.Message = "Solution directory is not inside a git repository"
+		return result
+	}
+	result.RepoRoot = root
+
+	// Resolve symlinks on both sides so the relative path is stable
+	if resolved, err := filepath.EvalSymlinks(absFile); err == nil {
+		absFile = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	relPath, err := filepath.Rel(root, absFile)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		result.Message = "Solution file is outside the repository"
+		return result
+	}
+	relPath = filepath.ToSlash(relPath)
+	result.Path = relPath
+
+	if !strings.Contains(relPath, "/submissions/") {
+		result.Message = fmt.Sprintf("Refusing to commit %s: only submission files can be committed", relPath)
+		return result
+	}
+
+	ref := "refs/heads/" + request.Branch
+	if _, err := gs.git(root, "check-ref-format", ref); err != nil {
+		result.Message = fmt.Sprintf("Invalid branch name %q", request.Branch)
+		return result
+	}
+	parent := ref
+	if !gs.branchExists(root, request.Branch) {
+		if parent, err = gs.baseRef(root, request.Base); err != nil {
+			result.Message = err.Error()
+			return result
+		}
+	}
+
+	if request.DryRun {
+		for _, args := range [][]string{
+			{"read-tree", parent},
+			{"update-index", "--add", "--cacheinfo", "100644,$(git hash-object -w -- " + relPath + ")," + relPath},
+			{"commit-tree", "$(git write-tree)", "-p", parent, "-m", request.Message},
+			{"update-ref", ref, "<commit>"},
+		} {
+			result.Steps = append(result.Steps, GitStep{Command: formatGitCommand(args)})
+		}
+		result.Success = true
+		result.Message = fmt.Sprintf("Dry run: would commit %s on branch %s from %s", relPath, request.Branch, parent)
+		return result
+	}
+
+	// The temporary index starts as the parent's tree; only the submission is added to it
+	index, err := os.CreateTemp("", "gip-index-*")
+	if err != nil {
+		result.Message = fmt.Sprintf("Could not create a temporary index: %v", err)
+		return result
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	run := func(args ...string) (string, bool) {
+		output, err := gs.gitEnv(root, env, args...)
+		result.Steps = append(result.Steps, GitStep{Command: formatGitCommand(args), Output: output})
+		if err != nil {
+			result.Message = fmt.Sprintf("git %s failed: %v", args[0], err)
+			return output, false
+		}
+		return output, true
+	}
+
+	parentSHA, ok := run("rev-parse", "--verify", parent+"^{commit}")
+	if !ok {
+		return result
+	}
+	if _, ok := run("read-tree", parentSHA); !ok {
+		return result
+	}
+	blob, ok := run("hash-object", "-w", "--", relPath)
+	if !ok {
+		return result
+	}
+	if _, ok := run("update-index", "--add", "--cacheinfo", "100644,"+blob+","+relPath); !ok {
+		return result
+	}
+	tree, ok := run("write-tree")
+	if !ok {
+		return result
+	}
+	if parentTree, err := gs.git(root, "rev-parse", parentSHA+"^{tree}"); err == nil && parentTree == tree {
+		result.Message = "No changes to commit: the saved solution matches the last commit"
+		return result
+	}
+	sha, ok := run("commit-tree", tree, "-p", parentSHA, "-m", request.Message)
+	if !ok {
+		return result
+	}
+
+	// Only move the branch if it is still where it was read, and never create it over
+	// a branch made in the meantime
+	expected := parentSHA
+	if parent != ref {
+		expected = strings.Repeat("0", len(parentSHA))
+	}
+	if _, ok := run("update-ref", "-m", "gip: "+request.Message, ref, sha, expected); !ok {
+		return result
+	}
+
+	result.CommitSHA = sha
+	result.Success = true
+	result.Message = fmt.Sprintf("Committed %s on branch %s", relPath, request.Branch)
+	return result
+}
+
+// baseRef returns the ref new submission branches start from: base when given, else
+// the remote's default branch, else a local main or master
+func (gs *GitService) baseRef(root, base string) (string, error) {
+	if base != "" {
+		if _, err := gs.git(root, "rev-parse", "--verify", "--quiet", base+"^{commit}"); err != nil {
+			return "", fmt.Errorf("base %q does not name a commit", base)
+		}
+		return base, nil
+	}
+	if head, err := gs.git(root, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return head, nil
+	}
+	for _, candidate := range []string{"origin/main", "origin/master", "main", "master"} {
+		if _, err := gs.git(root, "rev-parse", "--verify", "--quiet", candidate+"^{commit}"); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("could not find a base branch: no origin/HEAD, main or master")
+}
+
+// PushCommands returns the commands the user still runs to publish the branch
+func (gs *GitService) PushCommands(result GitCommitResult) []string {
+	return []string{
+		"cd " + result.RepoRoot,
+		"git push -u origin " + result.Branch,
+	}
+}
+
+func (gs *GitService) branchExists(root, branch string) bool {
+	_, err := gs.git(root, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	return err == nil
+}
+
+// git runs a git command in dir and returns its trimmed combined output
+func (gs *GitService) git(dir string, args ...string) (string, error) {
+	return gs.gitEnv(dir, nil, args...)
+}
+
+// gitEnv runs a git command in dir with extra environment variables
+func (gs *GitService) gitEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+func formatGitCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \"'") {
+			quoted[i] = fmt.Sprintf("%q", arg)
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return "git " + strings.Join(quoted, " ")
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo creates a repository with a commit on main and a feature branch checked out
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, name := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(name+"_NAME", "Test")
+		t.Setenv(name+"_EMAIL", "test@example.com")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	writeFile(t, filepath.Join(dir, "README.md"), "readme\n")
+	runGit(t, dir, "add", "README.md")
+	runGit(t, dir, "commit", "--quiet", "-m", "initial")
+	runGit(t, dir, "switch", "--quiet", "-c", "work")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "work in progress\n")
+	runGit(t, dir, "add", "notes.txt")
+	runGit(t, dir, "commit", "--quiet", "-m", "work")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitSubmissionLeavesCheckoutAlone(t *testing.T) {
+	dir := gitRepo(t)
+	gs := NewGitService(true)
+	head := runGit(t, dir, "rev-parse", "HEAD")
+
+	// Something unrelated is staged; it must stay staged and out of the commit
+	writeFile(t, filepath.Join(dir, "staged.txt"), "staged\n")
+	runGit(t, dir, "add", "staged.txt")
+
+	first := filepath.Join(dir, "challenge-1", "submissions", "alice", "solution-template.go")
+	writeFile(t, first, "package main\n")
+	result := gs.CommitSubmission(GitCommitRequest{FilePath: first, Branch: "challenge-1-alice", Message: "one"})
+	if !result.Success {
+		t.Fatalf("first commit: %s", result.Message)
+	}
+
+	second := filepath.Join(dir, "challenge-2", "submissions", "alice", "solution-template.go")
+	writeFile(t, second, "package main\n\nfunc main() {}\n")
+	result = gs.CommitSubmission(GitCommitRequest{FilePath: second, Branch: "challenge-2-alice", Message: "two"})
+	if !result.Success {
+		t.Fatalf("second commit: %s", result.Message)
+	}
+
+	if branch := runGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); branch != "work" {
+		t.Errorf("checkout switched to %s", branch)
+	}
+	if got := runGit(t, dir, "rev-parse", "HEAD"); got != head {
+		t.Errorf("HEAD moved from %s to %s", head, got)
+	}
+	if staged := runGit(t, dir, "diff", "--cached", "--name-only"); staged != "staged.txt" {
+		t.Errorf("staged files changed to %q", staged)
+	}
+
+	// Each branch is main plus its own submission only
+	for branch, want := range map[string]string{
+		"challenge-1-alice": "README.md\nchallenge-1/submissions/alice/solution-template.go",
+		"challenge-2-alice": "README.md\nchallenge-2/submissions/alice/solution-template.go",
+	} {
+		if files := runGit(t, dir, "ls-tree", "-r", "--name-only", branch); files != want {
+			t.Errorf("%s has files:\n%s\nwant:\n%s", branch, files, want)
+		}
+		if parent := runGit(t, dir, "rev-parse", branch+"^"); parent != runGit(t, dir, "rev-parse", "main") {
+			t.Errorf("%s does not start from main", branch)
+		}
+	}
+}
+
+func TestCommitSubmissionUpdatesExistingBranch(t *testing.T) {
+	dir := gitRepo(t)
+	gs := NewGitService(true)
+	file := filepath.Join(dir, "challenge-1", "submissions", "alice", "solution-template.go")
+	request := GitCommitRequest{FilePath: file, Branch: "challenge-1-alice", Message: "solution"}
+
+	writeFile(t, file, "package main\n")
+	first := gs.CommitSubmission(request)
+	if !first.Success {
+		t.Fatalf("first commit: %s", first.Message)
+	}
+
+	again := gs.CommitSubmission(request)
+	if again.Success || !strings.Contains(again.Message, "No changes") {
+		t.Errorf("committing an unchanged file: got success=%v %q", again.Success, again.Message)
+	}
+
+	writeFile(t, file, "package main\n\n// improved\n")
+	second := gs.CommitSubmission(request)
+	if !second.Success {
+		t.Fatalf("second commit: %s", second.Message)
+	}
+	if parent := runGit(t, dir, "rev-parse", "challenge-1-alice^"); parent != first.CommitSHA {
+		t.Errorf("second commit's parent is %s, want %s", parent, first.CommitSHA)
+	}
+}
+
+func TestCommitSubmissionRefuses(t *testing.T) {
+	dir := gitRepo(t)
+	gs := NewGitService(true)
+	submission := filepath.Join(dir, "challenge-1", "submissions", "alice", "solution-template.go")
+	writeFile(t, submission, "package main\n")
+	other := filepath.Join(dir, "challenge-1", "solution-template.go")
+	writeFile(t, other, "package main\n")
+
+	tests := []struct {
+		name    string
+		request GitCommitRequest
+	}{
+		{"protected branch", GitCommitRequest{FilePath: submission, Branch: "main", Message: "m"}},
+		{"not a submission", GitCommitRequest{FilePath: other, Branch: "challenge-1-alice", Message: "m"}},
+		{"unknown base", GitCommitRequest{FilePath: submission, Branch: "challenge-1-alice", Base: "nope", Message: "m"}},
+		{"invalid branch", GitCommitRequest{FilePath: submission, Branch: "bad..name", Message: "m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := gs.CommitSubmission(tt.request); result.Success {
+				t.Errorf("committed: %s", result.Message)
+			}
+		})
+	}
+	if branches := runGit(t, dir, "branch", "--format=%(refname:short)"); branches != "main\nwork" {
+		t.Errorf("branches changed to %q", branches)
+	}
+
+	if result := NewGitService(false).CommitSubmission(GitCommitRequest{FilePath: submission, Branch: "challenge-1-alice", Message: "m"}); result.Success {
+		t.Error("committed with the integration disabled")
+	}
+}
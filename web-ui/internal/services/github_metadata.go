@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"web-ui/internal/metrics"
+)
+
+// RepoMetadata is what the web UI shows about a package's GitHub repository
+type RepoMetadata struct {
+	Stars         int               `json:"stars"`
+	LatestRelease string            `json:"latestRelease,omitempty"` // Tag of the latest release
+	LastCommitAt  time.Time         `json:"lastCommitAt"`            // On the default branch
+	CheckedAt     time.Time         `json:"checkedAt"`
+	ETags         map[string]string `json:"etags,omitempty"` // Per API, for conditional requests
+}
+
+// errRateLimited stops a refresh round once GitHub's rate limit is used up
+var errRateLimited = fmt.Errorf("GitHub API rate limit exceeded")
+
+// GitHubMetadataService fetches stars, the latest release and the last commit date of
+// package repositories in the background. Results are kept on disk, requests are
+// conditional on the previous ETag, so unchanged data does not count against the rate
+// limit, and a round stops when the rate limit is used up until it resets.
+type GitHubMetadataService struct {
+	apiURL     string
+	token      string
+	httpClient *http.Client
+	path       string
+	interval   time.Duration
+
+	mu          sync.RWMutex
+	repos       map[string]*RepoMetadata // By owner/name
+	pausedUntil time.Time
+}
+
+// NewGitHubMetadataService creates a metadata service that refreshes every interval and
+// persists to <dataDir>/github-metadata.json. It authenticates with GITHUB_TOKEN or
+// GH_TOKEN when set and talks to GITHUB_API_URL if set.
+func NewGitHubMetadataService(dataDir string, interval time.Duration) *GitHubMetadataService {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	ms := &GitHubMetadataService{
+		apiURL:     strings.TrimRight(apiURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		path:       filepath.Join(dataDir, "github-metadata.json"),
+		interval:   interval,
+		repos:      make(map[string]*RepoMetadata),
+	}
+	if err := ms.load(); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Could not read the saved GitHub metadata", "path", ms.path, "error", err)
+	}
+	return ms
+}
+
+// Get returns the last known metadata of an owner/name repository
+func (ms *GitHubMetadataService) Get(repo string) (RepoMetadata, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	metadata, ok := ms.repos[repo]
+	if !ok {
+		return RepoMetadata{}, false
+	}
+	return *metadata, true
+}
+
+// metadataRetryInterval is how soon a refresh round that failed for some repository is retried
+const metadataRetryInterval = 15 * time.Minute
+
+// Start refreshes the repositories listed by repos in the background: at once if any
+// is missing or older than the interval, then every interval, sooner after failures and
+// later while the rate limit is used up. updated is called after each round that
+// refreshed something.
+func (ms *GitHubMetadataService) Start(repos func() []string, updated func()) {
+	go func() {
+		timer := time.NewTimer(ms.firstRefresh(repos()))
+		for range timer.C {
+			refreshed, failed := ms.Refresh(context.Background(), repos())
+			if refreshed > 0 && updated != nil {
+				updated()
+			}
+
+			wait := ms.interval
+			if failed > 0 && wait > metadataRetryInterval {
+				wait = metadataRetryInterval
+			}
+			ms.mu.RLock()
+			if paused := time.Until(ms.pausedUntil); paused > wait {
+				wait = paused
+			}
+			ms.mu.RUnlock()
+			timer.Reset(wait)
+		}
+	}()
+}
+
+// firstRefresh returns how long until the stalest saved repository is due
+func (ms *GitHubMetadataService) firstRefresh(repos []string) time.Duration {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	wait := ms.interval
+	for _, repo := range repos {
+		metadata, ok := ms.repos[repo]
+		if !ok {
+			return 0
+		}
+		if due := ms.interval - time.Since(metadata.CheckedAt); due < wait {
+			wait = due
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// Refresh fetches the repositories that are due and returns how many were refreshed
+// and how many could not be
+func (ms *GitHubMetadataService) Refresh(ctx context.Context, repos []string) (refreshed, failed int) {
+	for i, repo := range repos {
+		if previous, ok := ms.Get(repo); ok && time.Since(previous.CheckedAt) < ms.interval {
+			continue
+		}
+		err := ms.refreshRepo(ctx, repo)
+		if err == errRateLimited {
+			ms.mu.RLock()
+			until := ms.pausedUntil
+			ms.mu.RUnlock()
+			slog.Warn("GitHub API rate limit reached, pausing package metadata refresh", "until", until)
+			failed += len(repos) - i
+			break
+		}
+		if err != nil {
+			slog.Warn("Could not refresh GitHub metadata", "repo", repo, "error", err)
+			failed++
+			continue
+		}
+		refreshed++
+	}
+	if refreshed > 0 {
+		if err := ms.save(); err != nil {
+			slog.Warn("Could not save GitHub metadata", "path", ms.path, "error", err)
+		}
+	}
+	return refreshed, failed
+}
+
+// refreshRepo fetches one repository's stars, latest release and last commit
+func (ms *GitHubMetadataService) refreshRepo(ctx context.Context, repo string) error {
+	metadata, _ := ms.Get(repo)
+	etags := make(map[string]string)
+	for api, etag := range metadata.ETags {
+		etags[api] = etag
+	}
+
+	var repoData struct {
+		StargazersCount int `json:"stargazers_count"`
+	}
+	switch found, err := ms.get(ctx, "repos", "/repos/"+repo, etags, &repoData); {
+	case err != nil:
+		return err
+	case found:
+		metadata.Stars = repoData.StargazersCount
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	switch found, err := ms.get(ctx, "releases", "/repos/"+repo+"/releases/latest", etags, &release); {
+	case err == errNotFound:
+		metadata.LatestRelease = "" // The repository publishes no releases
+	case err != nil:
+		return err
+	case found:
+		metadata.LatestRelease = release.TagName
+	}
+
+	var commits []struct {
+		Commit struct {
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	switch found, err := ms.get(ctx, "commits", "/repos/"+repo+"/commits?per_page=1", etags, &commits); {
+	case err != nil:
+		return err
+	case found && len(commits) > 0:
+		metadata.LastCommitAt = commits[0].Commit.Committer.Date
+	}
+
+	metadata.ETags = etags
+	metadata.CheckedAt = time.Now()
+	ms.mu.Lock()
+	ms.repos[repo] = &metadata
+	ms.mu.Unlock()
+	return nil
+}
+
+// errNotFound is returned by get for a 404, such as a repository without releases
+var errNotFound = fmt.Errorf("not found")
+
+// get sends a conditional GET for path, decoding the response into target. It reports
+// false when GitHub answered 304 Not Modified, and updates etags[api].
+func (ms *GitHubMetadataService) get(ctx context.Context, api, path string, etags map[string]string, target interface{}) (bool, error) {
+	ms.mu.RLock()
+	paused := time.Now().Before(ms.pausedUntil)
+	ms.mu.RUnlock()
+	if paused {
+		return false, errRateLimited
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ms.apiURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "go-interview-practice-web-ui/1.0")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if ms.token != "" {
+		req.Header.Set("Authorization", "Bearer "+ms.token)
+	}
+	if etag := etags[api]; etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := ms.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitHubCall(api, 0)
+		return false, err
+	}
+	defer resp.Body.Close()
+	metrics.ObserveGitHubCall(api, resp.StatusCode)
+	ms.noteRateLimit(resp)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return false, nil
+	case resp.StatusCode == http.StatusNotFound:
+		delete(etags, api)
+		return false, errNotFound
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		ms.mu.RLock()
+		limited := time.Now().Before(ms.pausedUntil)
+		ms.mu.RUnlock()
+		if limited {
+			return false, errRateLimited
+		}
+		return false, fmt.Errorf("GitHub API returned %s for %s", resp.Status, path)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("GitHub API returned %s for %s", resp.Status, path)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return false, fmt.Errorf("invalid response for %s: %v", path, err)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		etags[api] = etag
+	}
+	return true, nil
+}
+
+// noteRateLimit pauses requests until the rate limit resets once it is used up, or for
+// Retry-After after a secondary rate limit
+func (ms *GitHubMetadataService) noteRateLimit(resp *http.Response) {
+	var until time.Time
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		until = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return
+		}
+		until = time.Unix(reset, 0)
+	} else {
+		return
+	}
+	ms.mu.Lock()
+	if until.After(ms.pausedUntil) {
+		ms.pausedUntil = until
+	}
+	ms.mu.Unlock()
+}
+
+func (ms *GitHubMetadataService) load() error {
+	data, err := os.ReadFile(ms.path)
+	if err != nil {
+		return err
+	}
+	repos := make(map[string]*RepoMetadata)
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	ms.repos = repos
+	ms.mu.Unlock()
+	return nil
+}
+
+func (ms *GitHubMetadataService) save() error {
+	ms.mu.RLock()
+	repos := make(map[string]*RepoMetadata, len(ms.repos))
+	for repo, metadata := range ms.repos {
+		repos[repo] = metadata // Entries are replaced, never modified, so sharing them is safe
+	}
+	ms.mu.RUnlock()
+	return writeJSONFile(ms.path, repos)
+}
+
+// VersionOutdated reports whether latest is a newer major or minor version than version,
+// e.g. v1.9.1 against v1.10.0. Patch releases and tags that are not semantic versions
+// do not count.
+func VersionOutdated(version, latest string) bool {
+	current, ok := parseMinorVersion(version)
+	if !ok {
+		return false
+	}
+	newest, ok := parseMinorVersion(latest)
+	if !ok {
+		return false
+	}
+	if newest[0] != current[0] {
+		return newest[0] > current[0]
+	}
+	return newest[1] > current[1]
+}
+
+// parseMinorVersion reads the major and minor numbers of a tag such as v1.9.1
+func parseMinorVersion(tag string) ([2]int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(strings.TrimSpace(tag), "v"), ".", 3)
+	if len(parts) < 2 || strings.ContainsAny(tag, "-+") {
+		return [2]int{}, false
+	}
+	var version [2]int
+	for i := range version {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return [2]int{}, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
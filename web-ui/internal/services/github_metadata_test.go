@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// githubMetadataStandIn serves the repository, release and commit APIs for o/r, which
+// has stars stars and release as its latest release ("" for none). It answers 304 when
+// the request carries the current ETag and records the requests it saw.
+type githubMetadataStandIn struct {
+	mu       sync.Mutex
+	stars    int
+	release  string
+	requests []string // Path and If-None-Match of each request
+}
+
+func (s *githubMetadataStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.Path+" "+r.Header.Get("If-None-Match"))
+
+	var etag, body string
+	switch r.URL.Path {
+	case "/repos/o/r":
+		etag, body = fmt.Sprintf(`"stars-%d"`, s.stars), fmt.Sprintf(`{"stargazers_count": %d}`, s.stars)
+	case "/repos/o/r/releases/latest":
+		if s.release == "" {
+			http.NotFound(w, r)
+			return
+		}
+		etag, body = `"release-`+s.release+`"`, `{"tag_name": "`+s.release+`"}`
+	case "/repos/o/r/commits":
+		etag, body = `"commits"`, `[{"commit": {"committer": {"date": "2026-10-01T12:00:00Z"}}}]`
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(body))
+}
+
+func (s *githubMetadataStandIn) takeRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+// newTestMetadataService returns a metadata service talking to handler, with every
+// repository due on each refresh
+func newTestMetadataService(t *testing.T, handler http.Handler, dataDir string) *GitHubMetadataService {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("GITHUB_API_URL", server.URL)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	return NewGitHubMetadataService(dataDir, 0)
+}
+
+func TestGitHubMetadataConditionalRequests(t *testing.T) {
+	standIn := &githubMetadataStandIn{stars: 10, release: "v1.2.0"}
+	dataDir := t.TempDir()
+	ms := newTestMetadataService(t, standIn, dataDir)
+
+	if refreshed, failed := ms.Refresh(context.Background(), []string{"o/r"}); refreshed != 1 || failed != 0 {
+		t.Fatalf("first round refreshed %d, failed %d; want 1, 0", refreshed, failed)
+	}
+	first, _ := ms.Get("o/r")
+	wantCommit := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	if first.Stars != 10 || first.LatestRelease != "v1.2.0" || !first.LastCommitAt.Equal(wantCommit) {
+		t.Fatalf("got %+v, want 10 stars, v1.2.0 and the commit date", first)
+	}
+	standIn.takeRequests()
+
+	// Unchanged data answers 304 and keeps the saved values; changed data is fetched again
+	standIn.stars = 11
+	standIn.release = ""
+	if refreshed, failed := ms.Refresh(context.Background(), []string{"o/r"}); refreshed != 1 || failed != 0 {
+		t.Fatalf("second round refreshed %d, failed %d; want 1, 0", refreshed, failed)
+	}
+	wantRequests := []string{
+		`/repos/o/r "stars-10"`,
+		`/repos/o/r/releases/latest "release-v1.2.0"`,
+		`/repos/o/r/commits "commits"`,
+	}
+	if got := standIn.takeRequests(); fmt.Sprint(got) != fmt.Sprint(wantRequests) {
+		t.Errorf("second round sent %q, want %q", got, wantRequests)
+	}
+	second, _ := ms.Get("o/r")
+	if second.Stars != 11 || second.LatestRelease != "" || !second.LastCommitAt.Equal(wantCommit) {
+		t.Errorf("got %+v, want 11 stars, no release and the same commit date", second)
+	}
+	if _, ok := second.ETags["releases"]; ok {
+		t.Errorf("kept the ETag of a release that is gone: %v", second.ETags)
+	}
+	if !second.CheckedAt.After(first.CheckedAt) {
+		t.Errorf("CheckedAt did not advance")
+	}
+
+	// The next start resumes from the saved metadata and its ETags
+	reloaded, ok := NewGitHubMetadataService(dataDir, 0).Get("o/r")
+	if !ok || reloaded.Stars != 11 || reloaded.ETags["repos"] != `"stars-11"` {
+		t.Errorf("reloaded %+v, %v; want the saved metadata", reloaded, ok)
+	}
+}
+
+func TestGitHubMetadataRateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name     string
+		status   int
+		headers  map[string]string
+		requests int       // Requests sent in the round
+		paused   time.Time // Zero if the service should not pause
+	}{
+		{"primary limit used up", http.StatusForbidden,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset.Unix(), 10)}, 1, reset},
+		{"last request of the limit succeeds", http.StatusOK,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(reset.Unix(), 10)}, 1, reset},
+		{"secondary limit", http.StatusTooManyRequests,
+			map[string]string{"Retry-After": "120"}, 1, time.Now().Add(120 * time.Second)},
+		{"forbidden without a limit", http.StatusForbidden,
+			map[string]string{"X-RateLimit-Remaining": "42"}, 2, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			ms := newTestMetadataService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				for name, value := range tt.headers {
+					w.Header().Set(name, value)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"stargazers_count": 1}`))
+			}), t.TempDir())
+
+			refreshed, failed := ms.Refresh(context.Background(), []string{"o/a", "o/b"})
+			if refreshed != 0 || failed != 2 {
+				t.Errorf("refreshed %d, failed %d; want 0, 2", refreshed, failed)
+			}
+			mu.Lock()
+			sent := requests
+			mu.Unlock()
+			if sent != tt.requests {
+				t.Errorf("sent %d requests, want %d", sent, tt.requests)
+			}
+
+			ms.mu.RLock()
+			pausedUntil := ms.pausedUntil
+			ms.mu.RUnlock()
+			if tt.paused.IsZero() {
+				if !pausedUntil.IsZero() {
+					t.Errorf("paused until %v, want no pause", pausedUntil)
+				}
+				return
+			}
+			if diff := pausedUntil.Sub(tt.paused); diff < -5*time.Second || diff > 5*time.Second {
+				t.Errorf("paused until %v, want about %v", pausedUntil, tt.paused)
+			}
+
+			// While paused, rounds send nothing
+			ms.Refresh(context.Background(), []string{"o/a", "o/b"})
+			mu.Lock()
+			sent = requests
+			mu.Unlock()
+			if sent != tt.requests {
+				t.Errorf("sent %d more requests while paused", sent-tt.requests)
+			}
+		})
+	}
+}
+
+func TestVersionOutdated(t *testing.T) {
+	tests := []struct {
+		version, latest string
+		want            bool
+	}{
+		{"v1.9.1", "v1.10.0", true},
+		{"v1.9.1", "v1.9.5", false},
+		{"v1.10.0", "v1.9.1", false},
+		{"v1.9.1", "v2.0.0", true},
+		{"v2.0.0", "v1.99.0", false},
+		{"v1.9", "v1.10", true},
+		{"1.9.1", "v1.10.0", true},
+		{"v1.9.1", "v1.10.0-rc.1", false},
+		{"v1.9.1-beta", "v1.10.0", false},
+		{"v1.9.1", "nightly", false},
+		{"", "v1.10.0", false},
+		{"v1.9.1", "", false},
+	}
+	for _, tt := range tests {
+		if got := VersionOutdated(tt.version, tt.latest); got != tt.want {
+			t.Errorf("VersionOutdated(%q, %q) = %v, want %v", tt.version, tt.latest, got, tt.want)
+		}
+	}
+}
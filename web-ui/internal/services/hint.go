@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"web-ui/internal/markdown"
+)
+
+// Where a hint on the ladder comes from
+const (
+	HintSourceAuthored = "authored" // A section of the challenge's hints.md
+	HintSourceAI       = "ai"       // Generated for the user's code once the authored hints run out
+)
+
+// Hint policy modes
+const (
+	HintsAllowed   = "allowed"
+	HintsPenalized = "penalized" // Hints are shown but each one costs points
+	HintsDisabled  = "disabled"
+)
+
+// maxAIHintLevel is the last AI rung of the ladder, the most detailed hint
+const maxAIHintLevel = 4
+
+var (
+	// ErrHintsDisabled is returned when the policy in force does not allow hints
+	ErrHintsDisabled = errors.New("hints are disabled")
+	// ErrNoMoreHints is returned once every hint on the ladder has been revealed
+	ErrNoMoreHints = errors.New("no more hints are available for this challenge")
+	// ErrHintBusy is returned while an AI hint for the same user and challenge is being written
+	ErrHintBusy = errors.New("a hint is already being prepared")
+)
+
+// HintPolicy controls whether hints may be used, e.g. during an interview or a contest
+type HintPolicy struct {
+	Mode    string `json:"mode"`              // HintsAllowed, HintsPenalized or HintsDisabled
+	Penalty int    `json:"penalty,omitempty"` // Points deducted per hint when penalized
+}
+
+// ParseHintPolicy reads a policy written as "allowed", "disabled" or "penalized:<points>"
+func ParseHintPolicy(value string) (HintPolicy, error) {
+	mode, points, hasPoints := strings.Cut(strings.TrimSpace(value), ":")
+	switch mode {
+	case "", HintsAllowed:
+		return HintPolicy{Mode: HintsAllowed}, nil
+	case HintsDisabled:
+		return HintPolicy{Mode: HintsDisabled}, nil
+	case HintsPenalized:
+		policy := HintPolicy{Mode: HintsPenalized, Penalty: 5}
+		if hasPoints {
+			penalty, err := strconv.Atoi(points)
+			if err != nil || penalty < 0 || penalty > 100 {
+				return HintPolicy{}, fmt.Errorf("invalid hint penalty %q", points)
+			}
+			policy.Penalty = penalty
+		}
+		return policy, nil
+	}
+	return HintPolicy{}, fmt.Errorf("unknown hint policy %q", value)
+}
+
+// Validate checks a policy supplied by a client
+func (p HintPolicy) Validate() error {
+	switch p.Mode {
+	case HintsAllowed, HintsDisabled:
+		return nil
+	case HintsPenalized:
+		if p.Penalty < 0 || p.Penalty > 100 {
+			return fmt.Errorf("hint penalty must be between 0 and 100")
+		}
+		return nil
+	}
+	return fmt.Errorf("hint policy mode must be one of %s, %s or %s", HintsAllowed, HintsPenalized, HintsDisabled)
+}
+
+// HintSection is one authored hint: a "## " section of hints.md
+type HintSection struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// ParseHintSections splits hints.md into its "## " sections. Text before the first
+// section and the "No hints available" placeholder are not hints.
+func ParseHintSections(markdown string) []HintSection {
+	sections := []HintSection{}
+	var current *HintSection
+	var content strings.Builder
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(content.String())
+			if current.Content != "" {
+				sections = append(sections, *current)
+			}
+		}
+		content.Reset()
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		if title, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "## "); ok {
+			flush()
+			current = &HintSection{Title: strings.TrimSpace(title)}
+			continue
+		}
+		if current != nil {
+			content.WriteString(line)
+			content.WriteString("\n")
+		}
+	}
+	flush()
+	return sections
+}
+
+// RevealedHint is a hint shown to a user, kept so it can be shown again
+type RevealedHint struct {
+	Step          int       `json:"step"` // Position on the ladder, from 1
+	Source        string    `json:"source"`
+	Title         string    `json:"title,omitempty"`
+	Content       string    `json:"content"`
+	AILevel       int       `json:"aiLevel,omitempty"` // 1-4 for AI hints
+	FailingTest   string    `json:"failingTest,omitempty"`
+	PromptVersion string    `json:"promptVersion,omitempty"`
+	Penalty       int       `json:"penalty,omitempty"`     // Points this hint cost under the policy in force
+	InterviewID   string    `json:"interviewId,omitempty"` // Interview the hint was used in
+	At            time.Time `json:"at"`
+	// HTML is Content rendered from Markdown; it is filled in for responses, not stored
+	HTML template.HTML `json:"html,omitempty"`
+}
+
+// HintProgress is how far a user is along a challenge's hint ladder
+type HintProgress struct {
+	Challenge         string         `json:"challenge"`
+	Revealed          []RevealedHint `json:"revealed"`
+	AuthoredTotal     int            `json:"authoredTotal"`
+	AuthoredRemaining int            `json:"authoredRemaining"`
+	AIRemaining       int            `json:"aiRemaining"` // 0 when AI is not configured
+	Next              string         `json:"next"`        // Source of the next hint, empty when none are left
+	Policy            HintPolicy     `json:"policy"`
+	Penalty           int            `json:"penalty"` // Total points the revealed hints cost
+}
+
+// HintService reveals a challenge's authored hints section by section and then AI hints
+// for the user's code, recording what each user has seen in the data directory
+type HintService struct {
+	path      string
+	aiService *AIService
+	policy    HintPolicy
+	usage     map[string]map[string][]RevealedHint // user -> challenge key -> hints in order
+	pending   map[string]bool                      // user + challenge keys with an AI hint in flight
+	mutex     sync.Mutex
+}
+
+// NewHintService loads recorded hint usage from dataDir. policy is the site-wide policy,
+// which contest deployments can set to disabled or penalized.
+func NewHintService(aiService *AIService, dataDir string, policy HintPolicy) *HintService {
+	service := &HintService{
+		path:      filepath.Join(dataDir, "hint-usage.json"),
+		aiService: aiService,
+		policy:    policy,
+		usage:     make(map[string]map[string][]RevealedHint),
+		pending:   make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(service.path)
+	if err == nil {
+		if err := json.Unmarshal(data, &service.usage); err != nil {
+			slog.Warn("Could not read hint usage", "path", service.path, "error", err)
+			service.usage = make(map[string]map[string][]RevealedHint)
+		}
+	} else if !os.IsNotExist(err) {
+		slog.Warn("Could not read hint usage", "path", service.path, "error", err)
+	}
+	return service
+}
+
+// DefaultPolicy returns the site-wide hint policy
+func (s *HintService) DefaultPolicy() HintPolicy {
+	return s.policy
+}
+
+// restrict applies the site-wide policy on top of policy, which may only be stricter.
+// Sessions saved before hint policies existed have none and follow the site.
+func (s *HintService) restrict(policy HintPolicy) HintPolicy {
+	switch {
+	case policy.Mode == "", s.policy.Mode == HintsDisabled:
+		return s.policy
+	case policy.Mode == HintsDisabled, s.policy.Mode == HintsAllowed:
+		return policy
+	case policy.Mode == HintsAllowed, policy.Penalty < s.policy.Penalty:
+		return s.policy
+	}
+	return policy
+}
+
+// Progress returns the hints user has seen for the challenge and what comes next
+// under policy
+func (s *HintService) Progress(user string, challenge *ChallengeContext, policy HintPolicy) *HintProgress {
+	policy = s.restrict(policy)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sections := ParseHintSections(challenge.Hints)
+	revealed := append([]RevealedHint{}, s.usage[user][challenge.Key()]...)
+	for i := range revealed {
+		revealed[i].HTML = markdown.Render(revealed[i].Content)
+	}
+	progress := &HintProgress{
+		Challenge:     challenge.Key(),
+		Revealed:      revealed,
+		AuthoredTotal: len(sections),
+		Policy:        policy,
+	}
+
+	authored, aiLevel := countHints(revealed)
+	progress.AuthoredRemaining = len(sections) - authored
+	if progress.AuthoredRemaining < 0 {
+		progress.AuthoredRemaining = 0
+	}
+	if s.aiAvailable() {
+		progress.AIRemaining = maxAIHintLevel - aiLevel
+	}
+	for _, hint := range revealed {
+		progress.Penalty += hint.Penalty
+	}
+
+	switch {
+	case policy.Mode == HintsDisabled:
+	case progress.AuthoredRemaining > 0:
+		progress.Next = HintSourceAuthored
+	case progress.AIRemaining > 0:
+		progress.Next = HintSourceAI
+	}
+	return progress
+}
+
+// Next reveals the next hint on the ladder for the user in ctx: the next authored
+// section, or once those are used up an AI hint conditioned on code and its first
+// failing test. The site-wide policy applies on top of policy. interviewID marks
+// hints used during an interview. AI failures are returned as *AIError and nothing
+// is recorded.
+func (s *HintService) Next(ctx context.Context, challenge *ChallengeContext, code string, policy HintPolicy, interviewID string) (*RevealedHint, error) {
+	policy = s.restrict(policy)
+	if policy.Mode == HintsDisabled {
+		return nil, ErrHintsDisabled
+	}
+	user := AIUserFromContext(ctx)
+	key := challenge.Key()
+	pendingKey := user + "\x00" + key
+
+	s.mutex.Lock()
+	if s.pending[pendingKey] {
+		s.mutex.Unlock()
+		return nil, ErrHintBusy
+	}
+	sections := ParseHintSections(challenge.Hints)
+	revealed := s.usage[user][key]
+	authored, aiLevel := countHints(revealed)
+
+	hint := RevealedHint{Step: len(revealed) + 1, InterviewID: interviewID}
+	if policy.Mode == HintsPenalized {
+		hint.Penalty = policy.Penalty
+	}
+	if authored < len(sections) {
+		section := sections[authored]
+		hint.Source = HintSourceAuthored
+		hint.Title = section.Title
+		hint.Content = section.Content
+		hint.At = time.Now()
+		s.recordLocked(user, key, hint)
+		s.mutex.Unlock()
+		hint.HTML = markdown.Render(hint.Content)
+		return &hint, nil
+	}
+	if aiLevel >= maxAIHintLevel || !s.aiAvailable() {
+		s.mutex.Unlock()
+		return nil, ErrNoMoreHints
+	}
+	s.pending[pendingKey] = true
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.pending, pendingKey)
+		s.mutex.Unlock()
+	}()
+
+	hint.Source = HintSourceAI
+	hint.AILevel = aiLevel + 1
+	hint.FailingTest = failingTestSummary(s.aiService.analyze(ctx, code, challenge))
+	content, promptVersion, err := s.aiService.GetLadderHint(ctx, code, challenge, hint.AILevel, hint.FailingTest, sections)
+	if err != nil {
+		return nil, err
+	}
+	hint.Content = content
+	hint.PromptVersion = promptVersion
+	hint.At = time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	hint.Step = len(s.usage[user][key]) + 1
+	s.recordLocked(user, key, hint)
+	hint.HTML = markdown.Render(hint.Content)
+	return &hint, nil
+}
+
+// aiAvailable reports whether the ladder can go on to AI hints
+func (s *HintService) aiAvailable() bool {
+	return s.aiService != nil && s.aiService.Provider() != nil
+}
+
+// recordLocked appends a revealed hint and saves the usage; callers must hold the mutex
+func (s *HintService) recordLocked(user, key string, hint RevealedHint) {
+	challenges, ok := s.usage[user]
+	if !ok {
+		challenges = make(map[string][]RevealedHint)
+		s.usage[user] = challenges
+	}
+	challenges[key] = append(challenges[key], hint)
+	if err := writeJSONFile(s.path, s.usage); err != nil {
+		slog.Warn("Could not save hint usage", "error", err)
+	}
+}
+
+// countHints returns how many authored hints were revealed and the highest AI level reached
+func countHints(revealed []RevealedHint) (authored, aiLevel int) {
+	for _, hint := range revealed {
+		if hint.Source == HintSourceAuthored {
+			authored++
+		} else if hint.AILevel > aiLevel {
+			aiLevel = hint.AILevel
+		}
+	}
+	return authored, aiLevel
+}
+
+// failingTestSummary describes what is wrong with the code according to its test run:
+// the compiler errors, or the first failing test and its output
+func failingTestSummary(analysis *CodeAnalysis) string {
+	if analysis == nil || analysis.Error != "" {
+		return ""
+	}
+	if analysis.BuildFailed {
+		var summary strings.Builder
+		summary.WriteString("The code does not compile:\n")
+		for i, diagnostic := range analysis.Diagnostics {
+			if i == 5 {
+				fmt.Fprintf(&summary, "(%d more)\n", len(analysis.Diagnostics)-5)
+				break
+			}
+			fmt.Fprintf(&summary, "- %s\n", diagnostic.Message)
+		}
+		return strings.TrimRight(summary.String(), "\n")
+	}
+	for _, test := range analysis.Tests {
+		if test.Status == "FAIL" && test.Depth == 0 {
+			for _, subtest := range analysis.Tests {
+				if subtest.Status == "FAIL" && strings.HasPrefix(subtest.Name, test.Name+"/") {
+					test = subtest
+					break
+				}
+			}
+			return fmt.Sprintf("%s fails (%d/%d tests pass):\n%s", test.Name, analysis.TestsPassed, analysis.TestsTotal, truncateText(strings.TrimSpace(test.Output), 1500))
+		}
+	}
+	if analysis.TestsTotal > 0 && analysis.Passed {
+		return fmt.Sprintf("All %d tests pass.", analysis.TestsTotal)
+	}
+	return ""
+}
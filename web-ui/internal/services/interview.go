@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Interview session states
+const (
+	InterviewActive    = "active"
+	InterviewCompleted = "completed"
+)
+
+// Speakers in an interview transcript
+const (
+	RoleInterviewer = "interviewer"
+	RoleCandidate   = "candidate"
+)
+
+const (
+	maxInterviewTurns  = 60
+	maxInterviewAnswer = 4000
+	maxInterviewCode   = 100 * 1024
+
+	// interviewIdleTimeout is how long an active session stays in memory without changes.
+	// Evicted sessions remain on disk and are loaded again when used.
+	interviewIdleTimeout = 2 * time.Hour
+)
+
+var (
+	// ErrInterviewNotFound is returned for unknown sessions or sessions owned by someone else
+	ErrInterviewNotFound = errors.New("interview session not found")
+	// ErrInterviewClosed is returned when changing a completed session
+	ErrInterviewClosed = errors.New("interview session is already completed")
+	// ErrInterviewBusy is returned while the interviewer is still answering a previous request
+	ErrInterviewBusy = errors.New("the interviewer is still responding")
+
+	interviewIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// InterviewInputError reports a request the interview cannot accept, as opposed to an AI failure
+type InterviewInputError struct {
+	Message string
+}
+
+func (e *InterviewInputError) Error() string {
+	return e.Message
+}
+
+// InterviewTurn is one message in the interview dialogue
+type InterviewTurn struct {
+	Role          string    `json:"role"` // RoleInterviewer or RoleCandidate
+	Content       string    `json:"content"`
+	PromptVersion string    `json:"promptVersion,omitempty"` // Prompt that produced an interviewer turn
+	At            time.Time `json:"at"`
+}
+
+// CodeSnapshot is the candidate's code at a point in the interview
+type CodeSnapshot struct {
+	Code string    `json:"code"`
+	At   time.Time `json:"at"`
+}
+
+// InterviewRunResult is the outcome of a test run during the interview
+type InterviewRunResult struct {
+	Passed      bool      `json:"passed"`
+	TestsPassed int       `json:"testsPassed"`
+	TestsTotal  int       `json:"testsTotal"`
+	At          time.Time `json:"at"`
+}
+
+// InterviewSession is a conversational mock interview about one challenge
+type InterviewSession struct {
+	ID             string               `json:"id"`
+	Username       string               `json:"username,omitempty"`
+	ChallengeID    int                  `json:"challengeId"`
+	ChallengeTitle string               `json:"challengeTitle"`
+	Status         string               `json:"status"`
+	Snapshots      []CodeSnapshot       `json:"snapshots"`
+	Runs           []InterviewRunResult `json:"runs"`
+	Turns          []InterviewTurn      `json:"turns"`
+	Evaluation     *InterviewEvaluation `json:"evaluation,omitempty"`
+	HintPolicy     HintPolicy           `json:"hintPolicy"`
+	HintsUsed      int                  `json:"hintsUsed"`
+	HintPenalty    int                  `json:"hintPenalty"` // Points deducted from the evaluation for hints
+	CreatedAt      time.Time            `json:"createdAt"`
+	UpdatedAt      time.Time            `json:"updatedAt"`
+
+	busy bool // An AI request for this session is in flight
+}
+
+// LatestCode returns the most recent code snapshot
+func (s *InterviewSession) LatestCode() string {
+	if len(s.Snapshots) == 0 {
+		return ""
+	}
+	return s.Snapshots[len(s.Snapshots)-1].Code
+}
+
+// InterviewService keeps interview sessions in memory and persists them in the data directory
+type InterviewService struct {
+	dir              string
+	aiService        *AIService
+	challengeService *ChallengeService
+	sessions         map[string]*InterviewSession
+	active           map[string]string // IDs of signed-in users' active sessions, by activeKey
+	activeLoaded     bool              // Whether active has been filled from disk
+	mutex            sync.Mutex
+}
+
+// NewInterviewService creates an interview service driven by the AI service
+func NewInterviewService(aiService *AIService, challengeService *ChallengeService, dataDir string) *InterviewService {
+	return &InterviewService{
+		dir:              filepath.Join(dataDir, "interviews"),
+		aiService:        aiService,
+		challengeService: challengeService,
+		sessions:         make(map[string]*InterviewSession),
+		active:           make(map[string]string),
+	}
+}
+
+// Start opens a session for the challenge and asks the first question. hintPolicy
+// decides whether the candidate may use hints during the interview.
+func (is *InterviewService) Start(ctx context.Context, username string, challengeID int, code string, hintPolicy HintPolicy) (*InterviewSession, error) {
+	challenge, exists := is.challengeService.GetChallenge(challengeID)
+	if !exists {
+		return nil, &InterviewInputError{fmt.Sprintf("challenge %d not found", challengeID)}
+	}
+	if len(code) > maxInterviewCode {
+		return nil, &InterviewInputError{"code is too large"}
+	}
+	if err := hintPolicy.Validate(); err != nil {
+		return nil, &InterviewInputError{err.Error()}
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &InterviewSession{
+		ID:             hex.EncodeToString(idBytes),
+		Username:       username,
+		ChallengeID:    challengeID,
+		ChallengeTitle: challenge.Title,
+		Status:         InterviewActive,
+		Snapshots:      []CodeSnapshot{},
+		Runs:           []InterviewRunResult{},
+		Turns:          []InterviewTurn{},
+		HintPolicy:     hintPolicy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if code != "" {
+		session.Snapshots = append(session.Snapshots, CodeSnapshot{Code: code, At: now})
+	}
+
+	question, promptVersion, err := is.aiService.InterviewerTurn(ctx, session, ClassicChallengeContext(challenge))
+	if err != nil {
+		return nil, err
+	}
+	session.Turns = append(session.Turns, InterviewTurn{Role: RoleInterviewer, Content: question, PromptVersion: promptVersion, At: time.Now()})
+
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+	is.evictLocked(time.Now())
+	is.sessions[session.ID] = session
+	if err := is.saveLocked(session); err != nil {
+		return nil, err
+	}
+	if username != "" {
+		is.active[activeKey(username, challengeID)] = session.ID
+	}
+	return session.copy(), nil
+}
+
+// ActiveSession returns the user's active session for the challenge, or nil if there is
+// none. Anonymous sessions are never returned, since they cannot be told apart.
+func (is *InterviewService) ActiveSession(username string, challengeID int) (*InterviewSession, error) {
+	if username == "" {
+		return nil, nil
+	}
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	if !is.activeLoaded {
+		if err := is.loadActiveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	key := activeKey(username, challengeID)
+	id, ok := is.active[key]
+	if !ok {
+		return nil, nil
+	}
+	session, err := is.getLocked(username, id)
+	if err != nil || session.Status != InterviewActive {
+		delete(is.active, key)
+		return nil, nil
+	}
+	return session.copy(), nil
+}
+
+// Get returns a session owned by the user
+func (is *InterviewService) Get(username, id string) (*InterviewSession, error) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	session, err := is.getLocked(username, id)
+	if err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+// RecordRun stores a code snapshot and the result of running its tests
+func (is *InterviewService) RecordRun(username, id, code string, result InterviewRunResult) (*InterviewSession, error) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	session, err := is.getLocked(username, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != InterviewActive {
+		return nil, ErrInterviewClosed
+	}
+
+	result.At = time.Now()
+	session.addSnapshot(code)
+	session.Runs = append(session.Runs, result)
+	session.UpdatedAt = result.At
+	if err := is.saveLocked(session); err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+// RecordHint counts a hint the candidate used during the session
+func (is *InterviewService) RecordHint(username, id string, hint *RevealedHint) (*InterviewSession, error) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+
+	session, err := is.getLocked(username, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != InterviewActive {
+		return nil, ErrInterviewClosed
+	}
+
+	session.HintsUsed++
+	session.HintPenalty += hint.Penalty
+	session.UpdatedAt = time.Now()
+	if err := is.saveLocked(session); err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+// Answer records the candidate's answer and returns the session with the interviewer's follow-up
+func (is *InterviewService) Answer(ctx context.Context, username, id, answer, code string) (*InterviewSession, error) {
+	if answer == "" {
+		return nil, &InterviewInputError{"answer is required"}
+	}
+	if len(answer) > maxInterviewAnswer {
+		return nil, &InterviewInputError{fmt.Sprintf("answer is too long (max %d characters)", maxInterviewAnswer)}
+	}
+
+	prepare := func(session *InterviewSession) error {
+		if len(session.Turns) >= maxInterviewTurns {
+			return &InterviewInputError{"this interview has reached its maximum length; finish it to get your evaluation"}
+		}
+		session.addSnapshot(code)
+		session.Turns = append(session.Turns, InterviewTurn{Role: RoleCandidate, Content: answer, At: time.Now()})
+		return nil
+	}
+	return is.withAI(username, id, prepare, func(session *InterviewSession) (func(*InterviewSession), error) {
+		challenge, exists := is.challengeService.GetChallenge(session.ChallengeID)
+		if !exists {
+			return nil, fmt.Errorf("challenge %d not found", session.ChallengeID)
+		}
+		reply, promptVersion, err := is.aiService.InterviewerTurn(ctx, session, ClassicChallengeContext(challenge))
+		if err != nil {
+			return nil, err
+		}
+		return func(s *InterviewSession) {
+			s.Turns = append(s.Turns, InterviewTurn{Role: RoleInterviewer, Content: reply, PromptVersion: promptVersion, At: time.Now()})
+		}, nil
+	})
+}
+
+// Finish closes the session with a rubric-based evaluation
+func (is *InterviewService) Finish(ctx context.Context, username, id, code string) (*InterviewSession, error) {
+	prepare := func(session *InterviewSession) error {
+		session.addSnapshot(code)
+		return nil
+	}
+	return is.withAI(username, id, prepare, func(session *InterviewSession) (func(*InterviewSession), error) {
+		challenge, exists := is.challengeService.GetChallenge(session.ChallengeID)
+		if !exists {
+			return nil, fmt.Errorf("challenge %d not found", session.ChallengeID)
+		}
+		evaluation, err := is.aiService.EvaluateInterview(ctx, session, ClassicChallengeContext(challenge))
+		if err != nil {
+			return nil, err
+		}
+		return func(s *InterviewSession) {
+			s.Evaluation = evaluation
+			s.Status = InterviewCompleted
+		}, nil
+	})
+}
+
+// withAI runs prepare on a copy of the session, asks the model without holding the
+// lock, and only then applies prepare and the result to the stored session, so a
+// failed AI call leaves it unchanged. One AI request per session runs at a time.
+func (is *InterviewService) withAI(
+	username, id string,
+	prepare func(*InterviewSession) error,
+	call func(*InterviewSession) (func(*InterviewSession), error),
+) (*InterviewSession, error) {
+	is.mutex.Lock()
+	session, err := is.getLocked(username, id)
+	if err == nil && session.Status != InterviewActive {
+		err = ErrInterviewClosed
+	}
+	if err == nil && session.busy {
+		err = ErrInterviewBusy
+	}
+	var pending *InterviewSession
+	if err == nil {
+		pending = session.copy()
+		err = prepare(pending)
+	}
+	if err != nil {
+		is.mutex.Unlock()
+		return nil, err
+	}
+	session.busy = true
+	is.mutex.Unlock()
+
+	apply, err := call(pending)
+
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+	session.busy = false
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prepare(session); err != nil {
+		return nil, err
+	}
+	apply(session)
+	session.UpdatedAt = time.Now()
+	if session.Status != InterviewActive && is.active[activeKey(session.Username, session.ChallengeID)] == session.ID {
+		// Another active session on the challenge may take its place
+		is.activeLoaded = false
+	}
+	if err := is.saveLocked(session); err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+// getLocked finds a session in memory or on disk; callers must hold the mutex.
+// Only active sessions are kept in memory once loaded.
+func (is *InterviewService) getLocked(username, id string) (*InterviewSession, error) {
+	if !interviewIDPattern.MatchString(id) {
+		return nil, ErrInterviewNotFound
+	}
+	is.evictLocked(time.Now())
+
+	session, ok := is.sessions[id]
+	if !ok {
+		data, err := os.ReadFile(filepath.Join(is.dir, id+".json"))
+		if err != nil {
+			return nil, ErrInterviewNotFound
+		}
+		session = &InterviewSession{}
+		if err := json.Unmarshal(data, session); err != nil {
+			return nil, fmt.Errorf("could not read interview session: %v", err)
+		}
+		if session.Status == InterviewActive {
+			is.sessions[id] = session
+		}
+	}
+
+	// Sessions started while signed in are private to that user
+	if session.Username != "" && session.Username != username {
+		return nil, ErrInterviewNotFound
+	}
+	return session, nil
+}
+
+// evictLocked drops completed and idle sessions from memory; they stay on disk. Sessions
+// with an AI request in flight are kept, since withAI still holds on to them.
+func (is *InterviewService) evictLocked(now time.Time) {
+	for id, session := range is.sessions {
+		if !session.busy && (session.Status != InterviewActive || now.Sub(session.UpdatedAt) > interviewIdleTimeout) {
+			delete(is.sessions, id)
+		}
+	}
+}
+
+// loadActiveLocked indexes the active sessions saved on disk, keeping the most recently
+// started one per user and challenge; callers must hold the mutex
+func (is *InterviewService) loadActiveLocked() error {
+	entries, err := os.ReadDir(is.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not list interview sessions: %v", err)
+	}
+	is.active = make(map[string]string)
+	started := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(is.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var session InterviewSession
+		if json.Unmarshal(data, &session) != nil || session.Status != InterviewActive || session.Username == "" {
+			continue
+		}
+		key := activeKey(session.Username, session.ChallengeID)
+		if at, ok := started[key]; !ok || session.CreatedAt.After(at) {
+			started[key] = session.CreatedAt
+			is.active[key] = session.ID
+		}
+	}
+	is.activeLoaded = true
+	return nil
+}
+
+func activeKey(username string, challengeID int) string {
+	return fmt.Sprintf("%s\x00%d", username, challengeID)
+}
+
+func (is *InterviewService) saveLocked(session *InterviewSession) error {
+	return writeJSONFile(filepath.Join(is.dir, session.ID+".json"), session)
+}
+
+// addSnapshot records code if it changed since the last snapshot
+func (s *InterviewSession) addSnapshot(code string) {
+	if code == "" || len(code) > maxInterviewCode || code == s.LatestCode() {
+		return
+	}
+	s.Snapshots = append(s.Snapshots, CodeSnapshot{Code: code, At: time.Now()})
+}
+
+// copy returns a copy that can be read without holding the service lock
+func (s *InterviewSession) copy() *InterviewSession {
+	c := *s
+	c.Snapshots = append([]CodeSnapshot{}, s.Snapshots...)
+	c.Runs = append([]InterviewRunResult{}, s.Runs...)
+	c.Turns = append([]InterviewTurn{}, s.Turns...)
+	c.busy = false
+	if s.Evaluation != nil {
+		evaluation := *s.Evaluation
+		c.Evaluation = &evaluation
+	}
+	return &c
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"web-ui/internal/models"
+)
+
+// fakeProvider answers every request with respond, which gets the 1-based call number
+type fakeProvider struct {
+	mu      sync.Mutex
+	calls   int
+	respond func(request CompletionRequest, call int) (string, error)
+}
+
+func (p *fakeProvider) Name() string  { return "fake" }
+func (p *fakeProvider) Model() string { return "fake-model" }
+
+func (p *fakeProvider) Complete(ctx context.Context, request CompletionRequest) (string, error) {
+	p.mu.Lock()
+	p.calls++
+	call := p.calls
+	p.mu.Unlock()
+	return p.respond(request, call)
+}
+
+func (p *fakeProvider) CompleteJSON(ctx context.Context, request CompletionRequest, v interface{}) error {
+	text, err := p.Complete(ctx, request)
+	if err != nil {
+		return err
+	}
+	return decodeJSONResponse(text, v)
+}
+
+func (p *fakeProvider) Stream(ctx context.Context, request CompletionRequest, onChunk func(string) error) (string, error) {
+	text, err := p.Complete(ctx, request)
+	if err == nil {
+		err = onChunk(text)
+	}
+	return text, err
+}
+
+func (p *fakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// newTestAIService returns an AI service that sends every request to provider, without retries
+func newTestAIService(provider Provider) *AIService {
+	ai := NewAIServiceWithConfig(LLMConfig{
+		Provider:          ProviderMock,
+		Timeout:           time.Second,
+		MaxRepairAttempts: 2,
+		CacheTTL:          time.Hour,
+		CacheSize:         10,
+	}, nil, nil, nil)
+	ai.provider = provider
+	return ai
+}
+
+func newTestInterviewService(t *testing.T, provider Provider) *InterviewService {
+	challenges := NewChallengeService(t.TempDir())
+	challenges.challenges[1] = &models.Challenge{ID: 1, Title: "Sum", Description: "Add two numbers"}
+	return NewInterviewService(newTestAIService(provider), challenges, t.TempDir())
+}
+
+func TestInterviewFailedAICallLeavesSessionUnchanged(t *testing.T) {
+	var fail error
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+		if fail != nil {
+			return "", fail
+		}
+		return "What is the complexity?", nil
+	}}
+	is := newTestInterviewService(t, provider)
+
+	session, err := is.Start(context.Background(), "alice", 1, "package main", HintPolicy{Mode: HintsAllowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fail = errors.New("model unavailable")
+	if _, err := is.Answer(context.Background(), "alice", session.ID, "Linear", "package main // v2"); err == nil {
+		t.Fatal("Answer succeeded with a failing provider")
+	}
+	if _, err := is.Finish(context.Background(), "alice", session.ID, "package main // v3"); err == nil {
+		t.Fatal("Finish succeeded with a failing provider")
+	}
+
+	after, err := is.Get("alice", session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Turns) != 1 || len(after.Snapshots) != 1 || after.Status != InterviewActive || after.Evaluation != nil {
+		t.Errorf("failed calls changed the session: %d turns, %d snapshots, status %s", len(after.Turns), len(after.Snapshots), after.Status)
+	}
+
+	// The failures released the session for the next request
+	fail = nil
+	after, err = is.Answer(context.Background(), "alice", session.ID, "Linear", "package main // v2")
+	if err != nil {
+		t.Fatalf("Answer after a failure: %v", err)
+	}
+	if len(after.Turns) != 3 || len(after.Snapshots) != 2 {
+		t.Errorf("got %d turns and %d snapshots, want 3 and 2", len(after.Turns), len(after.Snapshots))
+	}
+}
+
+func TestInterviewOneAIRequestAtATime(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+		if call == 2 {
+			close(started)
+			<-release
+		}
+		return "Next question?", nil
+	}}
+	is := newTestInterviewService(t, provider)
+	session, err := is.Start(context.Background(), "alice", 1, "", HintPolicy{Mode: HintsAllowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := is.Answer(context.Background(), "alice", session.ID, "first", "")
+		done <- err
+	}()
+	<-started
+	if _, err := is.Answer(context.Background(), "alice", session.ID, "second", ""); !errors.Is(err, ErrInterviewBusy) {
+		t.Errorf("concurrent Answer: got %v, want %v", err, ErrInterviewBusy)
+	}
+
+	// Busy sessions are not evicted, however long the request takes
+	is.mutex.Lock()
+	is.evictLocked(time.Now().Add(2 * interviewIdleTimeout))
+	_, kept := is.sessions[session.ID]
+	is.mutex.Unlock()
+	if !kept {
+		t.Error("evicted a session with a request in flight")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := is.Get("alice", session.ID); len(after.Turns) != 3 {
+		t.Errorf("got %d turns, want 3", len(after.Turns))
+	}
+}
+
+func TestInterviewEviction(t *testing.T) {
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+		if request.Task == TaskInterviewEvaluation {
+			return mockResponse(request), nil
+		}
+		return "Question?", nil
+	}}
+	is := newTestInterviewService(t, provider)
+	idle, err := is.Start(context.Background(), "alice", 1, "", HintPolicy{Mode: HintsAllowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished, err := is.Start(context.Background(), "alice", 1, "", HintPolicy{Mode: HintsAllowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Finish(context.Background(), "alice", finished.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	is.mutex.Lock()
+	is.sessions[idle.ID].UpdatedAt = time.Now().Add(-interviewIdleTimeout - time.Minute)
+	is.evictLocked(time.Now())
+	remaining := len(is.sessions)
+	is.mutex.Unlock()
+	if remaining != 0 {
+		t.Errorf("%d sessions left in memory, want the idle and completed ones evicted", remaining)
+	}
+
+	// Evicted sessions are still served from disk; completed ones are not cached again
+	for _, id := range []string{idle.ID, finished.ID} {
+		if _, err := is.Get("alice", id); err != nil {
+			t.Errorf("Get(%s) after eviction: %v", id, err)
+		}
+		if _, err := is.Get("bob", id); !errors.Is(err, ErrInterviewNotFound) {
+			t.Errorf("Get(%s) as another user: got %v, want %v", id, err, ErrInterviewNotFound)
+		}
+	}
+	is.mutex.Lock()
+	_, idleCached := is.sessions[idle.ID]
+	_, finishedCached := is.sessions[finished.ID]
+	is.mutex.Unlock()
+	if !idleCached || finishedCached {
+		t.Errorf("cached idle %v, finished %v; want only the active session cached", idleCached, finishedCached)
+	}
+	if _, err := is.Answer(context.Background(), "alice", finished.ID, "more", ""); !errors.Is(err, ErrInterviewClosed) {
+		t.Errorf("Answer on a completed session: got %v, want %v", err, ErrInterviewClosed)
+	}
+}
+
+func TestInterviewActiveSession(t *testing.T) {
+	provider := &fakeProvider{respond: func(request CompletionRequest, call int) (string, error) {
+		if request.Task == TaskInterviewEvaluation {
+			return mockResponse(request), nil
+		}
+		return "Question?", nil
+	}}
+	is := newTestInterviewService(t, provider)
+	first, err := is.Start(context.Background(), "alice", 1, "", HintPolicy{Mode: HintsDisabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := is.Start(context.Background(), "alice", 1, "", HintPolicy{Mode: HintsAllowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Start(context.Background(), "", 1, "", HintPolicy{Mode: HintsAllowed}); err != nil {
+		t.Fatal(err)
+	}
+
+	activeID := func(is *InterviewService, username string) string {
+		t.Helper()
+		session, err := is.ActiveSession(username, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session == nil {
+			return ""
+		}
+		return session.ID
+	}
+	if got := activeID(is, "alice"); got != second.ID {
+		t.Errorf("active session %q, want the latest %q", got, second.ID)
+	}
+	for _, username := range []string{"bob", ""} {
+		if got := activeID(is, username); got != "" {
+			t.Errorf("active session for %q: got %q, want none", username, got)
+		}
+	}
+
+	// Finishing the latest session falls back to the earlier one, also after a restart
+	if _, err := is.Finish(context.Background(), "alice", second.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeID(is, "alice"); got != first.ID {
+		t.Errorf("after finishing: active session %q, want %q", got, first.ID)
+	}
+	restarted := NewInterviewService(is.aiService, is.challengeService, filepath.Dir(is.dir))
+	if got := activeID(restarted, "alice"); got != first.ID {
+		t.Errorf("after a restart: active session %q, want %q", got, first.ID)
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMissingAPIKey is returned when a provider that needs an API key has none configured
+var ErrMissingAPIKey = errors.New("AI provider API key is not configured")
+
+// ErrAIDisabled is reported when AI features are turned off in the configuration
+var ErrAIDisabled = errors.New("AI features are turned off on this server")
+
+// CompletionRequest is a single prompt sent to an LLM provider
+type CompletionRequest struct {
+	Task        string // What the prompt is for, e.g. "code_review"; used by the mock and for accounting
+	System      string
+	Prompt      string
+	MaxTokens   int
+	Temperature float64
+	JSON        bool // Ask the provider for JSON output where it supports it
+	// JSONObject says the JSON is a single object rather than e.g. an array, so providers
+	// whose JSON mode only allows objects may enforce it
+	JSONObject bool
+	// Usage, when set, receives the token counts the provider reports for the response
+	Usage *Usage
+}
+
+// Usage is the token count of a completion
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	Estimated        bool `json:"estimated"` // The provider did not report usage; counted from text length
+}
+
+// Total returns the prompt and completion tokens together
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// reportUsage passes token counts back to the caller, if it asked for them
+func (r CompletionRequest) reportUsage(promptTokens, completionTokens int) {
+	if r.Usage != nil {
+		*r.Usage = Usage{PromptTokens: promptTokens, CompletionTokens: completionTokens}
+	}
+}
+
+// estimateUsage fills in usage from text length when the provider reported none,
+// at roughly four characters per token
+func estimateUsage(request CompletionRequest, response string) Usage {
+	if request.Usage != nil && request.Usage.Total() > 0 {
+		return *request.Usage
+	}
+	return Usage{
+		PromptTokens:     (len(request.System) + len(request.Prompt) + 3) / 4,
+		CompletionTokens: (len(response) + 3) / 4,
+		Estimated:        true,
+	}
+}
+
+// Provider is an LLM backend
+type Provider interface {
+	// Name returns the registered provider name
+	Name() string
+	// Model returns the model requests are sent to
+	Model() string
+	// Complete returns the full response text
+	Complete(ctx context.Context, request CompletionRequest) (string, error)
+	// CompleteJSON requests JSON output and decodes it into v
+	CompleteJSON(ctx context.Context, request CompletionRequest, v interface{}) error
+	// Stream calls onChunk for each piece of text as it arrives and returns the full text
+	Stream(ctx context.Context, request CompletionRequest, onChunk func(chunk string) error) (string, error)
+}
+
+// ProviderError is a non-success HTTP response from a provider
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // From the Retry-After header, if the provider sent one
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s API error (HTTP %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// ProviderFactory builds a provider from configuration
+type ProviderFactory func(config LLMConfig) (Provider, error)
+
+var (
+	providerRegistry = make(map[LLMProvider]ProviderFactory)
+	registryMutex    sync.RWMutex
+)
+
+// RegisterProvider makes a provider available under the given name
+func RegisterProvider(name LLMProvider, factory ProviderFactory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	providerRegistry[name] = factory
+}
+
+// RegisteredProviders lists the provider names that can be configured
+func RegisteredProviders() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(providerRegistry))
+	for name := range providerRegistry {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider builds the configured provider, wrapped for recording when configured
+func NewProvider(config LLMConfig) (Provider, error) {
+	registryMutex.RLock()
+	factory, ok := providerRegistry[config.Provider]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider %q (available: %s)", config.Provider, strings.Join(RegisteredProviders(), ", "))
+	}
+
+	provider, err := factory(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.RecordFile != "" {
+		provider = newRecordingProvider(provider, config.RecordFile)
+	}
+	return provider, nil
+}
+
+// decodeJSONResponse extracts the JSON value from a model response, tolerating code fences and prose
+func decodeJSONResponse(response string, v interface{}) error {
+	text, err := extractJSON(response)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(text), v)
+}
+
+// postJSON sends a JSON request and decodes a JSON response, turning HTTP errors into ProviderErrors
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body, out interface{}) error {
+	resp, err := sendJSON(ctx, client, provider, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid %s response: %v", provider, err)
+	}
+	return nil
+}
+
+// streamSSE sends a JSON request and calls onData with the payload of each server-sent event
+func streamSSE(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body interface{}, onData func(data string) error) error {
+	resp, err := sendJSON(ctx, client, provider, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			return nil
+		}
+		if err := onData(data); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// sendJSON posts body and returns the response, or a ProviderError for non-2xx statuses
+func sendJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return nil, &ProviderError{
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Message:    providerErrorMessage(data),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return resp, nil
+}
+
+// retryAfter parses a Retry-After header given in seconds, capped so a request never waits too long
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	if seconds > 30 {
+		seconds = 30
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// providerErrorMessage extracts the message from the common {"error": {"message": ...}} shape
+func providerErrorMessage(data []byte) string {
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	message := strings.TrimSpace(string(data))
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	return message
+}
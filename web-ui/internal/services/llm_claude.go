@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterProvider(ProviderClaude, func(config LLMConfig) (Provider, error) {
+		if config.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+		baseURL := config.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.anthropic.com/v1"
+		}
+		model := config.Model
+		if model == "" {
+			model = "claude-3-sonnet-20240229"
+		}
+		return &claudeProvider{
+			endpoint:   strings.TrimRight(baseURL, "/") + "/messages",
+			apiKey:     config.APIKey,
+			model:      model,
+			httpClient: &http.Client{},
+		}, nil
+	})
+}
+
+// ClaudeRequest represents the request structure for Claude API
+type ClaudeRequest struct {
+	Model       string          `json:"model"`
+	System      string          `json:"system,omitempty"`
+	Messages    []ClaudeMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens"`
+	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+type ClaudeMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ClaudeResponse represents the response from Claude API
+type ClaudeResponse struct {
+	Content []ClaudeContent `json:"content"`
+	Usage   ClaudeUsage     `json:"usage"`
+	Error   *ClaudeError    `json:"error,omitempty"`
+}
+
+// ClaudeUsage is the token usage reported for a message
+type ClaudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type ClaudeContent struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type ClaudeError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// claudeStreamEvent is the subset of Messages API stream events used for text
+type claudeStreamEvent struct {
+	Type    string          `json:"type"`
+	Delta   ClaudeContent   `json:"delta"`
+	Message *ClaudeResponse `json:"message,omitempty"` // message_start: input token usage
+	Usage   *ClaudeUsage    `json:"usage,omitempty"`   // message_delta: output token usage
+	Error   *ClaudeError    `json:"error,omitempty"`
+}
+
+// claudeProvider talks to the Anthropic Messages API
+type claudeProvider struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+func (p *claudeProvider) Name() string  { return string(ProviderClaude) }
+func (p *claudeProvider) Model() string { return p.model }
+
+func (p *claudeProvider) Complete(ctx context.Context, request CompletionRequest) (string, error) {
+	var response ClaudeResponse
+	if err := postJSON(ctx, p.httpClient, "Claude", p.endpoint, p.headers(), p.buildRequest(request, false), &response); err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("Claude API error: %s", response.Error.Message)
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from Claude")
+	}
+	request.reportUsage(response.Usage.InputTokens, response.Usage.OutputTokens)
+	return text.String(), nil
+}
+
+func (p *claudeProvider) CompleteJSON(ctx context.Context, request CompletionRequest, v interface{}) error {
+	request.JSON = true
+	text, err := p.Complete(ctx, request)
+	if err != nil {
+		return err
+	}
+	return decodeJSONResponse(text, v)
+}
+
+func (p *claudeProvider) Stream(ctx context.Context, request CompletionRequest, onChunk func(string) error) (string, error) {
+	var full strings.Builder
+	var usage ClaudeUsage
+	err := streamSSE(ctx, p.httpClient, "Claude", p.endpoint, p.headers(), p.buildRequest(request, true), func(data string) error {
+		var event claudeStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("invalid Claude stream event: %v", err)
+		}
+		if event.Error != nil {
+			return fmt.Errorf("Claude API error: %s", event.Error.Message)
+		}
+		if event.Message != nil {
+			usage.InputTokens = event.Message.Usage.InputTokens
+		}
+		if event.Usage != nil {
+			usage.OutputTokens = event.Usage.OutputTokens
+			request.reportUsage(usage.InputTokens, usage.OutputTokens)
+		}
+		if event.Type != "content_block_delta" || event.Delta.Text == "" {
+			return nil
+		}
+		full.WriteString(event.Delta.Text)
+		return onChunk(event.Delta.Text)
+	})
+	return full.String(), err
+}
+
+func (p *claudeProvider) buildRequest(request CompletionRequest, stream bool) ClaudeRequest {
+	system := request.System
+	// Claude has no JSON mode, so make sure the instruction is there
+	if request.JSON && !strings.Contains(system, "JSON") {
+		system = strings.TrimSpace(system + " Respond ONLY with strict JSON. No markdown.")
+	}
+	return ClaudeRequest{
+		Model:       p.model,
+		System:      system,
+		Messages:    []ClaudeMessage{{Role: "user", Content: request.Prompt}},
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		Stream:      stream,
+	}
+}
+
+func (p *claudeProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterProvider(ProviderGemini, func(config LLMConfig) (Provider, error) {
+		if config.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+		baseURL := config.BaseURL
+		if baseURL == "" {
+			baseURL = "https://generativelanguage.googleapis.com/v1beta/models"
+		}
+		model := config.Model
+		if model == "" {
+			model = "gemini-2.5-flash"
+		}
+		return &geminiProvider{
+			baseURL:    strings.TrimRight(baseURL, "/"),
+			apiKey:     config.APIKey,
+			model:      model,
+			httpClient: &http.Client{},
+		}, nil
+	})
+}
+
+// GeminiRequest represents the request structure for Gemini API
+type GeminiRequest struct {
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent         `json:"contents"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiContent struct {
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	ResponseMIME    string   `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse represents the response from Gemini API
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	Error         *GeminiError         `json:"error,omitempty"`
+}
+
+// GeminiUsageMetadata is the token usage reported for a response; streamed chunks carry running totals
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+// reportUsage passes the response's token usage, if any, to the request
+func (r *GeminiResponse) reportUsage(request CompletionRequest) {
+	if r.UsageMetadata != nil {
+		request.reportUsage(r.UsageMetadata.PromptTokenCount, r.UsageMetadata.CandidatesTokenCount)
+	}
+}
+
+type GeminiCandidate struct {
+	Content GeminiContent `json:"content"`
+}
+
+type GeminiError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// text concatenates the parts of the first candidate
+func (r *GeminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// geminiProvider talks to the Google Gemini generateContent API
+type geminiProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+func (p *geminiProvider) Name() string  { return string(ProviderGemini) }
+func (p *geminiProvider) Model() string { return p.model }
+
+func (p *geminiProvider) Complete(ctx context.Context, request CompletionRequest) (string, error) {
+	endpoint := fmt.Sprintf("%s/%s:generateContent?key=%s", p.baseURL, p.model, url.QueryEscape(p.apiKey))
+
+	var response GeminiResponse
+	if err := postJSON(ctx, p.httpClient, "Gemini", endpoint, nil, p.buildRequest(request), &response); err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("Gemini API error: %s", response.Error.Message)
+	}
+	text := response.text()
+	if text == "" {
+		return "", fmt.Errorf("no response from Gemini")
+	}
+	response.reportUsage(request)
+	return text, nil
+}
+
+func (p *geminiProvider) CompleteJSON(ctx context.Context, request CompletionRequest, v interface{}) error {
+	request.JSON = true
+	text, err := p.Complete(ctx, request)
+	if err != nil {
+		return err
+	}
+	return decodeJSONResponse(text, v)
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, request CompletionRequest, onChunk func(string) error) (string, error) {
+	endpoint := fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse&key=%s", p.baseURL, p.model, url.QueryEscape(p.apiKey))
+
+	var full strings.Builder
+	err := streamSSE(ctx, p.httpClient, "Gemini", endpoint, nil, p.buildRequest(request), func(data string) error {
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid Gemini stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("Gemini API error: %s", chunk.Error.Message)
+		}
+		chunk.reportUsage(request)
+		text := chunk.text()
+		if text == "" {
+			return nil
+		}
+		full.WriteString(text)
+		return onChunk(text)
+	})
+	return full.String(), err
+}
+
+func (p *geminiProvider) buildRequest(request CompletionRequest) GeminiRequest {
+	body := GeminiRequest{
+		Contents: []GeminiContent{{Parts: []GeminiPart{{Text: request.Prompt}}}},
+		GenerationConfig: &GeminiGenerationConfig{
+			Temperature:     &request.Temperature,
+			MaxOutputTokens: &request.MaxTokens,
+		},
+	}
+	if request.System != "" {
+		body.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: request.System}}}
+	}
+	if request.JSON {
+		body.GenerationConfig.ResponseMIME = "application/json"
+	}
+	return body
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	RegisterProvider(ProviderOpenAI, func(config LLMConfig) (Provider, error) {
+		if config.APIKey == "" {
+			return nil, ErrMissingAPIKey
+		}
+		return newOpenAIProvider(string(ProviderOpenAI), config, "https://api.openai.com/v1", "gpt-4o-mini", true), nil
+	})
+
+	// Any server speaking the OpenAI chat completions API, e.g. Ollama
+	// (http://localhost:11434/v1) or llama.cpp (http://localhost:8080/v1).
+	// The API key is optional and JSON mode is left to the prompt.
+	RegisterProvider(ProviderOpenAICompatible, func(config LLMConfig) (Provider, error) {
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("AI_BASE_URL is required for the %s provider", ProviderOpenAICompatible)
+		}
+		if config.Model == "" {
+			return nil, fmt.Errorf("AI_MODEL is required for the %s provider", ProviderOpenAICompatible)
+		}
+		return newOpenAIProvider(string(ProviderOpenAICompatible), config, "", "", false), nil
+	})
+}
+
+// OpenAIRequest represents the request structure for OpenAI API
+type OpenAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []Message             `json:"messages"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Temperature    float64               `json:"temperature"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+// OpenAIStreamOptions asks for token usage in the last streamed chunk
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIResponseFormat requests structured output
+type OpenAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// Message represents a message in the OpenAI chat
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAIResponse represents the response from OpenAI API
+type OpenAIResponse struct {
+	Choices []Choice     `json:"choices"`
+	Usage   *OpenAIUsage `json:"usage,omitempty"`
+	Error   *OpenAIError `json:"error,omitempty"`
+}
+
+// OpenAIUsage is the token usage reported for a response
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Choice represents a choice in OpenAI response
+type Choice struct {
+	Message Message `json:"message"`
+	Delta   Message `json:"delta"` // Set on streamed chunks
+}
+
+// OpenAIError represents an error from OpenAI API
+type OpenAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// openAIProvider talks to the OpenAI chat completions API or a compatible server
+type openAIProvider struct {
+	name       string
+	endpoint   string
+	apiKey     string
+	model      string
+	jsonMode   bool // Whether the server supports response_format json_object
+	httpClient *http.Client
+}
+
+func newOpenAIProvider(name string, config LLMConfig, defaultBaseURL, defaultModel string, jsonMode bool) *openAIProvider {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	model := config.Model
+	if model == "" {
+		model = defaultModel
+	}
+	return &openAIProvider{
+		name:       name,
+		endpoint:   strings.TrimRight(baseURL, "/") + "/chat/completions",
+		apiKey:     config.APIKey,
+		model:      model,
+		jsonMode:   jsonMode,
+		httpClient: &http.Client{},
+	}
+}
+
+func (p *openAIProvider) Name() string  { return p.name }
+func (p *openAIProvider) Model() string { return p.model }
+
+func (p *openAIProvider) Complete(ctx context.Context, request CompletionRequest) (string, error) {
+	var response OpenAIResponse
+	if err := postJSON(ctx, p.httpClient, p.name, p.endpoint, p.headers(), p.buildRequest(request, false), &response); err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("%s API error: %s", p.name, response.Error.Message)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no response from %s", p.name)
+	}
+	if response.Usage != nil {
+		request.reportUsage(response.Usage.PromptTokens, response.Usage.CompletionTokens)
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+func (p *openAIProvider) CompleteJSON(ctx context.Context, request CompletionRequest, v interface{}) error {
+	request.JSON = true
+	text, err := p.Complete(ctx, request)
+	if err != nil {
+		return err
+	}
+	return decodeJSONResponse(text, v)
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, request CompletionRequest, onChunk func(string) error) (string, error) {
+	var full strings.Builder
+	err := streamSSE(ctx, p.httpClient, p.name, p.endpoint, p.headers(), p.buildRequest(request, true), func(data string) error {
+		var chunk OpenAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid %s stream chunk: %v", p.name, err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("%s API error: %s", p.name, chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			request.reportUsage(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		full.WriteString(chunk.Choices[0].Delta.Content)
+		return onChunk(chunk.Choices[0].Delta.Content)
+	})
+	return full.String(), err
+}
+
+func (p *openAIProvider) buildRequest(request CompletionRequest, stream bool) OpenAIRequest {
+	var messages []Message
+	if request.System != "" {
+		messages = append(messages, Message{Role: "system", Content: request.System})
+	}
+	messages = append(messages, Message{Role: "user", Content: request.Prompt})
+
+	body := OpenAIRequest{
+		Model:       p.model,
+		Messages:    messages,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		Stream:      stream,
+	}
+	// Compatible servers may reject stream_options, so only the OpenAI API is asked for streamed usage
+	if stream && p.jsonMode {
+		body.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+	// json_object only allows a single object, so other JSON relies on the prompt alone
+	if request.JSON && request.JSONObject && p.jsonMode {
+		body.ResponseFormat = &OpenAIResponseFormat{Type: "json_object"}
+	}
+	return body
+}
+
+func (p *openAIProvider) headers() map[string]string {
+	if p.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
@@ -0,0 +1,40 @@
+package services
+
+import "testing"
+
+func TestOpenAIJSONMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonMode bool
+		request  CompletionRequest
+		want     bool
+	}{
+		{"text", true, CompletionRequest{Prompt: "Respond with a single JSON object"}, false},
+		{"JSON array", true, CompletionRequest{Prompt: "Return a JSON array", JSON: true}, false},
+		{"JSON object", true, CompletionRequest{Prompt: "Return the review", JSON: true, JSONObject: true}, true},
+		{"server without JSON mode", false, CompletionRequest{Prompt: "x", JSON: true, JSONObject: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &openAIProvider{name: "openai", model: "m", jsonMode: tt.jsonMode}
+			body := p.buildRequest(tt.request, false)
+			if got := body.ResponseFormat != nil; got != tt.want {
+				t.Errorf("response_format set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletionRequestFormat(t *testing.T) {
+	ai := &AIService{}
+	for format, want := range map[responseFormat][2]bool{
+		textResponse:       {false, false},
+		jsonResponse:       {true, false},
+		jsonObjectResponse: {true, true},
+	} {
+		request := ai.completionRequest(TaskCodeReview, "prompt", format)
+		if request.JSON != want[0] || request.JSONObject != want[1] {
+			t.Errorf("format %d: JSON=%v JSONObject=%v, want %v %v", format, request.JSON, request.JSONObject, want[0], want[1])
+		}
+	}
+}
@@ -3,33 +3,108 @@ package services
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
+	"web-ui/internal/markdown"
 	"web-ui/internal/models"
 )
 
 type PackageService struct {
-	httpClient   *http.Client
 	packagesPath string
-	// In-memory cache to avoid repeated GitHub API calls (no TTL; load once per process)
+	metadata     *GitHubMetadataService // Stars, releases and commits from GitHub; nil to use package.json only
+	// In-memory cache of the packages directory; replaced by LoadPackages
+	mu             sync.RWMutex
 	cachedPackages map[string]*models.Package
 }
 
-func NewPackageService() *PackageService {
+// NewPackageService creates a package service for the packages directory of the repository at repoRoot
+func NewPackageService(repoRoot string) *PackageService {
 	return &PackageService{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		packagesPath:   "../packages", // Relative to web-ui directory
+		packagesPath:   filepath.Join(repoRoot, "packages"),
 		cachedPackages: nil,
 	}
 }
 
+// SetGitHubMetadata makes packages show the stars, latest release and last commit
+// fetched in the background by metadata
+func (s *PackageService) SetGitHubMetadata(metadata *GitHubMetadataService) {
+	s.metadata = metadata
+}
+
+// GitHubRepos returns the owner/name of every package's GitHub repository
+func (s *PackageService) GitHubRepos() []string {
+	var repos []string
+	for _, pkg := range s.GetPackages() {
+		if repo := githubRepo(pkg.GitHubURL); repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// ApplyGitHubMetadata updates the loaded packages with the latest GitHub metadata. The
+// packages are copied, so pages rendering the previous ones are unaffected.
+func (s *PackageService) ApplyGitHubMetadata() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cachedPackages == nil {
+		return
+	}
+	packages := make(map[string]*models.Package, len(s.cachedPackages))
+	for name, pkg := range s.cachedPackages {
+		updated := *pkg
+		s.applyGitHubMetadata(&updated)
+		packages[name] = &updated
+	}
+	s.cachedPackages = packages
+}
+
+// applyGitHubMetadata sets the GitHub fields of pkg, keeping package.json's stars until
+// GitHub has been asked
+func (s *PackageService) applyGitHubMetadata(pkg *models.Package) {
+	if s.metadata == nil {
+		return
+	}
+	metadata, ok := s.metadata.Get(githubRepo(pkg.GitHubURL))
+	if !ok {
+		return
+	}
+	if metadata.Stars > 0 {
+		pkg.Stars = metadata.Stars
+	}
+	pkg.LatestRelease = metadata.LatestRelease
+	if !metadata.LastCommitAt.IsZero() {
+		lastCommitAt := metadata.LastCommitAt
+		pkg.LastCommitAt = &lastCommitAt
+	}
+	pkg.Outdated = VersionOutdated(pkg.Version, metadata.LatestRelease)
+}
+
+// githubRepo returns owner/name from a GitHub repository URL, or "" if it is not one
+func githubRepo(githubURL string) string {
+	path, ok := strings.CutPrefix(strings.TrimSuffix(githubURL, "/"), "https://github.com/")
+	if !ok {
+		return ""
+	}
+	parts := strings.Split(strings.TrimSuffix(path, ".git"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// ChallengeDir returns the directory of a package challenge
+func (s *PackageService) ChallengeDir(packageName, challengeID string) string {
+	return filepath.Join(s.packagesPath, packageName, challengeID)
+}
+
 type PackageMetadata struct {
 	Name             string   `json:"name"`
 	DisplayName      string   `json:"display_name"`
@@ -47,29 +122,45 @@ type PackageMetadata struct {
 	RealWorldUsage   []string `json:"real_world_usage"`
 }
 
+// LoadPackages loads all packages from the filesystem, replacing those loaded before
 func (s *PackageService) LoadPackages() error {
-	// This method is called to ensure packages are loaded
-	// Load packages and count them for logging
-	packages := s.GetPackages()
-	fmt.Printf("Loaded %d packages with real-time GitHub stars\n", len(packages))
+	packages := s.readPackages()
+	s.mu.Lock()
+	s.cachedPackages = packages
+	s.mu.Unlock()
+	slog.Info("Loaded packages", "count", len(packages))
 	return nil
 }
 
+// GetPackages returns all packages, loading them on first use. The map is replaced, not
+// modified, on reload.
 func (s *PackageService) GetPackages() map[string]*models.Package {
-	// Serve from cache if already populated
-	if s.cachedPackages != nil {
-		return s.cachedPackages
+	s.mu.RLock()
+	packages := s.cachedPackages
+	s.mu.RUnlock()
+	if packages != nil {
+		return packages
+	}
+
+	packages = s.readPackages()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cachedPackages == nil {
+		s.cachedPackages = packages
 	}
+	return s.cachedPackages
+}
 
+// readPackages loads every package directory; an unreadable packages directory gives an
+// empty map, so it is not retried on every request
+func (s *PackageService) readPackages() map[string]*models.Package {
 	packages := make(map[string]*models.Package)
 
 	// Read packages directory
 	entries, err := os.ReadDir(s.packagesPath)
 	if err != nil {
-		fmt.Printf("Error reading packages directory: %v\n", err)
-		// Populate cache (empty) to prevent repeated attempts this run
-		s.cachedPackages = packages
-		return s.cachedPackages
+		slog.Error("Could not read packages directory", "error", err)
+		return packages
 	}
 
 	for _, entry := range entries {
@@ -80,44 +171,28 @@ func (s *PackageService) GetPackages() map[string]*models.Package {
 			}
 		}
 	}
-
-	// Populate cache once per process lifetime
-	s.cachedPackages = packages
-	return s.cachedPackages
+	return packages
 }
 
 func (s *PackageService) loadPackage(packagePath, packageName string) *models.Package {
-	// Ensure httpClient is initialized
-	if s.httpClient == nil {
-		s.httpClient = &http.Client{
-			Timeout: 30 * time.Second,
-		}
-	}
-
 	// Load package.json
 	metadataPath := filepath.Join(packagePath, "package.json")
 	metadataBytes, err := os.ReadFile(metadataPath)
 	if err != nil {
-		fmt.Printf("Error reading package.json for %s: %v\n", packageName, err)
+		slog.Error("Could not read package.json", "package", packageName, "error", err)
 		return nil
 	}
 
 	var metadata PackageMetadata
 	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
-		fmt.Printf("Error parsing package.json for %s: %v\n", packageName, err)
+		slog.Error("Could not parse package.json", "package", packageName, "error", err)
 		return nil
 	}
 
-	// Fetch real-time GitHub stars
-	stars := s.fetchGitHubStars(metadata.GitHubURL)
-	if stars > 0 {
-		metadata.Stars = stars
-	}
-
 	// Load challenge details dynamically
 	challengeDetails := s.loadChallengeDetails(packagePath, metadata.LearningPath)
 
-	return &models.Package{
+	pkg := &models.Package{
 		Name:             packageName,
 		DisplayName:      metadata.DisplayName,
 		Description:      metadata.Description,
@@ -134,6 +209,8 @@ func (s *PackageService) loadPackage(packagePath, packageName string) *models.Pa
 		RealWorldUsage:   metadata.RealWorldUsage,
 		ChallengeDetails: challengeDetails,
 	}
+	s.applyGitHubMetadata(pkg)
+	return pkg
 }
 
 // loadChallengeDetails dynamically loads metadata for each challenge in the learning path
@@ -366,8 +443,12 @@ func (s *PackageService) loadChallenge(challengePath, challengeName string) *mod
 	// Determine difficulty - try to load from metadata first, then infer from challenge name
 	difficulty := "Beginner" // default fallback
 
-	// Try to load metadata.json for difficulty
+	// Try to load metadata.json for difficulty and AI prompt additions
 	metadata := s.loadChallengeMetadata(challengePath)
+	var aiPrompts map[string]string
+	if metadata != nil {
+		aiPrompts = metadata.AIPrompts
+	}
 	if metadata != nil && metadata.Difficulty != "" {
 		difficulty = metadata.Difficulty
 	} else {
@@ -389,6 +470,10 @@ func (s *PackageService) loadChallenge(challengePath, challengeName string) *mod
 		TestFile:          testFile,
 		Hints:             hints,
 		LearningMaterials: learningMaterials, // Use learning.md for learning materials tab
+		AIPrompts:         aiPrompts,
+		Dir:               challengePath,
+		DescriptionHTML:   markdown.Render(readmeContent),
+		LearningHTML:      markdown.Render(learningMaterials),
 	}
 }
 
@@ -400,66 +485,6 @@ func (s *PackageService) readFileContent(filePath string) string {
 	return string(content)
 }
 
-func (s *PackageService) fetchGitHubStars(githubURL string) int {
-	// Add more robust nil checking
-	if s == nil || s.httpClient == nil || githubURL == "" {
-		return 0
-	}
-
-	// Extract owner/repo from GitHub URL
-	parts := strings.Split(githubURL, "/")
-	if len(parts) < 2 {
-		return 0
-	}
-
-	repo := fmt.Sprintf("%s/%s", parts[len(parts)-2], parts[len(parts)-1])
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s", repo)
-
-	// Build request with optional auth and proper headers
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return 0
-	}
-
-	// Required header for GitHub API
-	req.Header.Set("User-Agent", "go-interview-practice-web-ui/1.0")
-
-	// Optional GitHub token for higher rate limits
-	token := os.Getenv("GITHUB_TOKEN")
-	if token == "" {
-		token = os.Getenv("GH_TOKEN")
-	}
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return 0
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 403 {
-		// Most likely rate limited or missing/invalid auth
-		fmt.Printf("GitHub API returned status 403 for %s\n", githubURL)
-		return 0
-	}
-
-	if resp.StatusCode != 200 {
-		return 0
-	}
-
-	var repoData struct {
-		StargazersCount int `json:"stargazers_count"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&repoData); err != nil {
-		return 0
-	}
-
-	return repoData.StargazersCount
-}
-
 func (s *PackageService) GetPackage(packageID string) (*models.Package, error) {
 	packages := s.GetPackages()
 	if pkg, exists := packages[packageID]; exists {
@@ -469,6 +494,10 @@ func (s *PackageService) GetPackage(packageID string) (*models.Package, error) {
 }
 
 func (s *PackageService) GetChallenge(packageID, challengeID string) *models.PackageChallenge {
+	if !ValidPackageName(packageID) || !ValidPackageChallengeID(challengeID) {
+		return nil
+	}
+
 	// Load challenge directly from filesystem
 	packagePath := filepath.Join(s.packagesPath, packageID)
 	challengePath := filepath.Join(packagePath, challengeID)
@@ -482,6 +511,9 @@ func (s *PackageService) GetChallenge(packageID, challengeID string) *models.Pac
 }
 
 func (s *PackageService) GetPackageChallenges(packageID string) (map[string]*models.PackageChallenge, error) {
+	if !ValidPackageName(packageID) {
+		return nil, fmt.Errorf("package %s not found", packageID)
+	}
 	packagePath := filepath.Join(s.packagesPath, packageID)
 
 	// Check if package directory exists
@@ -502,6 +534,9 @@ func (s *PackageService) GetPackageChallenges(packageID string) (map[string]*mod
 }
 
 func (s *PackageService) GetPackageChallenge(packageID, challengeID string) (*models.PackageChallenge, error) {
+	if !ValidPackageName(packageID) || !ValidPackageChallengeID(challengeID) {
+		return nil, fmt.Errorf("challenge %s not found in package %s", challengeID, packageID)
+	}
 	// Load challenge directly from filesystem
 	packagePath := filepath.Join(s.packagesPath, packageID)
 	challengePath := filepath.Join(packagePath, challengeID)
@@ -0,0 +1,30 @@
+package services
+
+import "regexp"
+
+// Names that become path components of files the server reads and writes. They are
+// checked against these patterns before use so that "..", slashes and the like can
+// never reach the filesystem.
+var (
+	// usernamePattern matches GitHub logins: letters, digits and hyphens, up to 39
+	// characters, not starting or ending with a hyphen
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,37}[A-Za-z0-9])?$`)
+	// slugPattern matches package names such as "gin" and package challenge IDs such
+	// as "challenge-1-basic-routing"
+	slugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9_-]{0,62}[a-z0-9])?$`)
+)
+
+// ValidUsername reports whether name is safe to use as a submissions directory
+func ValidUsername(name string) bool {
+	return usernamePattern.MatchString(name)
+}
+
+// ValidPackageName reports whether name is safe to use as a package directory
+func ValidPackageName(name string) bool {
+	return slugPattern.MatchString(name)
+}
+
+// ValidPackageChallengeID reports whether id is safe to use as a package challenge directory
+func ValidPackageChallengeID(id string) bool {
+	return slugPattern.MatchString(id)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// killProcessGroup is a no-op where process groups are not available; canceling the
+// context kills only the go command
+func killProcessGroup(cmd *exec.Cmd) {}
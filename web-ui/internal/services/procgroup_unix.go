@@ -0,0 +1,17 @@
+//go:build unix
+
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup runs cmd in its own process group and makes canceling its context kill
+// the whole group, so the test binary that `go test` starts does not outlive it
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
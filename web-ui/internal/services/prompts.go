@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// defaultPrompts are the built-in prompt templates, one <task>.tmpl per AI task
+//
+//go:embed prompts/*.tmpl
+var defaultPrompts embed.FS
+
+// promptTasks are the AI tasks that are prompted from a template
+var promptTasks = []string{
+	TaskCodeReview,
+	TaskInterviewerQuestions,
+	TaskCodeHint,
+	TaskInterviewTurn,
+	TaskInterviewEvaluation,
+	TaskAdversarialCases,
+}
+
+// promptVersionPattern matches the version comment a template starts with,
+// e.g. {{/* version: v2 */}}
+var promptVersionPattern = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*version:\s*(\S+)\s*\*/\s*-?\}\}`)
+
+// hintStyles describe how much each hint level gives away
+var hintStyles = map[int]string{
+	1: "a subtle nudge in the right direction",
+	2: "a more direct hint about the approach",
+	3: "a specific suggestion about implementation",
+	4: "a detailed explanation with partial code example",
+}
+
+// PromptData is what the prompt templates are rendered with. Fields that do not
+// apply to a task are left empty.
+type PromptData struct {
+	Challenge        *ChallengeContext
+	ChallengeSection string // The challenge as the model sees it; package challenges include requirements and tests
+	ReviewCriteria   string // Framework-specific review points, empty for classic challenges
+	Instructions     string // The challenge's own additions to this prompt, from ai_prompts in metadata.json
+	Code             string
+	NumberedCode     string // Code with each line prefixed by its number, for reviews
+	Context          string // What the candidate asked the reviewer to focus on
+	Facts            string // Test, vet and coverage results for reviews
+	UserProgress     string
+	HintLevel        int           // 1-4
+	HintStyle        string        // How much the hint at HintLevel gives away
+	FailingTest      string        // The first problem found by running the code, for hints
+	HintsShown       []HintSection // Authored hints the user has already seen
+	Opening          string        // How the interviewer should open this turn
+	InterviewState   string        // The candidate's latest code and test runs
+	Transcript       string
+	Rubric           []RubricCriterion
+	Recommendations  []string
+	AllowedImports   []string // Packages adversarial cases may import
+	MaxCases         int      // How many adversarial cases to propose at most
+}
+
+// promptTemplate is a loaded prompt for one task
+type promptTemplate struct {
+	version  string
+	template *template.Template
+}
+
+// PromptService renders the prompts sent to the AI provider. Templates are embedded
+// and can be replaced per deployment with files in a prompts directory.
+type PromptService struct {
+	templates map[string]*promptTemplate
+	defaults  map[string]*promptTemplate
+}
+
+// NewPromptService loads the built-in prompts and any overrides from dir, if set
+func NewPromptService(dir string) *PromptService {
+	service := &PromptService{
+		templates: make(map[string]*promptTemplate),
+		defaults:  make(map[string]*promptTemplate),
+	}
+
+	for _, task := range promptTasks {
+		text, err := defaultPrompts.ReadFile("prompts/" + task + ".tmpl")
+		if err != nil {
+			panic(fmt.Sprintf("missing built-in prompt for %s: %v", task, err))
+		}
+		builtin, err := parsePromptTemplate(task, string(text))
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in prompt for %s: %v", task, err))
+		}
+		service.defaults[task] = builtin
+		service.templates[task] = builtin
+
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, task+".tmpl")
+		text, err = os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			slog.Warn("Could not read prompt, using the built-in prompt", "path", path, "error", err)
+			continue
+		}
+		override, err := parsePromptTemplate(task, string(text))
+		if err != nil {
+			slog.Warn("Invalid prompt, using the built-in prompt", "path", path, "error", err)
+			continue
+		}
+		service.templates[task] = override
+		slog.Info("AI prompt override", "task", task, "version", override.version, "path", path)
+	}
+	return service
+}
+
+// parsePromptTemplate parses a template and checks that it renders. Templates without
+// a version comment are versioned by a hash of their text.
+func parsePromptTemplate(task, text string) (*promptTemplate, error) {
+	tmpl, err := template.New(task).Funcs(template.FuncMap{
+		"truncate": func(max int, text string) string { return truncateText(text, max) },
+		"join":     func(sep string, items []string) string { return strings.Join(items, sep) },
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, &PromptData{Challenge: &ChallengeContext{}}); err != nil {
+		return nil, err
+	}
+
+	version := ""
+	if match := promptVersionPattern.FindStringSubmatch(text); match != nil {
+		version = match[1]
+	} else {
+		sum := sha256.Sum256([]byte(text))
+		version = "sha-" + hex.EncodeToString(sum[:4])
+	}
+	return &promptTemplate{version: version, template: tmpl}, nil
+}
+
+// Render builds the prompt for task, adding the challenge's own instructions for it.
+// It returns the prompt and the version of the template used.
+func (s *PromptService) Render(task string, data *PromptData) (string, string) {
+	if data.Challenge != nil {
+		data.Instructions = strings.TrimSpace(data.Challenge.PromptAdditions[task])
+	}
+
+	prompt := s.templates[task]
+	var text bytes.Buffer
+	if err := prompt.template.Execute(&text, data); err != nil {
+		// Overrides are checked at startup, but fall back rather than fail a request
+		slog.Warn("AI prompt failed, using the built-in prompt", "task", task, "version", prompt.version, "error", err)
+		prompt = s.defaults[task]
+		text.Reset()
+		prompt.template.Execute(&text, data)
+	}
+	return strings.TrimSpace(text.String()), prompt.version
+}
+
+// Versions returns the version of every prompt, keyed by task
+func (s *PromptService) Versions() map[string]string {
+	versions := make(map[string]string, len(s.templates))
+	for task, prompt := range s.templates {
+		versions[task] = prompt.version
+	}
+	return versions
+}
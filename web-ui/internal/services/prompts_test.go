@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParsePromptTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		version string // A regexp, as unversioned templates get a hash
+		err     bool
+	}{
+		{"version comment", "{{/* version: v3 */}}\nReview {{.Code}}", "^v3$", false},
+		{"trimmed version comment", "  {{- /* version: 2024-06-01 */ -}}\nReview", "^2024-06-01$", false},
+		{"no version comment", "Review {{.Code}}", "^sha-[0-9a-f]{8}$", false},
+		{"version comment after text", "Review\n{{/* version: v3 */}}", "^sha-[0-9a-f]{8}$", false},
+		{"syntax error", "Review {{.Code", "", true},
+		{"unknown field", "Review {{.Source}}", "", true},
+		{"unknown function", "{{shout .Code}}", "", true},
+		{"functions", `{{truncate 10 .Code}} {{join ", " .AllowedImports}}`, "^sha-", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := parsePromptTemplate(TaskCodeReview, tt.text)
+			if tt.err {
+				if err == nil {
+					t.Errorf("parsed with version %s, want an error", prompt.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !regexp.MustCompile(tt.version).MatchString(prompt.version) {
+				t.Errorf("got version %q, want %s", prompt.version, tt.version)
+			}
+		})
+	}
+
+	// Hash versions follow the text
+	a, _ := parsePromptTemplate(TaskCodeReview, "Review {{.Code}}")
+	b, _ := parsePromptTemplate(TaskCodeReview, "Review {{.Code}}")
+	c, _ := parsePromptTemplate(TaskCodeReview, "Review this: {{.Code}}")
+	if a.version != b.version || a.version == c.version {
+		t.Errorf("got versions %s, %s and %s; want the same text to share a version", a.version, b.version, c.version)
+	}
+}
+
+// writePromptOverrides creates a prompts directory with the given task templates
+func writePromptOverrides(t *testing.T, templates map[string]string) string {
+	dir := t.TempDir()
+	for task, text := range templates {
+		writeFile(t, filepath.Join(dir, task+".tmpl"), text)
+	}
+	return dir
+}
+
+func TestPromptOverrides(t *testing.T) {
+	builtin := NewPromptService("").Versions()
+	for _, task := range promptTasks {
+		if builtin[task] == "" {
+			t.Errorf("built-in prompt for %s has no version", task)
+		}
+	}
+
+	dir := writePromptOverrides(t, map[string]string{
+		TaskCodeHint:      "{{/* version: team-1 */}}\nHint for {{.Code}} at level {{.HintLevel}}",
+		TaskInterviewTurn: "{{.Nope}}",            // Invalid, so the built-in prompt stays
+		"not_a_task":      "{{/* version: x */}}", // Ignored
+	})
+	if err := os.Mkdir(filepath.Join(dir, TaskCodeReview+".tmpl"), 0755); err != nil { // Unreadable
+		t.Fatal(err)
+	}
+	prompts := NewPromptService(dir)
+
+	versions := prompts.Versions()
+	for _, task := range promptTasks {
+		want := builtin[task]
+		if task == TaskCodeHint {
+			want = "team-1"
+		}
+		if versions[task] != want {
+			t.Errorf("%s: got version %s, want %s", task, versions[task], want)
+		}
+	}
+	if _, ok := versions["not_a_task"]; ok {
+		t.Error("loaded a template for an unknown task")
+	}
+
+	text, version := prompts.Render(TaskCodeHint, &PromptData{Code: "x := 1", HintLevel: 2})
+	if text != "Hint for x := 1 at level 2" || version != "team-1" {
+		t.Errorf("got %q version %s", text, version)
+	}
+}
+
+func TestRenderFallsBackToBuiltin(t *testing.T) {
+	builtin := NewPromptService("").Versions()[TaskCodeHint]
+
+	// Renders with the empty data checked at startup, fails with a real request
+	dir := writePromptOverrides(t, map[string]string{
+		TaskCodeHint: "{{/* version: broken */}}\n{{if .Code}}{{index .HintsShown 3}}{{end}}",
+	})
+	prompts := NewPromptService(dir)
+	if version := prompts.Versions()[TaskCodeHint]; version != "broken" {
+		t.Fatalf("override not loaded: version %s", version)
+	}
+	text, version := prompts.Render(TaskCodeHint, &PromptData{Challenge: &ChallengeContext{}, Code: "x := 1", HintLevel: 1})
+	if version != builtin || !strings.Contains(text, "x := 1") {
+		t.Errorf("got version %s, want the built-in %s and its text", version, builtin)
+	}
+
+	// Usage is charged to the prompt that was actually sent
+	ai := newTestAIService(&fakeProvider{respond: func(request CompletionRequest, call int) (string, error) { return "Try a map.", nil }})
+	ai.prompts = prompts
+	ai.usageService = NewAIUsageService(t.TempDir(), AIQuota{})
+	_, hintVersion, err := ai.GetCodeHint(context.Background(), "x := 1", &ChallengeContext{Title: "Sum"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hintVersion != builtin {
+		t.Errorf("hint reports version %s, want %s", hintVersion, builtin)
+	}
+	day := ai.usageService.Report(1).Days[0]
+	if len(day.Prompts) != 1 || day.Prompts[0].Name != TaskCodeHint+"@"+builtin {
+		t.Errorf("usage recorded under %+v, want %s@%s", day.Prompts, TaskCodeHint, builtin)
+	}
+}
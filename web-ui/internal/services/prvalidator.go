@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"web-ui/internal/models"
+)
+
+var (
+	challengeSubmissionPattern = regexp.MustCompile(`^challenge-(\d+)/submissions/([^/]+)/solution-template\.go$`)
+	packageSubmissionPattern   = regexp.MustCompile(`^packages/([^/]+)/(challenge-[^/]+)/submissions/([^/]+)/solution\.go$`)
+)
+
+// maxReportOutputLines caps the test output quoted in the report for failing submissions
+const maxReportOutputLines = 60
+
+// ChangedFile is a single entry of the diff between base and head
+type ChangedFile struct {
+	Status string // A, M, D, ...
+	Path   string
+}
+
+// PRSubmission is a submission file added or modified by a pull request
+type PRSubmission struct {
+	Path             string
+	Username         string
+	ChallengeID      int    // Set for classic challenges
+	PackageName      string // Set for package challenges, with PackageChallenge
+	PackageChallenge string
+	Passed           int
+	Total            int
+	Success          bool
+	ExecutionMs      int64
+	Output           string
+	Error            string
+}
+
+// Name returns the challenge reference used in reports, e.g. "challenge-5" or "gin/challenge-1-basic-routing"
+func (s *PRSubmission) Name() string {
+	if s.PackageName != "" {
+		return s.PackageName + "/" + s.PackageChallenge
+	}
+	return fmt.Sprintf("challenge-%d", s.ChallengeID)
+}
+
+// ScoreboardPath returns the repository-relative scoreboard for the submission's challenge
+func (s *PRSubmission) ScoreboardPath() string {
+	if s.PackageName != "" {
+		return filepath.Join("packages", s.PackageName, s.PackageChallenge, "SCOREBOARD.md")
+	}
+	return filepath.Join(fmt.Sprintf("challenge-%d", s.ChallengeID), "SCOREBOARD.md")
+}
+
+// PathViolation is a changed file the author is not allowed to touch
+type PathViolation struct {
+	Path   string
+	Reason string
+}
+
+// PRValidationReport is the outcome of validating a pull request
+type PRValidationReport struct {
+	Author             string
+	Base               string
+	Head               string
+	Violations         []PathViolation
+	Submissions        []*PRSubmission
+	UpdatedScoreboards []string
+}
+
+// Passed reports whether the pull request only adds passing submissions by its author
+func (r *PRValidationReport) Passed() bool {
+	if len(r.Violations) > 0 || len(r.Submissions) == 0 {
+		return false
+	}
+	for _, submission := range r.Submissions {
+		if !submission.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// PRValidator checks submission pull requests against a local clone
+type PRValidator struct {
+	repoRoot         string
+	executionService *ExecutionService
+}
+
+// NewPRValidator creates a validator for the repository containing repoPath
+func NewPRValidator(repoPath string, executionService *ExecutionService) (*PRValidator, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository", repoPath)
+	}
+	return &PRValidator{
+		repoRoot:         strings.TrimSpace(string(output)),
+		executionService: executionService,
+	}, nil
+}
+
+// Validate checks the diff between base and head and judges every submission it adds
+func (v *PRValidator) Validate(base, head, author string) (*PRValidationReport, error) {
+	baseSHA, err := v.git("rev-parse", "--verify", base+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown base revision %q", base)
+	}
+	headSHA, err := v.git("rev-parse", "--verify", head+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown head revision %q", head)
+	}
+
+	files, err := v.ChangedFiles(baseSHA, headSHA)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PRValidationReport{
+		Author: author,
+		Base:   baseSHA,
+		Head:   headSHA,
+	}
+
+	for _, file := range files {
+		submission, reason := v.classify(file, author)
+		if reason != "" {
+			report.Violations = append(report.Violations, PathViolation{Path: file.Path, Reason: reason})
+			continue
+		}
+		report.Submissions = append(report.Submissions, submission)
+	}
+
+	for _, submission := range report.Submissions {
+		v.judge(submission, baseSHA, headSHA)
+	}
+
+	return report, nil
+}
+
+// FetchPullRequest fetches a GitHub pull request's head and its base branch from origin,
+// so that both can be validated locally
+func (v *PRValidator) FetchPullRequest(number int, baseRef string) error {
+	if number < 1 || baseRef == "" || strings.HasPrefix(baseRef, "-") || strings.ContainsAny(baseRef, ": \t\n") {
+		return fmt.Errorf("invalid pull request %d onto %q", number, baseRef)
+	}
+	_, err := v.git("fetch", "--no-tags", "origin",
+		fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", baseRef, baseRef),
+		fmt.Sprintf("+refs/pull/%d/head:refs/remotes/origin/pull/%d", number, number))
+	if err != nil {
+		return fmt.Errorf("git fetch of pull request %d failed: %v", number, err)
+	}
+	return nil
+}
+
+// ChangedFiles lists the files changed on head since it diverged from base
+func (v *PRValidator) ChangedFiles(base, head string) ([]ChangedFile, error) {
+	// --no-renames reports a rename as a delete plus an add, so both paths are checked
+	output, err := v.git("diff", "--name-status", "--no-renames", base+"..."+head)
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %v", err)
+	}
+
+	var files []ChangedFile
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		files = append(files, ChangedFile{Status: fields[0], Path: fields[1]})
+	}
+	return files, nil
+}
+
+// classify maps a changed file to a submission, or returns why it is not allowed
+func (v *PRValidator) classify(file ChangedFile, author string) (*PRSubmission, string) {
+	submission := &PRSubmission{Path: file.Path}
+
+	if match := challengeSubmissionPattern.FindStringSubmatch(file.Path); match != nil {
+		submission.ChallengeID, _ = strconv.Atoi(match[1])
+		submission.Username = match[2]
+	} else if match := packageSubmissionPattern.FindStringSubmatch(file.Path); match != nil {
+		submission.PackageName = match[1]
+		submission.PackageChallenge = match[2]
+		submission.Username = match[3]
+	} else {
+		return nil, "not a submission file"
+	}
+
+	if !strings.EqualFold(submission.Username, author) {
+		return nil, fmt.Sprintf("submission belongs to %s, not %s", submission.Username, author)
+	}
+	if file.Status != "A" && file.Status != "M" {
+		return nil, fmt.Sprintf("submissions may only be added or modified (status %s)", file.Status)
+	}
+	return submission, ""
+}
+
+// judge runs the submission from head against the test file from base,
+// so a pull request cannot weaken the tests it is judged by
+func (v *PRValidator) judge(submission *PRSubmission, base, head string) {
+	challengeDir := filepath.Dir(filepath.Dir(filepath.Dir(submission.Path)))
+	testFile, err := v.git("show", base+":"+challengeDir+"/solution-template_test.go")
+	if err != nil {
+		submission.Error = fmt.Sprintf("no tests found for %s on the base branch", submission.Name())
+		return
+	}
+	code, err := v.git("show", head+":"+submission.Path)
+	if err != nil {
+		submission.Error = fmt.Sprintf("could not read %s: %v", submission.Path, err)
+		return
+	}
+
+	challenge := &models.Challenge{
+		ID:       submission.ChallengeID, // Package challenges don't use numeric IDs
+		Title:    submission.Name(),
+		TestFile: testFile,
+	}
+	result := v.executionService.RunCode(context.Background(), code, challenge)
+
+	submission.Output = result.Output
+	submission.ExecutionMs = result.ExecutionMs
+	submission.Passed, submission.Total = CountTestResults(result.Output)
+	submission.Success = result.Passed && submission.Total > 0 && submission.Passed == submission.Total
+}
+
+// UpdateScoreboards writes the judged results into the affected SCOREBOARD.md files
+func (v *PRValidator) UpdateScoreboards(report *PRValidationReport) error {
+	for _, submission := range report.Submissions {
+		if submission.Error != "" {
+			continue
+		}
+		path := submission.ScoreboardPath()
+		if err := UpdateScoreboardRow(filepath.Join(v.repoRoot, path), submission.scoreboardTitle(), submission.Username, submission.Passed, submission.Total); err != nil {
+			return fmt.Errorf("failed to update %s: %v", path, err)
+		}
+		report.UpdatedScoreboards = append(report.UpdatedScoreboards, path)
+	}
+	return nil
+}
+
+// scoreboardTitle is the heading for a new scoreboard; package scoreboards leave a blank line after it
+func (s *PRSubmission) scoreboardTitle() string {
+	if s.PackageName != "" {
+		return fmt.Sprintf("# Scoreboard for %s %s\n", s.PackageName, s.PackageChallenge)
+	}
+	return fmt.Sprintf("# Scoreboard for challenge-%d", s.ChallengeID)
+}
+
+// git runs a git command in the repository root and returns its trimmed output
+func (v *PRValidator) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = v.repoRoot
+	output, err := cmd.Output()
+	return strings.TrimRight(string(output), "\n"), err
+}
+
+// UpdateScoreboardRow inserts or replaces a user's row in a SCOREBOARD.md file, keeping
+// rows sorted by passed tests (highest first) and then by username
+func UpdateScoreboardRow(path, title, username string, passed, total int) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var header, rows []string
+	if len(content) == 0 {
+		header = []string{title, "| Username   | Passed Tests | Total Tests |", "|------------|--------------|-------------|"}
+	} else {
+		for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+			if !isScoreboardRow(line) {
+				header = append(header, line)
+				continue
+			}
+			if strings.EqualFold(scoreboardCell(line, 1), username) {
+				continue // Replaced below
+			}
+			rows = append(rows, line)
+		}
+	}
+
+	rows = append(rows, fmt.Sprintf("| %s | %d | %d |", username, passed, total))
+	sort.SliceStable(rows, func(i, j int) bool {
+		pi, _ := strconv.Atoi(scoreboardCell(rows[i], 2))
+		pj, _ := strconv.Atoi(scoreboardCell(rows[j], 2))
+		if pi != pj {
+			return pi > pj
+		}
+		return rows[i] < rows[j]
+	})
+
+	data := strings.Join(append(header, rows...), "\n") + "\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(data), 0644)
+}
+
+// isScoreboardRow reports whether a line is a data row rather than the title, header or separator
+func isScoreboardRow(line string) bool {
+	if !strings.HasPrefix(strings.TrimSpace(line), "|") {
+		return false
+	}
+	first := scoreboardCell(line, 1)
+	return first != "" && first != "Username" && !strings.HasPrefix(first, "---")
+}
+
+// scoreboardCell returns the trimmed n-th cell (1-based) of a Markdown table row
+func scoreboardCell(line string, n int) string {
+	cells := strings.Split(line, "|")
+	if n >= len(cells) {
+		return ""
+	}
+	return strings.TrimSpace(cells[n])
+}
+
+// Markdown renders the report as a pull request comment
+func (r *PRValidationReport) Markdown() string {
+	var b strings.Builder
+
+	b.WriteString("## 🤖 Submission Validation\n\n")
+	fmt.Fprintf(&b, "**Author:** @%s · **Base:** `%s` · **Head:** `%s`\n\n", r.Author, shortSHA(r.Base), shortSHA(r.Head))
+
+	if r.Passed() {
+		b.WriteString("### ✅ Ready to merge\n\n")
+	} else {
+		b.WriteString("### ❌ Changes required\n\n")
+	}
+
+	b.WriteString("#### Changed paths\n\n")
+	if len(r.Violations) == 0 {
+		fmt.Fprintf(&b, "All %d changed file(s) are @%s's own submissions.\n\n", len(r.Submissions), r.Author)
+	} else {
+		b.WriteString("Pull requests may only add or update your own `challenge-N/submissions/<user>/solution-template.go` or `packages/<pkg>/<challenge>/submissions/<user>/solution.go`.\n\n")
+		b.WriteString("| Path | Problem |\n|------|---------|\n")
+		for _, violation := range r.Violations {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", violation.Path, violation.Reason)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("#### Test results\n\n")
+	if len(r.Submissions) == 0 {
+		b.WriteString("No submissions found in this pull request.\n\n")
+	} else {
+		b.WriteString("| Challenge | Result | Passed | Total | Time |\n|-----------|--------|--------|-------|------|\n")
+		for _, submission := range r.Submissions {
+			status := "✅ Passed"
+			if submission.Error != "" {
+				status = "⚠️ " + submission.Error
+			} else if !submission.Success {
+				status = "❌ Failed"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %dms |\n", submission.Name(), status, submission.Passed, submission.Total, submission.ExecutionMs)
+		}
+		b.WriteString("\n")
+
+		for _, submission := range r.Submissions {
+			if submission.Success || submission.Output == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "<details>\n<summary>Test output for %s</summary>\n\n```text\n%s\n```\n\n</details>\n\n", submission.Name(), tailLines(submission.Output, maxReportOutputLines))
+		}
+	}
+
+	if len(r.UpdatedScoreboards) > 0 {
+		b.WriteString("#### Scoreboards updated\n\n")
+		for _, path := range r.UpdatedScoreboards {
+			fmt.Fprintf(&b, "- `%s`\n", path)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// tailLines keeps the last n lines of output, where go test reports failures
+func tailLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("... (%d lines omitted)\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	v := &PRValidator{}
+	tests := []struct {
+		name   string
+		file   ChangedFile
+		author string
+		want   *PRSubmission
+		reason string // Prefix of the violation
+	}{
+		{"added challenge submission", ChangedFile{"A", "challenge-3/submissions/alice/solution-template.go"}, "alice",
+			&PRSubmission{Path: "challenge-3/submissions/alice/solution-template.go", ChallengeID: 3, Username: "alice"}, ""},
+		{"modified package submission", ChangedFile{"M", "packages/gin/challenge-1-basic-routing/submissions/alice/solution.go"}, "alice",
+			&PRSubmission{Path: "packages/gin/challenge-1-basic-routing/submissions/alice/solution.go", PackageName: "gin", PackageChallenge: "challenge-1-basic-routing", Username: "alice"}, ""},
+		{"author in another case", ChangedFile{"A", "challenge-3/submissions/Alice/solution-template.go"}, "aLICE",
+			&PRSubmission{Path: "challenge-3/submissions/Alice/solution-template.go", ChallengeID: 3, Username: "Alice"}, ""},
+		{"another user's submission", ChangedFile{"M", "challenge-3/submissions/bob/solution-template.go"}, "alice", nil, "submission belongs to bob"},
+		{"another user's package submission", ChangedFile{"A", "packages/gin/challenge-1-basic-routing/submissions/bob/solution.go"}, "alice", nil, "submission belongs to bob"},
+		{"deleted own submission", ChangedFile{"D", "challenge-3/submissions/alice/solution-template.go"}, "alice", nil, "submissions may only be added or modified"},
+		{"deleted other submission", ChangedFile{"D", "challenge-3/submissions/bob/solution-template.go"}, "alice", nil, "submission belongs to bob"},
+		{"type change", ChangedFile{"T", "challenge-3/submissions/alice/solution-template.go"}, "alice", nil, "submissions may only be added or modified"},
+		{"test file", ChangedFile{"M", "challenge-3/solution-template_test.go"}, "alice", nil, "not a submission file"},
+		{"extra file in own directory", ChangedFile{"A", "challenge-3/submissions/alice/helper.go"}, "alice", nil, "not a submission file"},
+		{"nested directory", ChangedFile{"A", "challenge-3/submissions/alice/x/solution-template.go"}, "alice", nil, "not a submission file"},
+		{"scoreboard", ChangedFile{"M", "challenge-3/SCOREBOARD.md"}, "alice", nil, "not a submission file"},
+		{"web UI source", ChangedFile{"M", "web-ui/main.go"}, "alice", nil, "not a submission file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := v.classify(tt.file, tt.author)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got submission %+v, want %+v", got, tt.want)
+			}
+			if !strings.HasPrefix(reason, tt.reason) || (tt.reason == "") != (reason == "") {
+				t.Errorf("got reason %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestChangedFilesReportsRenamesAsDeleteAndAdd(t *testing.T) {
+	dir := gitRepo(t)
+	writeFile(t, filepath.Join(dir, "challenge-1/submissions/bob/solution-template.go"), "package main\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "bob")
+	base := runGit(t, dir, "rev-parse", "HEAD")
+
+	// Moving bob's submission into alice's directory must not hide the deletion
+	runGit(t, dir, "mv", "challenge-1/submissions/bob", "challenge-1/submissions/alice")
+	runGit(t, dir, "commit", "--quiet", "-m", "take over")
+
+	v, err := NewPRValidator(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := v.ChangedFiles(base, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChangedFile{
+		{"A", "challenge-1/submissions/alice/solution-template.go"},
+		{"D", "challenge-1/submissions/bob/solution-template.go"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("got %v, want %v", files, want)
+	}
+	if _, reason := v.classify(files[1], "alice"); reason == "" {
+		t.Error("deleting another user's submission was allowed")
+	}
+}
+
+// scoreboardFixture is the start of a real challenge scoreboard
+const scoreboardFixture = `# Scoreboard for challenge-1
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| 0xJaskirat | 6 | 6 |
+| ADEMOLA200 | 6 | 6 |
+| zed | 4 | 6 |
+`
+
+func TestUpdateScoreboardRow(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // "" when the file does not exist yet
+		title    string
+		username string
+		passed   int
+		want     string
+	}{
+		{"insert sorted by username", scoreboardFixture, "", "alice", 6, `# Scoreboard for challenge-1
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| 0xJaskirat | 6 | 6 |
+| ADEMOLA200 | 6 | 6 |
+| alice | 6 | 6 |
+| zed | 4 | 6 |
+`},
+		{"insert sorted by passed tests", scoreboardFixture, "", "bob", 5, `# Scoreboard for challenge-1
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| 0xJaskirat | 6 | 6 |
+| ADEMOLA200 | 6 | 6 |
+| bob | 5 | 6 |
+| zed | 4 | 6 |
+`},
+		{"replace moves the row", scoreboardFixture, "", "zed", 6, `# Scoreboard for challenge-1
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| 0xJaskirat | 6 | 6 |
+| ADEMOLA200 | 6 | 6 |
+| zed | 6 | 6 |
+`},
+		{"replace ignores case", scoreboardFixture, "", "ademola200", 3, `# Scoreboard for challenge-1
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| 0xJaskirat | 6 | 6 |
+| zed | 4 | 6 |
+| ademola200 | 3 | 6 |
+`},
+		{"new challenge scoreboard", "", "# Scoreboard for challenge-9", "alice", 2, `# Scoreboard for challenge-9
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| alice | 2 | 6 |
+`},
+		{"new package scoreboard", "", "# Scoreboard for gin challenge-1-basic-routing\n", "alice", 6, `# Scoreboard for gin challenge-1-basic-routing
+
+| Username   | Passed Tests | Total Tests |
+|------------|--------------|-------------|
+| alice | 6 | 6 |
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "challenge", "SCOREBOARD.md")
+			if tt.existing != "" {
+				writeFile(t, path, tt.existing)
+			}
+			if err := UpdateScoreboardRow(path, tt.title, tt.username, tt.passed, 6); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	report := &PRValidationReport{
+		Author:     "alice",
+		Base:       "0123456789abcdef",
+		Head:       "fedcba9876543210",
+		Violations: []PathViolation{{Path: "challenge-1/SCOREBOARD.md", Reason: "not a submission file"}},
+		Submissions: []*PRSubmission{
+			{ChallengeID: 1, Passed: 6, Total: 6, Success: true, ExecutionMs: 12, Output: "ok"},
+			{PackageName: "gin", PackageChallenge: "challenge-1-basic-routing", Passed: 1, Total: 3, Output: "--- FAIL: TestRoutes"},
+			{ChallengeID: 2, Error: "no tests found for challenge-2 on the base branch"},
+		},
+		UpdatedScoreboards: []string{"challenge-1/SCOREBOARD.md"},
+	}
+	markdown := report.Markdown()
+	for _, want := range []string{
+		"**Author:** @alice · **Base:** `0123456` · **Head:** `fedcba9`",
+		"### ❌ Changes required",
+		"| `challenge-1/SCOREBOARD.md` | not a submission file |",
+		"| challenge-1 | ✅ Passed | 6 | 6 | 12ms |",
+		"| gin/challenge-1-basic-routing | ❌ Failed | 1 | 3 | 0ms |",
+		"| challenge-2 | ⚠️ no tests found for challenge-2 on the base branch | 0 | 0 | 0ms |",
+		"<summary>Test output for gin/challenge-1-basic-routing</summary>\n\n```text\n--- FAIL: TestRoutes\n```",
+		"- `challenge-1/SCOREBOARD.md`",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("report is missing %q:\n%s", want, markdown)
+		}
+	}
+	if strings.Contains(markdown, "Test output for challenge-1<") {
+		t.Error("report quotes the output of a passing submission")
+	}
+
+	report.Violations = nil
+	report.Submissions = report.Submissions[:1]
+	if markdown := report.Markdown(); !strings.Contains(markdown, "### ✅ Ready to merge") ||
+		!strings.Contains(markdown, "All 1 changed file(s) are @alice's own submissions.") {
+		t.Errorf("passing report:\n%s", markdown)
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a simple token-bucket rate limiter
+type TokenBucket struct {
+	capacity   float64
+	refillRate float64 // tokens per second
+	tokens     float64
+	last       time.Time
+	mutex      sync.Mutex
+}
+
+// NewTokenBucket creates a bucket allowing limit requests per period, with bursts up to limit
+func NewTokenBucket(limit int, period time.Duration) *TokenBucket {
+	return &TokenBucket{
+		capacity:   float64(limit),
+		refillRate: float64(limit) / period.Seconds(),
+		tokens:     float64(limit),
+		last:       time.Now(),
+	}
+}
+
+// Allow consumes a token if one is available
+func (b *TokenBucket) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.refillRate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RetryAfter returns how long until the next token becomes available
+func (b *TokenBucket) RetryAfter() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.tokens >= 1 || b.refillRate == 0 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.refillRate * float64(time.Second))
+}
+
+// RateLimiter keeps a token bucket per key, such as a username or client IP
+type RateLimiter struct {
+	limit   int
+	period  time.Duration
+	buckets map[string]*keyedBucket
+	mutex   sync.Mutex
+}
+
+type keyedBucket struct {
+	bucket   *TokenBucket
+	lastUsed time.Time
+}
+
+// maxIdleBuckets is how many buckets are kept before idle ones are dropped
+const maxIdleBuckets = 10000
+
+// NewRateLimiter creates a limiter allowing limit requests per period for each key
+func NewRateLimiter(limit int, period time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		period:  period,
+		buckets: make(map[string]*keyedBucket),
+	}
+}
+
+// Allow consumes a token from key's bucket. When none is left it reports how long
+// until the next one.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mutex.Lock()
+	now := time.Now()
+	entry, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.pruneLocked(now)
+		}
+		entry = &keyedBucket{bucket: NewTokenBucket(l.limit, l.period)}
+		l.buckets[key] = entry
+	}
+	entry.lastUsed = now
+	l.mutex.Unlock()
+
+	if entry.bucket.Allow() {
+		return true, 0
+	}
+	return false, entry.bucket.RetryAfter()
+}
+
+// pruneLocked drops buckets unused for a whole period; they would be full again anyway.
+// Callers must hold the mutex.
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	for key, entry := range l.buckets {
+		if now.Sub(entry.lastUsed) >= l.period {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RepoSyncService keeps the server's checkout and the content loaded from it in step
+// with GitHub: pushes to the checked-out branch are pulled and reloaded, and opened or
+// updated pull requests are judged like the validate-pr command does in CI
+type RepoSyncService struct {
+	repoRoot          string
+	reportsDir        string
+	challengeService  *ChallengeService
+	scoreboardService *ScoreboardService
+	packageService    *PackageService
+	searchService     *SearchService
+	validator         *PRValidator // nil when the repository is not a git checkout
+}
+
+// NewRepoSyncService creates a repository sync service; pull request reports are
+// written to <dataDir>/pr-reports
+func NewRepoSyncService(
+	repoRoot, dataDir string,
+	challengeService *ChallengeService,
+	scoreboardService *ScoreboardService,
+	packageService *PackageService,
+	searchService *SearchService,
+	executionService *ExecutionService,
+) *RepoSyncService {
+	validator, err := NewPRValidator(repoRoot, executionService)
+	if err != nil {
+		slog.Warn("Pull request webhooks will be ignored", "error", err)
+	}
+	return &RepoSyncService{
+		repoRoot:          repoRoot,
+		reportsDir:        filepath.Join(dataDir, "pr-reports"),
+		challengeService:  challengeService,
+		scoreboardService: scoreboardService,
+		packageService:    packageService,
+		searchService:     searchService,
+		validator:         validator,
+	}
+}
+
+// Subscribe handles push and pull request events from the bus
+func (rs *RepoSyncService) Subscribe(bus *EventBus) {
+	bus.Subscribe(EventPush, rs.OnPush)
+	bus.Subscribe(EventPullRequest, rs.OnPullRequest)
+}
+
+// OnPush fast-forwards the checkout when its current branch was pushed to, then reloads
+// challenges, scoreboards, packages and the search index
+func (rs *RepoSyncService) OnPush(event Event) {
+	push, ok := event.Payload.(*PushEvent)
+	if !ok || push.Branch() == "" || push.Deleted {
+		return
+	}
+	log := slog.With("delivery", event.Delivery, "repository", push.Repository, "branch", push.Branch())
+
+	current, err := rs.git("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		log.Error("Could not determine the checked-out branch", "error", err)
+		return
+	}
+	if current != push.Branch() {
+		log.Info("Ignoring push to a branch that is not checked out", "checked_out", current)
+		return
+	}
+
+	if output, err := rs.git("pull", "--ff-only", "--no-tags", "origin", push.Branch()); err != nil {
+		log.Error("git pull failed", "error", err, "output", output)
+		return
+	}
+	log.Info("Pulled pushed commits", "after", push.After)
+
+	if err := rs.Reload(); err != nil {
+		log.Error("Reload after push failed", "error", err)
+	}
+}
+
+// OnPullRequest judges the submissions of an opened, reopened or updated pull request
+// and writes the Markdown report to the reports directory
+func (rs *RepoSyncService) OnPullRequest(event Event) {
+	pr, ok := event.Payload.(*PullRequestEvent)
+	if !ok {
+		return
+	}
+	switch pr.Action {
+	case "opened", "reopened", "synchronize":
+	default:
+		return
+	}
+	log := slog.With("delivery", event.Delivery, "repository", pr.Repository, "pull_request", pr.Number, "author", pr.Author)
+	if rs.validator == nil {
+		log.Warn("Ignoring pull request: the repository is not a git checkout")
+		return
+	}
+
+	if err := rs.validator.FetchPullRequest(pr.Number, pr.BaseRef); err != nil {
+		log.Error("Could not fetch pull request", "error", err)
+		return
+	}
+	report, err := rs.validator.Validate(pr.BaseSHA, pr.HeadSHA, pr.Author)
+	if err != nil {
+		log.Error("Pull request validation failed", "error", err)
+		return
+	}
+
+	path := filepath.Join(rs.reportsDir, fmt.Sprintf("pull-%d.md", pr.Number))
+	if err := os.MkdirAll(rs.reportsDir, 0755); err != nil {
+		log.Error("Could not write pull request report", "error", err)
+		return
+	}
+	if err := os.WriteFile(path, []byte(report.Markdown()), 0644); err != nil {
+		log.Error("Could not write pull request report", "error", err)
+		return
+	}
+	log.Info("Validated pull request", "passed", report.Passed(),
+		"submissions", len(report.Submissions), "violations", len(report.Violations), "report", path)
+}
+
+// Reload reads challenges, scoreboards and packages from the checkout again and
+// rebuilds the search index over them
+func (rs *RepoSyncService) Reload() error {
+	if err := rs.challengeService.LoadChallenges(); err != nil {
+		return fmt.Errorf("failed to load challenges: %v", err)
+	}
+	if err := rs.scoreboardService.LoadScoreboards(rs.challengeService.GetChallenges()); err != nil {
+		return fmt.Errorf("failed to load scoreboards: %v", err)
+	}
+	if err := rs.packageService.LoadPackages(); err != nil {
+		return fmt.Errorf("failed to load packages: %v", err)
+	}
+	rs.searchService.Rebuild()
+	return nil
+}
+
+// git runs a git command in the repository root and returns its trimmed combined output
+func (rs *RepoSyncService) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = rs.repoRoot
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"web-ui/internal/models"
@@ -12,36 +13,45 @@ import (
 
 // ScoreboardService handles scoreboard-related operations
 type ScoreboardService struct {
+	root        string
+	mu          sync.RWMutex
 	scoreboards models.ScoreboardMap
 }
 
-// NewScoreboardService creates a new scoreboard service
-func NewScoreboardService() *ScoreboardService {
+// NewScoreboardService creates a scoreboard service for the repository at repoRoot
+func NewScoreboardService(repoRoot string) *ScoreboardService {
 	return &ScoreboardService{
+		root:        repoRoot,
 		scoreboards: make(models.ScoreboardMap),
 	}
 }
 
-// LoadScoreboards loads all scoreboards from the filesystem
+// LoadScoreboards loads all scoreboards from the filesystem, replacing those loaded before
 func (ss *ScoreboardService) LoadScoreboards(challenges models.ChallengeMap) error {
+	scoreboards := make(models.ScoreboardMap)
 	for id := range challenges {
-		challengeDir := filepath.Join("..", "challenge-"+strconv.Itoa(id))
-		ss.loadScoreboardForChallenge(id, challengeDir)
+		challengeDir := filepath.Join(ss.root, "challenge-"+strconv.Itoa(id))
+		if entries, ok := ss.loadScoreboardForChallenge(id, challengeDir); ok {
+			scoreboards[id] = entries
+		}
 	}
+
+	ss.mu.Lock()
+	ss.scoreboards = scoreboards
+	ss.mu.Unlock()
 	return nil
 }
 
 // loadScoreboardForChallenge loads the scoreboard for a specific challenge
-func (ss *ScoreboardService) loadScoreboardForChallenge(id int, dir string) {
+func (ss *ScoreboardService) loadScoreboardForChallenge(id int, dir string) ([]models.ScoreboardEntry, bool) {
 	scoreboardPath := filepath.Join(dir, "SCOREBOARD.md")
 	scoreboardContent, err := ioutil.ReadFile(scoreboardPath)
 	if err != nil {
-		return
+		return nil, false
 	}
 
 	// Parse scoreboard markdown table
-	entries := ss.parseScoreboardMarkdown(string(scoreboardContent), id)
-	ss.scoreboards[id] = entries
+	return ss.parseScoreboardMarkdown(string(scoreboardContent), id), true
 }
 
 // parseScoreboardMarkdown parses the scoreboard markdown table
@@ -124,12 +134,17 @@ func (ss *ScoreboardService) isNumeric(s string) bool {
 
 // GetScoreboard returns the scoreboard for a specific challenge
 func (ss *ScoreboardService) GetScoreboard(challengeID int) ([]models.ScoreboardEntry, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
 	scoreboard, exists := ss.scoreboards[challengeID]
 	return scoreboard, exists
 }
 
-// GetAllScoreboards returns all scoreboards
+// GetAllScoreboards returns all scoreboards. The map is replaced, not modified, by
+// reloads and new submissions.
 func (ss *ScoreboardService) GetAllScoreboards() models.ScoreboardMap {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
 	return ss.scoreboards
 }
 
@@ -141,10 +156,17 @@ func (ss *ScoreboardService) AddSubmission(submission models.Submission) {
 		SubmittedAt: submission.SubmittedAt,
 	}
 
-	// Add to the scoreboard for this challenge
-	if ss.scoreboards[submission.ChallengeID] == nil {
-		ss.scoreboards[submission.ChallengeID] = []models.ScoreboardEntry{}
-	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 
-	ss.scoreboards[submission.ChallengeID] = append(ss.scoreboards[submission.ChallengeID], entry)
+	// Copy the map and the challenge's entries, so readers holding the old map are unaffected
+	scoreboards := make(models.ScoreboardMap, len(ss.scoreboards)+1)
+	for id, entries := range ss.scoreboards {
+		scoreboards[id] = entries
+	}
+	existing := scoreboards[submission.ChallengeID]
+	entries := make([]models.ScoreboardEntry, len(existing), len(existing)+1)
+	copy(entries, existing)
+	scoreboards[submission.ChallengeID] = append(entries, entry)
+	ss.scoreboards = scoreboards
 }
@@ -0,0 +1,658 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"web-ui/internal/markdown"
+)
+
+// Kinds of search results
+const (
+	SearchKindChallenge        = "challenge"         // A classic challenge
+	SearchKindPackage          = "package"           // A package learning path
+	SearchKindPackageChallenge = "package-challenge" // A challenge of a package
+)
+
+// SearchTrackClassic is the track of the classic challenges; the track of a package and
+// its challenges is the package name
+const SearchTrackClassic = "classic"
+
+// Search result limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchQuery is a full-text query with optional filters. Text may be empty when a
+// filter is given, which lists everything the filters match.
+type SearchQuery struct {
+	Text       string
+	Difficulty string   // Case-insensitive; "" for any
+	Track      string   // SearchTrackClassic or a package name; "" for any
+	Tags       []string // Results must have every tag
+	Limit      int      // DefaultSearchLimit when 0, at most MaxSearchLimit
+}
+
+// SearchResult is a challenge or package that matched a query
+type SearchResult struct {
+	Kind       string   `json:"kind"`
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	URL        string   `json:"url"`
+	Track      string   `json:"track"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Score      float64  `json:"score"`
+	Field      string   `json:"field,omitempty"` // The field that contributed most to the score
+	// Snippet is an HTML-escaped excerpt with the matched words in <mark>
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchResults is a page of results, best first
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"` // Matches before the limit was applied
+	Results []SearchResult `json:"results"`
+}
+
+// Indexed fields, with the weight of a match in each
+const (
+	fieldTitle = iota
+	fieldTags
+	fieldObjectives
+	fieldReadme
+	fieldLearning
+	fieldHints
+	numFields
+)
+
+var (
+	fieldNames   = [numFields]string{"title", "tags", "learning_objectives", "readme", "learning", "hints"}
+	fieldWeights = [numFields]float64{6, 4, 3, 1.5, 1, 1}
+)
+
+// BM25 parameters, and the weight of a query term matched only as a prefix
+const (
+	bm25K1       = 1.2
+	bm25B        = 0.75
+	prefixWeight = 0.7
+	minPrefixLen = 3
+	maxPrefixes  = 50
+)
+
+// searchStopWords are too common to be worth indexing
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "how": true, "in": true, "into": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true,
+	"to": true, "with": true, "you": true, "your": true,
+}
+
+// searchDoc is an indexed challenge or package
+type searchDoc struct {
+	result SearchResult // Without Score, Field and Snippet
+	text   [numFields]string
+	length [numFields]int // Terms in each field
+}
+
+// posting records how often a term occurs in each field of a document
+type posting struct {
+	doc  int
+	freq [numFields]int
+}
+
+// searchIndex is an inverted index over the documents; it is not modified once built
+type searchIndex struct {
+	docs      []*searchDoc
+	postings  map[string][]posting // Term -> documents containing it, in document order
+	terms     []string             // Every term, sorted, for prefix matches
+	avgLength [numFields]float64
+}
+
+// SearchService answers full-text queries over the challenges, packages and their
+// learning material. The index is rebuilt from the loaded content by Rebuild.
+type SearchService struct {
+	challengeService *ChallengeService
+	packageService   *PackageService
+	mu               sync.RWMutex
+	index            *searchIndex
+}
+
+// NewSearchService creates a search service; the index is empty until Rebuild
+func NewSearchService(challengeService *ChallengeService, packageService *PackageService) *SearchService {
+	return &SearchService{
+		challengeService: challengeService,
+		packageService:   packageService,
+		index:            buildSearchIndex(nil),
+	}
+}
+
+// Rebuild indexes the currently loaded challenges and packages and replaces the index
+func (s *SearchService) Rebuild() {
+	index := buildSearchIndex(s.documents())
+	s.mu.Lock()
+	s.index = index
+	s.mu.Unlock()
+	slog.Info("Built search index", "documents", len(index.docs), "terms", len(index.terms))
+}
+
+// documents collects the classic challenges, then each package followed by its challenges
+func (s *SearchService) documents() []*searchDoc {
+	var docs []*searchDoc
+
+	challenges := s.challengeService.GetChallenges()
+	ids := make([]int, 0, len(challenges))
+	for id := range challenges {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		c := challenges[id]
+		doc := &searchDoc{result: SearchResult{
+			Kind:       SearchKindChallenge,
+			ID:         fmt.Sprint(c.ID),
+			Title:      c.Title,
+			URL:        fmt.Sprintf("/challenge/%d", c.ID),
+			Track:      SearchTrackClassic,
+			Difficulty: c.Difficulty,
+			Tags:       c.Tags,
+		}}
+		doc.text[fieldObjectives] = strings.Join(c.LearningObjectives, "\n")
+		doc.text[fieldReadme] = markdown.Text(c.DescriptionHTML)
+		doc.text[fieldLearning] = markdown.Text(c.LearningHTML)
+		doc.text[fieldHints] = hintsText(c.Hints)
+		docs = append(docs, doc)
+	}
+
+	packages := s.packageService.GetPackages()
+	names := make([]string, 0, len(packages))
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pkg := packages[name]
+		title := pkg.DisplayName
+		if title == "" {
+			title = pkg.Name
+		}
+		doc := &searchDoc{result: SearchResult{
+			Kind:       SearchKindPackage,
+			ID:         name,
+			Title:      title,
+			URL:        "/packages/" + name,
+			Track:      name,
+			Difficulty: pkg.Difficulty,
+			Tags:       pkg.Tags,
+		}}
+		doc.text[fieldReadme] = strings.Join(append([]string{pkg.Description}, pkg.RealWorldUsage...), "\n")
+		docs = append(docs, doc)
+
+		packageChallenges, err := s.packageService.GetPackageChallenges(name)
+		if err != nil {
+			slog.Warn("Could not index package challenges", "package", name, "error", err)
+			continue
+		}
+		challengeIDs := make([]string, 0, len(packageChallenges))
+		for id := range packageChallenges {
+			challengeIDs = append(challengeIDs, id)
+		}
+		sort.Slice(challengeIDs, func(i, j int) bool {
+			return challengeOrder(challengeIDs[i]) < challengeOrder(challengeIDs[j])
+		})
+		for _, id := range challengeIDs {
+			c := packageChallenges[id]
+			result := SearchResult{
+				Kind:       SearchKindPackageChallenge,
+				ID:         name + "/" + id,
+				Title:      c.Title,
+				URL:        "/packages/" + name + "/" + id,
+				Track:      name,
+				Difficulty: c.Difficulty,
+				Tags:       c.Tags,
+			}
+			objectives := c.LearningObjectives
+			if info := pkg.ChallengeDetails[id]; info != nil {
+				// The package's metadata describes its challenges better than the
+				// names derived from their directories
+				if info.Title != "" {
+					result.Title = info.Title
+				}
+				if info.Difficulty != "" {
+					result.Difficulty = info.Difficulty
+				}
+				if len(info.Tags) > 0 {
+					result.Tags = info.Tags
+				}
+				if len(info.LearningObjectives) > 0 {
+					objectives = info.LearningObjectives
+				}
+			}
+			doc := &searchDoc{result: result}
+			doc.text[fieldObjectives] = strings.Join(objectives, "\n")
+			doc.text[fieldReadme] = markdown.Text(c.DescriptionHTML)
+			doc.text[fieldLearning] = markdown.Text(c.LearningHTML)
+			doc.text[fieldHints] = hintsText(c.Hints)
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// challengeOrder returns the number of a "challenge-<n>-name" directory
+func challengeOrder(id string) int {
+	var n int
+	fmt.Sscanf(id, "challenge-%d", &n)
+	return n
+}
+
+// hintsText returns the plain text of the sections of a hints.md
+func hintsText(hints string) string {
+	var b strings.Builder
+	for _, section := range ParseHintSections(hints) {
+		b.WriteString(section.Title + "\n" + markdown.Text(markdown.Render(section.Content)) + "\n")
+	}
+	return b.String()
+}
+
+// buildSearchIndex indexes docs; the title and tag fields are taken from their results
+func buildSearchIndex(docs []*searchDoc) *searchIndex {
+	index := &searchIndex{docs: docs, postings: make(map[string][]posting)}
+	var total [numFields]int
+	for i, doc := range docs {
+		doc.text[fieldTitle] = doc.result.Title
+		doc.text[fieldTags] = strings.Join(doc.result.Tags, "\n")
+
+		freqs := make(map[string]*posting)
+		for field, text := range doc.text {
+			for _, word := range searchWords(text) {
+				for _, term := range word.terms {
+					p := freqs[term]
+					if p == nil {
+						p = &posting{doc: i}
+						freqs[term] = p
+					}
+					p.freq[field]++
+					doc.length[field]++
+				}
+			}
+			total[field] += doc.length[field]
+		}
+		for term, p := range freqs {
+			index.postings[term] = append(index.postings[term], *p)
+		}
+	}
+
+	for field := range total {
+		if len(docs) > 0 {
+			index.avgLength[field] = float64(total[field]) / float64(len(docs))
+		}
+	}
+	index.terms = make([]string, 0, len(index.postings))
+	for term := range index.postings {
+		index.terms = append(index.terms, term)
+	}
+	sort.Strings(index.terms)
+	return index
+}
+
+// Search returns the documents matching every term of the query and its filters, best
+// first. Terms also match the indexed words they are a prefix of, at a lower weight.
+func (s *SearchService) Search(query SearchQuery) SearchResults {
+	s.mu.RLock()
+	index := s.index
+	s.mu.RUnlock()
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	type match struct {
+		doc     int
+		score   float64
+		byField [numFields]float64
+	}
+	var matches []*match
+	matched := make(map[string]bool) // Indexed terms the query matched, for snippets
+
+	terms := queryTerms(query.Text)
+	if len(terms) == 0 {
+		if strings.TrimSpace(query.Text) == "" {
+			for i, doc := range index.docs {
+				if doc.matchesFilters(query) {
+					matches = append(matches, &match{doc: i})
+				}
+			}
+		}
+	} else {
+		// Each query term scores its best expansion in each document; documents must
+		// match every term
+		byDoc := make(map[int]*match)
+		for n, term := range terms {
+			best := make(map[int][numFields]float64)
+			for _, expansion := range index.expand(term) {
+				postings := index.postings[expansion.term]
+				idf := math.Log(1 + (float64(len(index.docs))-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+				for _, p := range postings {
+					if n > 0 && byDoc[p.doc] == nil {
+						continue
+					}
+					var scores [numFields]float64
+					for field, freq := range p.freq {
+						if freq == 0 {
+							continue
+						}
+						norm := 1 - bm25B + bm25B*float64(index.docs[p.doc].length[field])/index.avgLength[field]
+						tf := float64(freq) * (bm25K1 + 1) / (float64(freq) + bm25K1*norm)
+						scores[field] = fieldWeights[field] * expansion.weight * idf * tf
+					}
+					if sum(scores) > sum(best[p.doc]) {
+						best[p.doc] = scores
+					}
+					matched[expansion.term] = true
+				}
+			}
+
+			next := make(map[int]*match, len(best))
+			for doc, scores := range best {
+				m := byDoc[doc]
+				if m == nil {
+					if n > 0 || !index.docs[doc].matchesFilters(query) {
+						continue
+					}
+					m = &match{doc: doc}
+				}
+				for field, score := range scores {
+					m.byField[field] += score
+				}
+				next[doc] = m
+			}
+			byDoc = next
+		}
+
+		phrase := strings.Join(strings.Fields(strings.ToLower(query.Text)), " ")
+		for _, m := range byDoc {
+			m.score = sum(m.byField)
+			if len(terms) > 1 {
+				// Words appearing together as typed count for more than scattered ones
+				for field, text := range index.docs[m.doc].text {
+					if strings.Contains(strings.ToLower(text), phrase) {
+						m.score += fieldWeights[field]
+						m.byField[field] += fieldWeights[field]
+					}
+				}
+			}
+			matches = append(matches, m)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].doc < matches[j].doc
+	})
+
+	results := SearchResults{Query: query.Text, Total: len(matches), Results: []SearchResult{}}
+	for _, m := range matches[:min(limit, len(matches))] {
+		doc := index.docs[m.doc]
+		result := doc.result
+		result.Score = math.Round(m.score*1000) / 1000
+
+		bestField, snippetField := -1, -1
+		for field, score := range m.byField {
+			if score > 0 && (bestField < 0 || score > m.byField[bestField]) {
+				bestField = field
+			}
+			// Hints are searched but never quoted, so they are not given away
+			if field >= fieldObjectives && field < fieldHints && score > 0 &&
+				(snippetField < 0 || score > m.byField[snippetField]) {
+				snippetField = field
+			}
+		}
+		if bestField >= 0 {
+			result.Field = fieldNames[bestField]
+		}
+		if snippetField >= 0 {
+			result.Snippet = snippet(doc.text[snippetField], matched)
+		} else {
+			result.Snippet = snippet(doc.text[fieldReadme], nil)
+		}
+		results.Results = append(results.Results, result)
+	}
+	return results
+}
+
+// matchesFilters reports whether the document has the query's difficulty, track and tags
+func (doc *searchDoc) matchesFilters(query SearchQuery) bool {
+	if query.Difficulty != "" && !strings.EqualFold(doc.result.Difficulty, query.Difficulty) {
+		return false
+	}
+	if query.Track != "" && !strings.EqualFold(doc.result.Track, query.Track) {
+		return false
+	}
+	for _, tag := range query.Tags {
+		found := false
+		for _, have := range doc.result.Tags {
+			if strings.EqualFold(have, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type termExpansion struct {
+	term   string
+	weight float64
+}
+
+// expand returns the indexed terms a query term matches: itself, and the longer terms
+// it is a prefix of
+func (index *searchIndex) expand(term string) []termExpansion {
+	var expansions []termExpansion
+	if _, ok := index.postings[term]; ok {
+		expansions = append(expansions, termExpansion{term, 1})
+	}
+	if len(term) < minPrefixLen {
+		return expansions
+	}
+	for i := sort.SearchStrings(index.terms, term); i < len(index.terms) && len(expansions) < maxPrefixes; i++ {
+		if !strings.HasPrefix(index.terms[i], term) {
+			break
+		}
+		if index.terms[i] != term {
+			expansions = append(expansions, termExpansion{index.terms[i], prefixWeight})
+		}
+	}
+	return expansions
+}
+
+// queryTerms returns the distinct terms of a query in the order they are typed
+func queryTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range searchWords(text) {
+		// A query word is matched by its whole form; its camelCase parts are only
+		// indexed so that searching for one part finds identifiers
+		if term := word.terms[0]; !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchWord is a run of letters and digits in a text, with the terms it is indexed as
+type searchWord struct {
+	start, end int
+	terms      []string
+}
+
+// searchWords splits text into words. A word is indexed as its stem and, if it is
+// written in camelCase, as the stems of its parts. Stop words are left out.
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	start := -1
+	for i, r := range text + " " {
+		alnum := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case alnum && start < 0:
+			start = i
+		case !alnum && start >= 0:
+			word := text[start:i]
+			start = -1
+			lower := strings.ToLower(word)
+			if searchStopWords[lower] {
+				continue
+			}
+			terms := []string{stem(lower)}
+			if parts := camelParts(word); len(parts) > 1 {
+				for _, part := range parts {
+					if part = strings.ToLower(part); !searchStopWords[part] {
+						terms = append(terms, stem(part))
+					}
+				}
+			}
+			words = append(words, searchWord{start: i - len(word), end: i, terms: terms})
+		}
+	}
+	return words
+}
+
+// camelParts splits an identifier like "WithContext" or "HTTPServer" at its case changes
+func camelParts(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+		acronymEnd := unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		letterDigit := unicode.IsLetter(runes[i-1]) != unicode.IsLetter(runes[i])
+		if lowerToUpper || acronymEnd || letterDigit {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// stem strips common English inflections, so "cancelling", "cancelled" and
+// "cancellation" are all indexed as "cancel". It is deliberately light: terms only
+// need to agree with each other, not to be words.
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	for _, suffix := range []string{"ations", "ation", "ings", "ing", "ed"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 4 {
+			return undouble(word[:len(word)-len(suffix)])
+		}
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") || strings.HasSuffix(word, "ied"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "shes") || strings.HasSuffix(word, "ches") ||
+		strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "zes"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// undouble drops the last letter of a stem ending in a doubled consonant other than s
+func undouble(word string) string {
+	n := len(word)
+	if n >= 2 && word[n-1] == word[n-2] && word[n-1] != 's' && strings.IndexByte("aeiou", word[n-1]) < 0 {
+		return word[:n-1]
+	}
+	return word
+}
+
+// Snippet length in bytes, and how much of it comes before the first match
+const (
+	snippetLength  = 200
+	snippetContext = 60
+)
+
+// snippet returns an HTML-escaped excerpt of text around the first word indexed as one
+// of the matched terms, with those words in <mark>; without matches it is the start of
+// the text
+func snippet(text string, matched map[string]bool) string {
+	words := searchWords(text)
+	isMatch := func(word searchWord) bool {
+		for _, term := range word.terms {
+			if matched[term] {
+				return true
+			}
+		}
+		return false
+	}
+
+	start := 0
+	for _, word := range words {
+		if isMatch(word) {
+			if word.start > snippetContext {
+				start = word.start - snippetContext
+				if space := strings.IndexByte(text[start:word.start], ' '); space >= 0 {
+					start += space + 1
+				}
+			}
+			break
+		}
+	}
+	end := len(text)
+	if end-start > snippetLength {
+		end = start + snippetLength
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if space := strings.LastIndexByte(text[start:end], ' '); space > snippetLength/2 {
+			end = start + space
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := start
+	for _, word := range words {
+		if word.start < start || word.end > end || !isMatch(word) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:word.start]))
+		b.WriteString("<mark>" + html.EscapeString(text[word.start:word.end]) + "</mark>")
+		last = word.end
+	}
+	b.WriteString(html.EscapeString(text[last:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func sum(scores [numFields]float64) float64 {
+	var total float64
+	for _, score := range scores {
+		total += score
+	}
+	return total
+}
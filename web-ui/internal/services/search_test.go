@@ -0,0 +1,165 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestSearchService indexes two classic challenges, a package and one of its challenges
+func newTestSearchService() *SearchService {
+	docs := []*searchDoc{
+		{result: SearchResult{Kind: SearchKindChallenge, ID: "1", Title: "Context Cancellation", Track: SearchTrackClassic,
+			Difficulty: "Medium", Tags: []string{"context", "concurrency"}}},
+		{result: SearchResult{Kind: SearchKindChallenge, ID: "2", Title: "Rate Limiter", Track: SearchTrackClassic,
+			Difficulty: "Hard", Tags: []string{"concurrency"}}},
+		{result: SearchResult{Kind: SearchKindPackage, ID: "gin", Title: "Gin", Track: "gin",
+			Difficulty: "Beginner", Tags: []string{"web", "http"}}},
+		{result: SearchResult{Kind: SearchKindPackageChallenge, ID: "gin/challenge-1-basic-routing", Title: "Basic Routing", Track: "gin",
+			Difficulty: "Beginner", Tags: []string{"routing"}}},
+	}
+	docs[0].text[fieldReadme] = "Stop long running work with WithContext when the caller gives up. Wait on <select> & channels."
+	docs[1].text[fieldReadme] = "Limit requests per second. Cancelled requests are dropped."
+	docs[2].text[fieldReadme] = "HTTP web framework with routing and middleware."
+	docs[3].text[fieldReadme] = "Register routes for a small API."
+	docs[3].text[fieldHints] = "The secret trick is a router group."
+	return &SearchService{index: buildSearchIndex(docs)}
+}
+
+func resultIDs(results SearchResults) []string {
+	ids := []string{}
+	for _, result := range results.Results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestSearchService()
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string // IDs, best first
+	}{
+		{"title outranks body", SearchQuery{Text: "cancellation"}, []string{"1", "2"}},
+		{"stemmed query", SearchQuery{Text: "cancelling"}, []string{"1", "2"}},
+		{"plural", SearchQuery{Text: "request"}, []string{"2"}},
+		{"every term required", SearchQuery{Text: "routing middleware"}, []string{"gin"}},
+		{"prefix expansion", SearchQuery{Text: "midd"}, []string{"gin"}},
+		{"prefix of several words", SearchQuery{Text: "limi"}, []string{"2"}},
+		{"short prefix not expanded", SearchQuery{Text: "mi"}, []string{}},
+		{"camelCase part", SearchQuery{Text: "context"}, []string{"1"}},
+		{"whole identifier", SearchQuery{Text: "WithContext"}, []string{"1"}},
+		{"stop words only", SearchQuery{Text: "the and"}, []string{}},
+		{"hints are searched", SearchQuery{Text: "trick"}, []string{"gin/challenge-1-basic-routing"}},
+		{"difficulty filter", SearchQuery{Text: "concurrency", Difficulty: "hard"}, []string{"2"}},
+		{"track filter", SearchQuery{Text: "routing", Track: "gin"}, []string{"gin/challenge-1-basic-routing", "gin"}},
+		{"filters without text", SearchQuery{Track: SearchTrackClassic}, []string{"1", "2"}},
+		{"every tag required", SearchQuery{Tags: []string{"Concurrency", "context"}}, []string{"1"}},
+		{"limit", SearchQuery{Difficulty: "beginner", Limit: 1}, []string{"gin"}},
+		{"no text or filters", SearchQuery{}, []string{"1", "2", "gin", "gin/challenge-1-basic-routing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resultIDs(s.Search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%+v) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchResultFields(t *testing.T) {
+	s := newTestSearchService()
+
+	results := s.Search(SearchQuery{Text: "channels"})
+	if len(results.Results) != 1 {
+		t.Fatalf("got %q, want challenge 1", resultIDs(results))
+	}
+	result := results.Results[0]
+	if result.Field != "readme" || result.Score <= 0 {
+		t.Errorf("got field %q and score %v, want readme and a positive score", result.Field, result.Score)
+	}
+	if want := "Wait on &lt;select&gt; &amp; <mark>channels</mark>."; !strings.HasSuffix(result.Snippet, want) {
+		t.Errorf("snippet %q does not end with %q", result.Snippet, want)
+	}
+
+	// Hints are never quoted, even when they are all that matched
+	results = s.Search(SearchQuery{Text: "trick"})
+	if len(results.Results) != 1 || results.Results[0].Snippet != "Register routes for a small API." || results.Results[0].Field != "hints" {
+		t.Errorf("got %+v, want the readme quoted for a hint match", results.Results)
+	}
+
+	if results := s.Search(SearchQuery{Difficulty: "beginner", Limit: 1}); results.Total != 2 {
+		t.Errorf("total %d, want 2 before the limit", results.Total)
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"cancel":       "cancel",
+		"cancelling":   "cancel",
+		"cancelled":    "cancel",
+		"cancellation": "cancel",
+		"running":      "run",
+		"routes":       "route",
+		"queries":      "query",
+		"matches":      "match",
+		"boxes":        "box",
+		"status":       "status",
+		"analysis":     "analysis",
+		"class":        "class",
+		"missed":       "miss",
+		"bed":          "bed",
+		"go":           "go",
+	}
+	for word, want := range tests {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestCamelParts(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"WithContext", []string{"With", "Context"}},
+		{"HTTPServer", []string{"HTTP", "Server"}},
+		{"newHTTPClient", []string{"new", "HTTP", "Client"}},
+		{"utf8Decode", []string{"utf", "8", "Decode"}},
+		{"ID", []string{"ID"}},
+		{"simple", []string{"simple"}},
+	}
+	for _, tt := range tests {
+		if got := camelParts(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("camelParts(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("filler ", 20) + "the <b>deadline</b> & more " + strings.Repeat("padding ", 40)
+	tests := []struct {
+		name    string
+		text    string
+		matched map[string]bool
+		want    string
+	}{
+		{"escapes text and matches", "Use <select> & a channel.", map[string]bool{"channel": true},
+			"Use &lt;select&gt; &amp; a <mark>channel</mark>."},
+		{"marks stemmed and camelCase words", "Call WithContext; contexts cancel.", map[string]bool{"context": true},
+			"Call <mark>WithContext</mark>; <mark>contexts</mark> cancel."},
+		{"no matches shows the start", "a <b> b", nil, "a &lt;b&gt; b"},
+		{"long text is cut around the first match", long, map[string]bool{"deadline": true},
+			"…" + strings.Repeat("filler ", 7) + "the &lt;b&gt;<mark>deadline</mark>&lt;/b&gt; &amp; more" +
+				strings.Repeat(" padding", 15) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snippet(tt.text, tt.matched); got != tt.want {
+				t.Errorf("snippet\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
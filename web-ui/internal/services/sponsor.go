@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"web-ui/internal/metrics"
+)
+
+// SponsorProvider lists the GitHub logins sponsoring the project's maintainer
+type SponsorProvider interface {
+	Name() string
+	Sponsors(ctx context.Context) ([]string, error)
+}
+
+// FileSponsorProvider reads sponsors from a file with one GitHub login per line; blank
+// lines and lines starting with # are skipped
+type FileSponsorProvider struct {
+	Path string
+}
+
+// Name returns the provider identifier
+func (p *FileSponsorProvider) Name() string {
+	return "file"
+}
+
+// Sponsors reads the file
+func (p *FileSponsorProvider) Sponsors(ctx context.Context) ([]string, error) {
+	file, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sponsors []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		login := strings.TrimPrefix(line, "@")
+		if !ValidUsername(login) {
+			return nil, fmt.Errorf("%s: invalid GitHub login %q", p.Path, line)
+		}
+		sponsors = append(sponsors, login)
+	}
+	return sponsors, scanner.Err()
+}
+
+// GitHubSponsorsResponse represents the GitHub GraphQL response for sponsors
+type GitHubSponsorsResponse struct {
+	Data struct {
+		User struct {
+			SponsorshipsAsMaintainer struct {
+				Nodes []struct {
+					SponsorEntity struct {
+						Login string `json:"login"`
+					} `json:"sponsorEntity"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+			} `json:"sponsorshipsAsMaintainer"`
+		} `json:"user"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// sponsorsQuery pages through the public sponsorships of a maintainer
+const sponsorsQuery = `query($login: String!, $after: String) {
+  user(login: $login) {
+    sponsorshipsAsMaintainer(first: 100, after: $after, activeOnly: true) {
+      nodes { sponsorEntity { ... on User { login } ... on Organization { login } } }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// maxSponsorPages bounds the GraphQL pages fetched in one refresh
+const maxSponsorPages = 20
+
+// GraphQLSponsorProvider asks the GitHub GraphQL API for the maintainer's sponsors. It
+// needs a token; any token sees public sponsorships, the maintainer's also private ones.
+type GraphQLSponsorProvider struct {
+	Account    string
+	Token      string
+	APIURL     string // e.g. https://api.github.com
+	httpClient *http.Client
+}
+
+// NewGraphQLSponsorProvider creates a GraphQL provider with the token from
+// GITHUB_SPONSORS_TOKEN, GITHUB_TOKEN or GH_TOKEN and the API from GITHUB_API_URL
+func NewGraphQLSponsorProvider(account string) *GraphQLSponsorProvider {
+	token := os.Getenv("GITHUB_SPONSORS_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	return &GraphQLSponsorProvider{
+		Account:    account,
+		Token:      token,
+		APIURL:     strings.TrimRight(apiURL, "/"),
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name returns the provider identifier
+func (p *GraphQLSponsorProvider) Name() string {
+	return "graphql"
+}
+
+// Sponsors fetches every page of sponsorships
+func (p *GraphQLSponsorProvider) Sponsors(ctx context.Context) ([]string, error) {
+	if p.Token == "" {
+		return nil, fmt.Errorf("no GitHub token: set GITHUB_SPONSORS_TOKEN or GITHUB_TOKEN")
+	}
+
+	var sponsors []string
+	var after *string
+	for page := 0; page < maxSponsorPages; page++ {
+		response, err := p.query(ctx, after)
+		if err != nil {
+			return nil, err
+		}
+		connection := response.Data.User.SponsorshipsAsMaintainer
+		for _, node := range connection.Nodes {
+			if login := node.SponsorEntity.Login; login != "" {
+				sponsors = append(sponsors, login)
+			}
+		}
+		if !connection.PageInfo.HasNextPage {
+			return sponsors, nil
+		}
+		cursor := connection.PageInfo.EndCursor
+		after = &cursor
+	}
+	return sponsors, nil
+}
+
+func (p *GraphQLSponsorProvider) query(ctx context.Context, after *string) (*GitHubSponsorsResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     sponsorsQuery,
+		"variables": map[string]interface{}{"login": p.Account, "after": after},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.APIURL+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-interview-practice-web-ui/1.0")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitHubCall("graphql_sponsors", 0)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	metrics.ObserveGitHubCall("graphql_sponsors", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub GraphQL API returned %s", resp.Status)
+	}
+
+	var response GitHubSponsorsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid GraphQL response: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("GitHub GraphQL API: %s", response.Errors[0].Message)
+	}
+	return &response, nil
+}
+
+var (
+	sponsorAvatarPattern = regexp.MustCompile(`alt="@([a-zA-Z0-9][a-zA-Z0-9\-]*)"`)
+	sponsorLinkPattern   = regexp.MustCompile(`href="/([a-zA-Z0-9][a-zA-Z0-9\-]+)"`)
+)
+
+// ScraperSponsorProvider reads sponsors from the public github.com/sponsors page. It
+// breaks whenever GitHub changes the page's markup, so it is only a fallback.
+type ScraperSponsorProvider struct {
+	Account    string
+	httpClient *http.Client
+}
+
+// NewScraperSponsorProvider creates a scraper for the account's sponsors page
+func NewScraperSponsorProvider(account string) *ScraperSponsorProvider {
+	return &ScraperSponsorProvider{
+		Account:    account,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider identifier
+func (p *ScraperSponsorProvider) Name() string {
+	return "scraper"
+}
+
+// Sponsors scrapes the sponsors page for avatar alt texts, falling back to profile links
+func (p *ScraperSponsorProvider) Sponsors(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://github.com/sponsors/"+p.Account, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Set user agent to avoid being blocked
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GoSponsorScraper/1.0)")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		metrics.ObserveGitHubCall("sponsors_page", 0)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	metrics.ObserveGitHubCall("sponsors_page", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub sponsors page returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	html := string(body)
+
+	found := make(map[string]bool)
+	for _, match := range sponsorAvatarPattern.FindAllStringSubmatch(html, -1) {
+		if match[1] != p.Account {
+			found[match[1]] = true
+		}
+	}
+	// Fallback: if no sponsors found with avatar method, try href patterns that aren't common GitHub paths
+	if len(found) == 0 {
+		for _, match := range sponsorLinkPattern.FindAllStringSubmatch(html, -1) {
+			username := match[1]
+			if username != "sponsors" && username != "github" && username != p.Account && len(username) > 2 {
+				found[username] = true
+			}
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no sponsors found on the sponsors page; its markup may have changed")
+	}
+
+	sponsors := make([]string, 0, len(found))
+	for login := range found {
+		sponsors = append(sponsors, login)
+	}
+	return sponsors, nil
+}
+
+// sponsorRefreshTimeout bounds one refresh across all providers
+const sponsorRefreshTimeout = time.Minute
+
+// SponsorList is the sponsor list as persisted to disk
+type SponsorList struct {
+	Sponsors  []string  `json:"sponsors"`
+	Source    string    `json:"source"` // Name of the provider the list came from
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SponsorService serves the sponsor list from memory. It is refreshed in the background
+// from the first provider that answers and persisted, so requests never wait for GitHub
+// and a restart starts from the last known list.
+type SponsorService struct {
+	providers []SponsorProvider
+	interval  time.Duration
+	path      string
+
+	mu       sync.RWMutex
+	list     SponsorList
+	sponsors map[string]bool
+
+	refresh chan struct{}
+}
+
+// NewSponsorService creates a sponsor service that tries providers in order, refreshes
+// every interval and persists the list to <dataDir>/sponsors.json
+func NewSponsorService(dataDir string, interval time.Duration, providers ...SponsorProvider) *SponsorService {
+	ss := &SponsorService{
+		providers: providers,
+		interval:  interval,
+		path:      filepath.Join(dataDir, "sponsors.json"),
+		sponsors:  make(map[string]bool),
+		refresh:   make(chan struct{}, 1),
+	}
+	if err := ss.load(); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Could not read the saved sponsor list", "path", ss.path, "error", err)
+	}
+	return ss
+}
+
+// Sponsors returns the logins of the current sponsors; the map must not be modified
+func (ss *SponsorService) Sponsors() map[string]bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sponsors
+}
+
+// List returns the sponsor list with its source and age
+func (ss *SponsorService) List() SponsorList {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.list
+}
+
+// Start refreshes the list in the background: at once if the saved list is missing or
+// older than the interval, then every interval and whenever RequestRefresh is called
+func (ss *SponsorService) Start() {
+	go func() {
+		wait := ss.interval - time.Since(ss.List().UpdatedAt)
+		if wait < 0 {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+		for {
+			select {
+			case <-timer.C:
+			case <-ss.refresh:
+				if !timer.Stop() {
+					<-timer.C
+				}
+			}
+			if err := ss.Refresh(context.Background()); err != nil {
+				slog.Warn("Could not refresh sponsors; keeping the previous list", "error", err)
+			}
+			timer.Reset(ss.interval)
+		}
+	}()
+}
+
+// RequestRefresh asks the background loop to refresh soon, without waiting for it
+func (ss *SponsorService) RequestRefresh() {
+	select {
+	case ss.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Subscribe refreshes the list when a sponsorship webhook arrives
+func (ss *SponsorService) Subscribe(bus *EventBus) {
+	bus.Subscribe(EventSponsorship, func(event Event) {
+		slog.Info("Sponsorship changed, refreshing sponsors", "delivery", event.Delivery)
+		ss.RequestRefresh()
+	})
+}
+
+// Refresh asks the providers in order and keeps the first list returned
+func (ss *SponsorService) Refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, sponsorRefreshTimeout)
+	defer cancel()
+
+	var failures []string
+	for _, provider := range ss.providers {
+		sponsors, err := provider.Sponsors(ctx)
+		if err != nil {
+			slog.Debug("Sponsor provider failed", "provider", provider.Name(), "error", err)
+			failures = append(failures, provider.Name()+": "+err.Error())
+			continue
+		}
+		ss.set(SponsorList{Sponsors: sponsors, Source: provider.Name(), UpdatedAt: time.Now()})
+		if err := ss.save(); err != nil {
+			slog.Warn("Could not save the sponsor list", "path", ss.path, "error", err)
+		}
+		slog.Info("Refreshed sponsors", "provider", provider.Name(), "count", len(sponsors))
+		return nil
+	}
+	if len(failures) == 0 {
+		return fmt.Errorf("no sponsor providers configured")
+	}
+	return fmt.Errorf("all sponsor providers failed: %s", strings.Join(failures, "; "))
+}
+
+func (ss *SponsorService) set(list SponsorList) {
+	sort.Strings(list.Sponsors)
+	sponsors := make(map[string]bool, len(list.Sponsors))
+	for _, login := range list.Sponsors {
+		sponsors[login] = true
+	}
+	ss.mu.Lock()
+	ss.list = list
+	ss.sponsors = sponsors
+	ss.mu.Unlock()
+}
+
+func (ss *SponsorService) load() error {
+	data, err := os.ReadFile(ss.path)
+	if err != nil {
+		return err
+	}
+	var list SponsorList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	ss.set(list)
+	return nil
+}
+
+func (ss *SponsorService) save() error {
+	return writeJSONFile(ss.path, ss.List())
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSponsorProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		err     string // Substring of the error
+	}{
+		{"logins", "alice\nbob\n", []string{"alice", "bob"}, ""},
+		{"comments, blanks and @", "# Sponsors\n\n  @alice  \n# bob\ncarol-d\n", []string{"alice", "carol-d"}, ""},
+		{"empty", "# nobody yet\n", nil, ""},
+		{"invalid login", "alice\nnot a login\n", nil, `invalid GitHub login "not a login"`},
+		{"path in login", "../alice\n", nil, "invalid GitHub login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sponsors.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := (&FileSponsorProvider{Path: path}).Sponsors(context.Background())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %q, %v; want error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&FileSponsorProvider{Path: filepath.Join(t.TempDir(), "missing.txt")}).Sponsors(context.Background()); !os.IsNotExist(err) {
+		t.Errorf("missing file: got %v, want a not-exist error", err)
+	}
+}
+
+// fakeSponsorProvider returns a fixed list or error and counts its calls
+type fakeSponsorProvider struct {
+	name     string
+	sponsors []string
+	err      error
+	calls    int
+}
+
+func (p *fakeSponsorProvider) Name() string { return p.name }
+
+func (p *fakeSponsorProvider) Sponsors(ctx context.Context) ([]string, error) {
+	p.calls++
+	return p.sponsors, p.err
+}
+
+func TestSponsorRefreshFallsThroughProviders(t *testing.T) {
+	down := errors.New("down")
+	tests := []struct {
+		name      string
+		providers []*fakeSponsorProvider
+		source    string   // Provider the list should come from; "" if the refresh fails
+		sponsors  []string // Sorted
+		calls     []int    // Calls per provider
+		err       string   // Substring of the error
+	}{
+		{"first answers",
+			[]*fakeSponsorProvider{{name: "graphql", sponsors: []string{"bob", "alice"}}, {name: "file", sponsors: []string{"carol"}}},
+			"graphql", []string{"alice", "bob"}, []int{1, 0}, ""},
+		{"falls through failures in order",
+			[]*fakeSponsorProvider{{name: "graphql", err: down}, {name: "scraper", err: down}, {name: "file", sponsors: []string{"carol"}}},
+			"file", []string{"carol"}, []int{1, 1, 1}, ""},
+		{"an empty list is an answer",
+			[]*fakeSponsorProvider{{name: "graphql", sponsors: []string{}}, {name: "file", sponsors: []string{"carol"}}},
+			"graphql", []string{}, []int{1, 0}, ""},
+		{"all fail",
+			[]*fakeSponsorProvider{{name: "graphql", err: down}, {name: "file", err: errors.New("no file")}},
+			"", nil, []int{1, 1}, "graphql: down; file: no file"},
+		{"no providers", nil, "", nil, nil, "no sponsor providers configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			providers := make([]SponsorProvider, len(tt.providers))
+			for i, p := range tt.providers {
+				providers[i] = p
+			}
+			ss := NewSponsorService(dataDir, 0, providers...)
+
+			err := ss.Refresh(context.Background())
+			for i, p := range tt.providers {
+				if p.calls != tt.calls[i] {
+					t.Errorf("provider %s called %d times, want %d", p.name, p.calls, tt.calls[i])
+				}
+			}
+			if tt.source == "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				if list := ss.List(); list.Source != "" || len(list.Sponsors) != 0 {
+					t.Errorf("failed refresh changed the list to %+v", list)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			list := ss.List()
+			if list.Source != tt.source || !reflect.DeepEqual(list.Sponsors, tt.sponsors) || list.UpdatedAt.IsZero() {
+				t.Errorf("got list %+v, want %q from %s", list, tt.sponsors, tt.source)
+			}
+			for _, login := range tt.sponsors {
+				if !ss.Sponsors()[login] {
+					t.Errorf("%s is not a sponsor", login)
+				}
+			}
+
+			// The list is saved and loaded by the next service
+			reloaded := NewSponsorService(dataDir, 0).List()
+			if reloaded.Source != tt.source || len(reloaded.Sponsors) != len(tt.sponsors) {
+				t.Errorf("reloaded list %+v, want %q from %s", reloaded, tt.sponsors, tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	testPassPattern   = regexp.MustCompile(`(?m)^\s*--- PASS: `)
+	testFailPattern   = regexp.MustCompile(`(?m)^\s*--- FAIL: `)
+	testRunPattern    = regexp.MustCompile(`^=== (?:RUN|CONT)\s+(\S+)`)
+	testResultPattern = regexp.MustCompile(`^(\s*)--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+s)\)`)
+)
+
+// TestCase is the result of a single test or subtest in `go test -v` output
+type TestCase struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"` // PASS, FAIL or SKIP
+	Duration string `json:"duration"`
+	Depth    int    `json:"depth"`            // 0 for top-level tests, 1+ for subtests
+	Output   string `json:"output,omitempty"` // Log lines reported under a failing test
+}
+
+// Passed reports whether the test passed (skipped tests count as passed)
+func (tc TestCase) Passed() bool {
+	return tc.Status != "FAIL"
+}
+
+// CountTestResults counts passing and failing tests (including subtests) in `go test -v` output,
+// the same way the scoreboard workflow does
+func CountTestResults(output string) (passed int, total int) {
+	passed = len(testPassPattern.FindAllString(output, -1))
+	failed := len(testFailPattern.FindAllString(output, -1))
+	return passed, passed + failed
+}
+
+// ParseTestCases extracts every test and subtest result from `go test -v` output, in order
+func ParseTestCases(output string) []TestCase {
+	var cases []TestCase
+	logs := make(map[string]*strings.Builder)
+	running := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		if match := testRunPattern.FindStringSubmatch(line); match != nil {
+			running = match[1]
+			continue
+		}
+
+		if match := testResultPattern.FindStringSubmatch(line); match != nil {
+			testCase := TestCase{
+				Name:     match[3],
+				Status:   match[2],
+				Duration: match[4],
+				Depth:    len(match[1]) / 4,
+			}
+			if testCase.Status == "FAIL" && logs[testCase.Name] != nil {
+				testCase.Output = logs[testCase.Name].String()
+			}
+			cases = append(cases, testCase)
+			continue
+		}
+
+		// t.Error and t.Log lines are printed indented while their test is running
+		if running != "" && strings.HasPrefix(line, "    ") {
+			if logs[running] == nil {
+				logs[running] = &strings.Builder{}
+			}
+			logs[running].WriteString(strings.TrimSpace(line) + "\n")
+		}
+	}
+	return cases
+}
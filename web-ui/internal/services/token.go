@@ -0,0 +1,325 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token scopes
+const (
+	ScopeRead   = "read"
+	ScopeRun    = "run"
+	ScopeSubmit = "submit"
+)
+
+// AllScopes lists every scope a token can be granted
+var AllScopes = []string{ScopeRead, ScopeRun, ScopeSubmit}
+
+const (
+	tokenPrefix           = "gip_"
+	defaultTokenRateLimit = 60  // requests per minute
+	maxTokenRateLimit     = 600 // requests per minute
+	lastUsedPersistPeriod = time.Minute
+)
+
+var (
+	// ErrInvalidToken is returned for unknown or revoked tokens
+	ErrInvalidToken = errors.New("invalid or revoked API token")
+	// ErrTokenNotFound is returned when revoking a token the user does not own
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// APIToken is a personal access token. Only the SHA-256 hash of the secret is stored.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the secret, for display
+	Hash       string     `json:"hash"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"` // Requests per minute
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token was granted the scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenInfo is the public view of a token returned by list/create
+type APITokenInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateTokenRequest describes a new token
+type CreateTokenRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	RateLimit int      `json:"rate_limit"`
+}
+
+// TokenService manages personal API tokens persisted to a JSON file
+type TokenService struct {
+	path          string
+	tokens        map[string]*APIToken // hash -> token
+	limiters      map[string]*TokenBucket
+	lastPersisted time.Time
+	mutex         sync.RWMutex
+}
+
+// NewTokenService creates a token service storing tokens in dataDir
+func NewTokenService(dataDir string) *TokenService {
+	ts := &TokenService{
+		path:     filepath.Join(dataDir, "tokens.json"),
+		tokens:   make(map[string]*APIToken),
+		limiters: make(map[string]*TokenBucket),
+	}
+	if err := ts.load(); err != nil {
+		slog.Warn("Could not load API tokens", "error", err)
+	}
+	return ts
+}
+
+// Create issues a new token for the user and returns the plaintext secret once
+func (ts *TokenService) Create(username string, request CreateTokenRequest) (string, *APITokenInfo, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+
+	scopes, err := normalizeScopes(request.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rateLimit := request.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultTokenRateLimit
+	}
+	if rateLimit > maxTokenRateLimit {
+		rateLimit = maxTokenRateLimit
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, err
+	}
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+	secret := tokenPrefix + hex.EncodeToString(secretBytes)
+
+	token := &APIToken{
+		ID:        hex.EncodeToString(idBytes),
+		Username:  username,
+		Name:      name,
+		Prefix:    secret[:len(tokenPrefix)+6],
+		Hash:      hashToken(secret),
+		Scopes:    scopes,
+		RateLimit: rateLimit,
+		CreatedAt: time.Now(),
+	}
+
+	ts.mutex.Lock()
+	ts.tokens[token.Hash] = token
+	err = ts.saveLocked()
+	ts.mutex.Unlock()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return secret, token.info(), nil
+}
+
+// List returns the tokens owned by the user, newest first
+func (ts *TokenService) List(username string) []*APITokenInfo {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+
+	infos := []*APITokenInfo{}
+	for _, token := range ts.tokens {
+		if token.Username == username {
+			infos = append(infos, token.info())
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// Revoke deletes a token owned by the user
+func (ts *TokenService) Revoke(username, id string) error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	for hash, token := range ts.tokens {
+		if token.ID == id && token.Username == username {
+			delete(ts.tokens, hash)
+			delete(ts.limiters, token.ID)
+			return ts.saveLocked()
+		}
+	}
+	return ErrTokenNotFound
+}
+
+// Authenticate resolves a bearer secret to its token and records its use
+func (ts *TokenService) Authenticate(secret string) (*APIToken, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+	hash := hashToken(secret)
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	token, ok := ts.tokens[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	token.LastUsedAt = &now
+	// Persist last-used timestamps at most once per period to avoid a write per request
+	if now.Sub(ts.lastPersisted) > lastUsedPersistPeriod {
+		if err := ts.saveLocked(); err != nil {
+			slog.Warn("Could not persist API token usage", "error", err)
+		}
+	}
+
+	copied := *token
+	return &copied, nil
+}
+
+// Allow applies the token's per-minute rate limit
+func (ts *TokenService) Allow(token *APIToken) (bool, time.Duration) {
+	ts.mutex.Lock()
+	limiter, ok := ts.limiters[token.ID]
+	if !ok {
+		limiter = NewTokenBucket(token.RateLimit, time.Minute)
+		ts.limiters[token.ID] = limiter
+	}
+	ts.mutex.Unlock()
+
+	if limiter.Allow() {
+		return true, 0
+	}
+	return false, limiter.RetryAfter()
+}
+
+func (ts *TokenService) load() error {
+	data, err := os.ReadFile(ts.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var tokens []*APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		ts.tokens[token.Hash] = token
+	}
+	return nil
+}
+
+// saveLocked writes all tokens to disk; callers must hold the write lock
+func (ts *TokenService) saveLocked() error {
+	tokens := make([]*APIToken, 0, len(ts.tokens))
+	for _, token := range ts.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := ts.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, ts.path); err != nil {
+		return err
+	}
+	ts.lastPersisted = time.Now()
+	return nil
+}
+
+func (t *APIToken) info() *APITokenInfo {
+	return &APITokenInfo{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		RateLimit:  t.RateLimit,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes validates and de-duplicates requested scopes, defaulting to read-only
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return []string{ScopeRead}, nil
+	}
+
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, scope := range requested {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		valid := false
+		for _, known := range AllScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown scope %q (allowed: %s)", scope, strings.Join(AllScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenCreateStoresOnlyHash(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTokenService(dir)
+
+	secret, info, err := ts.Create("alice", CreateTokenRequest{Name: "laptop"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		t.Errorf("secret %q does not start with %q", secret, tokenPrefix)
+	}
+	if !strings.HasPrefix(secret, info.Prefix) {
+		t.Errorf("prefix %q is not the start of the secret", info.Prefix)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatalf("reading tokens.json: %v", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("tokens.json contains the plaintext secret")
+	}
+	if !strings.Contains(string(data), hashToken(secret)) {
+		t.Error("tokens.json does not contain the secret's hash")
+	}
+}
+
+func TestTokenAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTokenService(dir)
+	secret, info, err := ts.Create("alice", CreateTokenRequest{Name: "ci", Scopes: []string{"run"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		ok     bool
+	}{
+		{"valid", secret, true},
+		{"empty", "", false},
+		{"missing prefix", strings.TrimPrefix(secret, tokenPrefix), false},
+		{"altered", secret[:len(secret)-1] + "x", false},
+		{"hash instead of secret", hashToken(secret), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ts.Authenticate(tt.secret)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Authenticate: %v", err)
+				}
+				if token.Username != "alice" || token.ID != info.ID {
+					t.Errorf("got token for %s/%s, want alice/%s", token.Username, token.ID, info.ID)
+				}
+				return
+			}
+			if err != ErrInvalidToken {
+				t.Errorf("got error %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	// Tokens survive a restart and stop working once revoked
+	reloaded := NewTokenService(dir)
+	if _, err := reloaded.Authenticate(secret); err != nil {
+		t.Fatalf("Authenticate after reload: %v", err)
+	}
+	if err := reloaded.Revoke("bob", info.ID); err != ErrTokenNotFound {
+		t.Errorf("revoking another user's token: got %v, want ErrTokenNotFound", err)
+	}
+	if err := reloaded.Revoke("alice", info.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := reloaded.Authenticate(secret); err != ErrInvalidToken {
+		t.Errorf("Authenticate after revoke: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		want      []string
+		wantErr   bool
+	}{
+		{"default is read only", nil, []string{ScopeRead}, false},
+		{"normalized and deduplicated", []string{" Run", "run", "SUBMIT"}, []string{ScopeRun, ScopeSubmit}, false},
+		{"unknown scope", []string{"read", "admin"}, nil, true},
+		{"empty scope", []string{""}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewTokenService(t.TempDir())
+			secret, _, err := ts.Create("alice", CreateTokenRequest{Name: "t", Scopes: tt.requested})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Create succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			token, err := ts.Authenticate(secret)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			for _, scope := range AllScopes {
+				granted := false
+				for _, want := range tt.want {
+					granted = granted || want == scope
+				}
+				if token.HasScope(scope) != granted {
+					t.Errorf("HasScope(%q) = %v, want %v", scope, !granted, granted)
+				}
+				identity := &Identity{Username: "alice", Token: token}
+				if identity.HasScope(scope) != granted {
+					t.Errorf("Identity.HasScope(%q) = %v, want %v", scope, !granted, granted)
+				}
+			}
+		})
+	}
+
+	// Browser sessions are not limited by scopes
+	session := &Identity{Username: "alice", Provider: "github"}
+	for _, scope := range AllScopes {
+		if !session.HasScope(scope) {
+			t.Errorf("session identity lacks scope %q", scope)
+		}
+	}
+}
@@ -14,13 +14,15 @@ import (
 
 // UserService handles user-related operations
 type UserService struct {
+	root         string
 	userAttempts models.UserAttemptsMap
 	mutex        sync.RWMutex
 }
 
-// NewUserService creates a new user service
-func NewUserService() *UserService {
+// NewUserService creates a user service for the repository at repoRoot
+func NewUserService(repoRoot string) *UserService {
 	return &UserService{
+		root:         repoRoot,
 		userAttempts: make(models.UserAttemptsMap),
 	}
 }
@@ -61,48 +63,29 @@ func (us *UserService) LoadUserAttempts(username string, challenges models.Chall
 
 // hasUserSubmission checks if a user has a submission for a challenge
 func (us *UserService) hasUserSubmission(username string, challengeID int) bool {
-	// Try different path formats to handle potential path issues
-	// Absolute path
-	submissionDir := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "submissions", username)
-	submissionFile := filepath.Join(submissionDir, "solution-template.go")
-
-	// Check if the file exists
-	if _, err := os.Stat(submissionFile); err == nil {
-		return true
-	}
-
-	// Alternative path (direct from workspace root)
-	altSubmissionFile := filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-
-	if _, err := os.Stat(altSubmissionFile); err == nil {
-		return true
+	if !ValidUsername(username) {
+		return false
 	}
+	_, err := os.Stat(us.submissionFile(username, challengeID))
+	return err == nil
+}
 
-	return false
+// submissionFile returns the path of a user's solution to a challenge
+func (us *UserService) submissionFile(username string, challengeID int) string {
+	return filepath.Join(us.root, fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
 }
 
 // GetExistingSolution returns the content of an existing solution file if it exists
 func (us *UserService) GetExistingSolution(username string, challengeID int) string {
-	if username == "" {
+	if !ValidUsername(username) {
 		return ""
 	}
 
-	// Try different path formats
-	// First try the relative path from web-ui
-	submissionFile := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-	content, err := ioutil.ReadFile(submissionFile)
-	if err == nil {
-		return string(content)
-	}
-
-	// Try alternative path from root directory
-	altSubmissionFile := filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "submissions", username, "solution-template.go")
-	content, err = ioutil.ReadFile(altSubmissionFile)
-	if err == nil {
-		return string(content)
+	content, err := ioutil.ReadFile(us.submissionFile(username, challengeID))
+	if err != nil {
+		return ""
 	}
-
-	return ""
+	return string(content)
 }
 
 // RefreshUserAttempts clears the cache for a user and reloads their attempts
@@ -129,16 +112,11 @@ func (us *UserService) GetUserAttempts(username string, challenges models.Challe
 // calculateScore calculates the score for a user's submission for a challenge
 func (us *UserService) calculateScore(username string, challengeID int) int {
 	// Read the scoreboard file for this challenge
-	scoreboardPath := filepath.Join("..", fmt.Sprintf("challenge-%d", challengeID), "SCOREBOARD.md")
+	scoreboardPath := filepath.Join(us.root, fmt.Sprintf("challenge-%d", challengeID), "SCOREBOARD.md")
 	content, err := ioutil.ReadFile(scoreboardPath)
 	if err != nil {
-		// Try alternative path
-		scoreboardPath = filepath.Join(fmt.Sprintf("challenge-%d", challengeID), "SCOREBOARD.md")
-		content, err = ioutil.ReadFile(scoreboardPath)
-		if err != nil {
-			// No scoreboard file, return default score
-			return 50
-		}
+		// No scoreboard file, return default score
+		return 50
 	}
 
 	scoreboardContent := string(content)
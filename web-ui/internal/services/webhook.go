@@ -0,0 +1,201 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxRememberedDeliveries bounds the delivery IDs kept to recognize redeliveries
+const maxRememberedDeliveries = 1000
+
+// PushEvent is a GitHub push webhook
+type PushEvent struct {
+	Repository string // owner/name
+	Ref        string // e.g. refs/heads/main
+	Before     string
+	After      string
+	Deleted    bool
+	Pusher     string
+}
+
+// Branch returns the pushed branch, or "" for tags
+func (e *PushEvent) Branch() string {
+	if !strings.HasPrefix(e.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(e.Ref, "refs/heads/")
+}
+
+// PullRequestEvent is a GitHub pull_request webhook
+type PullRequestEvent struct {
+	Repository string
+	Action     string // opened, synchronize, reopened, closed, ...
+	Number     int
+	Author     string
+	BaseRef    string
+	BaseSHA    string
+	HeadSHA    string
+	Merged     bool
+}
+
+// SponsorshipEvent is a GitHub sponsorship webhook
+type SponsorshipEvent struct {
+	Action  string // created, cancelled, tier_changed, ...
+	Sponsor string
+	Tier    string
+}
+
+// WebhookService verifies GitHub webhook deliveries and recognizes redeliveries
+type WebhookService struct {
+	secret     []byte
+	mu         sync.Mutex
+	deliveries map[string]bool
+	order      []string // Remembered deliveries, oldest first
+}
+
+// NewWebhookService creates a webhook service with the secret from GITHUB_WEBHOOK_SECRET.
+// Without a secret every delivery is rejected.
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		secret:     []byte(os.Getenv("GITHUB_WEBHOOK_SECRET")),
+		deliveries: make(map[string]bool),
+	}
+}
+
+// Enabled reports whether a webhook secret is configured
+func (ws *WebhookService) Enabled() bool {
+	return len(ws.secret) > 0
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header, "sha256=" followed by the
+// hex HMAC-SHA256 of the body keyed with the webhook secret
+func (ws *WebhookService) VerifySignature(body []byte, signature string) bool {
+	if !ws.Enabled() || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, ws.secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Remember records a delivery ID and reports whether it is new. GitHub reuses the ID
+// when a delivery is retried or redelivered.
+func (ws *WebhookService) Remember(delivery string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.deliveries[delivery] {
+		return false
+	}
+	ws.deliveries[delivery] = true
+	ws.order = append(ws.order, delivery)
+	if len(ws.order) > maxRememberedDeliveries {
+		delete(ws.deliveries, ws.order[0])
+		ws.order = ws.order[1:]
+	}
+	return true
+}
+
+// Forget drops a delivery ID, so a delivery that could not be handled can be retried
+func (ws *WebhookService) Forget(delivery string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	delete(ws.deliveries, delivery)
+}
+
+// ParseWebhookEvent decodes the payload of a push, pull_request or sponsorship webhook.
+// It returns nil without an error for other event types.
+func ParseWebhookEvent(eventType string, body []byte) (interface{}, error) {
+	switch eventType {
+	case EventPush:
+		var payload struct {
+			Ref        string `json:"ref"`
+			Before     string `json:"before"`
+			After      string `json:"after"`
+			Deleted    bool   `json:"deleted"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Pusher struct {
+				Name string `json:"name"`
+			} `json:"pusher"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid push payload: %v", err)
+		}
+		return &PushEvent{
+			Repository: payload.Repository.FullName,
+			Ref:        payload.Ref,
+			Before:     payload.Before,
+			After:      payload.After,
+			Deleted:    payload.Deleted,
+			Pusher:     payload.Pusher.Name,
+		}, nil
+
+	case EventPullRequest:
+		var payload struct {
+			Action      string `json:"action"`
+			Number      int    `json:"number"`
+			PullRequest struct {
+				User struct {
+					Login string `json:"login"`
+				} `json:"user"`
+				Base struct {
+					Ref string `json:"ref"`
+					SHA string `json:"sha"`
+				} `json:"base"`
+				Head struct {
+					SHA string `json:"sha"`
+				} `json:"head"`
+				Merged bool `json:"merged"`
+			} `json:"pull_request"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid pull_request payload: %v", err)
+		}
+		return &PullRequestEvent{
+			Repository: payload.Repository.FullName,
+			Action:     payload.Action,
+			Number:     payload.Number,
+			Author:     payload.PullRequest.User.Login,
+			BaseRef:    payload.PullRequest.Base.Ref,
+			BaseSHA:    payload.PullRequest.Base.SHA,
+			HeadSHA:    payload.PullRequest.Head.SHA,
+			Merged:     payload.PullRequest.Merged,
+		}, nil
+
+	case EventSponsorship:
+		var payload struct {
+			Action      string `json:"action"`
+			Sponsorship struct {
+				Sponsor struct {
+					Login string `json:"login"`
+				} `json:"sponsor"`
+				Tier struct {
+					Name string `json:"name"`
+				} `json:"tier"`
+			} `json:"sponsorship"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid sponsorship payload: %v", err)
+		}
+		return &SponsorshipEvent{
+			Action:  payload.Action,
+			Sponsor: payload.Sponsorship.Sponsor.Login,
+			Tier:    payload.Sponsorship.Tier.Name,
+		}, nil
+	}
+	return nil, nil
+}
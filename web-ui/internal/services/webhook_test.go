@@ -0,0 +1,78 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// signWebhook returns the X-Hub-Signature-256 GitHub sends for body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookVerifySignature(t *testing.T) {
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	ws := NewWebhookService()
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	valid := signWebhook("secret", body)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", body, valid, true},
+		{"missing", body, "", false},
+		{"other secret", body, signWebhook("other", body), false},
+		{"altered body", []byte(`{"ref":"refs/heads/evil"}`), valid, false},
+		{"truncated", body, valid[:len(valid)-2], false},
+		{"not hex", body, "sha256=zz", false},
+		{"without prefix", body, valid[len("sha256="):], false},
+		{"SHA-1 header format", body, "sha1=" + valid[len("sha256="):], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ws.VerifySignature(tt.body, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "")
+	if disabled := NewWebhookService(); disabled.VerifySignature(body, signWebhook("", body)) {
+		t.Error("accepted a delivery without a configured secret")
+	}
+}
+
+func TestWebhookRemember(t *testing.T) {
+	ws := NewWebhookService()
+	if !ws.Remember("a") {
+		t.Fatal("first delivery reported as a duplicate")
+	}
+	if ws.Remember("a") {
+		t.Error("redelivery not recognized")
+	}
+
+	// A delivery that could not be queued may be retried
+	ws.Forget("a")
+	if !ws.Remember("a") {
+		t.Error("forgotten delivery reported as a duplicate")
+	}
+
+	// Only the latest deliveries are remembered
+	for i := 0; i < maxRememberedDeliveries; i++ {
+		ws.Remember(fmt.Sprintf("d%d", i))
+	}
+	if !ws.Remember("a") {
+		t.Error("oldest delivery still remembered past the limit")
+	}
+	if ws.Remember(fmt.Sprintf("d%d", maxRememberedDeliveries-1)) {
+		t.Error("recent delivery forgotten")
+	}
+}
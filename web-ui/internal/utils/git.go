@@ -13,27 +13,28 @@ type GitUserInfo struct {
 	Source   string // "git-config", "remote-origin", "not-found"
 }
 
-// GetGitUsername attempts to extract the GitHub username from git configuration
-func GetGitUsername() *GitUserInfo {
+// GetGitUsername attempts to extract the GitHub username from the git configuration
+// of the repository at dir, or of the working directory if dir is empty
+func GetGitUsername(dir string) *GitUserInfo {
 	info := &GitUserInfo{
 		Source: "not-found",
 	}
 
 	// Try to get from git remote origin URL first (most reliable for GitHub username)
-	if username := getGitUsernameFromRemote(); username != "" {
+	if username := getGitUsernameFromRemote(dir); username != "" {
 		info.Username = username
 		info.Source = "remote-origin"
 		return info
 	}
 
 	// Fallback to git config user.name
-	if username := getGitConfigValue("user.name"); username != "" {
+	if username := getGitConfigValue(dir, "user.name"); username != "" {
 		info.Username = username
 		info.Source = "git-config"
 	}
 
 	// Also get email for reference
-	if email := getGitConfigValue("user.email"); email != "" {
+	if email := getGitConfigValue(dir, "user.email"); email != "" {
 		info.Email = email
 		// If we got username from config but not remote, try to extract from email
 		if info.Username == "" && strings.Contains(email, "@") {
@@ -47,8 +48,9 @@ func GetGitUsername() *GitUserInfo {
 }
 
 // getGitUsernameFromRemote extracts username from git remote origin URL
-func getGitUsernameFromRemote() string {
+func getGitUsernameFromRemote(dir string) string {
 	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -72,8 +74,9 @@ func getGitUsernameFromRemote() string {
 }
 
 // getGitConfigValue gets a value from git config
-func getGitConfigValue(key string) string {
+func getGitConfigValue(dir, key string) string {
 	cmd := exec.Command("git", "config", "--get", key)
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -4,95 +4,10 @@ import (
 	"fmt"
 	"html/template"
 	"reflect"
-	"regexp"
 	"strings"
-)
-
-// Simple markdown to HTML converter
-func markdownToHTML(markdown string) string {
-	html := markdown
-
-	// Convert headers
-	html = regexp.MustCompile(`(?m)^#{6}\s+(.+)$`).ReplaceAllString(html, "<h6>$1</h6>")
-	html = regexp.MustCompile(`(?m)^#{5}\s+(.+)$`).ReplaceAllString(html, "<h5>$1</h5>")
-	html = regexp.MustCompile(`(?m)^#{4}\s+(.+)$`).ReplaceAllString(html, "<h4>$1</h4>")
-	html = regexp.MustCompile(`(?m)^#{3}\s+(.+)$`).ReplaceAllString(html, "<h3>$1</h3>")
-	html = regexp.MustCompile(`(?m)^#{2}\s+(.+)$`).ReplaceAllString(html, "<h2>$1</h2>")
-	html = regexp.MustCompile(`(?m)^#{1}\s+(.+)$`).ReplaceAllString(html, "<h1>$1</h1>")
-
-	// Convert bold text
-	html = regexp.MustCompile(`\*\*(.+?)\*\*`).ReplaceAllString(html, "<strong>$1</strong>")
-
-	// Convert italic text
-	html = regexp.MustCompile(`\*([^*]+)\*`).ReplaceAllString(html, "<em>$1</em>")
-
-	// Convert code blocks
-	html = regexp.MustCompile("(?s)```(\\w*)\\n(.*?)```").ReplaceAllStringFunc(html, func(match string) string {
-		parts := regexp.MustCompile("(?s)```(\\w*)\\n(.*?)```").FindStringSubmatch(match)
-		if len(parts) >= 3 {
-			language := parts[1]
-			code := strings.TrimSpace(parts[2])
-			if language != "" {
-				return fmt.Sprintf(`<pre><code class="language-%s">%s</code></pre>`, language, template.HTMLEscapeString(code))
-			}
-			return fmt.Sprintf(`<pre><code>%s</code></pre>`, template.HTMLEscapeString(code))
-		}
-		return match
-	})
-
-	// Convert inline code
-	html = regexp.MustCompile("`([^`]+)`").ReplaceAllString(html, "<code>$1</code>")
-
-	// Convert links
-	html = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`).ReplaceAllString(html, `<a href="$2" target="_blank">$1</a>`)
-
-	// Convert lists (simple implementation)
-	lines := strings.Split(html, "\n")
-	var result []string
-	inList := false
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Handle numbered lists
-		if matched, _ := regexp.MatchString(`^\d+\.\s+`, trimmed); matched {
-			if !inList {
-				result = append(result, "<ol>")
-				inList = true
-			}
-			content := regexp.MustCompile(`^\d+\.\s+`).ReplaceAllString(trimmed, "")
-			result = append(result, fmt.Sprintf("<li>%s</li>", content))
-		} else if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
-			if !inList {
-				result = append(result, "<ul>")
-				inList = true
-			}
-			content := strings.TrimPrefix(strings.TrimPrefix(trimmed, "- "), "* ")
-			result = append(result, fmt.Sprintf("<li>%s</li>", content))
-		} else {
-			if inList {
-				result = append(result, "</ul>")
-				inList = false
-			}
-			if trimmed != "" {
-				// Check if the line is already HTML (header, code block, etc.)
-				if strings.HasPrefix(trimmed, "<h") || strings.HasPrefix(trimmed, "<pre") ||
-					strings.HasPrefix(trimmed, "<div") || strings.HasPrefix(trimmed, "<code") ||
-					strings.HasPrefix(trimmed, "<blockquote") || strings.HasPrefix(trimmed, "<hr") {
-					result = append(result, trimmed)
-				} else {
-					result = append(result, fmt.Sprintf("<p>%s</p>", trimmed))
-				}
-			}
-		}
-	}
-
-	if inList {
-		result = append(result, "</ul>")
-	}
-
-	return strings.Join(result, "\n")
-}
+	"web-ui/internal/markdown"
+)
 
 // GetTemplateFuncs returns the template functions used across the application
 func GetTemplateFuncs() template.FuncMap {
@@ -410,7 +325,7 @@ func GetTemplateFuncs() template.FuncMap {
 			return template.HTML(s)
 		},
 		"markdown": func(s string) template.HTML {
-			return template.HTML(markdownToHTML(s))
+			return markdown.Render(s)
 		},
 		"formatStars": func(stars int) string {
 			if stars >= 1000000 {
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"bufio"
 	"embed"
-	"fmt"
+	"flag"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"strings"
 
+	"web-ui/internal/config"
+	"web-ui/internal/logging"
 	"web-ui/internal/server"
 	"web-ui/internal/services"
 )
@@ -17,32 +17,84 @@ import (
 var content embed.FS
 
 func main() {
-	// Load environment variables from .env file
-	loadEnvFile()
+	cfg, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if err := logging.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.File != "" {
+		slog.Info("Loaded configuration", "path", cfg.File)
+	}
+	slog.Info("Resolved paths", "repo_root", cfg.RepoRoot, "data_dir", cfg.DataDir)
+
+	hintPolicy, err := services.ParseHintPolicy(cfg.Features.HintPolicy)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Initialize services
-	challengeService := services.NewChallengeService()
-	scoreboardService := services.NewScoreboardService()
-	userService := services.NewUserService()
-	executionService := services.NewExecutionService()
-	packageService := services.NewPackageService()
-	aiService := services.NewAIService()
+	challengeService := services.NewChallengeService(cfg.RepoRoot)
+	scoreboardService := services.NewScoreboardService(cfg.RepoRoot)
+	userService := services.NewUserService(cfg.RepoRoot)
+	executionService := services.NewExecutionService(cfg.RepoRoot, services.ExecutionLimits{
+		TestTimeout:       cfg.Execution.TestTimeout,
+		MaxConcurrentRuns: cfg.Execution.MaxConcurrentRuns,
+	})
+	packageService := services.NewPackageService(cfg.RepoRoot)
+	githubMetadata := services.NewGitHubMetadataService(cfg.DataDir, cfg.Packages.MetadataRefreshInterval)
+	packageService.SetGitHubMetadata(githubMetadata)
+	aiUsageService := services.NewAIUsageService(cfg.DataDir, services.AIQuota{
+		UserTokens:   cfg.AI.DailyUserTokens,
+		GlobalTokens: cfg.AI.DailyGlobalTokens,
+	})
+	promptService := services.NewPromptService(cfg.AI.PromptsDir)
+	aiService := services.NewAIService(cfg.AI.Provider, cfg.AI.Model, cfg.AI.BaseURL, executionService, aiUsageService, promptService)
+	authService, err := services.NewAuthService(cfg.RepoRoot)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Server.TLSCertFile != "" {
+		authService.RequireSecureCookies()
+	}
+	tokenService := services.NewTokenService(cfg.DataDir)
+	gitService := services.NewGitService(cfg.Features.GitIntegration)
+	interviewService := services.NewInterviewService(aiService, challengeService, cfg.DataDir)
+	hintService := services.NewHintService(aiService, cfg.DataDir, hintPolicy)
+	searchService := services.NewSearchService(challengeService, packageService)
+	webhookService := services.NewWebhookService()
+	events := services.NewEventBus()
+	sponsorService := services.NewSponsorService(cfg.DataDir, cfg.Sponsors.RefreshInterval, sponsorProviders(cfg.Sponsors)...)
 
 	// Load data
-	log.Println("Loading challenges...")
 	if err := challengeService.LoadChallenges(); err != nil {
-		log.Fatalf("Failed to load challenges: %v", err)
+		fatal("Failed to load challenges", err)
 	}
-
-	log.Println("Loading scoreboards...")
 	if err := scoreboardService.LoadScoreboards(challengeService.GetChallenges()); err != nil {
-		log.Fatalf("Failed to load scoreboards: %v", err)
+		fatal("Failed to load scoreboards", err)
 	}
-
-	log.Println("Loading packages...")
 	if err := packageService.LoadPackages(); err != nil {
-		log.Fatalf("Failed to load packages: %v", err)
+		fatal("Failed to load packages", err)
 	}
+	searchService.Rebuild()
+
+	// Refresh package stars, releases and commits in the background; cards show the last known values
+	githubMetadata.Start(packageService.GitHubRepos, packageService.ApplyGitHubMetadata)
+
+	// Pull and reload on pushes, judge pull requests
+	repoSync := services.NewRepoSyncService(cfg.RepoRoot, cfg.DataDir, challengeService, scoreboardService, packageService, searchService, executionService)
+	repoSync.Subscribe(events)
+
+	// Save AI usage in the background rather than on every request
+	aiUsageService.Start()
+
+	// Keep the sponsor list fresh in the background; leaderboards use the last known list
+	sponsorService.Subscribe(events)
+	sponsorService.Start()
 
 	// Initialize server
 	srv := server.NewServer(
@@ -53,66 +105,59 @@ func main() {
 		executionService,
 		packageService,
 		aiService,
+		authService,
+		tokenService,
+		gitService,
+		interviewService,
+		hintService,
+		searchService,
+		sponsorService,
+		webhookService,
+		events,
 	)
 
 	// Setup routes
-	mux := srv.SetupRoutes()
-
-	// Start server
-	port := 8080
-	log.Printf("Server starting on http://localhost:%d", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
-}
-
-// loadEnvFile loads environment variables from a .env file
-func loadEnvFile() {
-	// Try to load .env from current directory and parent directories
-	files := []string{".env", "../.env", "../../.env"}
-
-	for _, file := range files {
-		if loadEnvFromFile(file) {
-			log.Printf("Loaded environment variables from %s", file)
-			break
-		}
+	handler := srv.SetupRoutes(server.SecurityOptions{
+		UserRateLimit: cfg.Security.UserRateLimit,
+		IPRateLimit:   cfg.Security.IPRateLimit,
+		TrustProxy:    cfg.Security.TrustProxy,
+		ProxyHops:     cfg.Security.ProxyHops,
+	})
+
+	// Start server; returns after a graceful shutdown on SIGINT or SIGTERM
+	err = srv.ListenAndServe(handler, server.ListenOptions{
+		Addr:            cfg.Addr,
+		ReadTimeout:     cfg.Server.ReadTimeout,
+		WriteTimeout:    cfg.Server.WriteTimeout,
+		APIWriteTimeout: cfg.Server.APIWriteTimeout,
+		IdleTimeout:     cfg.Server.IdleTimeout,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+		MaxBodyBytes:    cfg.Server.MaxBodyBytes,
+		TLSCertFile:     cfg.Server.TLSCertFile,
+		TLSKeyFile:      cfg.Server.TLSKeyFile,
+	})
+	if err := aiUsageService.Flush(); err != nil {
+		slog.Warn("Could not save AI usage", "error", err)
+	}
+	if err != nil {
+		fatal("Server failed", err)
 	}
 }
 
-func loadEnvFromFile(filename string) bool {
-	file, err := os.Open(filename)
-	if err != nil {
-		return false
+// sponsorProviders returns the configured sponsor sources, most reliable first: the
+// file when set, then the GraphQL API, then scraping the sponsors page as a fallback
+func sponsorProviders(sponsors config.SponsorConfig) []services.SponsorProvider {
+	if sponsors.File != "" {
+		return []services.SponsorProvider{&services.FileSponsorProvider{Path: sponsors.File}}
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		// Remove quotes if present
-		if (strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"")) ||
-			(strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'")) {
-			value = value[1 : len(value)-1]
-		}
-
-		// Set environment variable if not already set
-		if os.Getenv(key) == "" {
-			os.Setenv(key, value)
-		}
+	return []services.SponsorProvider{
+		services.NewGraphQLSponsorProvider(sponsors.Account),
+		services.NewScraperSponsorProvider(sponsors.Account),
 	}
+}
 
-	return true
+// fatal logs err and exits
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,26 @@
+// Adds the session's CSRF token to same-origin requests that change state. The server
+// hands the token out in the csrf_token cookie and rejects such requests without it.
+(function () {
+    const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    const originalFetch = window.fetch.bind(window);
+    window.fetch = function (input, init) {
+        init = init || {};
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+        const token = csrfToken();
+        if (token && url.origin === window.location.origin && !SAFE_METHODS.includes(method)) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            if (!headers.has('X-CSRF-Token')) {
+                headers.set('X-CSRF-Token', token);
+            }
+            init = Object.assign({}, init, { headers: headers });
+        }
+        return originalFetch(input, init);
+    };
+})();
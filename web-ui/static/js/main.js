@@ -362,7 +362,8 @@ class LearningHighlighter {
 
 // Initialize syntax highlighting for code blocks
 function initSyntaxHighlighting() {
-    document.querySelectorAll('pre code').forEach((el) => {
+    // Go code rendered on the server is highlighted already
+    document.querySelectorAll('pre code:not(.hljs)').forEach((el) => {
         // Fix for Go language blocks
         if (el.className === 'language-go') {
             el.className = 'language-golang'; // Convert 'go' to 'golang' for better highlighting
@@ -547,7 +548,7 @@ document.addEventListener('DOMContentLoaded', function() {
         // Otherwise just ensure proper syntax highlighting for existing content
         else {
             // Make sure Go code blocks have the right class for syntax highlighting
-            el.querySelectorAll('pre code.language-go').forEach(function(codeEl) {
+            el.querySelectorAll('pre code.language-go:not(.hljs)').forEach(function(codeEl) {
                 codeEl.className = 'language-golang';
                 hljs.highlightElement(codeEl);
             });
@@ -581,100 +582,115 @@ function initLearningMaterials(containerId, challengeId) {
     }
 }
 
-// Initialize hints system
-function initializeHints(hintsText) {
-    if (!hintsText) return;
-    
-    try {
-        const hints = JSON.parse(hintsText);
-        const container = document.getElementById('hints-container');
-        const showHintBtn = document.getElementById('show-hint-btn');
-        const resetHintsBtn = document.getElementById('reset-hints-btn');
-        const progressSpan = document.getElementById('hints-progress');
-        const totalHintsSpan = document.getElementById('total-hints');
-        
-        if (!container || !showHintBtn) return;
-        
-        let currentHintIndex = 0;
-        const storageKey = `hints_progress_${window.location.pathname.split('/').pop()}`;
-        
-        // Load saved progress
-        const savedProgress = localStorage.getItem(storageKey);
-        if (savedProgress) {
-            currentHintIndex = parseInt(savedProgress);
+// Initialize the hint ladder: the challenge's authored hints are revealed one at a time,
+// then AI hints for the code in the editor. What has been revealed is kept on the server.
+function initializeHints(challengeRef, getCode, interviewId) {
+    const container = document.getElementById('hints-container');
+    const showHintBtn = document.getElementById('show-hint-btn');
+    const progressSpan = document.getElementById('hints-progress');
+    const totalHintsSpan = document.getElementById('total-hints');
+    const statusSpan = document.getElementById('hints-status');
+
+    if (!container || !showHintBtn) return;
+
+    const params = new URLSearchParams(challengeRef);
+    if (interviewId) {
+        params.set('interviewId', interviewId);
+    }
+    let nextSource = '';
+
+    function showHint(hint) {
+        const hintDiv = document.createElement('div');
+        hintDiv.className = hint.source === 'ai' ? 'hint-item alert alert-primary mb-3' : 'hint-item alert alert-info mb-3';
+        hintDiv.style.animation = 'slideIn 0.3s ease-in-out';
+
+        const label = hint.source === 'ai' ? `AI Hint (level ${hint.aiLevel}/4)` : `Hint ${hint.step}`;
+        const penalty = hint.penalty ? `<span class="badge bg-danger ms-2">-${hint.penalty} pts</span>` : '';
+        const title = hint.title ? `<strong>${escapeHtml(hint.title)}</strong>` : '';
+        hintDiv.innerHTML = `
+            <div class="d-flex align-items-center mb-2">
+                <span class="badge bg-warning text-dark me-2">${label}</span>${title}${penalty}
+            </div>
+            <div class="hint-content markdown-content"></div>
+        `;
+        const content = hintDiv.querySelector('.hint-content');
+        if (hint.html) {
+            // Rendered, sanitized and highlighted on the server
+            content.innerHTML = hint.html;
+            content.querySelectorAll('pre code:not(.hljs)').forEach((el) => hljs.highlightElement(el));
+        } else {
+            renderMarkdown(hint.content, content);
         }
-        
-        // Update total hints count
-        totalHintsSpan.textContent = hints.length;
-        
-        // Show hints up to current index
-        for (let i = 0; i < currentHintIndex; i++) {
-            if (hints[i]) {
-                this.showHint(hints[i], i + 1);
-            }
+        container.appendChild(hintDiv);
+        return hintDiv;
+    }
+
+    function updateProgress(progress) {
+        const aiUsed = progress.revealed.filter(hint => hint.source === 'ai').length;
+        progressSpan.textContent = progress.revealed.length;
+        totalHintsSpan.textContent = progress.authoredTotal + aiUsed + progress.aiRemaining;
+        nextSource = progress.next;
+
+        showHintBtn.classList.toggle('d-none', !nextSource);
+        showHintBtn.innerHTML = nextSource === 'ai'
+            ? '<i class="bi bi-robot me-2"></i>Get an AI Hint for My Code'
+            : '<i class="bi bi-lightbulb me-2"></i>Show Next Hint';
+
+        if (!statusSpan) return;
+        if (progress.policy.mode === 'disabled') {
+            statusSpan.textContent = 'Hints are disabled here.';
+        } else if (!nextSource) {
+            statusSpan.textContent = progress.revealed.length ? 'All hints revealed.' : 'No hints are available for this challenge.';
+        } else if (progress.policy.mode === 'penalized') {
+            statusSpan.textContent = `Each hint costs ${progress.policy.penalty} points (${progress.penalty} so far).`;
+        } else {
+            statusSpan.textContent = '';
         }
-        
-        // Update progress display
-        this.updateHintsProgress();
-        
-        // Show hint button click handler
-        showHintBtn.addEventListener('click', () => {
-            if (currentHintIndex < hints.length) {
-                this.showHint(hints[currentHintIndex], currentHintIndex + 1);
-                currentHintIndex++;
-                localStorage.setItem(storageKey, currentHintIndex.toString());
-                this.updateHintsProgress();
-                
-                if (currentHintIndex >= hints.length) {
-                    showHintBtn.classList.add('d-none');
-                    resetHintsBtn.classList.remove('d-none');
+    }
+
+    fetch('/api/hints?' + params.toString())
+        .then(response => response.ok ? response.json() : null)
+        .then(progress => {
+            if (!progress) return;
+            progress.revealed.forEach(showHint);
+            updateProgress(progress);
+        })
+        .catch(error => console.error('Error loading hints:', error));
+
+    showHintBtn.addEventListener('click', async () => {
+        const originalHtml = showHintBtn.innerHTML;
+        showHintBtn.disabled = true;
+        if (nextSource === 'ai') {
+            showHintBtn.innerHTML = '<span class="spinner-border spinner-border-sm me-2"></span>Looking at your code...';
+        }
+
+        try {
+            const response = await fetch('/api/hints/next', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ ...challengeRef, code: getCode(), interviewId: interviewId || '' })
+            });
+            if (!response.ok) {
+                let message = await response.text();
+                try {
+                    const data = JSON.parse(message);
+                    message = (data.error && data.error.message) || message;
+                } catch (e) {
+                    // Plain-text error
                 }
+                showHintBtn.innerHTML = originalHtml;
+                if (statusSpan) statusSpan.textContent = message;
+                return;
             }
-        });
-        
-        // Reset hints button click handler
-        resetHintsBtn.addEventListener('click', () => {
-            if (confirm('Are you sure you want to reset all hints?')) {
-                container.innerHTML = '';
-                currentHintIndex = 0;
-                localStorage.removeItem(storageKey);
-                this.updateHintsProgress();
-                showHintBtn.classList.remove('d-none');
-                resetHintsBtn.classList.add('d-none');
-            }
-        });
-        
-        // Helper function to show a hint
-        this.showHint = function(hint, hintNumber) {
-            const hintDiv = document.createElement('div');
-            hintDiv.className = 'hint-item alert alert-warning';
-            hintDiv.innerHTML = `
-                <div class="d-flex justify-content-between align-items-start">
-                    <div class="flex-grow-1">
-                        <h6 class="alert-heading">
-                            <i class="bi bi-lightbulb me-2"></i>Hint ${hintNumber}
-                        </h6>
-                        <div class="markdown-content">${marked.parse(hint)}</div>
-                    </div>
-                    <span class="badge bg-warning text-dark ms-2">${hintNumber}</span>
-                </div>
-            `;
-            container.appendChild(hintDiv);
-            
-            // Apply syntax highlighting to code blocks in the hint
-            setTimeout(() => {
-                hintDiv.querySelectorAll('pre code').forEach((el) => {
-                    hljs.highlightElement(el);
-                });
-            }, 100);
-        };
-        
-        // Helper function to update progress display
-        this.updateHintsProgress = function() {
-            progressSpan.textContent = currentHintIndex;
-        };
-        
-    } catch (error) {
-        console.error('Error initializing hints:', error);
-    }
-} 
\ No newline at end of file
+
+            const data = await response.json();
+            showHint(data.hint).scrollIntoView({ behavior: 'smooth', block: 'nearest' });
+            updateProgress(data.progress);
+        } catch (error) {
+            showHintBtn.innerHTML = originalHtml;
+            if (statusSpan) statusSpan.textContent = 'Could not load the next hint: ' + error.message;
+        } finally {
+            showHintBtn.disabled = false;
+        }
+    });
+}